@@ -0,0 +1,313 @@
+// Package aggregate is the API implementation composing
+// multiple runtimes into one, for example the docker daemon and
+// the "k8s.io" namespace of containerd on the same node.
+//
+// The IDs of images and containers are namespaced by the name
+// of the runtime they come from, in the form of "name/id", so
+// that they are routed to the right runtime. The images and
+// containers opened are those of the underlying runtimes.
+package aggregate
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+	"golang.org/x/xerrors"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// Separator is the separator between the name of runtime and
+// the ID inside the runtime.
+const Separator = "/"
+
+// NewOption is the option that can be used for initializing an
+// aggregate.Runtime object.
+type NewOption func(*Runtime)
+
+// WithRuntime adds a runtime with the name, which must be
+// unique and free of separators. The runtime added is closed
+// when the aggregated runtime is closed.
+func WithRuntime(name string, runtime api.Runtime) NewOption {
+	return func(r *Runtime) {
+		r.names = append(r.names, name)
+		r.runtimes = append(r.runtimes, runtime)
+	}
+}
+
+// WithoutDedup disables the de-duplication of images, which
+// is enabled by default.
+func WithoutDedup() NewOption {
+	return func(r *Runtime) {
+		r.nodedup = true
+	}
+}
+
+// Runtime is the runtime composed of other runtimes.
+//
+// The images sharing the same config are considered the same
+// content, and only the one in the runtime added first is
+// listed, unless the de-duplication is disabled. The config is
+// compared by the digest of its OCISpecV1, since the IDs of
+// the same image differ among runtimes. The images whose
+// configs have no layers, like root file systems, or fail to
+// be read are never de-duplicated.
+type Runtime struct {
+	names    []string
+	runtimes []api.Runtime
+	nodedup  bool
+
+	mu   sync.Mutex
+	keys map[string]string
+}
+
+func New(opts ...NewOption) (api.Runtime, error) {
+	r := &Runtime{}
+	for _, opt := range opts {
+		opt(r)
+	}
+	seen := make(map[string]struct{})
+	for _, name := range r.names {
+		if name == "" || strings.Contains(name, Separator) {
+			return nil, xerrors.Errorf("aggregate: invalid name %q", name)
+		}
+		if _, ok := seen[name]; ok {
+			return nil, xerrors.Errorf("aggregate: duplicate name %q", name)
+		}
+		seen[name] = struct{}{}
+	}
+	return r, nil
+}
+
+// Names returns the names of the runtimes in order.
+func (r *Runtime) Names() []string {
+	return append([]string(nil), r.names...)
+}
+
+// Runtimes returns the runtimes in order.
+func (r *Runtime) Runtimes() []api.Runtime {
+	return append([]api.Runtime(nil), r.runtimes...)
+}
+
+// Runtime returns the runtime of the name.
+func (r *Runtime) Runtime(name string) (api.Runtime, bool) {
+	for i, n := range r.names {
+		if n == name {
+			return r.runtimes[i], true
+		}
+	}
+	return nil, false
+}
+
+// Join namespaces the ID in the runtime of the name.
+func Join(name, id string) string {
+	return name + Separator + id
+}
+
+// split the namespaced ID into the index of the runtime and
+// the ID inside the runtime.
+func (r *Runtime) split(id string) (int, string, error) {
+	i := strings.Index(id, Separator)
+	if i < 0 {
+		return -1, "", xerrors.Errorf("aggregate: invalid ID %q", id)
+	}
+	for index, name := range r.names {
+		if name == id[:i] {
+			return index, id[i+len(Separator):], nil
+		}
+	}
+	return -1, "", xerrors.Errorf(
+		"aggregate: unknown runtime %q", id[:i])
+}
+
+// Resolve returns the runtime and the ID inside the runtime
+// of the namespaced ID.
+func (r *Runtime) Resolve(id string) (api.Runtime, string, error) {
+	index, id, err := r.split(id)
+	if err != nil {
+		return nil, "", err
+	}
+	return r.runtimes[index], id, nil
+}
+
+// imageKey returns the key de-duplicating the image, which is
+// the digest of its OCISpecV1, or the namespaced ID when the
+// config has no layers or fails to be read. The keys are
+// cached since the config of an image never changes.
+func (r *Runtime) imageKey(index int, id string) string {
+	name := Join(r.names[index], id)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if key, ok := r.keys[name]; ok {
+		return key
+	}
+	key := name
+	image, err := r.runtimes[index].OpenImageByID(id)
+	if err == nil {
+		config, err := image.OCISpecV1()
+		if err == nil && len(config.RootFS.DiffIDs) > 0 {
+			if data, err := json.Marshal(config); err == nil {
+				key = digest.FromBytes(data).String()
+			}
+		}
+		_ = image.Close()
+	}
+	if r.keys == nil {
+		r.keys = make(map[string]string)
+	}
+	r.keys[name] = key
+	return key
+}
+
+// collect the IDs from each runtime and namespace them, where
+// the duplicated IDs are removed by their keys when dedup is
+// specified.
+func (r *Runtime) collect(
+	dedup func(int, string) string,
+	f func(int, api.Runtime) ([]string, error),
+) ([]string, error) {
+	var result []string
+	seen := make(map[string]struct{})
+	for i, runtime := range r.runtimes {
+		ids, err := f(i, runtime)
+		if err != nil {
+			return nil, xerrors.Errorf("%s: %w", r.names[i], err)
+		}
+		for _, id := range ids {
+			key := Join(r.names[i], id)
+			if dedup != nil {
+				key = dedup(i, id)
+			}
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			result = append(result, Join(r.names[i], id))
+		}
+	}
+	return result, nil
+}
+
+// find matches the IDs in each runtime, the pattern in the
+// form of namespaced ID is also matched in its runtime.
+func (r *Runtime) find(
+	pattern string, f func(api.Runtime, string) ([]string, error),
+) ([]string, error) {
+	name, rest := "", ""
+	if i := strings.Index(pattern, Separator); i >= 0 {
+		name, rest = pattern[:i], pattern[i+len(Separator):]
+	}
+	return r.collect(nil, func(i int, runtime api.Runtime) ([]string, error) {
+		ids, err := f(runtime, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if r.names[i] == name && rest != "" {
+			named, err := f(runtime, rest)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, named...)
+		}
+		return ids, nil
+	})
+}
+
+func (r *Runtime) ListImageIDs() ([]string, error) {
+	var dedup func(int, string) string
+	if !r.nodedup {
+		dedup = r.imageKey
+	}
+	return r.collect(dedup, func(
+		_ int, runtime api.Runtime,
+	) ([]string, error) {
+		return runtime.ListImageIDs()
+	})
+}
+
+// FindImageIDs matches the images in each runtime, the images
+// matched are attributed to the runtime listing them first.
+func (r *Runtime) FindImageIDs(pattern string) ([]string, error) {
+	ids, err := r.find(pattern, func(
+		runtime api.Runtime, pattern string,
+	) ([]string, error) {
+		return runtime.FindImageIDs(pattern)
+	})
+	if err != nil || r.nodedup || len(ids) == 0 {
+		return ids, err
+	}
+	listed, err := r.ListImageIDs()
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[string]string)
+	for _, id := range listed {
+		index, rest, err := r.split(id)
+		if err != nil {
+			return nil, err
+		}
+		owners[r.imageKey(index, rest)] = id
+	}
+	var result []string
+	seen := make(map[string]struct{})
+	for _, id := range ids {
+		index, rest, err := r.split(id)
+		if err != nil {
+			return nil, err
+		}
+		if owner, ok := owners[r.imageKey(index, rest)]; ok {
+			id = owner
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		result = append(result, id)
+	}
+	return result, nil
+}
+
+func (r *Runtime) OpenImageByID(id string) (api.Image, error) {
+	runtime, id, err := r.Resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	return runtime.OpenImageByID(id)
+}
+
+func (r *Runtime) ListContainerIDs() ([]string, error) {
+	return r.collect(nil, func(
+		_ int, runtime api.Runtime,
+	) ([]string, error) {
+		return runtime.ListContainerIDs()
+	})
+}
+
+func (r *Runtime) FindContainerIDs(pattern string) ([]string, error) {
+	return r.find(pattern, func(
+		runtime api.Runtime, pattern string,
+	) ([]string, error) {
+		return runtime.FindContainerIDs(pattern)
+	})
+}
+
+func (r *Runtime) OpenContainerByID(id string) (api.Container, error) {
+	runtime, id, err := r.Resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	return runtime.OpenContainerByID(id)
+}
+
+// Close all runtimes, the first error is returned.
+func (r *Runtime) Close() error {
+	var result error
+	for i, runtime := range r.runtimes {
+		if err := runtime.Close(); err != nil && result == nil {
+			result = xerrors.Errorf("%s: %w", r.names[i], err)
+		}
+	}
+	return result
+}
@@ -0,0 +1,310 @@
+package aggregate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+type testImage struct {
+	api.Image
+	id     string
+	config *imageV1.Image
+}
+
+func (i *testImage) ID() string {
+	return i.id
+}
+
+func (i *testImage) OCISpecV1() (*imageV1.Image, error) {
+	if i.config == nil {
+		return nil, errors.New("no config")
+	}
+	return i.config, nil
+}
+
+func (i *testImage) Close() error {
+	return nil
+}
+
+type testContainer struct {
+	api.Container
+	id string
+}
+
+func (c *testContainer) ID() string {
+	return c.id
+}
+
+// testRuntime lists the images in order, whose IDs are matched
+// by prefix, and records the images opened.
+type testRuntime struct {
+	api.Runtime
+	images     []*testImage
+	containers []string
+	err        error
+	closeErr   error
+	closed     bool
+	opens      int
+}
+
+func (r *testRuntime) ListImageIDs() ([]string, error) {
+	return r.FindImageIDs("")
+}
+
+func (r *testRuntime) FindImageIDs(pattern string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	var result []string
+	for _, image := range r.images {
+		if strings.HasPrefix(image.id, pattern) {
+			result = append(result, image.id)
+		}
+	}
+	return result, nil
+}
+
+func (r *testRuntime) OpenImageByID(id string) (api.Image, error) {
+	r.opens++
+	for _, image := range r.images {
+		if image.id == id {
+			return image, nil
+		}
+	}
+	return nil, errors.New("image not found")
+}
+
+func (r *testRuntime) ListContainerIDs() ([]string, error) {
+	return r.FindContainerIDs("")
+}
+
+func (r *testRuntime) FindContainerIDs(pattern string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	var result []string
+	for _, id := range r.containers {
+		if strings.HasPrefix(id, pattern) {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+func (r *testRuntime) OpenContainerByID(id string) (api.Container, error) {
+	for _, c := range r.containers {
+		if c == id {
+			return &testContainer{id: id}, nil
+		}
+	}
+	return nil, errors.New("container not found")
+}
+
+func (r *testRuntime) Close() error {
+	r.closed = true
+	return r.closeErr
+}
+
+// testConfig is the config of an image with the layers.
+func testConfig(diffIDs ...digest.Digest) *imageV1.Image {
+	return &imageV1.Image{RootFS: imageV1.RootFS{
+		Type: "layers", DiffIDs: diffIDs,
+	}}
+}
+
+// newTestRuntime aggregates "docker" and "containerd", where
+// "docker/a" and "containerd/a2" share the same config, and the
+// images "r" and "r2" share the config without layers.
+func newTestRuntime(t *testing.T, opts ...NewOption) (*Runtime, []*testRuntime) {
+	docker := &testRuntime{
+		images: []*testImage{
+			{id: "a", config: testConfig("sha256:aa")},
+			{id: "b", config: testConfig("sha256:bb")},
+			{id: "r", config: testConfig()},
+		},
+		containers: []string{"c1", "c2"},
+	}
+	containerd := &testRuntime{
+		images: []*testImage{
+			{id: "a2", config: testConfig("sha256:aa")},
+			{id: "c", config: testConfig("sha256:aa", "sha256:cc")},
+			{id: "r2", config: testConfig()},
+			{id: "e"},
+		},
+		containers: []string{"c1"},
+	}
+	runtime, err := New(append([]NewOption{
+		WithRuntime("docker", docker),
+		WithRuntime("containerd", containerd),
+	}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runtime.(*Runtime), []*testRuntime{docker, containerd}
+}
+
+func TestNew(t *testing.T) {
+	for _, c := range []struct {
+		names []string
+		err   bool
+	}{
+		{names: []string{"docker", "containerd"}},
+		{names: nil},
+		{names: []string{""}, err: true},
+		{names: []string{"k8s/io"}, err: true},
+		{names: []string{"docker", "docker"}, err: true},
+	} {
+		var opts []NewOption
+		for _, name := range c.names {
+			opts = append(opts, WithRuntime(name, &testRuntime{}))
+		}
+		if _, err := New(opts...); (err != nil) != c.err {
+			t.Errorf("new %q: unexpected error %v", c.names, err)
+		}
+	}
+}
+
+func TestListImageIDs(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		opts []NewOption
+		want []string
+	}{{
+		name: "dedup",
+		want: []string{
+			"docker/a", "docker/b", "docker/r",
+			"containerd/c", "containerd/r2", "containerd/e",
+		},
+	}, {
+		name: "without dedup",
+		opts: []NewOption{WithoutDedup()},
+		want: []string{
+			"docker/a", "docker/b", "docker/r",
+			"containerd/a2", "containerd/c", "containerd/r2", "containerd/e",
+		},
+	}} {
+		t.Run(c.name, func(t *testing.T) {
+			runtime, runtimes := newTestRuntime(t, c.opts...)
+			for i := 0; i < 2; i++ {
+				ids, err := runtime.ListImageIDs()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(ids, c.want) {
+					t.Fatalf("unexpected images %q, want %q", ids, c.want)
+				}
+			}
+
+			// The keys are cached rather than opening the
+			// images again on each listing.
+			for _, r := range runtimes {
+				if r.opens > len(r.images) {
+					t.Fatalf("images opened %d times", r.opens)
+				}
+			}
+		})
+	}
+}
+
+func TestFindImageIDs(t *testing.T) {
+	for _, c := range []struct {
+		pattern string
+		opts    []NewOption
+		want    []string
+	}{
+		{pattern: "a", want: []string{"docker/a"}},
+		{pattern: "a", opts: []NewOption{WithoutDedup()},
+			want: []string{"docker/a", "containerd/a2"}},
+		{pattern: "containerd/a2", want: []string{"docker/a"}},
+		{pattern: "containerd/c", want: []string{"containerd/c"}},
+		{pattern: "docker/c"},
+		{pattern: "r", want: []string{"docker/r", "containerd/r2"}},
+		{pattern: "x"},
+	} {
+		runtime, _ := newTestRuntime(t, c.opts...)
+		ids, err := runtime.FindImageIDs(c.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ids, c.want) {
+			t.Errorf("find %q: got %q, want %q", c.pattern, ids, c.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	runtime, runtimes := newTestRuntime(t)
+	for _, c := range []struct {
+		id      string
+		runtime api.Runtime
+		rest    string
+		err     bool
+	}{
+		{id: "docker/a", runtime: runtimes[0], rest: "a"},
+		{id: "containerd/sha256/c", runtime: runtimes[1], rest: "sha256/c"},
+		{id: "a", err: true},
+		{id: "podman/a", err: true},
+	} {
+		result, rest, err := runtime.Resolve(c.id)
+		if (err != nil) != c.err || result != c.runtime || rest != c.rest {
+			t.Errorf("resolve %q: got %v, %q, %v", c.id, result, rest, err)
+		}
+	}
+
+	image, err := runtime.OpenImageByID("containerd/c")
+	if err != nil || image.ID() != "c" {
+		t.Fatalf("unexpected image %v: %v", image, err)
+	}
+	container, err := runtime.OpenContainerByID("containerd/c1")
+	if err != nil || container.ID() != "c1" {
+		t.Fatalf("unexpected container %v: %v", container, err)
+	}
+	if _, err := runtime.OpenImageByID("podman/a"); err == nil {
+		t.Fatal("unknown runtime resolved")
+	}
+}
+
+func TestContainerIDs(t *testing.T) {
+	runtime, runtimes := newTestRuntime(t)
+	ids, err := runtime.ListContainerIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"docker/c1", "docker/c2", "containerd/c1"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Fatalf("unexpected containers %q, want %q", ids, want)
+	}
+	if ids, err = runtime.FindContainerIDs("docker/c1"); err != nil ||
+		!reflect.DeepEqual(ids, []string{"docker/c1"}) {
+		t.Fatalf("unexpected containers %q: %v", ids, err)
+	}
+
+	failed := errors.New("failed")
+	runtimes[1].err = failed
+	if _, err := runtime.ListContainerIDs(); !errors.Is(err, failed) ||
+		!strings.HasPrefix(err.Error(), "containerd: ") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	runtime, runtimes := newTestRuntime(t)
+	failed := errors.New("failed")
+	runtimes[0].closeErr = failed
+	if err := runtime.Close(); !errors.Is(err, failed) ||
+		!strings.HasPrefix(err.Error(), "docker: ") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, r := range runtimes {
+		if !r.closed {
+			t.Fatal("runtime left open")
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/xerrors"
+
+	api "github.com/chaitin/libveinmind/go"
+	"github.com/chaitin/libveinmind/go/pkg/discovery"
+	"github.com/chaitin/libveinmind/go/plugin/log"
+)
+
+// cognitiveOpeners are the functions opening the runtimes
+// recognized on the host, which are registered only when cgo
+// is enabled. A runtime might be opened as multiple ones, like
+// containerd opened once for each namespace.
+//
+// CRI-O and podman are recognized but not opened, since there's
+// no implementation on their containers/storage yet, and they
+// are reported as skipped.
+var cognitiveOpeners = make(map[discovery.Kind]func(
+	discovery.Runtime,
+) ([]api.Runtime, error))
+
+// ErrUnsupportedRuntime is the error of the runtimes skipped
+// since there's no implementation on them.
+var ErrUnsupportedRuntime = xerrors.New("unsupported runtime")
+
+// SkippedRuntime is a runtime discovered on the host but not
+// opened, with the error why it is skipped.
+type SkippedRuntime struct {
+	discovery.Runtime
+	Err error
+}
+
+func (s SkippedRuntime) Error() string {
+	return fmt.Sprintf("%s at %q: %v", s.Kind, s.RootDir, s.Err)
+}
+
+func (s SkippedRuntime) Unwrap() error {
+	return s.Err
+}
+
+// CognitiveRuntimes is the root object of the "cognitive" mode,
+// which is the runtimes opened along with the ones skipped, so
+// that the skipped ones could be reported with the result.
+type CognitiveRuntimes struct {
+	Runtimes []api.Runtime
+	Skipped  []SkippedRuntime
+}
+
+// cognitiveMode discovers the container runtimes on the host
+// and passes them as *CognitiveRuntimes to the handler.
+//
+// The runtimes unsupported or failed to open are skipped with
+// warnings, and it fails only when none of them can be opened.
+type cognitiveMode struct {
+}
+
+func (cognitiveMode) Name() string {
+	return "cognitive"
+}
+
+func (cognitiveMode) AddFlags(_ *pflag.FlagSet) {
+}
+
+func (cognitiveMode) Invoke(c *Command, args []string, m ModeHandler) error {
+	result := &CognitiveRuntimes{}
+	defer func() {
+		for _, r := range result.Runtimes {
+			_ = r.Close()
+		}
+	}()
+	for _, discovered := range discovery.Discover() {
+		open, ok := cognitiveOpeners[discovered.Kind]
+		if !ok {
+			skipped := SkippedRuntime{
+				Runtime: discovered, Err: ErrUnsupportedRuntime}
+			log.Warnf("skip runtime: %v", skipped)
+			result.Skipped = append(result.Skipped, skipped)
+			continue
+		}
+		runtimes, err := open(discovered)
+		if err != nil {
+			skipped := SkippedRuntime{Runtime: discovered, Err: err}
+			log.Warnf("skip runtime failed to open: %v", skipped)
+			result.Skipped = append(result.Skipped, skipped)
+			continue
+		}
+		result.Runtimes = append(result.Runtimes, runtimes...)
+	}
+	if len(result.Runtimes) == 0 {
+		if len(result.Skipped) > 0 {
+			return result.Skipped[0]
+		}
+		return xerrors.New("no container runtime discovered")
+	}
+	return m(c, args, result)
+}
+
+// rootRuntimes returns the runtimes of the root object, which
+// is a single runtime in most modes and a list of runtimes in
+// the cognitive mode.
+func rootRuntimes(root interface{}) ([]api.Runtime, bool) {
+	switch r := root.(type) {
+	case api.Runtime:
+		return []api.Runtime{r}, true
+	case []api.Runtime:
+		return r, true
+	case *CognitiveRuntimes:
+		return r.Runtimes, true
+	default:
+		return nil, false
+	}
+}
+
+// matchIDs collects the IDs of objects in the runtime that
+// matches the arguments, by listing all of them or finding by
+// each argument. When there're multiple runtimes, the exact IDs
+// not in this runtime are filtered out.
+func matchIDs(
+	args []string, exact, multiple bool,
+	list func() ([]string, error), find func(string) ([]string, error),
+) ([]string, error) {
+	if len(args) == 0 {
+		return list()
+	}
+	if exact && !multiple {
+		return append([]string(nil), args...), nil
+	}
+	if exact {
+		ids, err := list()
+		if err != nil {
+			return nil, err
+		}
+		owned := make(map[string]struct{})
+		for _, id := range ids {
+			owned[id] = struct{}{}
+		}
+		var result []string
+		for _, arg := range args {
+			if _, ok := owned[arg]; ok {
+				result = append(result, arg)
+			}
+		}
+		return result, nil
+	}
+	var result []string
+	for _, arg := range args {
+		ids, err := find(arg)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ids...)
+	}
+	return result, nil
+}
+
+func init() {
+	RegisterMode(&cognitiveMode{})
+}
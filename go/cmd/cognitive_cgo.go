@@ -0,0 +1,65 @@
+//go:build cgo
+// +build cgo
+
+package cmd
+
+import (
+	api "github.com/chaitin/libveinmind/go"
+	"github.com/chaitin/libveinmind/go/containerd"
+	"github.com/chaitin/libveinmind/go/docker"
+	"github.com/chaitin/libveinmind/go/pkg/discovery"
+)
+
+// cognitiveNamespaces are the namespaces of containerd opened
+// when the namespaces fail to be listed, where "k8s.io" is the
+// one of CRI.
+var cognitiveNamespaces = []string{"default", "k8s.io"}
+
+func init() {
+	cognitiveOpeners[discovery.Docker] = func(
+		r discovery.Runtime,
+	) ([]api.Runtime, error) {
+		opts := []docker.NewOption{docker.WithDataRootDir(r.RootDir)}
+		if r.ConfigPath != "" {
+			opts = append(opts, docker.WithConfigPath(r.ConfigPath))
+		}
+		runtime, err := docker.New(opts...)
+		if err != nil {
+			return nil, err
+		}
+		return []api.Runtime{runtime}, nil
+	}
+	cognitiveOpeners[discovery.Containerd] = openContainerdNamespaces
+}
+
+// openContainerdNamespaces opens the containerd once for each
+// namespace, so that the images and containers of CRI in the
+// "k8s.io" namespace are scanned along with the other ones.
+func openContainerdNamespaces(r discovery.Runtime) ([]api.Runtime, error) {
+	opts := []containerd.NewOption{containerd.WithRootDir(r.RootDir)}
+	if r.ConfigPath != "" {
+		opts = append(opts, containerd.WithConfigPath(r.ConfigPath))
+	}
+	runtime, err := containerd.New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := runtime.(*containerd.Containerd).ListNamespaces()
+	_ = runtime.Close()
+	if err != nil || len(namespaces) == 0 {
+		namespaces = cognitiveNamespaces
+	}
+	var result []api.Runtime
+	for _, namespace := range namespaces {
+		runtime, err := containerd.New(append(opts,
+			containerd.WithNamespace(namespace))...)
+		if err != nil {
+			for _, r := range result {
+				_ = r.Close()
+			}
+			return nil, err
+		}
+		result = append(result, runtime)
+	}
+	return result, nil
+}
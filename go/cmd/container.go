@@ -16,7 +16,7 @@ func ScanAllContainers(
 	if err != nil {
 		return err
 	}
-	return Scan(ctx, iter, runtime, opts...)
+	return scanAllIDs(ctx, iter, runtime, api.Runtime.ListContainerIDs, opts...)
 }
 
 // ScanContainers scans container provided by container list.
@@ -67,35 +67,30 @@ type ContainerIDsHandler func(*Command, api.Runtime, []string) error
 // The command will attempt to initialize the runtime object
 // from specified mode with flags, scan and match containers in
 // the runtime, and collect those qualified container IDs.
+//
+// When multiple runtimes are provided by the mode, like the
+// "cognitive" mode, the function is invoked for each runtime.
 func (idx *Index) MapContainerIDsCommand(
 	c *Command, f ContainerIDsHandler,
 ) *Command {
 	c = idx.MapModeCommand(c, "container", struct{}{}, func(
 		c *Command, args []string, root interface{},
 	) error {
-		r, ok := root.(api.Runtime)
+		runtimes, ok := rootRuntimes(root)
 		if !ok {
 			return IncompatibleMode()
 		}
-		var containerIDs []string
-		if len(args) == 0 {
-			ids, err := r.ListContainerIDs()
+		for _, r := range runtimes {
+			containerIDs, err := matchIDs(args, containerExactIDs, len(runtimes) > 1,
+				r.ListContainerIDs, r.FindContainerIDs)
 			if err != nil {
 				return err
 			}
-			containerIDs = append(containerIDs, ids...)
-		} else if containerExactIDs {
-			containerIDs = append(containerIDs, args...)
-		} else {
-			for _, arg := range args {
-				ids, err := r.FindContainerIDs(arg)
-				if err != nil {
-					return err
-				}
-				containerIDs = append(containerIDs, ids...)
+			if err := f(c, r, containerIDs); err != nil {
+				return err
 			}
 		}
-		return f(c, r, containerIDs)
+		return nil
 	})
 	flags := c.PersistentFlags()
 	flags.BoolVar(&containerExactIDs, "id", false,
@@ -1,3 +1,6 @@
+//go:build cgo
+// +build cgo
+
 package cmd
 
 import (
@@ -47,6 +50,12 @@ func (containerdMode) AddFlags(fset *pflag.FlagSet) {
 		return nil
 	}, "containerd-root",
 		`flag "--root" specified to the containerd command`)
+	pflagext.StringVarF(fset, func(namespace string) error {
+		containerdFlags = append(containerdFlags,
+			containerd.WithNamespace(namespace))
+		return nil
+	}, "containerd-namespace",
+		`namespace of containerd, like "default" and "k8s.io"`)
 	pflagext.StringVarF(fset, func(desc string) error {
 		containerdFlags = append(containerdFlags,
 			containerd.WithUniqueDesc(desc))
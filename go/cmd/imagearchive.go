@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"github.com/spf13/pflag"
+	"golang.org/x/xerrors"
+
+	"github.com/chaitin/libveinmind/go/imagearchive"
+	"github.com/chaitin/libveinmind/go/pkg/pflagext"
+	"github.com/chaitin/libveinmind/go/plugin"
+)
+
+type imagearchiveRoot struct {
+	a *imagearchive.Archive
+}
+
+// ErrArchiveReader is reported when partitioning the archive
+// opened from reader, since the plugins could not open it.
+var ErrArchiveReader = xerrors.New(
+	"image archive opened from reader cannot be partitioned")
+
+// newImagearchiveRoot creates the root of the archive, which
+// must be opened by path so that the plugins could open it.
+func newImagearchiveRoot(a *imagearchive.Archive) (Root, error) {
+	if a.Path() == "" {
+		return nil, ErrArchiveReader
+	}
+	return imagearchiveRoot{a: a}, nil
+}
+
+func (r imagearchiveRoot) ID() interface{} {
+	return r.a
+}
+
+func (r imagearchiveRoot) Mode() string {
+	return "imagearchive"
+}
+
+func (r imagearchiveRoot) Options() plugin.ExecOption {
+	args := []string{"--imagearchive-path", r.a.Path()}
+	if dir := r.a.TempDir(); dir != "" {
+		args = append(args, "--imagearchive-temp-dir", dir)
+	}
+	return plugin.WithExecOptions(plugin.WithPrependArgs(args...))
+}
+
+var imagearchiveFlags []imagearchive.NewOption
+
+type imagearchiveMode struct {
+}
+
+func (imagearchiveMode) Name() string {
+	return "imagearchive"
+}
+
+func (imagearchiveMode) AddFlags(fset *pflag.FlagSet) {
+	pflagext.StringVarF(fset, func(path string) error {
+		imagearchiveFlags = append(imagearchiveFlags,
+			imagearchive.WithPath(path))
+		return nil
+	}, "imagearchive-path",
+		"path of the image archive saved by docker or podman")
+	pflagext.StringVarF(fset, func(dir string) error {
+		imagearchiveFlags = append(imagearchiveFlags,
+			imagearchive.WithTempDir(dir))
+		return nil
+	}, "imagearchive-temp-dir",
+		"directory of temporary files decompressed into")
+}
+
+func (imagearchiveMode) Invoke(c *Command, args []string, m ModeHandler) error {
+	a, err := imagearchive.New(imagearchiveFlags...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = a.Close() }()
+	return m(c, args, a)
+}
+
+func init() {
+	RegisterPartition(func(a *imagearchive.Archive) (Root, error) {
+		return newImagearchiveRoot(a)
+	})
+	RegisterPartition(func(i *imagearchive.Image) (Root, string, error) {
+		root, err := newImagearchiveRoot(i.Runtime())
+		return root, i.ID(), err
+	})
+	RegisterMode(&imagearchiveMode{})
+}
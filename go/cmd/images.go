@@ -16,7 +16,7 @@ func ScanAllImages(
 	if err != nil {
 		return err
 	}
-	return Scan(ctx, iter, runtime, opts...)
+	return scanAllIDs(ctx, iter, runtime, api.Runtime.ListImageIDs, opts...)
 }
 
 // ScanImages scans image provided by image list.
@@ -67,35 +67,30 @@ type ImageIDsHandler func(*Command, api.Runtime, []string) error
 // The command will attempt to initialize the runtime object
 // from specified mode with flags, scan and match images in
 // the runtime, and collect those qualified image IDs.
+//
+// When multiple runtimes are provided by the mode, like the
+// "cognitive" mode, the function is invoked for each runtime.
 func (idx *Index) MapImageIDsCommand(
 	c *Command, f ImageIDsHandler,
 ) *Command {
 	c = idx.MapModeCommand(c, "image", struct{}{}, func(
 		c *Command, args []string, root interface{},
 	) error {
-		r, ok := root.(api.Runtime)
+		runtimes, ok := rootRuntimes(root)
 		if !ok {
 			return IncompatibleMode()
 		}
-		var imageIDs []string
-		if len(args) == 0 {
-			ids, err := r.ListImageIDs()
+		for _, r := range runtimes {
+			imageIDs, err := matchIDs(args, imageExactIDs, len(runtimes) > 1,
+				r.ListImageIDs, r.FindImageIDs)
 			if err != nil {
 				return err
 			}
-			imageIDs = append(imageIDs, ids...)
-		} else if imageExactIDs {
-			imageIDs = append(imageIDs, args...)
-		} else {
-			for _, arg := range args {
-				ids, err := r.FindImageIDs(arg)
-				if err != nil {
-					return err
-				}
-				imageIDs = append(imageIDs, ids...)
+			if err := f(c, r, imageIDs); err != nil {
+				return err
 			}
 		}
-		return f(c, r, imageIDs)
+		return nil
 	})
 	flags := c.PersistentFlags()
 	flags.BoolVar(&imageExactIDs, "id", false,
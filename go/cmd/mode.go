@@ -75,8 +75,6 @@ func (idx *Index) MapModeCommand(
 		return mode.(Mode).Invoke(c, args, f)
 	})
 	flags := c.PersistentFlags()
-	// TODO: create a mode "cognitive" that automatically
-	// discovers and recognizes containers.
 	flags.StringVarP(&modeName, "mode", "m", "docker",
 		"select mode to retrieve root object")
 	modes.Range(func(key, value interface{}) bool {
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"github.com/spf13/pflag"
+
+	"github.com/chaitin/libveinmind/go/ocilayout"
+	"github.com/chaitin/libveinmind/go/pkg/pflagext"
+	"github.com/chaitin/libveinmind/go/plugin"
+)
+
+type ocilayoutRoot struct {
+	l *ocilayout.Layout
+}
+
+func (r ocilayoutRoot) ID() interface{} {
+	return r.l
+}
+
+func (r ocilayoutRoot) Mode() string {
+	return "ocilayout"
+}
+
+func (r ocilayoutRoot) Options() plugin.ExecOption {
+	args := []string{"--ocilayout-path", r.l.Path()}
+	if dir := r.l.TempDir(); dir != "" {
+		args = append(args, "--ocilayout-temp-dir", dir)
+	}
+	return plugin.WithExecOptions(plugin.WithPrependArgs(args...))
+}
+
+var ocilayoutFlags []ocilayout.NewOption
+
+type ocilayoutMode struct {
+}
+
+func (ocilayoutMode) Name() string {
+	return "ocilayout"
+}
+
+func (ocilayoutMode) AddFlags(fset *pflag.FlagSet) {
+	pflagext.StringVarF(fset, func(path string) error {
+		ocilayoutFlags = append(ocilayoutFlags,
+			ocilayout.WithPath(path))
+		return nil
+	}, "ocilayout-path",
+		"path of the OCI image layout directory")
+	pflagext.StringVarF(fset, func(dir string) error {
+		ocilayoutFlags = append(ocilayoutFlags,
+			ocilayout.WithTempDir(dir))
+		return nil
+	}, "ocilayout-temp-dir",
+		"directory of temporary files decompressed into")
+}
+
+func (ocilayoutMode) Invoke(c *Command, args []string, m ModeHandler) error {
+	l, err := ocilayout.New(ocilayoutFlags...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = l.Close() }()
+	return m(c, args, l)
+}
+
+func init() {
+	RegisterPartition(func(l *ocilayout.Layout) Root {
+		return ocilayoutRoot{l: l}
+	})
+	RegisterPartition(func(i *ocilayout.Image) (Root, string) {
+		return ocilayoutRoot{l: i.Runtime()}, i.ID()
+	})
+	RegisterMode(&ocilayoutMode{})
+}
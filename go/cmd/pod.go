@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	api "github.com/chaitin/libveinmind/go"
+	"github.com/chaitin/libveinmind/go/pkg/cri"
+	"github.com/chaitin/libveinmind/go/plugin"
+	"github.com/chaitin/libveinmind/go/plugin/log"
+)
+
+// PodContainerIDs collects the IDs of containers in the pods
+// matching the pattern in the runtime, including their sandbox
+// containers. The pattern is matched by cri.Pod.Match, and the
+// containers failed to open or inspect are skipped with warnings.
+func PodContainerIDs(runtime api.Runtime, pattern string) ([]string, error) {
+	ids, err := runtime.ListContainerIDs()
+	if err != nil {
+		return nil, err
+	}
+	var containers []api.Container
+	defer func() {
+		for _, c := range containers {
+			_ = c.Close()
+		}
+	}()
+	listed := make(map[api.Container]string)
+	for _, id := range ids {
+		c, err := runtime.OpenContainerByID(id)
+		if err != nil {
+			log.Warnf("skip container %s failed to open: %v", id, err)
+			continue
+		}
+		containers = append(containers, c)
+		listed[c] = id
+	}
+	pods, err := cri.Group(containers)
+	var groupErr *cri.GroupError
+	if xerrors.As(err, &groupErr) {
+		for _, err := range groupErr.Errors {
+			log.Warnf("skip container %s failed to inspect: %v",
+				listed[err.Container], err.Err)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, pod := range pods {
+		if !pod.Match(pattern) {
+			continue
+		}
+		if pod.Sandbox != nil {
+			result = append(result, listed[pod.Sandbox])
+		}
+		for _, c := range pod.Containers {
+			result = append(result, listed[c])
+		}
+	}
+	return result, nil
+}
+
+// ScanPodContainers scans the containers of the pods matching
+// the pattern in the runtime, including their sandbox
+// containers.
+func ScanPodContainers(
+	ctx context.Context, rang plugin.ExecRange,
+	runtime api.Runtime, pattern string, opts ...plugin.ExecOption,
+) error {
+	ids, err := PodContainerIDs(runtime, pattern)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return ScanContainerIDs(ctx, rang, runtime, ids, opts...)
+}
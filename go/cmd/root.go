@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"sync"
 
+	api "github.com/chaitin/libveinmind/go"
 	"github.com/chaitin/libveinmind/go/plugin"
 )
 
@@ -37,13 +38,18 @@ type UniqueRoot interface {
 // be generated this case. When the partitioner is for some
 // object beneath the root, then "func(Type) (Root, string)"
 // is required. The Type will be used for registering directly.
+//
+// Either form could yield an error as the last result, when
+// the object cannot be partitioned, which fails the scan.
 type Partitioner interface{}
 
 var typeString = reflect.TypeOf("")
 
+var typeError = reflect.TypeOf((*error)(nil)).Elem()
+
 var typeRootObject = reflect.TypeOf((*Root)(nil)).Elem()
 
-type partitioner func(reflect.Value) (Root, []string)
+type partitioner func(reflect.Value) (Root, []string, error)
 
 func newPartitioner(p Partitioner) (reflect.Type, partitioner) {
 	val := reflect.ValueOf(p)
@@ -54,23 +60,33 @@ func newPartitioner(p Partitioner) (reflect.Type, partitioner) {
 	if typ.NumIn() != 1 {
 		panic("partitioner must have one eact input of object")
 	}
-	if typ.NumOut() < 1 || typ.Out(0) != typeRootObject {
+	numOut := typ.NumOut()
+	hasError := numOut >= 2 && typ.Out(numOut-1) == typeError
+	if hasError {
+		numOut--
+	}
+	if numOut < 1 || typ.Out(0) != typeRootObject {
 		panic("partitioner must have at least one output of Root")
 	}
-	if typ.NumOut() >= 2 && typ.Out(1) != typeString {
+	if numOut >= 2 && typ.Out(1) != typeString {
 		panic("partitioner must yield a string as second result")
 	}
-	if typ.NumOut() >= 3 {
+	if numOut >= 3 {
 		panic("partitioner has too many result")
 	}
-	return typ.In(0), func(in reflect.Value) (Root, []string) {
+	return typ.In(0), func(in reflect.Value) (Root, []string, error) {
 		out := val.Call([]reflect.Value{in})
+		if hasError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return nil, nil, err
+			}
+		}
 		root := out[0].Interface().(Root)
 		var ids []string
-		if len(out) > 1 {
+		if numOut > 1 {
 			ids = append(ids, out[1].Interface().(string))
 		}
-		return root, ids
+		return root, ids, nil
 	}
 }
 
@@ -93,15 +109,22 @@ func Scan(
 ) error {
 	objVals := reflect.ValueOf(objs)
 	length := objVals.Len()
-	var result sync.Map
+	var items []interface{}
 	for i := 0; i < length; i++ {
-		objVal := reflect.ValueOf(objVals.Index(i).Interface())
+		items = expandComposite(items, objVals.Index(i).Interface())
+	}
+	var result sync.Map
+	for _, item := range items {
+		objVal := reflect.ValueOf(item)
 		objTyp := objVal.Type()
 		val, ok := partitioners.Load(objTyp)
 		if !ok {
 			panic(fmt.Sprintf("undefined partition %q", objTyp))
 		}
-		root, ids := val.(partitioner)(objVal)
+		root, ids, err := val.(partitioner)(objVal)
+		if err != nil {
+			return err
+		}
 		var rootID interface{} = root
 		if uniq, ok := root.(UniqueRoot); ok {
 			rootID = uniq.ID()
@@ -133,13 +156,19 @@ func ScanIDs(
 	ctx context.Context, iter plugin.ExecIterator,
 	obj interface{}, ids []string, opts ...plugin.ExecOption,
 ) error {
+	if composite, ok := obj.(compositeRuntime); ok {
+		return scanCompositeIDs(ctx, iter, composite, ids, opts...)
+	}
 	objVal := reflect.ValueOf(obj)
 	objTyp := objVal.Type()
 	val, ok := partitioners.Load(objTyp)
 	if !ok {
 		panic(fmt.Sprintf("undefined partition %q", objTyp))
 	}
-	root, pids := val.(partitioner)(objVal)
+	root, pids, err := val.(partitioner)(objVal)
+	if err != nil {
+		return err
+	}
 	if len(pids) > 0 {
 		panic(fmt.Sprintf("invalid root object with ID"))
 	}
@@ -150,3 +179,94 @@ func ScanIDs(
 		plugin.WithPrependArgs("--mode", root.Mode()),
 		root.Options(), plugin.WithExecOptions(opts...))
 }
+
+// compositeRuntime is the runtime composed of other runtimes,
+// like aggregate.Runtime. Its runtimes are partitioned
+// individually, so that each of them still gets its own root
+// and flags when dispatched to plugins.
+type compositeRuntime interface {
+	api.Runtime
+	Runtimes() []api.Runtime
+	Resolve(id string) (api.Runtime, string, error)
+}
+
+// expandComposite appends the object to the list, or its
+// runtimes recursively if it is a composite runtime. This is
+// only for scanning the runtimes as a whole, the images and
+// containers are scanned by scanAllIDs instead.
+func expandComposite(items []interface{}, obj interface{}) []interface{} {
+	composite, ok := obj.(compositeRuntime)
+	if !ok {
+		return append(items, obj)
+	}
+	for _, r := range composite.Runtimes() {
+		items = expandComposite(items, r)
+	}
+	return items
+}
+
+// scanAllIDs scans all objects in the runtimes. The composite
+// runtimes are scanned by the IDs listed from them, which are
+// routed to the runtimes owning them, so that the objects
+// de-duplicated by the composite runtime are scanned once.
+func scanAllIDs(
+	ctx context.Context, iter plugin.ExecIterator,
+	runtimes []api.Runtime, list func(api.Runtime) ([]string, error),
+	opts ...plugin.ExecOption,
+) error {
+	var plain []api.Runtime
+	var composites []compositeRuntime
+	for _, r := range runtimes {
+		if composite, ok := r.(compositeRuntime); ok {
+			composites = append(composites, composite)
+		} else {
+			plain = append(plain, r)
+		}
+	}
+	if err := Scan(ctx, iter, plain, opts...); err != nil {
+		return err
+	}
+	for _, composite := range composites {
+		ids, err := list(composite)
+		if err != nil {
+			return err
+		}
+		iter.Reset()
+		if err := scanCompositeIDs(ctx, iter, composite, ids,
+			plugin.WithPrependArgs("--id"),
+			plugin.WithExecOptions(opts...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanCompositeIDs routes the IDs to the runtimes inside the
+// composite runtime, and scans them with each runtime.
+func scanCompositeIDs(
+	ctx context.Context, iter plugin.ExecIterator,
+	composite compositeRuntime, ids []string,
+	opts ...plugin.ExecOption,
+) error {
+	var runtimes []api.Runtime
+	routed := make(map[api.Runtime][]string)
+	for _, id := range ids {
+		r, rid, err := composite.Resolve(id)
+		if err != nil {
+			return err
+		}
+		if _, ok := routed[r]; !ok {
+			runtimes = append(runtimes, r)
+		}
+		routed[r] = append(routed[r], rid)
+	}
+	for i, r := range runtimes {
+		if i > 0 {
+			iter.Reset()
+		}
+		if err := ScanIDs(ctx, iter, r, routed[r], opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
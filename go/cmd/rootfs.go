@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"github.com/spf13/pflag"
+
+	"github.com/chaitin/libveinmind/go/pkg/pflagext"
+	"github.com/chaitin/libveinmind/go/plugin"
+	"github.com/chaitin/libveinmind/go/rootfs"
+)
+
+type rootfsRoot struct {
+	r *rootfs.Rootfs
+}
+
+func (r rootfsRoot) ID() interface{} {
+	return r.r
+}
+
+func (r rootfsRoot) Mode() string {
+	return "rootfs"
+}
+
+func (r rootfsRoot) Options() plugin.ExecOption {
+	var args []string
+	for _, path := range r.r.Paths() {
+		args = append(args, "--rootfs-path", path)
+	}
+	return plugin.WithExecOptions(plugin.WithPrependArgs(args...))
+}
+
+var rootfsFlags []rootfs.NewOption
+
+type rootfsMode struct {
+}
+
+func (rootfsMode) Name() string {
+	return "rootfs"
+}
+
+func (rootfsMode) AddFlags(fset *pflag.FlagSet) {
+	pflagext.StringVarF(fset, func(path string) error {
+		rootfsFlags = append(rootfsFlags,
+			rootfs.WithPath(path))
+		return nil
+	}, "rootfs-path",
+		"path of the root file system directory to scan")
+}
+
+func (rootfsMode) Invoke(c *Command, args []string, m ModeHandler) error {
+	r, err := rootfs.New(rootfsFlags...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+	return m(c, args, r)
+}
+
+func init() {
+	RegisterPartition(func(r *rootfs.Rootfs) Root {
+		return rootfsRoot{r: r}
+	})
+	RegisterPartition(func(i *rootfs.Image) (Root, string) {
+		return rootfsRoot{r: i.Runtime()}, i.ID()
+	})
+	RegisterMode(&rootfsMode{})
+}
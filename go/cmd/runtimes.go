@@ -34,18 +34,23 @@ func ScanRuntime(
 //
 // The command will attempt to initialize the runtime object
 // from specified mode with flags, and then invoke the function
-// specified by caller.
+// specified by caller, once for each runtime provided.
 func (idx *Index) MapRuntimeCommand(
 	c *Command, f RuntimeHandler,
 ) *Command {
 	return idx.MapModeCommand(c, "runtime", struct{}{}, func(
 		c *Command, _ []string, root interface{},
 	) error {
-		r, ok := root.(api.Runtime)
+		runtimes, ok := rootRuntimes(root)
 		if !ok {
 			return IncompatibleMode()
 		}
-		return f(c, r)
+		for _, r := range runtimes {
+			if err := f(c, r); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 }
 
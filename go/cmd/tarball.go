@@ -1,3 +1,6 @@
+//go:build cgo
+// +build cgo
+
 package cmd
 
 import (
@@ -41,6 +44,12 @@ func (tarballMode) AddFlags(fset *pflag.FlagSet) {
 		return nil
 	}, "tarball-root",
 		"tarball manager system data root")
+	pflagext.StringVarF(fset, func(dir string) error {
+		tarballFlags = append(tarballFlags,
+			tarball.WithTempDir(dir))
+		return nil
+	}, "tarball-temp-dir",
+		"directory of temporary files loaded archives copied into")
 }
 
 func (tarballMode) Invoke(c *Command, args []string, m ModeHandler) error {
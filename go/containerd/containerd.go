@@ -2,15 +2,25 @@
 package containerd
 
 import (
+	"encoding/json"
+	"regexp"
+
+	"golang.org/x/xerrors"
+
 	api "github.com/chaitin/libveinmind/go"
 	"github.com/chaitin/libveinmind/go/pkg/behaviour"
 	"github.com/chaitin/libveinmind/go/pkg/binding"
+	"github.com/chaitin/libveinmind/go/pkg/boltdb"
 )
 
 // newArgs is the internal state that a containerd.NewOption can
 // manipulate for creating a new containerd handle.
 type newArgs struct {
-	h binding.Handle
+	h          binding.Handle
+	configPath string
+	root       string
+	namespace  string
+	err        error
 }
 
 // NewOption is the option that can be used for initializing an
@@ -33,6 +43,7 @@ func WithConfigPath(path string) NewOption {
 		opt := binding.ContainerdWithConfigPath(path)
 		defer opt.Free()
 		opts.h.Append(opt)
+		opts.configPath = path
 	}
 }
 
@@ -46,9 +57,41 @@ func WithRootDir(path string) NewOption {
 		opt := binding.ContainerdWithRootDir(path)
 		defer opt.Free()
 		opts.h.Append(opt)
+		opts.root = path
 	}
 }
 
+// namespacePattern is the pattern of the namespace names
+// accepted by containerd.
+var namespacePattern = regexp.MustCompile(
+	`^[A-Za-z0-9]+(?:[._-][A-Za-z0-9]+)*$`)
+
+// WithNamespace specifies the containerd namespace to work in,
+// like "default", "k8s.io" and "moby".
+//
+// The images and containers enumerated by the native runtime
+// are filtered by the records of the namespace in the metadata
+// store, so that the ones of other namespaces are invisible to
+// the runtime. They are not filtered when unspecified.
+func WithNamespace(namespace string) NewOption {
+	return func(opts *newArgs) {
+		if !namespacePattern.MatchString(namespace) {
+			opts.err = xerrors.Errorf(
+				"containerd: invalid namespace %q", namespace)
+			return
+		}
+		opts.namespace = namespace
+	}
+}
+
+// uniqueDesc is the unique descriptor carrying the namespace
+// and root directory along with the descriptor of the binding.
+type uniqueDesc struct {
+	Namespace  string `json:"namespace"`
+	Root       string `json:"root,omitempty"`
+	UniqueDesc string `json:"uniqueDesc"`
+}
+
 // WithUniqueDesc specifies the unique descriptor of dockerd.
 //
 // This argument must be result of
@@ -58,6 +101,15 @@ func WithRootDir(path string) NewOption {
 // runtime context has not been set up properly.
 func WithUniqueDesc(desc string) NewOption {
 	return func(opts *newArgs) {
+		var wrapped uniqueDesc
+		if err := json.Unmarshal([]byte(desc), &wrapped); err == nil &&
+			wrapped.UniqueDesc != "" {
+			desc = wrapped.UniqueDesc
+			opts.root = wrapped.Root
+			if wrapped.Namespace != "" {
+				WithNamespace(wrapped.Namespace)(opts)
+			}
+		}
 		opt := binding.ContainerdWithUniqueDesc(desc)
 		defer opt.Free()
 		opts.h.Append(opt)
@@ -70,7 +122,9 @@ type Containerd struct {
 	behaviour.Closer
 	behaviour.Runtime
 	behaviour.FileSystem
-	runtime binding.Handle
+	runtime   binding.Handle
+	root      string
+	namespace string
 }
 
 // New a containerd runtime object.
@@ -81,11 +135,21 @@ func New(opts ...NewOption) (api.Runtime, error) {
 	for _, opt := range opts {
 		opt(args)
 	}
+	if args.err != nil {
+		return nil, args.err
+	}
 	h, err := binding.ContainerdNew(hopt)
 	if err != nil {
 		return nil, err
 	}
-	result := &Containerd{runtime: h}
+	result := &Containerd{
+		runtime:   h,
+		root:      args.root,
+		namespace: args.namespace,
+	}
+	if result.root == "" {
+		result.root = configRoot(args.configPath)
+	}
 	result.Closer = behaviour.NewCloser(&result.runtime)
 	result.Runtime = behaviour.NewRuntime(&result.runtime)
 	result.FileSystem = behaviour.NewFileSystem(&result.runtime)
@@ -96,7 +160,82 @@ func New(opts ...NewOption) (api.Runtime, error) {
 // arguments, which can be passed across process boundaries and
 // initialize the same docker in another process.
 func (d *Containerd) UniqueDesc() string {
-	return d.runtime.ContainerdUniqueDesc()
+	desc := d.runtime.ContainerdUniqueDesc()
+	data, err := json.Marshal(uniqueDesc{
+		Namespace:  d.namespace,
+		Root:       d.root,
+		UniqueDesc: desc,
+	})
+	if err != nil {
+		return desc
+	}
+	return string(data)
+}
+
+// Namespace returns the namespace specified to the runtime,
+// which is empty when it's the default one.
+func (d *Containerd) Namespace() string {
+	return d.namespace
+}
+
+// RootDir returns the root directory of containerd.
+func (d *Containerd) RootDir() string {
+	return d.root
+}
+
+// ListNamespaces lists the namespaces in the containerd, no
+// matter which namespace the runtime is working in.
+func (d *Containerd) ListNamespaces() ([]string, error) {
+	db, err := d.metadata()
+	if err != nil {
+		return nil, err
+	}
+	b, err := db.Bucket("v1")
+	if err != nil || b == nil {
+		return nil, err
+	}
+	var result []string
+	if err := b.ForEach(func(e boltdb.Entry) error {
+		if e.IsBucket() {
+			result = append(result, string(e.Key))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (d *Containerd) ListImageIDs() ([]string, error) {
+	ids, err := d.Runtime.ListImageIDs()
+	if err != nil {
+		return nil, err
+	}
+	return d.filterIDs(ids, d.namespaceImageIDs)
+}
+
+func (d *Containerd) FindImageIDs(pattern string) ([]string, error) {
+	ids, err := d.Runtime.FindImageIDs(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return d.filterIDs(ids, d.namespaceImageIDs)
+}
+
+func (d *Containerd) ListContainerIDs() ([]string, error) {
+	ids, err := d.Runtime.ListContainerIDs()
+	if err != nil {
+		return nil, err
+	}
+	return d.filterIDs(ids, d.namespaceContainerIDs)
+}
+
+func (d *Containerd) FindContainerIDs(pattern string) ([]string, error) {
+	ids, err := d.Runtime.FindContainerIDs(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return d.filterIDs(ids, d.namespaceContainerIDs)
 }
 
 // Image represents a containerd image, which is guaranteed to
@@ -107,6 +246,7 @@ type Image struct {
 	behaviour.FileSystem
 	runtime *Containerd
 	image   binding.Handle
+	layers  layerRecord
 }
 
 type Container struct {
@@ -119,6 +259,9 @@ type Container struct {
 }
 
 func (d *Containerd) OpenImageByID(id string) (api.Image, error) {
+	if err := d.checkID(id, d.namespaceImageIDs); err != nil {
+		return nil, err
+	}
 	h, err := d.runtime.RuntimeOpenImageByID(id)
 	if err != nil {
 		return nil, err
@@ -131,6 +274,9 @@ func (d *Containerd) OpenImageByID(id string) (api.Image, error) {
 }
 
 func (d *Containerd) OpenContainerByID(id string) (api.Container, error) {
+	if err := d.checkID(id, d.namespaceContainerIDs); err != nil {
+		return nil, err
+	}
 	h, err := d.runtime.RuntimeOpenContainerByID(id)
 	if err != nil {
 		return nil, err
@@ -150,3 +296,134 @@ func (i *Image) Runtime() *Containerd {
 func (c *Container) Runtime() *Containerd {
 	return c.runtime
 }
+
+// Config returns the container record in the metadata of
+// containerd, including the CRI metadata of the container.
+func (c *Container) Config() (*ContainerConfig, error) {
+	return c.runtime.containerConfig(c.ID())
+}
+
+// CRIAnnotations returns the labels of the container and the
+// annotations recorded in its CRI metadata, since the CRI plugin
+// of containerd only copies part of them into the OCI spec.
+func (c *Container) CRIAnnotations() (map[string]string, error) {
+	config, err := c.Config()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string)
+	for k, v := range config.Labels {
+		result[k] = v
+	}
+	container, err := config.CRIContainerMetadata()
+	if err != nil {
+		return nil, err
+	}
+	if container != nil && container.Config != nil {
+		for k, v := range container.Config.Annotations {
+			result[k] = v
+		}
+	}
+	sandbox, err := config.CRISandboxMetadata()
+	if err != nil {
+		return nil, err
+	}
+	if sandbox != nil && sandbox.Config != nil {
+		for k, v := range sandbox.Config.Annotations {
+			result[k] = v
+		}
+	}
+	return result, nil
+}
+
+// CRIAttempt returns the attempt in the CRI metadata of the
+// container or sandbox, which is the restart count of it.
+func (c *Container) CRIAttempt() (int, bool, error) {
+	config, err := c.Config()
+	if err != nil {
+		return 0, false, err
+	}
+	container, err := config.CRIContainerMetadata()
+	if err != nil {
+		return 0, false, err
+	}
+	if container != nil && container.Config != nil {
+		return int(container.Config.Metadata.Attempt), true, nil
+	}
+	sandbox, err := config.CRISandboxMetadata()
+	if err != nil {
+		return 0, false, err
+	}
+	if sandbox != nil && sandbox.Config != nil {
+		return int(sandbox.Config.Metadata.Attempt), true, nil
+	}
+	return 0, false, nil
+}
+
+// NumLayers returns the number of snapshots in the chain of
+// the container, from the snapshot of the bottom layer to the
+// active snapshot of the container.
+func (c *Container) NumLayers() (int, error) {
+	chain, err := c.snapshotChain()
+	if err != nil {
+		return 0, err
+	}
+	return len(chain), nil
+}
+
+// OpenLayer opens the snapshot at the index of the chain of
+// the container, whose ID is the snapshot key. The last one is
+// the active snapshot holding the changes of the container.
+func (c *Container) OpenLayer(i int) (api.Layer, error) {
+	chain, err := c.snapshotChain()
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= len(chain) {
+		return nil, xerrors.Errorf("containerd: layer %d out of range", i)
+	}
+	result, err := c.runtime.openLayer(chain[i])
+	if err != nil {
+		return nil, err
+	}
+	result.container = c
+	return result, nil
+}
+
+// snapshotChain returns the snapshots from the bottom layer
+// to the active snapshot of the container.
+func (c *Container) snapshotChain() ([]*snapshot, error) {
+	config, err := c.Config()
+	if err != nil {
+		return nil, err
+	}
+	if config.SnapshotKey == "" {
+		return nil, xerrors.Errorf(
+			"containerd: container %q has no snapshot", c.ID())
+	}
+	snapshots, err := c.runtime.namespaceBucket(
+		config.Namespace, "snapshots")
+	if err != nil {
+		return nil, err
+	}
+	var chain []*snapshot
+	seen := make(map[string]struct{})
+	for key := config.SnapshotKey; key != ""; {
+		if _, ok := seen[key]; ok {
+			return nil, xerrors.Errorf(
+				"containerd: snapshot %q in a cycle", key)
+		}
+		seen[key] = struct{}{}
+		s, err := lookupSnapshot(snapshots, config.Snapshotter, key)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			return nil, xerrors.Errorf(
+				"containerd: snapshot %q not found", key)
+		}
+		chain = append([]*snapshot{s}, chain...)
+		key = s.parent
+	}
+	return chain, nil
+}
@@ -0,0 +1,311 @@
+package containerd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/xerrors"
+
+	api "github.com/chaitin/libveinmind/go"
+	"github.com/chaitin/libveinmind/go/rootfs"
+)
+
+// Layer represents a containerd layer, which is guaranteed to
+// be the result of containerd.Image.OpenLayer or
+// containerd.Container.OpenLayer.
+//
+// The layer is the directory of the snapshot, whose files are
+// confined to the directory in the same way as rootfs.Image.
+type Layer struct {
+	api.FileSystem
+	image     *Image
+	container *Container
+	id        string
+	dir       string
+}
+
+// openLayer opens the layer of the snapshot.
+func (d *Containerd) openLayer(s *snapshot) (*Layer, error) {
+	dir, err := d.snapshotDir(s)
+	if err != nil {
+		return nil, err
+	}
+	runtime, err := rootfs.New(rootfs.WithPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	paths := runtime.(*rootfs.Rootfs).Paths()
+	image, err := runtime.OpenImageByID(paths[0])
+	if err != nil {
+		return nil, err
+	}
+	return &Layer{FileSystem: image, id: s.key, dir: dir}, nil
+}
+
+// Image returns the image of the layer, which is nil when it
+// is opened from a container.
+func (l *Layer) Image() *Image {
+	return l.image
+}
+
+// Container returns the container of the layer, which is nil
+// when it is opened from an image.
+func (l *Layer) Container() *Container {
+	return l.container
+}
+
+// ID returns the key of the snapshot, which is the chain ID
+// of the layer when it is opened from an image.
+func (l *Layer) ID() string {
+	return l.id
+}
+
+func (l *Layer) Close() error {
+	return nil
+}
+
+// Dir returns the host directory of the snapshot.
+func (l *Layer) Dir() string {
+	return l.dir
+}
+
+// Opaques returns the opaque directories of the layer, which
+// are marked by the overlay opaque xattr.
+func (l *Layer) Opaques() ([]string, error) {
+	opaques, _, err := scanWhiteouts(l.dir)
+	return opaques, err
+}
+
+// Whiteouts returns the files removed by the layer, which are
+// the overlay whiteouts of character device 0:0.
+func (l *Layer) Whiteouts() ([]string, error) {
+	_, whiteouts, err := scanWhiteouts(l.dir)
+	return whiteouts, err
+}
+
+// scanWhiteouts walks the snapshot directory for the opaque
+// directories and whiteouts of overlay.
+func scanWhiteouts(dir string) ([]string, []string, error) {
+	var opaques, whiteouts []string
+	err := filepath.Walk(dir, func(
+		p string, info os.FileInfo, err error,
+	) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name := path.Clean("/" + filepath.ToSlash(rel))
+		switch {
+		case info.IsDir() && name != "/":
+			opaque, err := isOpaque(p)
+			if err != nil {
+				return err
+			}
+			if opaque {
+				opaques = append(opaques, name)
+			}
+		case isWhiteout(info):
+			whiteouts = append(whiteouts, name)
+		}
+		return nil
+	})
+	return opaques, whiteouts, err
+}
+
+var _ api.LayeredImage = (*Image)(nil)
+
+// layerRecord is the layers of the image recorded in the
+// metadata store and content store.
+type layerRecord struct {
+	once    sync.Once
+	err     error
+	digests []string
+}
+
+func (i *Image) diffIDs() ([]string, error) {
+	config, err := i.OCISpecV1()
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, diffID := range config.RootFS.DiffIDs {
+		result = append(result, string(diffID))
+	}
+	return result, nil
+}
+
+// NumLayers returns the number of layers in the config of the
+// image, which is zero if the config is unreadable.
+func (i *Image) NumLayers() int {
+	diffIDs, err := i.diffIDs()
+	if err != nil {
+		return 0
+	}
+	return len(diffIDs)
+}
+
+func (im *Image) GetLayerDiffID(i int) (string, error) {
+	return im.LayerDiffID(i)
+}
+
+func (im *Image) LayerDiffID(i int) (string, error) {
+	diffIDs, err := im.diffIDs()
+	if err != nil {
+		return "", err
+	}
+	if i < 0 || i >= len(diffIDs) {
+		return "", xerrors.Errorf("containerd: layer %d out of range", i)
+	}
+	return diffIDs[i], nil
+}
+
+// LayerDigest returns the digest of the layer blob in the
+// manifest of the image, which is looked up among the images
+// of the namespace in the metadata store.
+func (im *Image) LayerDigest(i int) (string, error) {
+	im.layers.once.Do(func() {
+		im.layers.digests, im.layers.err = im.layerDigests()
+	})
+	if im.layers.err != nil {
+		return "", im.layers.err
+	}
+	if i < 0 || i >= len(im.layers.digests) {
+		return "", xerrors.Errorf("containerd: layer %d out of range", i)
+	}
+	return im.layers.digests[i], nil
+}
+
+// layerDigests finds the manifest of the image among the
+// targets of the images in the namespace. The manifest matches
+// if the digest of either itself or its config is the ID of the
+// image, or the diff IDs in its config are the ones of the
+// image.
+func (im *Image) layerDigests() ([]string, error) {
+	diffIDs, err := im.diffIDs()
+	if err != nil {
+		return nil, err
+	}
+	targets, err := im.runtime.imageTargets()
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	visited := make(map[string]struct{})
+	for _, target := range targets {
+		found, err := im.runtime.walkManifests(target, visited, 0, func(
+			digest string, manifest *imageV1.Manifest,
+		) (bool, error) {
+			ok, err := im.matchManifest(digest, manifest, diffIDs)
+			if err != nil || !ok {
+				return false, err
+			}
+			for _, layer := range manifest.Layers {
+				result = append(result, string(layer.Digest))
+			}
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return result, nil
+		}
+	}
+	return nil, xerrors.Errorf(
+		"containerd: manifest of image %q not found", im.ID())
+}
+
+func (im *Image) matchManifest(
+	digest string, manifest *imageV1.Manifest, diffIDs []string,
+) (bool, error) {
+	config := string(manifest.Config.Digest)
+	if digest == im.ID() || config == im.ID() {
+		return true, nil
+	}
+	if len(manifest.Layers) != len(diffIDs) {
+		return false, nil
+	}
+	data, err := im.runtime.readBlob(config)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var parsed imageV1.Image
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return false, nil
+	}
+	if len(parsed.RootFS.DiffIDs) != len(diffIDs) {
+		return false, nil
+	}
+	for i, diffID := range parsed.RootFS.DiffIDs {
+		if string(diffID) != diffIDs[i] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// chainID returns the chain ID of the layer, which is the key
+// of the snapshot of the layer unpacked.
+func (im *Image) chainID(i int) (string, error) {
+	diffIDs, err := im.diffIDs()
+	if err != nil {
+		return "", err
+	}
+	if i < 0 || i >= len(diffIDs) {
+		return "", xerrors.Errorf("containerd: layer %d out of range", i)
+	}
+	chainID := diffIDs[0]
+	for _, diffID := range diffIDs[1 : i+1] {
+		sum := sha256.Sum256([]byte(chainID + " " + diffID))
+		chainID = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return chainID, nil
+}
+
+// OpenLayer opens the snapshot of the layer unpacked, which is
+// identified by the chain ID of the layer.
+func (im *Image) OpenLayer(i int) (api.Layer, error) {
+	chainID, err := im.chainID(i)
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := im.runtime.namespaceBuckets()
+	if err != nil {
+		return nil, err
+	}
+	var s *snapshot
+	for _, namespace := range namespaces {
+		snapshots, err := namespace.Bucket.Bucket("snapshots")
+		if err != nil {
+			return nil, err
+		}
+		if s, err = lookupSnapshot(snapshots, "", chainID); err != nil {
+			return nil, err
+		}
+		if s != nil {
+			break
+		}
+	}
+	if s == nil {
+		return nil, xerrors.Errorf(
+			"containerd: snapshot of layer %d not found", i)
+	}
+	result, err := im.runtime.openLayer(s)
+	if err != nil {
+		return nil, err
+	}
+	result.image = im
+	return result, nil
+}
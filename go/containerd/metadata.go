@@ -0,0 +1,579 @@
+package containerd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/xerrors"
+
+	"github.com/chaitin/libveinmind/go/pkg/boltdb"
+)
+
+const (
+	defaultConfigPath = "/etc/containerd/config.toml"
+	defaultRootDir    = "/var/lib/containerd"
+	defaultNamespace  = "default"
+
+	metadataPath    = "io.containerd.metadata.v1.bolt/meta.db"
+	contentPath     = "io.containerd.content.v1.content/blobs"
+	snapshotterPath = "io.containerd.snapshotter.v1."
+)
+
+// configRoot returns the root directory specified in the
+// config of containerd, or the default one if unspecified.
+//
+// Only the top level "root" key is parsed, which precedes
+// all tables in the TOML file.
+func configRoot(path string) string {
+	if path == "" {
+		path = defaultConfigPath
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return defaultRootDir
+	}
+	defer func() { _ = f.Close() }()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			break
+		}
+		i := strings.Index(line, "=")
+		if i < 0 || strings.TrimSpace(line[:i]) != "root" {
+			continue
+		}
+		value := strings.TrimSpace(line[i+1:])
+		if strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") &&
+			len(value) >= 2 {
+			return value[1 : len(value)-1]
+		}
+		if root, err := strconv.Unquote(value); err == nil && root != "" {
+			return root
+		}
+	}
+	return defaultRootDir
+}
+
+// metadata reads the metadata store of containerd.
+func (d *Containerd) metadata() (*boltdb.DB, error) {
+	return boltdb.Open(filepath.Join(d.root, metadataPath))
+}
+
+// namespaceBucket is the bucket of a namespace in the metadata
+// store, containing buckets like "images" and "containers".
+type namespaceBucket struct {
+	name string
+	*boltdb.Bucket
+}
+
+// namespaceBuckets returns the buckets of the namespaces to
+// look up, which is the namespace specified, or every namespace
+// with the "default" one first when unspecified, since the
+// objects opened natively might be in any of them.
+func (d *Containerd) namespaceBuckets() ([]namespaceBucket, error) {
+	db, err := d.metadata()
+	if err != nil {
+		return nil, err
+	}
+	v1, err := db.Bucket("v1")
+	if err != nil || v1 == nil {
+		return nil, err
+	}
+	var result []namespaceBucket
+	if err := v1.ForEach(func(e boltdb.Entry) error {
+		name := string(e.Key)
+		if !e.IsBucket() || (d.namespace != "" && name != d.namespace) {
+			return nil
+		}
+		b := namespaceBucket{name: name, Bucket: v1.Open(e)}
+		if name == defaultNamespace {
+			result = append([]namespaceBucket{b}, result...)
+		} else {
+			result = append(result, b)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// namespaceBucket returns the bucket nested in the bucket of
+// the namespace, or nil if it does not exist.
+func (d *Containerd) namespaceBucket(
+	namespace string, names ...string,
+) (*boltdb.Bucket, error) {
+	db, err := d.metadata()
+	if err != nil {
+		return nil, err
+	}
+	return db.Bucket(append([]string{"v1", namespace}, names...)...)
+}
+
+// splitDigest splits the digest into its algorithm and hex,
+// which must be safe to be used as path components.
+func splitDigest(digest string) (string, string, error) {
+	i := strings.Index(digest, ":")
+	if i <= 0 || i == len(digest)-1 ||
+		strings.ContainsAny(digest, "/\\") {
+		return "", "", xerrors.Errorf("containerd: invalid digest %q", digest)
+	}
+	return digest[:i], digest[i+1:], nil
+}
+
+// readBlob reads the blob of the digest in the content store.
+func (d *Containerd) readBlob(digest string) ([]byte, error) {
+	algorithm, hex, err := splitDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(filepath.Join(d.root, contentPath, algorithm, hex))
+}
+
+// imageTargets returns the digests of the targets of the
+// images in the namespaces.
+func (d *Containerd) imageTargets() ([]string, error) {
+	namespaces, err := d.namespaceBuckets()
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, namespace := range namespaces {
+		images, err := namespace.Bucket.Bucket("images")
+		if err != nil {
+			return nil, err
+		}
+		if images == nil {
+			continue
+		}
+		if err := images.ForEach(func(e boltdb.Entry) error {
+			target, err := images.Open(e).Bucket("target")
+			if err != nil || target == nil {
+				return err
+			}
+			digest, err := target.Get("digest")
+			if err != nil {
+				return err
+			}
+			result = append(result, string(digest))
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// namespaceImageIDs returns the digests identifying the
+// images in the namespace, which are the digests of their
+// targets, manifests and configs.
+func (d *Containerd) namespaceImageIDs() (map[string]struct{}, error) {
+	targets, err := d.imageTargets()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]struct{})
+	visited := make(map[string]struct{})
+	for _, target := range targets {
+		result[target] = struct{}{}
+		if _, err := d.walkManifests(target, visited, 0, func(
+			digest string, manifest *imageV1.Manifest,
+		) (bool, error) {
+			result[digest] = struct{}{}
+			result[string(manifest.Config.Digest)] = struct{}{}
+			return false, nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// namespaceContainerIDs returns the IDs of the containers in
+// the namespace.
+func (d *Containerd) namespaceContainerIDs() (map[string]struct{}, error) {
+	namespaces, err := d.namespaceBuckets()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]struct{})
+	for _, namespace := range namespaces {
+		containers, err := namespace.Bucket.Bucket("containers")
+		if err != nil {
+			return nil, err
+		}
+		if containers == nil {
+			continue
+		}
+		if err := containers.ForEach(func(e boltdb.Entry) error {
+			if e.IsBucket() {
+				result[string(e.Key)] = struct{}{}
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// containsID returns whether the ID is in the set, where the
+// ID might be the hex of a digest without its algorithm.
+func containsID(ids map[string]struct{}, id string) bool {
+	if _, ok := ids[id]; ok {
+		return true
+	}
+	_, ok := ids["sha256:"+id]
+	return ok
+}
+
+// filterIDs keeps the IDs in the namespace, which are not
+// filtered when the namespace is unspecified.
+func (d *Containerd) filterIDs(
+	ids []string, namespaceIDs func() (map[string]struct{}, error),
+) ([]string, error) {
+	if d.namespace == "" {
+		return ids, nil
+	}
+	set, err := namespaceIDs()
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, id := range ids {
+		if containsID(set, id) {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+// checkID checks whether the ID is in the namespace.
+func (d *Containerd) checkID(
+	id string, namespaceIDs func() (map[string]struct{}, error),
+) error {
+	if d.namespace == "" {
+		return nil
+	}
+	set, err := namespaceIDs()
+	if err != nil {
+		return err
+	}
+	if !containsID(set, id) {
+		return xerrors.Errorf("containerd: %q not found in namespace %q",
+			id, d.namespace)
+	}
+	return nil
+}
+
+// unmarshalAny decodes the protobuf message of google.protobuf.Any,
+// whose type URL and value are the field 1 and 2.
+func unmarshalAny(data []byte) (*Any, error) {
+	result := &Any{}
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, xerrors.New("containerd: invalid protobuf tag")
+		}
+		data = data[n:]
+		field, wireType := tag>>3, tag&7
+		var value []byte
+		switch wireType {
+		case 0:
+			if _, n = binary.Uvarint(data); n <= 0 {
+				return nil, xerrors.New("containerd: invalid protobuf varint")
+			}
+			data = data[n:]
+			continue
+		case 2:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return nil, xerrors.New("containerd: invalid protobuf length")
+			}
+			value, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			return nil, xerrors.Errorf(
+				"containerd: unexpected protobuf wire type %d", wireType)
+		}
+		switch field {
+		case 1:
+			result.TypeURL = string(value)
+		case 2:
+			result.Value = append([]byte(nil), value...)
+		}
+	}
+	return result, nil
+}
+
+// containerConfig reads the record of the container in the
+// metadata store, along with the namespace it's found in.
+func (d *Containerd) containerConfig(id string) (*ContainerConfig, error) {
+	namespaces, err := d.namespaceBuckets()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		b         *boltdb.Bucket
+		namespace string
+	)
+	for _, ns := range namespaces {
+		if b, err = ns.Bucket.Bucket("containers"); err != nil {
+			return nil, err
+		}
+		if b == nil {
+			continue
+		}
+		if b, err = b.Bucket(id); err != nil {
+			return nil, err
+		}
+		if b != nil {
+			namespace = ns.name
+			break
+		}
+	}
+	if b == nil {
+		return nil, xerrors.Errorf(
+			"containerd: container %q not found in metadata", id)
+	}
+	result := &ContainerConfig{ID: id, Namespace: namespace}
+	values := map[string]*string{
+		"image":       &result.Image,
+		"snapshotKey": &result.SnapshotKey,
+		"snapshotter": &result.Snapshotter,
+		"sandboxid":   &result.SandboxID,
+	}
+	times := map[string]*time.Time{
+		"createdat": &result.CreatedAt,
+		"updatedat": &result.UpdatedAt,
+	}
+	if err := b.ForEach(func(e boltdb.Entry) error {
+		key := string(e.Key)
+		if e.IsBucket() {
+			return d.readContainerBucket(result, key, b.Open(e))
+		}
+		if v, ok := values[key]; ok {
+			*v = string(e.Value)
+			return nil
+		}
+		if v, ok := times[key]; ok {
+			if err := v.UnmarshalBinary(e.Value); err != nil {
+				return xerrors.Errorf("%s: %w", key, err)
+			}
+			return nil
+		}
+		if key != "spec" {
+			return nil
+		}
+		spec, err := unmarshalAny(e.Value)
+		if err != nil {
+			return xerrors.Errorf("spec: %w", err)
+		}
+		if err := json.Unmarshal(spec.Value, &result.Spec); err != nil {
+			return xerrors.Errorf("spec: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("containerd: container %q: %w", id, err)
+	}
+	return result, nil
+}
+
+// readContainerBucket reads the nested bucket in the record of
+// the container, which are the labels, runtime and extensions.
+func (d *Containerd) readContainerBucket(
+	result *ContainerConfig, key string, b *boltdb.Bucket,
+) error {
+	switch key {
+	case "labels":
+		result.Labels = make(map[string]string)
+		return b.ForEach(func(e boltdb.Entry) error {
+			if !e.IsBucket() {
+				result.Labels[string(e.Key)] = string(e.Value)
+			}
+			return nil
+		})
+	case "runtime":
+		return b.ForEach(func(e boltdb.Entry) error {
+			switch string(e.Key) {
+			case "name":
+				result.Runtime.Name = string(e.Value)
+			case "options":
+				options, err := unmarshalAny(e.Value)
+				if err != nil {
+					return xerrors.Errorf("runtime options: %w", err)
+				}
+				result.Runtime.Options = options
+			}
+			return nil
+		})
+	case "extensions":
+		result.Extensions = make(map[string]Any)
+		return b.ForEach(func(e boltdb.Entry) error {
+			if e.IsBucket() {
+				return nil
+			}
+			ext, err := unmarshalAny(e.Value)
+			if err != nil {
+				return xerrors.Errorf("extension %s: %w", e.Key, err)
+			}
+			result.Extensions[string(e.Key)] = *ext
+			return nil
+		})
+	}
+	return nil
+}
+
+// maxIndexDepth limits the nesting of the image indexes.
+const maxIndexDepth = 4
+
+// walkManifests visits the manifests under the descriptor of
+// the digest, which is either an index or a manifest, until fn
+// returns true. The blobs absent from the content store, which
+// are usually the ones of other platforms, are skipped.
+func (d *Containerd) walkManifests(
+	digest string, visited map[string]struct{}, depth int,
+	fn func(string, *imageV1.Manifest) (bool, error),
+) (bool, error) {
+	if _, ok := visited[digest]; ok || depth > maxIndexDepth {
+		return false, nil
+	}
+	visited[digest] = struct{}{}
+	data, err := d.readBlob(digest)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var content struct {
+		imageV1.Manifest
+		Manifests []imageV1.Descriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return false, nil
+	}
+	for _, m := range content.Manifests {
+		found, err := d.walkManifests(string(m.Digest), visited, depth+1, fn)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	if content.Config.Digest == "" {
+		return false, nil
+	}
+	return fn(digest, &content.Manifest)
+}
+
+// snapshot is a snapshot recorded in the metadata store.
+type snapshot struct {
+	snapshotter string
+	key         string
+	name        string
+	parent      string
+}
+
+// lookupSnapshot looks up the snapshot of the key in the
+// snapshots bucket of the namespace. The snapshot is looked up
+// in every snapshotter when the snapshotter is unspecified, and
+// nil is returned if it does not exist.
+func lookupSnapshot(
+	snapshots *boltdb.Bucket, snapshotter, key string,
+) (*snapshot, error) {
+	if snapshots == nil {
+		return nil, nil
+	}
+	var snapshotters []string
+	if snapshotter != "" {
+		snapshotters = []string{snapshotter}
+	} else if err := snapshots.ForEach(func(e boltdb.Entry) error {
+		if e.IsBucket() {
+			snapshotters = append(snapshotters, string(e.Key))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	for _, snapshotter := range snapshotters {
+		b, err := snapshots.Bucket(snapshotter)
+		if err != nil {
+			return nil, err
+		}
+		if b == nil {
+			continue
+		}
+		if b, err = b.Bucket(key); err != nil {
+			return nil, err
+		}
+		if b == nil {
+			continue
+		}
+		name, err := b.Get("name")
+		if err != nil {
+			return nil, err
+		}
+		parent, err := b.Get("parent")
+		if err != nil {
+			return nil, err
+		}
+		return &snapshot{
+			snapshotter: snapshotter,
+			key:         key,
+			name:        string(name),
+			parent:      string(parent),
+		}, nil
+	}
+	return nil, nil
+}
+
+// snapshotDir returns the directory of the snapshot in its
+// snapshotter, which is identified by the ID recorded in the
+// metadata store of the snapshotter.
+//
+// Only the snapshotters storing each snapshot in a directory,
+// like "overlayfs" and "native", are supported.
+func (d *Containerd) snapshotDir(s *snapshot) (string, error) {
+	root := filepath.Join(d.root, snapshotterPath+s.snapshotter)
+	db, err := boltdb.Open(filepath.Join(root, "metadata.db"))
+	if err != nil {
+		return "", err
+	}
+	b, err := db.Bucket("v1", "snapshots", s.name)
+	if err != nil {
+		return "", err
+	}
+	if b == nil {
+		return "", xerrors.Errorf(
+			"containerd: snapshot %q not found in %q", s.key, s.snapshotter)
+	}
+	value, err := b.Get("id")
+	if err != nil {
+		return "", err
+	}
+	id, n := binary.Uvarint(value)
+	if n <= 0 {
+		return "", xerrors.Errorf(
+			"containerd: invalid ID of snapshot %q", s.key)
+	}
+	dir := filepath.Join(root, "snapshots", strconv.FormatUint(id, 10))
+	switch s.snapshotter {
+	case "overlayfs":
+		dir = filepath.Join(dir, "fs")
+	case "native":
+	default:
+		return "", xerrors.Errorf(
+			"containerd: unsupported snapshotter %q", s.snapshotter)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
@@ -0,0 +1,39 @@
+package containerd
+
+import (
+	"os"
+	"syscall"
+)
+
+// opaqueXattrs are the xattrs marking the opaque directories,
+// where the latter one is used by the rootless overlay.
+var opaqueXattrs = []string{"trusted.overlay.opaque", "user.overlay.opaque"}
+
+// isOpaque returns whether the directory is an overlay opaque
+// directory.
+func isOpaque(p string) (bool, error) {
+	buf := make([]byte, 1)
+	for _, name := range opaqueXattrs {
+		n, err := syscall.Getxattr(p, name, buf)
+		switch err {
+		case nil:
+			if n == 1 && buf[0] == 'y' {
+				return true, nil
+			}
+		case syscall.ENODATA, syscall.ENOTSUP, syscall.ERANGE:
+		default:
+			return false, &os.PathError{Op: "getxattr", Path: p, Err: err}
+		}
+	}
+	return false, nil
+}
+
+// isWhiteout returns whether the file is an overlay whiteout,
+// which is a character device of number 0:0.
+func isWhiteout(info os.FileInfo) bool {
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Rdev == 0
+}
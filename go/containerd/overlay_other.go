@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package containerd
+
+import "os"
+
+// isOpaque returns false on the platforms other than linux,
+// where there's no overlay.
+func isOpaque(p string) (bool, error) {
+	return false, nil
+}
+
+// isWhiteout returns false on the platforms other than linux,
+// where there's no overlay.
+func isWhiteout(info os.FileInfo) bool {
+	return false
+}
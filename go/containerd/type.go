@@ -0,0 +1,139 @@
+package containerd
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/xerrors"
+)
+
+// Labels and extensions recorded by the CRI plugin.
+const (
+	LabelCRIKind      = "io.cri-containerd.kind"
+	LabelPodName      = "io.kubernetes.pod.name"
+	LabelPodNamespace = "io.kubernetes.pod.namespace"
+	LabelPodUID       = "io.kubernetes.pod.uid"
+	LabelContainer    = "io.kubernetes.container.name"
+
+	CRIKindContainer = "container"
+	CRIKindSandbox   = "sandbox"
+
+	ExtensionCRIContainer = "io.cri-containerd.container.metadata"
+	ExtensionCRISandbox   = "io.cri-containerd.sandbox.metadata"
+)
+
+// Any is a serialized protobuf message with its type URL.
+type Any struct {
+	TypeURL string `json:"type_url"`
+	Value   []byte `json:"value"`
+}
+
+// ContainerConfig reference for the container record in the
+// metadata of containerd runtime.
+type ContainerConfig struct {
+	ID        string            `json:"ID"`
+	Namespace string            `json:"Namespace"`
+	Labels    map[string]string `json:"Labels"`
+	Image     string            `json:"Image"`
+	Runtime   struct {
+		Name    string `json:"Name"`
+		Options *Any   `json:"Options"`
+	} `json:"Runtime"`
+	Spec        *specs.Spec    `json:"Spec"`
+	SnapshotKey string         `json:"SnapshotKey"`
+	Snapshotter string         `json:"Snapshotter"`
+	CreatedAt   time.Time      `json:"CreatedAt"`
+	UpdatedAt   time.Time      `json:"UpdatedAt"`
+	Extensions  map[string]Any `json:"Extensions"`
+	SandboxID   string         `json:"SandboxID"`
+}
+
+// CRIMetadata is the metadata of a container or a sandbox
+// in the CRI API.
+type CRIMetadata struct {
+	Name      string `json:"name"`
+	UID       string `json:"uid"`
+	Namespace string `json:"namespace"`
+	Attempt   uint32 `json:"attempt"`
+}
+
+// CRIContainerMetadata reference for the container metadata
+// recorded by the CRI plugin.
+type CRIContainerMetadata struct {
+	ID        string `json:"ID"`
+	Name      string `json:"Name"`
+	SandboxID string `json:"SandboxID"`
+	Config    *struct {
+		Metadata    CRIMetadata       `json:"metadata"`
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+		LogPath     string            `json:"log_path"`
+	} `json:"Config"`
+	ImageRef     string `json:"ImageRef"`
+	LogPath      string `json:"LogPath"`
+	StopSignal   string `json:"StopSignal"`
+	ProcessLabel string `json:"ProcessLabel"`
+}
+
+// CRISandboxMetadata reference for the pod sandbox metadata
+// recorded by the CRI plugin.
+type CRISandboxMetadata struct {
+	ID     string `json:"ID"`
+	Name   string `json:"Name"`
+	Config *struct {
+		Metadata     CRIMetadata       `json:"metadata"`
+		Hostname     string            `json:"hostname"`
+		LogDirectory string            `json:"log_directory"`
+		Labels       map[string]string `json:"labels"`
+		Annotations  map[string]string `json:"annotations"`
+	} `json:"Config"`
+	NetNSPath      string `json:"NetNSPath"`
+	IP             string `json:"IP"`
+	RuntimeHandler string `json:"RuntimeHandler"`
+}
+
+// versionedMetadata is the envelope of the CRI metadata.
+type versionedMetadata struct {
+	Version  string          `json:"Version"`
+	Metadata json.RawMessage `json:"Metadata"`
+}
+
+// extension decodes the CRI metadata in the extension, and
+// returns false if the extension is absent.
+func (c *ContainerConfig) extension(name string, v interface{}) (bool, error) {
+	ext, ok := c.Extensions[name]
+	if !ok {
+		return false, nil
+	}
+	var versioned versionedMetadata
+	if err := json.Unmarshal(ext.Value, &versioned); err != nil {
+		return false, xerrors.Errorf("%s: %w", name, err)
+	}
+	if err := json.Unmarshal(versioned.Metadata, v); err != nil {
+		return false, xerrors.Errorf("%s: %w", name, err)
+	}
+	return true, nil
+}
+
+// CRIContainerMetadata returns the metadata recorded by the
+// CRI plugin, which is nil if it is not a CRI container.
+func (c *ContainerConfig) CRIContainerMetadata() (*CRIContainerMetadata, error) {
+	var result CRIContainerMetadata
+	ok, err := c.extension(ExtensionCRIContainer, &result)
+	if !ok || err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CRISandboxMetadata returns the metadata recorded by the CRI
+// plugin, which is nil if it is not a CRI pod sandbox.
+func (c *ContainerConfig) CRISandboxMetadata() (*CRISandboxMetadata, error) {
+	var result CRISandboxMetadata
+	ok, err := c.extension(ExtensionCRISandbox, &result)
+	if !ok || err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
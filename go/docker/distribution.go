@@ -0,0 +1,109 @@
+package docker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultConfigPath  = "/etc/docker/daemon.json"
+	defaultDataRootDir = "/var/lib/docker"
+)
+
+// configDataRoot returns the data root specified in the config
+// of dockerd, or the default one if unspecified.
+func configDataRoot(path string) string {
+	if path == "" {
+		path = defaultConfigPath
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return defaultDataRootDir
+	}
+	var config struct {
+		DataRoot string `json:"data-root"`
+		Graph    string `json:"graph"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return defaultDataRootDir
+	}
+	if config.DataRoot != "" {
+		return config.DataRoot
+	}
+	if config.Graph != "" {
+		return config.Graph
+	}
+	return defaultDataRootDir
+}
+
+// v2Metadata is the record of the distribution of a layer,
+// which is written by dockerd when it is pulled or pushed.
+type v2Metadata struct {
+	Digest           string `json:"Digest"`
+	SourceRepository string `json:"SourceRepository"`
+}
+
+// layerDigest looks up the digest of the layer blob in the
+// distribution metadata of dockerd, where the one from the
+// repositories of the image is preferred, since the layer
+// might be compressed differently in other repositories.
+func (d *Docker) layerDigest(diffID string, repos []string) (string, error) {
+	algorithm, hex := "sha256", diffID
+	if i := strings.Index(diffID, ":"); i >= 0 {
+		algorithm, hex = diffID[:i], diffID[i+1:]
+	}
+	if strings.ContainsAny(algorithm+hex, "/\\") {
+		return "", xerrors.Errorf("docker: invalid diff ID %q", diffID)
+	}
+	matches, err := filepath.Glob(filepath.Join(d.dataRoot, "image", "*",
+		"distribution", "v2metadata-by-diffid", algorithm, hex))
+	if err != nil {
+		return "", err
+	}
+	var found string
+	for _, path := range matches {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", err
+		}
+		var records []v2Metadata
+		if err := json.Unmarshal(data, &records); err != nil {
+			return "", xerrors.Errorf("%s: %w", path, err)
+		}
+		for _, record := range records {
+			for _, repo := range repos {
+				if sameRepository(record.SourceRepository, repo) {
+					return record.Digest, nil
+				}
+			}
+			if found == "" {
+				found = record.Digest
+			}
+		}
+	}
+	if found == "" {
+		return "", xerrors.Errorf(
+			"docker: digest of layer %q unrecorded", diffID)
+	}
+	return found, nil
+}
+
+// sameRepository returns whether the repositories are the same
+// one, where the domain of docker hub is omitted in the names
+// of the image while recorded in the distribution metadata.
+func sameRepository(a, b string) bool {
+	return normalizeRepository(a) == normalizeRepository(b)
+}
+
+func normalizeRepository(repo string) string {
+	repo = strings.TrimPrefix(repo, "docker.io/")
+	return strings.TrimPrefix(repo, "library/")
+}
@@ -12,7 +12,9 @@ import (
 // newArgs is the internal state that a docker.NewOption can
 // manipulate for creating a new docker handle.
 type newArgs struct {
-	h binding.Handle
+	h          binding.Handle
+	configPath string
+	dataRoot   string
 }
 
 // NewOption is the option that can be used for initializing an
@@ -35,6 +37,7 @@ func WithConfigPath(path string) NewOption {
 		opt := binding.DockerWithConfigPath(path)
 		defer opt.Free()
 		opts.h.Append(opt)
+		opts.configPath = path
 	}
 }
 
@@ -48,9 +51,17 @@ func WithDataRootDir(path string) NewOption {
 		opt := binding.DockerWithDataRootDir(path)
 		defer opt.Free()
 		opts.h.Append(opt)
+		opts.dataRoot = path
 	}
 }
 
+// uniqueDesc is the unique descriptor carrying the data root
+// along with the descriptor of the binding.
+type uniqueDesc struct {
+	DataRoot   string `json:"dataRoot"`
+	UniqueDesc string `json:"uniqueDesc"`
+}
+
 // WithUniqueDesc specifies the unique descriptor of dockerd.
 //
 // This argument must be result of docker.(*Docker).UniqueDesc()
@@ -59,6 +70,12 @@ func WithDataRootDir(path string) NewOption {
 // runtime context has not been set up properly.
 func WithUniqueDesc(desc string) NewOption {
 	return func(opts *newArgs) {
+		var wrapped uniqueDesc
+		if err := json.Unmarshal([]byte(desc), &wrapped); err == nil &&
+			wrapped.UniqueDesc != "" {
+			desc = wrapped.UniqueDesc
+			opts.dataRoot = wrapped.DataRoot
+		}
 		opt := binding.DockerWithUniqueDesc(desc)
 		defer opt.Free()
 		opts.h.Append(opt)
@@ -70,7 +87,8 @@ type Docker struct {
 	behaviour.Closer
 	behaviour.Runtime
 	behaviour.FileSystem
-	runtime binding.Handle
+	runtime  binding.Handle
+	dataRoot string
 }
 
 // New a docker runtime object.
@@ -85,7 +103,10 @@ func New(opts ...NewOption) (api.Runtime, error) {
 	if err != nil {
 		return nil, err
 	}
-	result := &Docker{runtime: h}
+	result := &Docker{runtime: h, dataRoot: args.dataRoot}
+	if result.dataRoot == "" {
+		result.dataRoot = configDataRoot(args.configPath)
+	}
 	result.Closer = behaviour.NewCloser(&result.runtime)
 	result.Runtime = behaviour.NewRuntime(&result.runtime)
 	result.FileSystem = behaviour.NewFileSystem(&result.runtime)
@@ -96,7 +117,20 @@ func New(opts ...NewOption) (api.Runtime, error) {
 // arguments, which can be passed across process boundaries and
 // initialize the same docker in another process.
 func (d *Docker) UniqueDesc() string {
-	return d.runtime.DockerUniqueDesc()
+	desc := d.runtime.DockerUniqueDesc()
+	data, err := json.Marshal(uniqueDesc{
+		DataRoot:   d.dataRoot,
+		UniqueDesc: desc,
+	})
+	if err != nil {
+		return desc
+	}
+	return string(data)
+}
+
+// DataRootDir returns the data directory of dockerd.
+func (d *Docker) DataRootDir() string {
+	return d.dataRoot
 }
 
 // Image represents a docker image, which is guaranteed to be
@@ -118,6 +152,8 @@ type Container struct {
 	container binding.Handle
 }
 
+var _ api.LayeredImage = (*Image)(nil)
+
 func (d *Docker) OpenImageByID(id string) (api.Image, error) {
 	h, err := d.runtime.RuntimeOpenImageByID(id)
 	if err != nil {
@@ -155,6 +191,25 @@ func (im *Image) GetLayerDiffID(i int) (string, error) {
 	return im.image.DockerImageGetLayerDiffID(i)
 }
 
+func (im *Image) LayerDiffID(i int) (string, error) {
+	return im.image.DockerImageGetLayerDiffID(i)
+}
+
+// LayerDigest returns the digest of the layer blob recorded
+// by dockerd when the layer is pulled or pushed. The layers
+// built locally have no digest until they are pushed.
+func (im *Image) LayerDigest(i int) (string, error) {
+	diffID, err := im.LayerDiffID(i)
+	if err != nil {
+		return "", err
+	}
+	repos, err := im.Repos()
+	if err != nil {
+		return "", err
+	}
+	return im.runtime.layerDigest(diffID, repos)
+}
+
 func (c *Container) Runtime() *Docker {
 	return c.runtime
 }
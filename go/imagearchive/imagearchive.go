@@ -0,0 +1,432 @@
+// Package imagearchive is the API implementation on the image
+// archives produced by "docker save", "podman save" or exported
+// in the OCI archive format, which are read in place without
+// being loaded or extracted.
+//
+// The archive is indexed once when it is opened, and the blobs
+// inside are read randomly from the archive later. Uncompressed
+// layers are served directly from the archive, while the gzip
+// or zstd compressed ones are decompressed into temporary files
+// when they are opened. An archive compressed as a whole is
+// decompressed into a temporary file first.
+package imagearchive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"golang.org/x/xerrors"
+
+	api "github.com/chaitin/libveinmind/go"
+	"github.com/chaitin/libveinmind/go/pkg/reference"
+	"github.com/chaitin/libveinmind/go/pkg/tarfs"
+)
+
+const (
+	manifestFile = "manifest.json"
+	indexFile    = "index.json"
+
+	// annotationImageName is the full reference of the
+	// image annotated by containerd when exporting.
+	annotationImageName = "io.containerd.image.name"
+
+	// annotationReferenceType is the annotation marking
+	// attestations in the index by buildkit.
+	annotationReferenceType = "vnd.docker.reference.type"
+
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+var digestPattern = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+
+// NewOption is the option that can be used for initializing an
+// imagearchive.Archive object.
+type NewOption func(*Archive)
+
+// WithPath specifies the path of the archive file, which is
+// opened and closed with the archive.
+func WithPath(path string) NewOption {
+	return func(a *Archive) {
+		a.path = path
+	}
+}
+
+// WithReaderAt specifies the content of the archive, which
+// must be kept available until the archive is closed.
+//
+// Such an archive cannot be scanned by plugins, since they
+// could not open it again by path.
+func WithReaderAt(r io.ReaderAt, size int64) NewOption {
+	return func(a *Archive) {
+		a.reader, a.size = r, size
+	}
+}
+
+// WithTempDir specifies the directory of the temporary files
+// the compressed archive and layers are decompressed into,
+// which defaults to the directory returned by os.TempDir.
+func WithTempDir(dir string) NewOption {
+	return func(a *Archive) {
+		a.tempDir = dir
+	}
+}
+
+// layer is a layer blob of image in the archive.
+type layer struct {
+	path   string
+	digest string
+}
+
+// record is an image recorded in the archive.
+type record struct {
+	config imageV1.Image
+	layers []layer
+	names  []string
+}
+
+// Archive is the runtime over an image archive, where the
+// images are identified by the digest of their config.
+//
+// The archive has no containers, and it is read-only.
+type Archive struct {
+	path    string
+	file    *os.File
+	reader  io.ReaderAt
+	size    int64
+	tempDir string
+
+	index   *tarfs.Layer
+	ids     []string
+	records map[string]*record
+}
+
+func New(options ...NewOption) (api.Runtime, error) {
+	a := &Archive{records: make(map[string]*record)}
+	for _, opt := range options {
+		opt(a)
+	}
+	if a.path != "" {
+		f, err := os.Open(a.path)
+		if err != nil {
+			return nil, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		a.file, a.reader, a.size = f, f, info.Size()
+	}
+	if a.reader == nil {
+		return nil, errors.New("imagearchive: archive unspecified")
+	}
+	if err := a.load(); err != nil {
+		_ = a.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Archive) load() error {
+	index, err := tarfs.Open(a.reader, a.size, tarfs.WithTempDir(a.tempDir))
+	if err != nil {
+		return err
+	}
+	a.index = index
+	if _, err := index.Stat(manifestFile); err == nil {
+		return a.loadDockerManifest()
+	}
+	if _, err := index.Stat(imageV1.ImageLayoutFile); err == nil {
+		var layout imageV1.ImageLayout
+		if err := a.readJSON(imageV1.ImageLayoutFile, &layout); err != nil {
+			return err
+		}
+		if layout.Version != imageV1.ImageLayoutVersion {
+			return xerrors.Errorf(
+				"imagearchive: unsupported layout version %q", layout.Version)
+		}
+		var index imageV1.Index
+		if err := a.readJSON(indexFile, &index); err != nil {
+			return err
+		}
+		return a.loadIndex(index, nil)
+	}
+	return errors.New("imagearchive: unrecognized archive format")
+}
+
+// open the file in the archive, which is read in place.
+func (a *Archive) open(name string) (api.File, int64, error) {
+	f, err := a.index.Open(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+func (a *Archive) readFile(name string) ([]byte, error) {
+	f, _, err := a.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return ioutil.ReadAll(f)
+}
+
+func (a *Archive) readJSON(name string, v interface{}) error {
+	content, err := a.readFile(name)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return xerrors.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// blobPath returns the path of the blob in the OCI archive.
+func blobPath(d imageV1.Descriptor) (string, error) {
+	digest := string(d.Digest)
+	if !digestPattern.MatchString(digest) {
+		return "", xerrors.Errorf("imagearchive: invalid digest %q", digest)
+	}
+	i := strings.IndexByte(digest, ':')
+	return path.Join("blobs", digest[:i], digest[i+1:]), nil
+}
+
+func (a *Archive) readBlob(d imageV1.Descriptor, v interface{}) error {
+	name, err := blobPath(d)
+	if err != nil {
+		return err
+	}
+	return a.readJSON(name, v)
+}
+
+// addRecord adds the image whose config is the content, the
+// names are merged when the image has been added.
+func (a *Archive) addRecord(
+	config []byte, layers []layer, names []string,
+) error {
+	sum := sha256.Sum256(config)
+	id := "sha256:" + hex.EncodeToString(sum[:])
+	if r, ok := a.records[id]; ok {
+		r.names = appendNames(r.names, names...)
+		return nil
+	}
+	r := &record{layers: layers, names: appendNames(nil, names...)}
+	if err := json.Unmarshal(config, &r.config); err != nil {
+		return xerrors.Errorf("%s: %w", id, err)
+	}
+	a.records[id] = r
+	a.ids = append(a.ids, id)
+	return nil
+}
+
+// blobDigest returns the digest of the blob at the path in the
+// form of "blobs/<algorithm>/<hex>", or empty otherwise.
+func blobDigest(name string) string {
+	parts := strings.Split(path.Clean(name), "/")
+	if len(parts) != 3 || parts[0] != "blobs" {
+		return ""
+	}
+	digest := parts[1] + ":" + parts[2]
+	if !digestPattern.MatchString(digest) {
+		return ""
+	}
+	return digest
+}
+
+// loadDockerManifest loads the images in the manifest.json of
+// the archive produced by "docker save".
+func (a *Archive) loadDockerManifest() error {
+	var manifest []struct {
+		Config   string   `json:"Config"`
+		RepoTags []string `json:"RepoTags"`
+		Layers   []string `json:"Layers"`
+	}
+	if err := a.readJSON(manifestFile, &manifest); err != nil {
+		return err
+	}
+	for _, m := range manifest {
+		config, err := a.readFile(m.Config)
+		if err != nil {
+			return err
+		}
+		var layers []layer
+		for _, name := range m.Layers {
+			layers = append(layers, layer{
+				path: name, digest: blobDigest(name)})
+		}
+		if err := a.addRecord(config, layers, m.RepoTags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// descriptorNames returns the names annotated on descriptor,
+// the full reference annotated by containerd comes first.
+func descriptorNames(d imageV1.Descriptor) []string {
+	var result []string
+	for _, key := range []string{
+		annotationImageName, imageV1.AnnotationRefName,
+	} {
+		if name := d.Annotations[key]; name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// loadIndex loads the images in the index recursively, the
+// names of the nested index are inherited by its manifests.
+func (a *Archive) loadIndex(index imageV1.Index, names []string) error {
+	for _, d := range index.Manifests {
+		if _, ok := d.Annotations[annotationReferenceType]; ok {
+			continue
+		}
+		descNames := append(descriptorNames(d), names...)
+		switch d.MediaType {
+		case imageV1.MediaTypeImageIndex, mediaTypeDockerManifestList:
+			var nested imageV1.Index
+			if err := a.readBlob(d, &nested); err != nil {
+				return err
+			}
+			if err := a.loadIndex(nested, descNames); err != nil {
+				return err
+			}
+		case imageV1.MediaTypeImageManifest, mediaTypeDockerManifest:
+			if err := a.loadManifest(d, descNames); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *Archive) loadManifest(d imageV1.Descriptor, names []string) error {
+	var manifest imageV1.Manifest
+	if err := a.readBlob(d, &manifest); err != nil {
+		return err
+	}
+	configPath, err := blobPath(manifest.Config)
+	if err != nil {
+		return err
+	}
+	config, err := a.readFile(configPath)
+	if err != nil {
+		return err
+	}
+	var layers []layer
+	for _, l := range manifest.Layers {
+		name, err := blobPath(l)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, layer{path: name, digest: string(l.Digest)})
+	}
+	return a.addRecord(config, layers, names)
+}
+
+func appendNames(names []string, added ...string) []string {
+	for _, name := range added {
+		found := false
+		for _, existing := range names {
+			if existing == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Path returns the path of the archive, which is empty when
+// it is specified by WithReaderAt.
+func (a *Archive) Path() string {
+	return a.path
+}
+
+// TempDir returns the directory of the temporary files, which
+// is empty when unspecified.
+func (a *Archive) TempDir() string {
+	return a.tempDir
+}
+
+func (a *Archive) ListImageIDs() ([]string, error) {
+	return append([]string(nil), a.ids...), nil
+}
+
+func (a *Archive) FindImageIDs(pattern string) ([]string, error) {
+	if _, ok := a.records[pattern]; ok {
+		return []string{pattern}, nil
+	}
+	var result []string
+	for _, id := range a.ids {
+		r := a.records[id]
+		if reference.Match(pattern, id, repoRefs(r.names)) {
+			result = append(result, id)
+			continue
+		}
+		for _, name := range r.names {
+			if name == pattern {
+				result = append(result, id)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (a *Archive) OpenImageByID(id string) (api.Image, error) {
+	r, ok := a.records[id]
+	if !ok {
+		return nil, xerrors.Errorf("imagearchive: image %q not found", id)
+	}
+	return &Image{runtime: a, id: id, record: r}, nil
+}
+
+func (a *Archive) ListContainerIDs() ([]string, error) {
+	return nil, nil
+}
+
+func (a *Archive) FindContainerIDs(pattern string) ([]string, error) {
+	return nil, nil
+}
+
+func (a *Archive) OpenContainerByID(id string) (api.Container, error) {
+	return nil, errors.New("imagearchive: unsupported")
+}
+
+// Close the archive, and the file opened by WithPath.
+func (a *Archive) Close() error {
+	var result error
+	if a.index != nil {
+		result = a.index.Close()
+		a.index = nil
+	}
+	if a.file != nil {
+		if err := a.file.Close(); err != nil && result == nil {
+			result = err
+		}
+		a.file = nil
+	}
+	return result
+}
@@ -0,0 +1,283 @@
+package ocilayout
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/xerrors"
+
+	api "github.com/chaitin/libveinmind/go"
+	"github.com/chaitin/libveinmind/go/pkg/reference"
+	"github.com/chaitin/libveinmind/go/pkg/tarfs"
+)
+
+// repoRefs returns the normalized references of the names,
+// the names which are merely a tag are skipped.
+func repoRefs(names []string) []string {
+	var result []string
+	for _, name := range names {
+		if !strings.ContainsAny(name, "/:@") {
+			continue
+		}
+		ref, err := reference.Parse(name)
+		if err != nil {
+			continue
+		}
+		result = appendNames(result, ref.String())
+	}
+	return result
+}
+
+// Image represents an image in the OCI image layout.
+//
+// The layers are opened when the file system of the image is
+// visited for the first time, and they are kept open until
+// the image is closed, after which its file system reports
+// os.ErrClosed.
+type Image struct {
+	runtime *Layout
+	id      string
+	record  *record
+
+	mu     sync.Mutex
+	closed bool
+	err    error
+	fs     *tarfs.FS
+	layers []*Layer
+}
+
+type Layer struct {
+	*tarfs.Layer
+	file  *os.File
+	id    string
+	image *Image
+}
+
+func (i *Image) Runtime() *Layout {
+	return i.runtime
+}
+
+func (i *Image) ID() string {
+	return i.id
+}
+
+// Names returns the names of the image annotated in the index
+// of layout, which might be a tag without repository.
+func (i *Image) Names() []string {
+	return append([]string(nil), i.record.names...)
+}
+
+func (i *Image) Repos() ([]string, error) {
+	var result []string
+	for _, ref := range repoRefs(i.record.names) {
+		parsed, err := reference.Parse(ref)
+		if err != nil {
+			return nil, err
+		}
+		result = appendNames(result, parsed.Name())
+	}
+	return result, nil
+}
+
+func (i *Image) RepoRefs() ([]string, error) {
+	return repoRefs(i.record.names), nil
+}
+
+func (i *Image) OCISpecV1() (*imageV1.Image, error) {
+	config := i.record.config
+	return &config, nil
+}
+
+// Manifest returns the manifest of the image.
+func (i *Image) Manifest() imageV1.Manifest {
+	return i.record.manifest
+}
+
+func (i *Image) NumLayers() int {
+	return len(i.record.manifest.Layers)
+}
+
+func (i *Image) LayerDiffID(index int) (string, error) {
+	diffIDs := i.record.config.RootFS.DiffIDs
+	if index < 0 || index >= len(diffIDs) {
+		return "", xerrors.Errorf("ocilayout: layer %d out of range", index)
+	}
+	return string(diffIDs[index]), nil
+}
+
+func (i *Image) LayerDigest(index int) (string, error) {
+	layers := i.record.manifest.Layers
+	if index < 0 || index >= len(layers) {
+		return "", xerrors.Errorf("ocilayout: layer %d out of range", index)
+	}
+	return string(layers[index].Digest), nil
+}
+
+func (i *Image) openLayer(index int) (*Layer, error) {
+	layers := i.record.manifest.Layers
+	if index < 0 || index >= len(layers) {
+		return nil, xerrors.Errorf("ocilayout: layer %d out of range", index)
+	}
+	path, err := i.runtime.blobPath(layers[index])
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	layer, err := tarfs.Open(f, info.Size(),
+		tarfs.WithTempDir(i.runtime.tempDir))
+	if err != nil {
+		_ = f.Close()
+		return nil, xerrors.Errorf("%s: %w", path, err)
+	}
+	return &Layer{
+		Layer: layer,
+		file:  f,
+		id:    string(layers[index].Digest),
+		image: i,
+	}, nil
+}
+
+func (i *Image) OpenLayer(index int) (api.Layer, error) {
+	return i.openLayer(index)
+}
+
+// merged opens all layers and merges them on first call.
+func (i *Image) merged() (*tarfs.FS, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.closed {
+		return nil, os.ErrClosed
+	}
+	if i.fs != nil || i.err != nil {
+		return i.fs, i.err
+	}
+	var layers []*tarfs.Layer
+	for index := range i.record.manifest.Layers {
+		layer, err := i.openLayer(index)
+		if err != nil {
+			i.err = err
+			return nil, err
+		}
+		i.layers = append(i.layers, layer)
+		layers = append(layers, layer.Layer)
+	}
+	i.fs = tarfs.Merge(layers...)
+	return i.fs, nil
+}
+
+func (i *Image) Open(path string) (api.File, error) {
+	fs, err := i.merged()
+	if err != nil {
+		return nil, err
+	}
+	return fs.Open(path)
+}
+
+func (i *Image) Stat(path string) (os.FileInfo, error) {
+	fs, err := i.merged()
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(path)
+}
+
+func (i *Image) Lstat(path string) (os.FileInfo, error) {
+	fs, err := i.merged()
+	if err != nil {
+		return nil, err
+	}
+	return fs.Lstat(path)
+}
+
+func (i *Image) Readlink(path string) (string, error) {
+	fs, err := i.merged()
+	if err != nil {
+		return "", err
+	}
+	return fs.Readlink(path)
+}
+
+// Xattrs returns the extended attributes recorded in the
+// layers, so that they are preserved when the image is
+// exported by the archive package.
+func (i *Image) Xattrs(path string) (map[string]string, error) {
+	fs, err := i.merged()
+	if err != nil {
+		return nil, err
+	}
+	return fs.Xattrs(path)
+}
+
+func (i *Image) EvalSymlink(path string) (string, error) {
+	fs, err := i.merged()
+	if err != nil {
+		return "", err
+	}
+	return fs.EvalSymlink(path)
+}
+
+func (i *Image) Readdir(path string) ([]os.FileInfo, error) {
+	fs, err := i.merged()
+	if err != nil {
+		return nil, err
+	}
+	return fs.Readdir(path)
+}
+
+func (i *Image) Walk(root string, walkFn filepath.WalkFunc) error {
+	fs, err := i.merged()
+	if err != nil {
+		return err
+	}
+	return fs.Walk(root, walkFn)
+}
+
+func (i *Image) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.closed = true
+	i.fs, i.err = nil, nil
+	var result error
+	for _, layer := range i.layers {
+		if err := layer.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	i.layers = nil
+	return result
+}
+
+func (l *Layer) ID() string {
+	return l.id
+}
+
+func (l *Layer) Image() *Image {
+	return l.image
+}
+
+func (l *Layer) Opaques() ([]string, error) {
+	return l.Layer.Opaques(), nil
+}
+
+func (l *Layer) Whiteouts() ([]string, error) {
+	return l.Layer.Whiteouts(), nil
+}
+
+func (l *Layer) Close() error {
+	err := l.Layer.Close()
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
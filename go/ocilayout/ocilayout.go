@@ -0,0 +1,270 @@
+// Package ocilayout is the API implementation on the OCI image
+// layout directory, like the ones produced by "skopeo copy" or
+// "buildah push" to "oci:" destinations.
+//
+// It is implemented in pure Go and depends on nothing but the
+// directory, so it also works on builds without cgo.
+package ocilayout
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"golang.org/x/xerrors"
+
+	api "github.com/chaitin/libveinmind/go"
+	"github.com/chaitin/libveinmind/go/pkg/reference"
+)
+
+const (
+	indexFile = "index.json"
+
+	// annotationImageName is the full reference of the
+	// image annotated by containerd when exporting.
+	annotationImageName = "io.containerd.image.name"
+
+	// annotationReferenceType is the annotation marking
+	// attestations in the index by buildkit.
+	annotationReferenceType = "vnd.docker.reference.type"
+
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+var digestPattern = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+
+// NewOption is the option that can be used for initializing an
+// ocilayout.Layout object.
+type NewOption func(*Layout)
+
+// WithPath specifies the path of the image layout directory.
+func WithPath(path string) NewOption {
+	return func(l *Layout) {
+		l.path = path
+	}
+}
+
+// WithTempDir specifies the directory of the temporary files
+// the compressed layers are decompressed into, which defaults
+// to the directory returned by os.TempDir.
+func WithTempDir(dir string) NewOption {
+	return func(l *Layout) {
+		l.tempDir = dir
+	}
+}
+
+// record is an image recorded in the layout.
+type record struct {
+	manifest imageV1.Manifest
+	config   imageV1.Image
+	names    []string
+}
+
+// Layout is the runtime over an OCI image layout directory,
+// where the images are identified by their config digest.
+//
+// The layout has no containers, and it is read-only.
+type Layout struct {
+	path    string
+	tempDir string
+	ids     []string
+	records map[string]*record
+}
+
+func New(options ...NewOption) (api.Runtime, error) {
+	l := &Layout{records: make(map[string]*record)}
+	for _, opt := range options {
+		opt(l)
+	}
+	if l.path == "" {
+		return nil, errors.New("ocilayout: path unspecified")
+	}
+
+	var layout imageV1.ImageLayout
+	if err := l.readJSON(filepath.Join(
+		l.path, imageV1.ImageLayoutFile), &layout); err != nil {
+		return nil, err
+	}
+	if layout.Version != imageV1.ImageLayoutVersion {
+		return nil, xerrors.Errorf(
+			"ocilayout: unsupported layout version %q", layout.Version)
+	}
+	var index imageV1.Index
+	if err := l.readJSON(filepath.Join(l.path, indexFile), &index); err != nil {
+		return nil, err
+	}
+	if err := l.loadIndex(index, nil); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Layout) readJSON(path string, v interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return xerrors.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// blobPath returns the path of the blob in the layout.
+func (l *Layout) blobPath(d imageV1.Descriptor) (string, error) {
+	digest := string(d.Digest)
+	if !digestPattern.MatchString(digest) {
+		return "", xerrors.Errorf("ocilayout: invalid digest %q", digest)
+	}
+	i := strings.IndexByte(digest, ':')
+	return filepath.Join(l.path, "blobs", digest[:i], digest[i+1:]), nil
+}
+
+func (l *Layout) readBlob(d imageV1.Descriptor, v interface{}) error {
+	path, err := l.blobPath(d)
+	if err != nil {
+		return err
+	}
+	return l.readJSON(path, v)
+}
+
+// descriptorNames returns the names annotated on descriptor,
+// the full reference annotated by containerd comes first.
+func descriptorNames(d imageV1.Descriptor) []string {
+	var result []string
+	for _, key := range []string{
+		annotationImageName, imageV1.AnnotationRefName,
+	} {
+		if name := d.Annotations[key]; name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// loadIndex loads the images in the index recursively, the
+// names of the nested index are inherited by its manifests.
+func (l *Layout) loadIndex(index imageV1.Index, names []string) error {
+	for _, d := range index.Manifests {
+		if _, ok := d.Annotations[annotationReferenceType]; ok {
+			continue
+		}
+		descNames := append(descriptorNames(d), names...)
+		switch d.MediaType {
+		case imageV1.MediaTypeImageIndex, mediaTypeDockerManifestList:
+			var nested imageV1.Index
+			if err := l.readBlob(d, &nested); err != nil {
+				return err
+			}
+			if err := l.loadIndex(nested, descNames); err != nil {
+				return err
+			}
+		case imageV1.MediaTypeImageManifest, mediaTypeDockerManifest:
+			if err := l.loadManifest(d, descNames); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (l *Layout) loadManifest(d imageV1.Descriptor, names []string) error {
+	var manifest imageV1.Manifest
+	if err := l.readBlob(d, &manifest); err != nil {
+		return err
+	}
+	id := string(manifest.Config.Digest)
+	if r, ok := l.records[id]; ok {
+		r.names = appendNames(r.names, names...)
+		return nil
+	}
+	r := &record{manifest: manifest, names: appendNames(nil, names...)}
+	if err := l.readBlob(manifest.Config, &r.config); err != nil {
+		return err
+	}
+	l.records[id] = r
+	l.ids = append(l.ids, id)
+	return nil
+}
+
+func appendNames(names []string, added ...string) []string {
+	for _, name := range added {
+		found := false
+		for _, existing := range names {
+			if existing == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Path returns the path of the image layout directory.
+func (l *Layout) Path() string {
+	return l.path
+}
+
+// TempDir returns the directory of the temporary files, which
+// is empty when unspecified.
+func (l *Layout) TempDir() string {
+	return l.tempDir
+}
+
+func (l *Layout) ListImageIDs() ([]string, error) {
+	return append([]string(nil), l.ids...), nil
+}
+
+func (l *Layout) FindImageIDs(pattern string) ([]string, error) {
+	if _, ok := l.records[pattern]; ok {
+		return []string{pattern}, nil
+	}
+	var result []string
+	for _, id := range l.ids {
+		r := l.records[id]
+		if reference.Match(pattern, id, repoRefs(r.names)) {
+			result = append(result, id)
+			continue
+		}
+		for _, name := range r.names {
+			if name == pattern {
+				result = append(result, id)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (l *Layout) OpenImageByID(id string) (api.Image, error) {
+	r, ok := l.records[id]
+	if !ok {
+		return nil, xerrors.Errorf("ocilayout: image %q not found", id)
+	}
+	return &Image{runtime: l, id: id, record: r}, nil
+}
+
+func (l *Layout) ListContainerIDs() ([]string, error) {
+	return nil, nil
+}
+
+func (l *Layout) FindContainerIDs(pattern string) ([]string, error) {
+	return nil, nil
+}
+
+func (l *Layout) OpenContainerByID(id string) (api.Container, error) {
+	return nil, errors.New("ocilayout: unsupported")
+}
+
+func (l *Layout) Close() error {
+	return nil
+}
@@ -42,6 +42,30 @@ type Image interface {
 	OCISpecV1() (*imageV1.Image, error)
 }
 
+// LayeredImage is the image whose layers can be enumerated
+// and opened individually, regardless of the container
+// runtime it comes from.
+//
+// Layers are indexed from the bottom-most layer, which is
+// the same order as RootFS.DiffIDs in OCISpecV1().
+type LayeredImage interface {
+	Image
+
+	// NumLayers returns the number of layers in the image.
+	NumLayers() int
+
+	// OpenLayer attempt to open the layer at specified index.
+	OpenLayer(i int) (Layer, error)
+
+	// LayerDiffID returns the digest of the uncompressed
+	// content of the layer at specified index.
+	LayerDiffID(i int) (string, error)
+
+	// LayerDigest returns the digest of the layer blob at
+	// specified index, as is referred by the image manifest.
+	LayerDigest(i int) (string, error)
+}
+
 type Container interface {
 	FileSystem
 	Psutil
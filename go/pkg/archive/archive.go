@@ -0,0 +1,409 @@
+// Package archive serializes the file system of an image,
+// a layer or a container into a tar stream, so that it can
+// be handed over to external tools without the help of the
+// container runtime.
+package archive
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// Prefixes of the whiteout files in the layer tarball, which
+// is defined by the OCI image layer specification.
+const (
+	WhiteoutPrefix = ".wh."
+	WhiteoutOpaque = WhiteoutPrefix + WhiteoutPrefix + ".opq"
+)
+
+// XattrFileSystem is the file system that is able to provide
+// extended attributes of the files.
+//
+// The extended attributes are only exported when the file
+// system being exported implements this interface, which
+// includes the images of rootfs, ocilayout, imagearchive and
+// their layers, but not the ones of the native runtimes like
+// docker and containerd, whose files are read by libveinmind
+// without their extended attributes. Use WithXattrs to fail
+// rather than dropping them silently.
+type XattrFileSystem interface {
+	api.FileSystem
+	Xattrs(path string) (map[string]string, error)
+}
+
+// ErrXattrsUnsupported is reported when the extended
+// attributes are required by WithXattrs, but the file system
+// does not implement XattrFileSystem.
+var ErrXattrsUnsupported = xerrors.New(
+	"archive: extended attributes unsupported by file system")
+
+// Filter judges whether the entry should be exported. The
+// entry and its descendants are skipped when it returns false.
+// The info is nil when the entry is a whiteout.
+type Filter func(path string, info os.FileInfo) bool
+
+// fileStat is the unix specific attributes of a file.
+type fileStat struct {
+	uid, gid int
+	dev, ino uint64
+	nlink    uint64
+	rdev     uint64
+}
+
+type tarOption struct {
+	root          string
+	filters       []Filter
+	keepWhiteouts bool
+	xattrs        bool
+}
+
+// TarOption specifies how to export the file system.
+type TarOption func(*tarOption)
+
+// WithRoot specifies the directory to export, which defaults
+// to "/". Paths in the tarball will be relative to the root.
+func WithRoot(root string) TarOption {
+	return func(o *tarOption) {
+		o.root = root
+	}
+}
+
+// WithFilter specifies a filter of entries to export.
+//
+// Multiple filters can be specified and an entry is exported
+// only when all of them accept the entry.
+func WithFilter(f Filter) TarOption {
+	return func(o *tarOption) {
+		o.filters = append(o.filters, f)
+	}
+}
+
+// WithExcludes specifies the paths to skip while exporting,
+// and the descendants of the paths are also skipped, including
+// the whiteouts under them.
+func WithExcludes(paths ...string) TarOption {
+	excludes := make(map[string]struct{})
+	for _, p := range paths {
+		excludes[cleanPath(p)] = struct{}{}
+	}
+	return WithFilter(func(p string, _ os.FileInfo) bool {
+		// The whiteouts are not visited by walking, so their
+		// ancestors must be checked as well.
+		for {
+			if _, ok := excludes[p]; ok {
+				return false
+			}
+			if p == "/" {
+				return true
+			}
+			p = path.Dir(p)
+		}
+	})
+}
+
+// WithWhiteouts specifies the file system is an api.Layer,
+// and its whiteouts and opaque directories should also be
+// exported as whiteout files.
+//
+// The tarball produced is a valid layer tarball that can be
+// applied onto the lower layers. Exporting a file system that
+// is not an api.Layer with this option fails.
+func WithWhiteouts() TarOption {
+	return func(o *tarOption) {
+		o.keepWhiteouts = true
+	}
+}
+
+// WithXattrs requires the extended attributes to be exported,
+// and exporting a file system that is not a XattrFileSystem
+// fails with ErrXattrsUnsupported instead of dropping them.
+func WithXattrs() TarOption {
+	return func(o *tarOption) {
+		o.xattrs = true
+	}
+}
+
+func cleanPath(p string) string {
+	return path.Clean("/" + filepath.ToSlash(p))
+}
+
+// isParentOf judges whether the dir is a strict ancestor of
+// the specified path, both of them must have been cleaned.
+func isParentOf(dir, p string) bool {
+	if dir == "/" {
+		return p != "/"
+	}
+	return strings.HasPrefix(p, dir+"/")
+}
+
+type hardlinkKey struct {
+	dev, ino uint64
+}
+
+type exporter struct {
+	tarOption
+	fsys      api.FileSystem
+	tw        *tar.Writer
+	hardlinks map[hardlinkKey]string
+	whiteouts map[string]struct{}
+	opaques   map[string]struct{}
+}
+
+func (e *exporter) accept(p string, info os.FileInfo) bool {
+	for _, f := range e.filters {
+		if !f(p, info) {
+			return false
+		}
+	}
+	return true
+}
+
+// name converts the path into the name inside the tarball.
+func (e *exporter) name(p string) string {
+	rel := strings.TrimPrefix(p, e.root)
+	return strings.TrimPrefix(rel, "/")
+}
+
+func (e *exporter) header(p string, info os.FileInfo) (*tar.Header, error) {
+	hdr := &tar.Header{
+		Name:    e.name(p),
+		Mode:    int64(info.Mode().Perm()),
+		ModTime: info.ModTime(),
+	}
+	mode := info.Mode()
+	if mode&os.ModeSetuid != 0 {
+		hdr.Mode |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		hdr.Mode |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		hdr.Mode |= 01000
+	}
+	stat, hasStat := sysStat(info)
+	if hasStat {
+		hdr.Uid = stat.uid
+		hdr.Gid = stat.gid
+	}
+	switch {
+	case mode.IsRegular():
+		if hasStat && stat.nlink > 1 {
+			key := hardlinkKey{dev: stat.dev, ino: stat.ino}
+			if target, ok := e.hardlinks[key]; ok {
+				hdr.Typeflag = tar.TypeLink
+				hdr.Linkname = target
+				return hdr, nil
+			}
+			e.hardlinks[key] = hdr.Name
+		}
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = info.Size()
+	case mode.IsDir():
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += "/"
+	case mode&os.ModeSymlink != 0:
+		link, err := e.fsys.Readlink(p)
+		if err != nil {
+			return nil, err
+		}
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Linkname = link
+	case mode&os.ModeNamedPipe != 0:
+		hdr.Typeflag = tar.TypeFifo
+	case mode&os.ModeDevice != 0:
+		hdr.Typeflag = tar.TypeBlock
+		if mode&os.ModeCharDevice != 0 {
+			hdr.Typeflag = tar.TypeChar
+		}
+		if hasStat {
+			hdr.Devmajor = int64(major(stat.rdev))
+			hdr.Devminor = int64(minor(stat.rdev))
+		}
+	case mode&os.ModeSocket != 0:
+		return nil, nil
+	default:
+		return nil, xerrors.Errorf("unknown file type of %q", p)
+	}
+	if x, ok := e.fsys.(XattrFileSystem); ok {
+		xattrs, err := x.Xattrs(p)
+		if err != nil {
+			return nil, err
+		}
+		if len(xattrs) > 0 {
+			hdr.PAXRecords = make(map[string]string)
+			for k, v := range xattrs {
+				hdr.PAXRecords["SCHILY.xattr."+k] = v
+			}
+			hdr.Format = tar.FormatPAX
+		}
+	}
+	return hdr, nil
+}
+
+// major and minor decodes the device number in the same way
+// as the glibc does.
+func major(dev uint64) uint64 {
+	return ((dev >> 8) & 0xfff) | ((dev >> 32) & ^uint64(0xfff))
+}
+
+func minor(dev uint64) uint64 {
+	return (dev & 0xff) | ((dev >> 12) & ^uint64(0xff))
+}
+
+func (e *exporter) writeFile(p string, hdr *tar.Header) error {
+	if err := e.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if hdr.Typeflag != tar.TypeReg || hdr.Size == 0 {
+		return nil
+	}
+	f, err := e.fsys.Open(p)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.CopyN(e.tw, f, hdr.Size)
+	return err
+}
+
+// writeWhiteout writes an empty whiteout file to the tarball.
+func (e *exporter) writeWhiteout(p string, info os.FileInfo) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     e.name(p),
+		Mode:     0600,
+	}
+	if info != nil {
+		hdr.ModTime = info.ModTime()
+	}
+	return e.tw.WriteHeader(hdr)
+}
+
+func (e *exporter) walk(p string, info os.FileInfo, err error) error {
+	if err != nil {
+		return err
+	}
+	if p == e.root {
+		return nil
+	}
+	if !e.accept(p, info) {
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}
+	if _, ok := e.whiteouts[p]; ok {
+		// The whiteout might be represented as a character
+		// device in the layer, which will be written later.
+		return nil
+	}
+	hdr, err := e.header(p, info)
+	if err != nil {
+		return err
+	}
+	if hdr == nil {
+		return nil
+	}
+	if err := e.writeFile(p, hdr); err != nil {
+		return err
+	}
+	if _, ok := e.opaques[p]; ok {
+		return e.writeWhiteout(path.Join(p, WhiteoutOpaque), info)
+	}
+	return nil
+}
+
+func (e *exporter) loadWhiteouts() error {
+	layer, ok := e.fsys.(api.Layer)
+	if !ok {
+		return xerrors.New("whiteouts require an api.Layer")
+	}
+	whiteouts, err := layer.Whiteouts()
+	if err != nil {
+		return err
+	}
+	for _, w := range whiteouts {
+		e.whiteouts[cleanPath(w)] = struct{}{}
+	}
+	opaques, err := layer.Opaques()
+	if err != nil {
+		return err
+	}
+	for _, o := range opaques {
+		e.opaques[cleanPath(o)] = struct{}{}
+	}
+	return nil
+}
+
+func (e *exporter) writeWhiteouts() error {
+	var whiteouts []string
+	for w := range e.whiteouts {
+		whiteouts = append(whiteouts, w)
+	}
+	sort.Strings(whiteouts)
+	for _, w := range whiteouts {
+		if !isParentOf(e.root, w) {
+			continue
+		}
+		if !e.accept(w, nil) {
+			continue
+		}
+		dir, base := path.Split(w)
+		if err := e.writeWhiteout(path.Join(
+			dir, WhiteoutPrefix+base), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteTar serializes the file system into a tar stream.
+//
+// The modes, ownerships, symbolic links, hard links and
+// device numbers are preserved. Extended attributes are
+// preserved when the file system implements XattrFileSystem,
+// and dropped otherwise unless WithXattrs is specified.
+// Sockets are skipped since they cannot be archived.
+//
+// The tar footer is written after the file system has been
+// serialized, but the writer will not be closed.
+func WriteTar(
+	w io.Writer, fsys api.FileSystem, opts ...TarOption,
+) error {
+	e := &exporter{
+		tarOption: tarOption{root: "/"},
+		fsys:      fsys,
+		tw:        tar.NewWriter(w),
+		hardlinks: make(map[hardlinkKey]string),
+		whiteouts: make(map[string]struct{}),
+		opaques:   make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(&e.tarOption)
+	}
+	e.root = cleanPath(e.root)
+	if _, ok := fsys.(XattrFileSystem); e.xattrs && !ok {
+		return ErrXattrsUnsupported
+	}
+	if e.keepWhiteouts {
+		if err := e.loadWhiteouts(); err != nil {
+			return err
+		}
+	}
+	if err := fsys.Walk(e.root, e.walk); err != nil {
+		return err
+	}
+	if err := e.writeWhiteouts(); err != nil {
+		return err
+	}
+	return e.tw.Close()
+}
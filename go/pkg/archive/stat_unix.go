@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package archive
+
+import (
+	"os"
+	"syscall"
+)
+
+// sysStat extracts the unix specific attributes of the file.
+func sysStat(info os.FileInfo) (stat fileStat, ok bool) {
+	sys, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || sys == nil {
+		return stat, false
+	}
+	return fileStat{
+		uid:   int(sys.Uid),
+		gid:   int(sys.Gid),
+		dev:   uint64(sys.Dev),
+		ino:   uint64(sys.Ino),
+		nlink: uint64(sys.Nlink),
+		rdev:  uint64(sys.Rdev),
+	}, true
+}
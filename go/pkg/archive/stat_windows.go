@@ -0,0 +1,9 @@
+package archive
+
+import "os"
+
+// sysStat extracts the unix specific attributes of the file,
+// which is unavailable on windows.
+func sysStat(_ os.FileInfo) (stat fileStat, ok bool) {
+	return stat, false
+}
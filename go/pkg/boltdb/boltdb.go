@@ -0,0 +1,318 @@
+// Package boltdb is a read-only reader of the bolt database
+// files, like the metadata store of containerd and its
+// snapshotters, which is implemented in pure Go without
+// locking the database file.
+//
+// The whole file is read into the memory when it is opened,
+// and since bolt never overwrites the pages in use, the
+// snapshot is consistent as long as the meta page is intact.
+package boltdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/fnv"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"golang.org/x/xerrors"
+)
+
+const (
+	magic   = 0xED0CDAED
+	version = 2
+
+	pageHeaderSize = 16
+	elementSize    = 16
+	bucketSize     = 16
+
+	// metaSize is the size of the meta before its checksum.
+	metaSize = 56
+
+	branchPageFlag = 0x01
+	leafPageFlag   = 0x02
+	metaPageFlag   = 0x04
+
+	bucketLeafFlag = 0x01
+)
+
+// ErrCorrupted is returned when the database is malformed.
+var ErrCorrupted = errors.New("boltdb: database corrupted")
+
+// DB is the snapshot of a bolt database.
+type DB struct {
+	data     []byte
+	pageSize uint64
+	root     *Bucket
+}
+
+// Open reads the bolt database at the path.
+func Open(path string) (*DB, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Load(data)
+}
+
+// Load parses the bolt database in the data.
+func Load(data []byte) (*DB, error) {
+	db := &DB{data: data}
+	var (
+		txid  uint64
+		found bool
+	)
+	for _, offset := range db.metaOffsets() {
+		meta, ok := readMeta(data, offset)
+		if !ok || (found && meta.txid < txid) ||
+			meta.root >= uint64(len(data))/meta.pageSize {
+			continue
+		}
+		found, txid = true, meta.txid
+		db.pageSize = meta.pageSize
+		db.root = &Bucket{db: db, root: meta.root}
+	}
+	if !found {
+		return nil, xerrors.Errorf("%w: no valid meta page", ErrCorrupted)
+	}
+	return db, nil
+}
+
+// metaOffsets returns the offsets of both meta pages, where
+// the second one is located after the page size recorded in
+// the first one.
+func (db *DB) metaOffsets() []uint64 {
+	result := []uint64{0}
+	if meta, ok := readMeta(db.data, 0); ok {
+		return append(result, meta.pageSize)
+	}
+	// The first meta page might be torn, so the common page
+	// sizes are attempted for the second one.
+	for _, size := range []uint64{4096, 8192, 16384, 65536} {
+		if _, ok := readMeta(db.data, size); ok {
+			return append(result, size)
+		}
+	}
+	return result
+}
+
+type meta struct {
+	pageSize uint64
+	root     uint64
+	txid     uint64
+}
+
+// readMeta reads the meta page at the offset, which is
+// validated by its magic, version and checksum.
+func readMeta(data []byte, offset uint64) (meta, bool) {
+	start := offset + pageHeaderSize
+	if offset > uint64(len(data)) ||
+		uint64(len(data))-offset < pageHeaderSize+metaSize+8 {
+		return meta{}, false
+	}
+	flags := binary.LittleEndian.Uint16(data[offset+8:])
+	b := data[start : start+metaSize+8]
+	if flags&metaPageFlag == 0 ||
+		binary.LittleEndian.Uint32(b[0:]) != magic ||
+		binary.LittleEndian.Uint32(b[4:]) != version {
+		return meta{}, false
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(b[:metaSize])
+	if h.Sum64() != binary.LittleEndian.Uint64(b[metaSize:]) {
+		return meta{}, false
+	}
+	result := meta{
+		pageSize: uint64(binary.LittleEndian.Uint32(b[8:])),
+		root:     binary.LittleEndian.Uint64(b[16:]),
+		txid:     binary.LittleEndian.Uint64(b[48:]),
+	}
+	if result.pageSize < pageHeaderSize+metaSize+8 {
+		return meta{}, false
+	}
+	return result, true
+}
+
+// page returns the page with its overflow pages.
+func (db *DB) page(id uint64) ([]byte, error) {
+	if db.pageSize == 0 || id > uint64(len(db.data))/db.pageSize {
+		return nil, xerrors.Errorf("%w: page %d out of range", ErrCorrupted, id)
+	}
+	offset := id * db.pageSize
+	if uint64(len(db.data))-offset < pageHeaderSize {
+		return nil, xerrors.Errorf("%w: page %d out of range", ErrCorrupted, id)
+	}
+	overflow := uint64(binary.LittleEndian.Uint32(db.data[offset+12:]))
+	if overflow >= (uint64(len(db.data))-offset)/db.pageSize {
+		return nil, xerrors.Errorf("%w: page %d overflows", ErrCorrupted, id)
+	}
+	return db.data[offset : offset+(overflow+1)*db.pageSize], nil
+}
+
+// Bucket returns the bucket nested at the path of names from
+// the root, or nil if it does not exist.
+func (db *DB) Bucket(names ...string) (*Bucket, error) {
+	b := db.root
+	for _, name := range names {
+		var err error
+		if b, err = b.Bucket(name); err != nil || b == nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Bucket is a bucket in the database, whose pages are
+// either in the database or inlined in its parent.
+type Bucket struct {
+	db     *DB
+	root   uint64
+	inline []byte
+}
+
+// Entry is a key value pair in the bucket, and the value is
+// nil if the entry is a nested bucket.
+type Entry struct {
+	Key    []byte
+	Value  []byte
+	bucket []byte
+}
+
+// IsBucket returns whether the entry is a nested bucket.
+func (e Entry) IsBucket() bool {
+	return e.bucket != nil
+}
+
+// ForEach visits the entries in the bucket in the order of
+// their keys, until fn returns an error.
+func (b *Bucket) ForEach(fn func(Entry) error) error {
+	if b.root == 0 {
+		return b.forEachPage(b.inline, fn, 0)
+	}
+	return b.forEachPageID(b.root, fn, 0)
+}
+
+// maxDepth limits the depth of the branch pages, which
+// protects against the cycles in the corrupted database.
+const maxDepth = 64
+
+func (b *Bucket) forEachPageID(id uint64, fn func(Entry) error, depth int) error {
+	if depth > maxDepth {
+		return xerrors.Errorf("%w: branch too deep", ErrCorrupted)
+	}
+	page, err := b.db.page(id)
+	if err != nil {
+		return err
+	}
+	return b.forEachPage(page, fn, depth)
+}
+
+func (b *Bucket) forEachPage(page []byte, fn func(Entry) error, depth int) error {
+	if len(page) < pageHeaderSize {
+		return xerrors.Errorf("%w: page truncated", ErrCorrupted)
+	}
+	flags := binary.LittleEndian.Uint16(page[8:])
+	count := int(binary.LittleEndian.Uint16(page[10:]))
+	if pageHeaderSize+count*elementSize > len(page) {
+		return xerrors.Errorf("%w: elements truncated", ErrCorrupted)
+	}
+	for i := 0; i < count; i++ {
+		elem := pageHeaderSize + i*elementSize
+		e := page[elem : elem+elementSize]
+		switch {
+		case flags&branchPageFlag != 0:
+			if err := b.forEachPageID(binary.LittleEndian.Uint64(e[8:]),
+				fn, depth+1); err != nil {
+				return err
+			}
+		case flags&leafPageFlag != 0:
+			entry, err := leafEntry(page, elem, e)
+			if err != nil {
+				return err
+			}
+			if err := fn(entry); err != nil {
+				return err
+			}
+		default:
+			return xerrors.Errorf("%w: unexpected page flags %#x",
+				ErrCorrupted, flags)
+		}
+	}
+	return nil
+}
+
+// leafEntry decodes the leaf element at the offset elem of
+// the page, where the key and value are located relative to
+// the element.
+func leafEntry(page []byte, elem int, e []byte) (Entry, error) {
+	flags := binary.LittleEndian.Uint32(e[0:])
+	pos := uint64(elem) + uint64(binary.LittleEndian.Uint32(e[4:]))
+	ksize := uint64(binary.LittleEndian.Uint32(e[8:]))
+	vsize := uint64(binary.LittleEndian.Uint32(e[12:]))
+	if pos+ksize+vsize > uint64(len(page)) {
+		return Entry{}, xerrors.Errorf("%w: leaf truncated", ErrCorrupted)
+	}
+	key := page[pos : pos+ksize]
+	value := page[pos+ksize : pos+ksize+vsize]
+	if flags&bucketLeafFlag == 0 {
+		return Entry{Key: key, Value: value}, nil
+	}
+	if len(value) < bucketSize {
+		return Entry{}, xerrors.Errorf("%w: bucket truncated", ErrCorrupted)
+	}
+	return Entry{Key: key, bucket: value}, nil
+}
+
+// Bucket returns the nested bucket of the name, or nil if
+// it does not exist.
+func (b *Bucket) Bucket(name string) (*Bucket, error) {
+	var result *Bucket
+	err := b.ForEach(func(e Entry) error {
+		if !e.IsBucket() || !bytes.Equal(e.Key, []byte(name)) {
+			return nil
+		}
+		result = b.nested(e)
+		return errStop
+	})
+	if err != nil && err != errStop {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Open returns the nested bucket of the entry.
+func (b *Bucket) Open(e Entry) *Bucket {
+	if !e.IsBucket() {
+		return nil
+	}
+	return b.nested(e)
+}
+
+func (b *Bucket) nested(e Entry) *Bucket {
+	root := binary.LittleEndian.Uint64(e.bucket[0:])
+	result := &Bucket{db: b.db, root: root}
+	if root == 0 {
+		result.inline = e.bucket[bucketSize:]
+	}
+	return result
+}
+
+// Get returns the value of the key, or nil if it does not
+// exist or it is a nested bucket.
+func (b *Bucket) Get(key string) ([]byte, error) {
+	var result []byte
+	err := b.ForEach(func(e Entry) error {
+		if e.IsBucket() || !bytes.Equal(e.Key, []byte(key)) {
+			return nil
+		}
+		result = e.Value
+		return errStop
+	})
+	if err != nil && err != errStop {
+		return nil, err
+	}
+	return result, nil
+}
+
+// errStop stops the iteration once the entry is found.
+var errStop = errors.New("boltdb: stop")
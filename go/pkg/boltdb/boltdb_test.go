@@ -0,0 +1,231 @@
+package boltdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/fnv"
+	"strings"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+const testPageSize = 4096
+
+type testEntry struct {
+	key, value string
+	bucket     []byte
+}
+
+// testLeaf encodes the leaf page of the entries.
+func testLeaf(id uint64, entries []testEntry) []byte {
+	buf := make([]byte, pageHeaderSize+len(entries)*elementSize)
+	binary.LittleEndian.PutUint64(buf[0:], id)
+	binary.LittleEndian.PutUint16(buf[8:], leafPageFlag)
+	binary.LittleEndian.PutUint16(buf[10:], uint16(len(entries)))
+	for i, e := range entries {
+		elem := buf[pageHeaderSize+i*elementSize:]
+		value := []byte(e.value)
+		if e.bucket != nil {
+			binary.LittleEndian.PutUint32(elem[0:], bucketLeafFlag)
+			value = e.bucket
+		}
+		binary.LittleEndian.PutUint32(elem[4:],
+			uint32(len(buf)-(pageHeaderSize+i*elementSize)))
+		binary.LittleEndian.PutUint32(elem[8:], uint32(len(e.key)))
+		binary.LittleEndian.PutUint32(elem[12:], uint32(len(value)))
+		buf = append(buf, e.key...)
+		buf = append(buf, value...)
+	}
+	return buf
+}
+
+// testBranch encodes the branch page of the children.
+func testBranch(id uint64, keys []string, children []uint64) []byte {
+	buf := make([]byte, pageHeaderSize+len(keys)*elementSize)
+	binary.LittleEndian.PutUint64(buf[0:], id)
+	binary.LittleEndian.PutUint16(buf[8:], branchPageFlag)
+	binary.LittleEndian.PutUint16(buf[10:], uint16(len(keys)))
+	for i, key := range keys {
+		elem := buf[pageHeaderSize+i*elementSize:]
+		binary.LittleEndian.PutUint32(elem[0:],
+			uint32(len(buf)-(pageHeaderSize+i*elementSize)))
+		binary.LittleEndian.PutUint32(elem[4:], uint32(len(key)))
+		binary.LittleEndian.PutUint64(elem[8:], children[i])
+		buf = append(buf, key...)
+	}
+	return buf
+}
+
+// testBucket encodes the bucket header, followed by the page
+// when the bucket is inlined.
+func testBucket(root uint64, inline []byte) []byte {
+	buf := make([]byte, bucketSize)
+	binary.LittleEndian.PutUint64(buf[0:], root)
+	return append(buf, inline...)
+}
+
+// testMeta encodes the meta page.
+func testMeta(id, root, txid uint64) []byte {
+	buf := make([]byte, pageHeaderSize+metaSize+8)
+	binary.LittleEndian.PutUint64(buf[0:], id)
+	binary.LittleEndian.PutUint16(buf[8:], metaPageFlag)
+	m := buf[pageHeaderSize:]
+	binary.LittleEndian.PutUint32(m[0:], magic)
+	binary.LittleEndian.PutUint32(m[4:], version)
+	binary.LittleEndian.PutUint32(m[8:], testPageSize)
+	binary.LittleEndian.PutUint64(m[16:], root)
+	binary.LittleEndian.PutUint64(m[48:], txid)
+	h := fnv.New64a()
+	_, _ = h.Write(m[:metaSize])
+	binary.LittleEndian.PutUint64(m[metaSize:], h.Sum64())
+	return buf
+}
+
+// testDB lays out the pages, where the root bucket has a
+// nested bucket spanning a branch page, an inline bucket and
+// a value, and the value in the last page overflows.
+func testDB() []byte {
+	large := strings.Repeat("x", testPageSize+100)
+	pages := [][]byte{
+		testMeta(0, 2, 1),
+		testMeta(1, 3, 2),
+		testLeaf(2, nil),
+		testLeaf(3, []testEntry{
+			{key: "a", bucket: testBucket(4, nil)},
+			{key: "b", bucket: testBucket(0, testLeaf(0, []testEntry{
+				{key: "k", value: "v"},
+			}))},
+			{key: "c", value: "x"},
+		}),
+		testBranch(4, []string{"1", "2"}, []uint64{5, 6}),
+		testLeaf(5, []testEntry{{key: "1", value: "one"}}),
+		testLeaf(6, []testEntry{{key: "2", value: large}}),
+	}
+	var data []byte
+	for _, page := range pages {
+		padded := make([]byte, (len(page)+testPageSize-1)/
+			testPageSize*testPageSize)
+		copy(padded, page)
+		if n := len(padded) / testPageSize; n > 1 {
+			binary.LittleEndian.PutUint32(padded[12:], uint32(n-1))
+		}
+		data = append(data, padded...)
+	}
+	return data
+}
+
+func TestLoad(t *testing.T) {
+	db, err := Load(testDB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := db.root.Get("c"); err != nil || string(v) != "x" {
+		t.Fatalf("get c: %q %v", v, err)
+	}
+	b, err := db.Bucket("b")
+	if err != nil || b == nil {
+		t.Fatalf("bucket b: %v", err)
+	}
+	if v, err := b.Get("k"); err != nil || string(v) != "v" {
+		t.Fatalf("get b/k: %q %v", v, err)
+	}
+	a, err := db.Bucket("a")
+	if err != nil || a == nil {
+		t.Fatalf("bucket a: %v", err)
+	}
+	var keys []string
+	if err := a.ForEach(func(e Entry) error {
+		keys = append(keys, string(e.Key))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(keys, ",") != "1,2" {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	if v, err := a.Get("2"); err != nil || len(v) != testPageSize+100 {
+		t.Fatalf("get a/2: %d %v", len(v), err)
+	}
+	if b, err := db.Bucket("c"); err != nil || b != nil {
+		t.Fatalf("value opened as bucket: %v", err)
+	}
+	if b, err := db.Bucket("a", "missing"); err != nil || b != nil {
+		t.Fatalf("missing bucket: %v", err)
+	}
+}
+
+func TestLoadCorrupted(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		corrupt func([]byte) []byte
+	}{
+		{"truncated", func(b []byte) []byte { return b[:100] }},
+		{"checksum", func(b []byte) []byte {
+			b[pageHeaderSize+8]++
+			b[testPageSize+pageHeaderSize+8]++
+			return b
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Load(test.corrupt(testDB())); !xerrors.Is(
+				err, ErrCorrupted) {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+
+	for _, test := range []struct {
+		name    string
+		corrupt func([]byte)
+	}{
+		{"overflow", func(b []byte) {
+			binary.LittleEndian.PutUint32(b[6*testPageSize+12:], 100)
+		}},
+		{"count", func(b []byte) {
+			binary.LittleEndian.PutUint16(b[5*testPageSize+10:], 1000)
+		}},
+		{"child", func(b []byte) {
+			binary.LittleEndian.PutUint64(
+				b[4*testPageSize+pageHeaderSize+8:], 1<<40)
+		}},
+		{"cycle", func(b []byte) {
+			binary.LittleEndian.PutUint64(
+				b[4*testPageSize+pageHeaderSize+8:], 4)
+		}},
+		{"flags", func(b []byte) {
+			binary.LittleEndian.PutUint16(b[5*testPageSize+8:], 0x10)
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			data := testDB()
+			test.corrupt(data)
+			db, err := Load(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a, err := db.Bucket("a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = a.ForEach(func(Entry) error { return nil })
+			if !xerrors.Is(err, ErrCorrupted) {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadLatestMeta(t *testing.T) {
+	data := testDB()
+	// The stale meta page refers to the empty root, and is
+	// selected once the latest one is corrupted.
+	copy(data[testPageSize:], bytes.Repeat([]byte{0}, pageHeaderSize))
+	db, err := Load(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := db.Bucket("a"); err != nil || b != nil {
+		t.Fatalf("stale meta not selected: %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+// Package cri recognizes the containers created through the
+// container runtime interface of kubernetes, and groups them
+// by the pod sandbox they belong to.
+//
+// The pod of a container is derived from the labels and
+// annotations recorded by the CRI implementations in its OCI
+// spec, both the CRI plugin of containerd and CRI-O are
+// recognized.
+package cri
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// Kind is the kind of container in the CRI.
+type Kind string
+
+const (
+	KindSandbox   Kind = "sandbox"
+	KindContainer Kind = "container"
+)
+
+// Annotations recorded by the CRI plugin of containerd.
+const (
+	containerdType      = "io.kubernetes.cri.container-type"
+	containerdName      = "io.kubernetes.cri.container-name"
+	containerdSandboxID = "io.kubernetes.cri.sandbox-id"
+	containerdPodName   = "io.kubernetes.cri.sandbox-name"
+	containerdPodNS     = "io.kubernetes.cri.sandbox-namespace"
+	containerdPodUID    = "io.kubernetes.cri.sandbox-uid"
+)
+
+// Annotations recorded by CRI-O.
+const (
+	crioType        = "io.kubernetes.cri-o.ContainerType"
+	crioSandboxID   = "io.kubernetes.cri-o.SandboxID"
+	crioLabels      = "io.kubernetes.cri-o.Labels"
+	crioAnnotations = "io.kubernetes.cri-o.Annotations"
+	crioMetadata    = "io.kubernetes.cri-o.Metadata"
+)
+
+// Labels and annotations recorded by kubelet.
+const (
+	kubePodName       = "io.kubernetes.pod.name"
+	kubePodNamespace  = "io.kubernetes.pod.namespace"
+	kubePodUID        = "io.kubernetes.pod.uid"
+	kubeContainerName = "io.kubernetes.container.name"
+	kubeRestartCount  = "io.kubernetes.container.restartCount"
+)
+
+// Annotator is implemented by the containers recording their
+// CRI labels and annotations outside of the OCI spec, which
+// are merged with those in the OCI spec.
+type Annotator interface {
+	CRIAnnotations() (map[string]string, error)
+}
+
+// Attempter is implemented by the containers recording their
+// CRI metadata outside of the OCI spec, whose attempt is taken
+// as the restart count when kubelet annotates none. False is
+// returned when there's no CRI metadata.
+type Attempter interface {
+	CRIAttempt() (int, bool, error)
+}
+
+// Info is the CRI information of a container.
+type Info struct {
+	Kind Kind
+
+	// Name is the name of the container in the pod, which is
+	// empty for the sandbox.
+	Name string
+
+	PodName      string
+	PodNamespace string
+	PodUID       string
+
+	// SandboxID is the ID of the sandbox container of the pod,
+	// which is the ID of itself for the sandbox.
+	SandboxID string
+
+	// RestartCount is the number of times the container has
+	// been restarted by kubelet.
+	RestartCount int
+}
+
+// annotations collects the labels and annotations of the
+// container, where the JSON encoded ones of CRI-O are expanded.
+func annotations(c api.Container) (map[string]string, error) {
+	result := make(map[string]string)
+	spec, err := c.OCISpec()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range []string{crioLabels, crioAnnotations} {
+		var values map[string]string
+		if err := json.Unmarshal([]byte(
+			spec.Annotations[key]), &values); err == nil {
+			for k, v := range values {
+				result[k] = v
+			}
+		}
+	}
+	for k, v := range spec.Annotations {
+		result[k] = v
+	}
+	if annotator, ok := c.(Annotator); ok {
+		values, err := annotator.CRIAnnotations()
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range values {
+			if _, ok := result[k]; !ok {
+				result[k] = v
+			}
+		}
+	}
+	return result, nil
+}
+
+// first returns the first non-empty value of the keys.
+func first(values map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if value := values[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Inspect the CRI information of the container, false is
+// returned if it is not created through the CRI.
+func Inspect(c api.Container) (*Info, bool, error) {
+	values, err := annotations(c)
+	if err != nil {
+		return nil, false, err
+	}
+	kind := Kind(first(values, containerdType, crioType))
+	if kind != KindSandbox && kind != KindContainer {
+		return nil, false, nil
+	}
+	result := &Info{
+		Kind:         kind,
+		Name:         first(values, containerdName, kubeContainerName),
+		PodName:      first(values, containerdPodName, kubePodName),
+		PodNamespace: first(values, containerdPodNS, kubePodNamespace),
+		PodUID:       first(values, containerdPodUID, kubePodUID),
+		SandboxID:    first(values, containerdSandboxID, crioSandboxID),
+	}
+	if kind == KindSandbox {
+		result.Name = ""
+		if result.SandboxID == "" {
+			result.SandboxID = c.ID()
+		}
+	}
+	count, err := restartCount(c, values)
+	if err != nil {
+		return nil, false, err
+	}
+	result.RestartCount = count
+	return result, true, nil
+}
+
+// restartCount returns the restart count annotated by kubelet,
+// or the attempt in the CRI metadata of the container, which
+// is incremented by kubelet on each restart.
+func restartCount(c api.Container, values map[string]string) (int, error) {
+	if count, err := strconv.Atoi(values[kubeRestartCount]); err == nil {
+		return count, nil
+	}
+	var metadata struct {
+		Attempt int `json:"attempt"`
+	}
+	if err := json.Unmarshal([]byte(
+		values[crioMetadata]), &metadata); err == nil {
+		return metadata.Attempt, nil
+	}
+	if attempter, ok := c.(Attempter); ok {
+		attempt, _, err := attempter.CRIAttempt()
+		return attempt, err
+	}
+	return 0, nil
+}
+
+// Pod is the group of containers in the same pod sandbox.
+type Pod struct {
+	Name      string
+	Namespace string
+	UID       string
+	SandboxID string
+
+	// Sandbox is the sandbox container of the pod, which is nil
+	// when it is not in the containers grouped.
+	Sandbox api.Container
+
+	// Containers are the containers of the pod other than the
+	// sandbox container, in the order they are grouped.
+	Containers []api.Container
+
+	// Infos are the CRI information of the containers.
+	Infos []*Info
+}
+
+// Match whether the pod matches the pattern, which is either
+// the UID, the sandbox ID, the name or the name in the form of
+// "namespace/name".
+func (p *Pod) Match(pattern string) bool {
+	if pattern == "" {
+		return false
+	}
+	if i := strings.Index(pattern, "/"); i >= 0 {
+		return p.Namespace == pattern[:i] && p.Name == pattern[i+1:]
+	}
+	return pattern == p.UID || pattern == p.SandboxID || pattern == p.Name
+}
+
+// InspectError is the error of inspecting the container.
+type InspectError struct {
+	Container api.Container
+	Err       error
+}
+
+func (e *InspectError) Error() string {
+	return "inspect container " + e.Container.ID() + ": " + e.Err.Error()
+}
+
+func (e *InspectError) Unwrap() error {
+	return e.Err
+}
+
+// GroupError collects the errors of the containers skipped
+// while grouping.
+type GroupError struct {
+	Errors []*InspectError
+}
+
+func (e *GroupError) Error() string {
+	var messages []string
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Group the containers by their pod sandbox, the containers
+// not created through the CRI are omitted. The pods are sorted
+// by their namespace and name.
+//
+// The containers failed to be inspected are skipped, and the
+// pods of the others are still returned, along with the
+// *GroupError collecting the errors of the skipped ones.
+func Group(containers []api.Container) ([]*Pod, error) {
+	var result []*Pod
+	var errs []*InspectError
+	pods := make(map[string]*Pod)
+	for _, c := range containers {
+		info, ok, err := Inspect(c)
+		if err != nil {
+			errs = append(errs, &InspectError{Container: c, Err: err})
+			continue
+		}
+		if !ok {
+			continue
+		}
+		pod, ok := pods[info.SandboxID]
+		if !ok {
+			pod = &Pod{SandboxID: info.SandboxID}
+			pods[info.SandboxID] = pod
+			result = append(result, pod)
+		}
+		if pod.Name == "" {
+			pod.Name = info.PodName
+		}
+		if pod.Namespace == "" {
+			pod.Namespace = info.PodNamespace
+		}
+		if pod.UID == "" {
+			pod.UID = info.PodUID
+		}
+		if info.Kind == KindSandbox {
+			pod.Sandbox = c
+		} else {
+			pod.Containers = append(pod.Containers, c)
+			pod.Infos = append(pod.Infos, info)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Name < result[j].Name
+	})
+	if len(errs) > 0 {
+		return result, &GroupError{Errors: errs}
+	}
+	return result, nil
+}
@@ -0,0 +1,230 @@
+package cri
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// testContainer is the container with its annotations in the
+// OCI spec, and the CRI annotations recorded outside of it.
+type testContainer struct {
+	api.Container
+	id          string
+	annotations map[string]string
+	external    map[string]string
+	err         error
+}
+
+func (c *testContainer) ID() string {
+	return c.id
+}
+
+func (c *testContainer) OCISpec() (*specs.Spec, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &specs.Spec{Annotations: c.annotations}, nil
+}
+
+func (c *testContainer) CRIAnnotations() (map[string]string, error) {
+	return c.external, nil
+}
+
+// testAttemptContainer is the container with CRI metadata
+// recorded outside of the OCI spec.
+type testAttemptContainer struct {
+	testContainer
+	attempt int
+}
+
+func (c *testAttemptContainer) CRIAttempt() (int, bool, error) {
+	return c.attempt, true, nil
+}
+
+func TestInspect(t *testing.T) {
+	failed := errors.New("failed")
+	for _, c := range []struct {
+		name      string
+		container api.Container
+		want      *Info
+		err       error
+	}{{
+		name: "containerd sandbox",
+		container: &testContainer{id: "s1", annotations: map[string]string{
+			containerdType:    "sandbox",
+			containerdName:    "ignored",
+			containerdPodName: "web",
+			containerdPodNS:   "default",
+			containerdPodUID:  "uid1",
+		}},
+		want: &Info{
+			Kind: KindSandbox, PodName: "web", PodNamespace: "default",
+			PodUID: "uid1", SandboxID: "s1",
+		},
+	}, {
+		name: "containerd container",
+		container: &testContainer{id: "c1", annotations: map[string]string{
+			containerdType:      "container",
+			containerdName:      "nginx",
+			containerdSandboxID: "s1",
+			containerdPodName:   "web",
+			containerdPodNS:     "default",
+			kubeRestartCount:    "2",
+		}},
+		want: &Info{
+			Kind: KindContainer, Name: "nginx", PodName: "web",
+			PodNamespace: "default", SandboxID: "s1", RestartCount: 2,
+		},
+	}, {
+		name: "cri-o container",
+		container: &testContainer{id: "c2", annotations: map[string]string{
+			crioType:      "container",
+			crioSandboxID: "s2",
+			crioLabels: `{"io.kubernetes.pod.name":"db",` +
+				`"io.kubernetes.pod.namespace":"kube-system",` +
+				`"io.kubernetes.container.name":"redis"}`,
+			crioAnnotations: `{"io.kubernetes.pod.uid":"uid2"}`,
+			crioMetadata:    `{"name":"redis","attempt":3}`,
+		}},
+		want: &Info{
+			Kind: KindContainer, Name: "redis", PodName: "db",
+			PodNamespace: "kube-system", PodUID: "uid2", SandboxID: "s2",
+			RestartCount: 3,
+		},
+	}, {
+		name: "external annotations",
+		container: &testContainer{id: "c3", annotations: map[string]string{
+			containerdType:      "container",
+			containerdSandboxID: "s3",
+			kubePodName:         "spec",
+		}, external: map[string]string{
+			kubePodName:       "external",
+			kubeContainerName: "app",
+		}},
+		want: &Info{
+			Kind: KindContainer, Name: "app", PodName: "spec",
+			SandboxID: "s3",
+		},
+	}, {
+		name: "attempt",
+		container: &testAttemptContainer{testContainer: testContainer{
+			id: "c4", annotations: map[string]string{
+				containerdType:      "container",
+				containerdSandboxID: "s4",
+			},
+		}, attempt: 4},
+		want: &Info{Kind: KindContainer, SandboxID: "s4", RestartCount: 4},
+	}, {
+		name: "restart count over attempt",
+		container: &testAttemptContainer{testContainer: testContainer{
+			id: "c5", annotations: map[string]string{
+				containerdType:      "container",
+				containerdSandboxID: "s5",
+				kubeRestartCount:    "1",
+			},
+		}, attempt: 4},
+		want: &Info{Kind: KindContainer, SandboxID: "s5", RestartCount: 1},
+	}, {
+		name: "not cri",
+		container: &testContainer{id: "d1", annotations: map[string]string{
+			"org.opencontainers.image.title": "docker",
+		}},
+	}, {
+		name:      "spec error",
+		container: &testContainer{id: "e1", err: failed},
+		err:       failed,
+	}} {
+		t.Run(c.name, func(t *testing.T) {
+			info, ok, err := Inspect(c.container)
+			if err != c.err {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if ok != (c.want != nil) || (ok && !reflect.DeepEqual(info, c.want)) {
+				t.Fatalf("unexpected info %+v", info)
+			}
+		})
+	}
+}
+
+func TestGroup(t *testing.T) {
+	failed := errors.New("failed")
+	newContainer := func(id, kind, sandbox, pod, namespace string) api.Container {
+		return &testContainer{id: id, annotations: map[string]string{
+			containerdType:      kind,
+			containerdSandboxID: sandbox,
+			containerdPodName:   pod,
+			containerdPodNS:     namespace,
+		}}
+	}
+	containers := []api.Container{
+		newContainer("c1", "container", "s1", "web", "prod"),
+		newContainer("c2", "container", "s2", "", ""),
+		&testContainer{id: "d1"},
+		newContainer("s2", "sandbox", "", "api", "prod"),
+		&testContainer{id: "e1", err: failed},
+		newContainer("c3", "container", "s1", "web", "prod"),
+		newContainer("s3", "sandbox", "", "dns", "kube-system"),
+	}
+	pods, err := Group(containers)
+	var groupErr *GroupError
+	if !errors.As(err, &groupErr) || len(groupErr.Errors) != 1 ||
+		groupErr.Errors[0].Container.ID() != "e1" ||
+		!errors.Is(groupErr.Errors[0], failed) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err.Error() != "inspect container e1: failed" {
+		t.Fatalf("unexpected error %q", err)
+	}
+
+	var result []string
+	for _, pod := range pods {
+		var ids []string
+		for _, c := range pod.Containers {
+			ids = append(ids, c.ID())
+		}
+		sandbox := ""
+		if pod.Sandbox != nil {
+			sandbox = pod.Sandbox.ID()
+		}
+		if len(pod.Infos) != len(pod.Containers) {
+			t.Fatalf("unexpected infos %v", pod.Infos)
+		}
+		result = append(result, pod.Namespace+"/"+pod.Name+" "+
+			pod.SandboxID+" "+sandbox+" "+strings.Join(ids, ","))
+	}
+	want := []string{
+		"kube-system/dns s3 s3 ",
+		"prod/api s2 s2 c2",
+		"prod/web s1  c1,c3",
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Fatalf("unexpected pods %q, want %q", result, want)
+	}
+}
+
+func TestPodMatch(t *testing.T) {
+	pod := &Pod{Name: "web", Namespace: "prod", UID: "uid1", SandboxID: "s1"}
+	for _, c := range []struct {
+		pattern string
+		want    bool
+	}{
+		{"web", true},
+		{"prod/web", true},
+		{"uid1", true},
+		{"s1", true},
+		{"default/web", false},
+		{"prod/", false},
+		{"prod", false},
+		{"", false},
+	} {
+		if result := pod.Match(c.pattern); result != c.want {
+			t.Errorf("match %q: got %v, want %v", c.pattern, result, c.want)
+		}
+	}
+}
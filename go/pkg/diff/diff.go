@@ -0,0 +1,410 @@
+// Package diff compares the file systems of two objects and
+// reports the entries that have been added, removed or
+// modified from one to another.
+//
+// The typical use cases are comparing an image with its
+// running container to see what the container has changed,
+// or comparing two versions of the same repository to detect
+// drift between base images. Any api.FileSystem is accepted.
+//
+// The comparison is performed by walking both file systems
+// side by side in depth-first order, where the entries of each
+// directory are sorted by name, so only the listing of the
+// directories being visited are kept in memory.
+package diff
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// Kind is the kind of change to an entry.
+type Kind int
+
+const (
+	Added Kind = iota
+	Removed
+	Modified
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+// Field is the bit set of attributes that differ between
+// the old and new version of a modified entry.
+type Field uint32
+
+const (
+	FieldMode Field = 1 << iota
+	FieldOwner
+	FieldSize
+	FieldLinkname
+	FieldContent
+)
+
+// Has judges whether the specified fields are all set.
+func (f Field) Has(g Field) bool {
+	return f&g == g
+}
+
+func (f Field) String() string {
+	var result []string
+	for _, item := range []struct {
+		f    Field
+		name string
+	}{
+		{FieldMode, "mode"},
+		{FieldOwner, "owner"},
+		{FieldSize, "size"},
+		{FieldLinkname, "linkname"},
+		{FieldContent, "content"},
+	} {
+		if f.Has(item.f) {
+			result = append(result, item.name)
+		}
+	}
+	return strings.Join(result, ",")
+}
+
+// Entry is the attributes of a file system entry that are
+// taken into account while comparing.
+type Entry struct {
+	Info     os.FileInfo
+	Linkname string
+
+	// Digest is the hex encoded sha256 digest of a regular
+	// file, which is only calculated when WithContentHash
+	// is specified and the file needs to be compared.
+	Digest string
+}
+
+// Change is a difference found between two file systems.
+type Change struct {
+	Kind Kind
+	Path string
+
+	// Old is the entry in the old file system, which is nil
+	// when the entry is added.
+	Old *Entry
+
+	// New is the entry in the new file system, which is nil
+	// when the entry is removed.
+	New *Entry
+
+	// Fields are the attributes differ when the entry is
+	// modified, and is zero otherwise.
+	Fields Field
+}
+
+// Handler is the function to receive changes. Returning an
+// error aborts the comparison and the error is returned.
+type Handler func(Change) error
+
+// Filter judges whether the entry should be compared. The
+// entry and its descendants are skipped when it returns
+// false, and the info is from whichever side it exists.
+type Filter func(path string, info os.FileInfo) bool
+
+type diffOption struct {
+	root        string
+	contentHash bool
+	filters     []Filter
+}
+
+// Option specifies how to compare the file systems.
+type Option func(*diffOption)
+
+// WithRoot specifies the directory to start comparing with,
+// which defaults to "/".
+func WithRoot(root string) Option {
+	return func(o *diffOption) {
+		o.root = root
+	}
+}
+
+// WithContentHash specifies that the content of regular
+// files should be compared by their sha256 digest, when
+// their sizes are equal.
+//
+// Without this option, regular files with different content
+// but identical attributes will not be reported.
+func WithContentHash() Option {
+	return func(o *diffOption) {
+		o.contentHash = true
+	}
+}
+
+// WithFilter specifies a filter of entries to compare.
+//
+// Filters are useful to skip pseudo file systems like "/proc"
+// and "/sys" when comparing containers. Multiple filters can
+// be specified and an entry is compared only when all of them
+// accept the entry.
+func WithFilter(f Filter) Option {
+	return func(o *diffOption) {
+		o.filters = append(o.filters, f)
+	}
+}
+
+// WithExcludes specifies the paths to skip while comparing,
+// and the descendants of the paths are also skipped.
+func WithExcludes(paths ...string) Option {
+	var excludes []string
+	for _, path := range paths {
+		excludes = append(excludes, cleanPath(path))
+	}
+	return WithFilter(func(path string, _ os.FileInfo) bool {
+		for _, exclude := range excludes {
+			if path == exclude {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func cleanPath(path string) string {
+	return filepath.Clean(filepath.Join("/", path))
+}
+
+type differ struct {
+	diffOption
+	old, new api.FileSystem
+	handler  Handler
+}
+
+func (d *differ) accept(path string, info os.FileInfo) bool {
+	for _, f := range d.filters {
+		if !f(path, info) {
+			return false
+		}
+	}
+	return true
+}
+
+// readdir lists the directory sorted by name, and returns
+// empty listing when the entry is not a directory.
+func readdir(
+	fs api.FileSystem, path string, info os.FileInfo,
+) ([]os.FileInfo, error) {
+	if info == nil || !info.IsDir() {
+		return nil, nil
+	}
+	infos, err := fs.Readdir(path)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+	return infos, nil
+}
+
+func digest(fs api.FileSystem, path string) (string, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func newEntry(
+	fs api.FileSystem, path string, info os.FileInfo,
+) (*Entry, error) {
+	result := &Entry{Info: info}
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := fs.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		result.Linkname = link
+	}
+	return result, nil
+}
+
+// compare the entries existing in both file systems.
+func (d *differ) compare(path string, old, new *Entry) (Field, error) {
+	var fields Field
+	oldInfo, newInfo := old.Info, new.Info
+	if oldInfo.Mode() != newInfo.Mode() {
+		fields |= FieldMode
+	}
+	oldUID, oldGID, oldOK := owner(oldInfo)
+	newUID, newGID, newOK := owner(newInfo)
+	if oldOK && newOK && (oldUID != newUID || oldGID != newGID) {
+		fields |= FieldOwner
+	}
+	if oldInfo.Mode().IsRegular() && newInfo.Mode().IsRegular() {
+		if oldInfo.Size() != newInfo.Size() {
+			fields |= FieldSize
+		} else if d.contentHash {
+			var err error
+			if old.Digest, err = digest(d.old, path); err != nil {
+				return 0, err
+			}
+			if new.Digest, err = digest(d.new, path); err != nil {
+				return 0, err
+			}
+			if old.Digest != new.Digest {
+				fields |= FieldContent
+			}
+		}
+	}
+	if old.Linkname != new.Linkname {
+		fields |= FieldLinkname
+	}
+	return fields, nil
+}
+
+// walk compares the entry at path, and descends into the
+// entry if either side of it is a directory.
+func (d *differ) walk(path string, oldInfo, newInfo os.FileInfo) error {
+	change := Change{Path: path}
+	var err error
+	if oldInfo != nil {
+		if change.Old, err = newEntry(d.old, path, oldInfo); err != nil {
+			return err
+		}
+	}
+	if newInfo != nil {
+		if change.New, err = newEntry(d.new, path, newInfo); err != nil {
+			return err
+		}
+	}
+	switch {
+	case change.Old == nil:
+		change.Kind = Added
+	case change.New == nil:
+		change.Kind = Removed
+	default:
+		change.Kind = Modified
+		if change.Fields, err = d.compare(
+			path, change.Old, change.New); err != nil {
+			return err
+		}
+	}
+	if change.Kind != Modified || change.Fields != 0 {
+		if err := d.handler(change); err != nil {
+			return err
+		}
+	}
+	return d.walkDir(path, oldInfo, newInfo)
+}
+
+// walkDir merges the sorted listing of both directories.
+func (d *differ) walkDir(path string, oldInfo, newInfo os.FileInfo) error {
+	oldList, err := readdir(d.old, path, oldInfo)
+	if err != nil {
+		return err
+	}
+	newList, err := readdir(d.new, path, newInfo)
+	if err != nil {
+		return err
+	}
+	i, j := 0, 0
+	for i < len(oldList) || j < len(newList) {
+		var oldItem, newItem os.FileInfo
+		switch {
+		case j >= len(newList):
+			oldItem = oldList[i]
+			i++
+		case i >= len(oldList):
+			newItem = newList[j]
+			j++
+		case oldList[i].Name() < newList[j].Name():
+			oldItem = oldList[i]
+			i++
+		case oldList[i].Name() > newList[j].Name():
+			newItem = newList[j]
+			j++
+		default:
+			oldItem, newItem = oldList[i], newList[j]
+			i++
+			j++
+		}
+		info := oldItem
+		if info == nil {
+			info = newItem
+		}
+		name := filepath.Join(path, info.Name())
+		if !d.accept(name, info) {
+			continue
+		}
+		if err := d.walk(name, oldItem, newItem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Diff compares the old file system against the new one,
+// and invokes the handler for each change found.
+//
+// Changes are reported in depth-first order, where the paths
+// are compared component by component, so a directory is
+// reported right before its descendants, for example "/a",
+// "/a/b" and then "/a.txt", which is not the lexical order of
+// the whole paths. When a whole directory is added or
+// removed, each of its descendants is also reported.
+func Diff(
+	old, new api.FileSystem, handler Handler, opts ...Option,
+) error {
+	d := &differ{
+		diffOption: diffOption{root: "/"},
+		old:        old,
+		new:        new,
+		handler:    handler,
+	}
+	for _, opt := range opts {
+		opt(&d.diffOption)
+	}
+	root := cleanPath(d.root)
+	oldInfo, err := old.Lstat(root)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	newInfo, err := new.Lstat(root)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if oldInfo == nil && newInfo == nil {
+		return nil
+	}
+	return d.walk(root, oldInfo, newInfo)
+}
+
+// DiffContainer compares the container with the image it is
+// created from, which must be opened from the runtime.
+func DiffContainer(
+	runtime api.Runtime, container api.Container,
+	handler Handler, opts ...Option,
+) error {
+	image, err := runtime.OpenImageByID(container.ImageID())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = image.Close() }()
+	return Diff(image, container, handler, opts...)
+}
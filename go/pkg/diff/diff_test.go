@@ -0,0 +1,169 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/chaitin/libveinmind/go/pkg/iofs"
+)
+
+func TestDiff(t *testing.T) {
+	base := fstest.MapFS{
+		"etc/hostname":   {Data: []byte("old"), Mode: 0644},
+		"etc/passwd":     {Data: []byte("root"), Mode: 0644},
+		"usr/bin/sh":     {Data: []byte("sh"), Mode: 0755},
+		"usr/lib/a.so":   {Data: []byte("a"), Mode: 0644},
+		"var/log/a.log":  {Data: []byte("a"), Mode: 0644},
+		"var/log/b.log":  {Data: []byte("b"), Mode: 0644},
+		"var/cache/data": {Data: []byte("cache"), Mode: 0644},
+	}
+	modify := func(f func(fstest.MapFS)) fstest.MapFS {
+		result := make(fstest.MapFS)
+		for name, file := range base {
+			copied := *file
+			result[name] = &copied
+		}
+		f(result)
+		return result
+	}
+	for _, c := range []struct {
+		name string
+		new  fstest.MapFS
+		opts []Option
+		want []string
+	}{{
+		name: "identical",
+		new:  modify(func(fstest.MapFS) {}),
+	}, {
+		name: "attributes",
+		new: modify(func(m fstest.MapFS) {
+			m["etc/passwd"].Data = []byte("root:x")
+			m["usr/bin/sh"].Mode = 0700
+		}),
+		want: []string{
+			"modified /etc/passwd size",
+			"modified /usr/bin/sh mode",
+		},
+	}, {
+		name: "content",
+		new: modify(func(m fstest.MapFS) {
+			m["etc/hostname"].Data = []byte("new")
+		}),
+		opts: []Option{WithContentHash()},
+		want: []string{"modified /etc/hostname content"},
+	}, {
+		name: "content ignored",
+		new: modify(func(m fstest.MapFS) {
+			m["etc/hostname"].Data = []byte("new")
+		}),
+	}, {
+		// The paths are ordered component by component, so
+		// "/usr/lib/b.so" is reported before "/usr/lib.old".
+		name: "added and removed",
+		new: modify(func(m fstest.MapFS) {
+			delete(m, "var/log/a.log")
+			delete(m, "var/log/b.log")
+			m["usr/lib.old"] = &fstest.MapFile{Data: []byte("x")}
+			m["usr/lib/b.so"] = &fstest.MapFile{Data: []byte("b")}
+		}),
+		want: []string{
+			"added /usr/lib/b.so",
+			"added /usr/lib.old",
+			"removed /var/log",
+			"removed /var/log/a.log",
+			"removed /var/log/b.log",
+		},
+	}, {
+		name: "directory replaced",
+		new: modify(func(m fstest.MapFS) {
+			delete(m, "usr/lib/a.so")
+			m["usr/lib"] = &fstest.MapFile{Data: []byte("lib")}
+		}),
+		want: []string{
+			"modified /usr/lib mode",
+			"removed /usr/lib/a.so",
+		},
+	}, {
+		name: "excludes",
+		new: modify(func(m fstest.MapFS) {
+			m["var/cache/data"].Data = nil
+			m["var/log/c.log"] = &fstest.MapFile{Data: []byte("c")}
+		}),
+		opts: []Option{WithExcludes("/var/cache")},
+		want: []string{"added /var/log/c.log"},
+	}, {
+		name: "filter",
+		new: modify(func(m fstest.MapFS) {
+			m["etc/passwd"].Data = nil
+			m["var/log/c.log"] = &fstest.MapFile{Data: []byte("c")}
+		}),
+		opts: []Option{WithFilter(func(path string, _ os.FileInfo) bool {
+			return !strings.HasSuffix(path, ".log")
+		})},
+		want: []string{"modified /etc/passwd size"},
+	}, {
+		name: "root",
+		new: modify(func(m fstest.MapFS) {
+			m["etc/passwd"].Data = nil
+			m["var/log/c.log"] = &fstest.MapFile{Data: []byte("c")}
+		}),
+		opts: []Option{WithRoot("var")},
+		want: []string{"added /var/log/c.log"},
+	}} {
+		t.Run(c.name, func(t *testing.T) {
+			var result []string
+			if err := Diff(iofs.NewFileSystem(base),
+				iofs.NewFileSystem(c.new), func(change Change) error {
+					item := change.Kind.String() + " " + change.Path
+					if change.Fields != 0 {
+						item += " " + change.Fields.String()
+					}
+					result = append(result, item)
+					return nil
+				}, c.opts...); err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(result) != fmt.Sprint(c.want) {
+				t.Fatalf("unexpected changes %q, want %q", result, c.want)
+			}
+		})
+	}
+}
+
+func TestDiffHandlerError(t *testing.T) {
+	old := fstest.MapFS{"a": {Data: []byte("a")}}
+	new := fstest.MapFS{"b": {Data: []byte("b")}, "c": {Data: []byte("c")}}
+	aborted := errors.New("aborted")
+	var changes int
+	err := Diff(iofs.NewFileSystem(old), iofs.NewFileSystem(new),
+		func(Change) error {
+			changes++
+			return aborted
+		})
+	if err != aborted || changes != 1 {
+		t.Fatalf("unexpected error %v after %d changes", err, changes)
+	}
+}
+
+func TestFieldString(t *testing.T) {
+	for _, c := range []struct {
+		field Field
+		want  string
+	}{
+		{0, ""},
+		{FieldMode, "mode"},
+		{FieldMode | FieldContent, "mode,content"},
+		{FieldOwner | FieldSize | FieldLinkname, "owner,size,linkname"},
+	} {
+		if result := c.field.String(); result != c.want {
+			t.Errorf("field %d: got %q, want %q", c.field, result, c.want)
+		}
+	}
+	if !(FieldMode | FieldSize).Has(FieldSize) || FieldMode.Has(FieldSize) {
+		t.Fatal("unexpected field set")
+	}
+}
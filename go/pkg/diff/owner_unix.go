@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package diff
+
+import (
+	"os"
+	"syscall"
+)
+
+// owner returns the uid and gid recorded in the file info.
+func owner(info os.FileInfo) (uid, gid int, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat == nil {
+		return 0, 0, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
@@ -0,0 +1,318 @@
+// Package discovery recognizes the container runtimes on the
+// host, by their running daemons, config files and data roots.
+//
+// The host file system is visited through the vfs package, so
+// the runtimes will be recognized under the host root file
+// system specified by LIBVEINMIND_HOST_ROOTFS. The paths
+// recognized are always the ones on the host, which are the
+// same as those specified to the runtime daemons.
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chaitin/libveinmind/go/pkg/vfs"
+)
+
+// Kind is the kind of container runtime.
+type Kind string
+
+const (
+	Docker     Kind = "docker"
+	Containerd Kind = "containerd"
+	CRIO       Kind = "cri-o"
+	Podman     Kind = "podman"
+)
+
+// Runtime is a container runtime recognized on the host.
+type Runtime struct {
+	Kind Kind
+
+	// ConfigPath is the config file of the runtime, which is
+	// empty when there's none.
+	ConfigPath string
+
+	// RootDir is the data root of the runtime, for CRI-O and
+	// podman it is the graph root of containers/storage.
+	RootDir string
+}
+
+// Default paths of the runtimes.
+const (
+	dockerConfig      = "/etc/docker/daemon.json"
+	dockerRoot        = "/var/lib/docker"
+	containerdConfig  = "/etc/containerd/config.toml"
+	containerdRoot    = "/var/lib/containerd"
+	crioConfig        = "/etc/crio/crio.conf"
+	storageConfig     = "/etc/containers/storage.conf"
+	storageRoot       = "/var/lib/containers/storage"
+	containerdMetaDir = "io.containerd.metadata.v1.bolt"
+)
+
+var podmanBinaries = []string{
+	"/usr/bin/podman", "/usr/local/bin/podman",
+}
+
+// daemon is the flags of a running daemon.
+type daemon struct {
+	kind   Kind
+	config string
+	root   string
+}
+
+// daemonFlags are the flags specifying the config file and
+// data root of each daemon, indexed by the executable name.
+var daemonFlags = map[string]struct {
+	kind   Kind
+	config []string
+	root   []string
+}{
+	"dockerd":    {Docker, []string{"--config-file"}, []string{"--data-root", "-g", "--graph"}},
+	"containerd": {Containerd, []string{"--config", "-c"}, []string{"--root"}},
+	"crio":       {CRIO, []string{"--config"}, []string{"--root", "-r"}},
+}
+
+// flagValue returns the value of flag in the command line,
+// in the form of "--flag value" or "--flag=value".
+func flagValue(args []string, names []string) string {
+	result := ""
+	for i := 0; i < len(args); i++ {
+		for _, name := range names {
+			if args[i] == name && i+1 < len(args) {
+				result = args[i+1]
+			} else if strings.HasPrefix(args[i], name+"=") {
+				result = strings.TrimPrefix(args[i], name+"=")
+			}
+		}
+	}
+	return result
+}
+
+// daemons recognizes the running daemons in the procfs, it is
+// fine when the procfs of host is not accessible.
+func daemons() []daemon {
+	entries, err := vfs.Readdir("/proc")
+	if err != nil {
+		return nil
+	}
+	var result []daemon
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		content, err := readFile(filepath.Join(
+			"/proc", entry.Name(), "cmdline"))
+		if err != nil || len(content) == 0 {
+			continue
+		}
+		args := strings.Split(strings.TrimRight(
+			string(content), "\x00"), "\x00")
+		flags, ok := daemonFlags[filepath.Base(args[0])]
+		if !ok {
+			continue
+		}
+		result = append(result, daemon{
+			kind:   flags.kind,
+			config: flagValue(args[1:], flags.config),
+			root:   flagValue(args[1:], flags.root),
+		})
+	}
+	return result
+}
+
+func readFile(path string) ([]byte, error) {
+	f, err := vfs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return ioutil.ReadAll(f)
+}
+
+func exists(path string) bool {
+	_, err := vfs.Stat(path)
+	return err == nil
+}
+
+var (
+	tomlTable  = regexp.MustCompile(`^\s*\[\s*([^\]]*?)\s*\]`)
+	tomlString = regexp.MustCompile(`^\s*([A-Za-z0-9_-]+)\s*=\s*"((?:[^"\\]|\\.)*)"`)
+)
+
+// tomlValue retrieves the string value of the key in the table
+// of a toml config, where the empty table stands for the top
+// level keys. It is sufficient for the simple configs of
+// the runtimes, and the value is empty when it's not found.
+func tomlValue(content []byte, table, key string) string {
+	current := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := tomlTable.FindStringSubmatch(line); match != nil {
+			current = strings.Trim(match[1], `"`)
+			continue
+		}
+		if current != table {
+			continue
+		}
+		if match := tomlString.FindStringSubmatch(line); match != nil &&
+			match[1] == key {
+			if value, err := strconv.Unquote(`"` + match[2] + `"`); err == nil {
+				return value
+			}
+			return match[2]
+		}
+	}
+	return ""
+}
+
+func discoverDocker(d daemon) (Runtime, bool) {
+	result := Runtime{Kind: Docker, ConfigPath: d.config, RootDir: d.root}
+	config := result.ConfigPath
+	if config == "" {
+		config = dockerConfig
+	}
+	if content, err := readFile(config); err == nil {
+		result.ConfigPath = config
+		var daemonConfig struct {
+			DataRoot string `json:"data-root"`
+			Graph    string `json:"graph"`
+		}
+		if err := json.Unmarshal(content, &daemonConfig); err == nil &&
+			result.RootDir == "" {
+			result.RootDir = daemonConfig.DataRoot
+			if result.RootDir == "" {
+				result.RootDir = daemonConfig.Graph
+			}
+		}
+	} else if result.ConfigPath != "" {
+		return result, false
+	}
+	if result.RootDir == "" {
+		result.RootDir = dockerRoot
+	}
+	return result, exists(filepath.Join(result.RootDir, "image"))
+}
+
+func discoverContainerd(d daemon) (Runtime, bool) {
+	result := Runtime{Kind: Containerd, ConfigPath: d.config, RootDir: d.root}
+	config := result.ConfigPath
+	if config == "" {
+		config = containerdConfig
+	}
+	if content, err := readFile(config); err == nil {
+		result.ConfigPath = config
+		if result.RootDir == "" {
+			result.RootDir = tomlValue(content, "", "root")
+		}
+	} else if result.ConfigPath != "" {
+		return result, false
+	}
+	if result.RootDir == "" {
+		result.RootDir = containerdRoot
+	}
+	return result, exists(filepath.Join(result.RootDir, containerdMetaDir))
+}
+
+// storageGraphRoot returns the graph root of containers/storage
+// specified in the storage config.
+func storageGraphRoot() string {
+	if content, err := readFile(storageConfig); err == nil {
+		if root := tomlValue(content, "storage", "graphroot"); root != "" {
+			return root
+		}
+	}
+	return storageRoot
+}
+
+func discoverCRIO(d daemon) (Runtime, bool) {
+	result := Runtime{Kind: CRIO, ConfigPath: d.config, RootDir: d.root}
+	config := result.ConfigPath
+	if config == "" {
+		config = crioConfig
+	}
+	if content, err := readFile(config); err == nil {
+		result.ConfigPath = config
+		if result.RootDir == "" {
+			result.RootDir = tomlValue(content, "crio", "root")
+		}
+	} else if result.ConfigPath != "" {
+		return result, false
+	}
+	if result.ConfigPath == "" && d.kind != CRIO {
+		return result, false
+	}
+	if result.RootDir == "" {
+		result.RootDir = storageGraphRoot()
+	}
+	return result, exists(result.RootDir)
+}
+
+func discoverPodman() (Runtime, bool) {
+	installed := false
+	for _, binary := range podmanBinaries {
+		if exists(binary) {
+			installed = true
+			break
+		}
+	}
+	if !installed {
+		return Runtime{}, false
+	}
+	result := Runtime{Kind: Podman, RootDir: storageGraphRoot()}
+	if exists(storageConfig) {
+		result.ConfigPath = storageConfig
+	}
+	return result, exists(result.RootDir)
+}
+
+// Discover the container runtimes on the host.
+//
+// The running daemons are recognized first, and the config
+// files and data roots at their default locations are
+// recognized then. The runtimes whose data root is absent
+// are omitted.
+func Discover() []Runtime {
+	var result []Runtime
+	seen := make(map[Runtime]struct{})
+	add := func(r Runtime, ok bool) {
+		if !ok {
+			return
+		}
+		r.ConfigPath = filepath.Clean(r.ConfigPath)
+		if r.ConfigPath == "." {
+			r.ConfigPath = ""
+		}
+		r.RootDir = filepath.Clean(r.RootDir)
+		key := Runtime{Kind: r.Kind, RootDir: r.RootDir}
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		result = append(result, r)
+	}
+	discoverers := map[Kind]func(daemon) (Runtime, bool){
+		Docker:     discoverDocker,
+		Containerd: discoverContainerd,
+		CRIO:       discoverCRIO,
+	}
+	for _, d := range daemons() {
+		add(discoverers[d.kind](d))
+	}
+	add(discoverDocker(daemon{}))
+	add(discoverContainerd(daemon{}))
+	add(discoverCRIO(daemon{}))
+	add(discoverPodman())
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Kind < result[j].Kind
+	})
+	return result
+}
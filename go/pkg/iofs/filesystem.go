@@ -0,0 +1,197 @@
+package iofs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// linkFS is the file system that is aware of symbolic links.
+//
+// It is a subset of fs.ReadLinkFS in newer standard library,
+// so fstest.MapFS and os.DirFS will be recognized when they
+// implements them.
+type linkFS interface {
+	fs.FS
+	ReadLink(name string) (string, error)
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// maxSymlinks is the maximum number of symbolic links to
+// follow while evaluating a path, in accordance with the
+// MAXSYMLINKS in linux.
+const maxSymlinks = 40
+
+// FileSystem is the api.FileSystem view of an fs.FS.
+//
+// The file system is read-only and every attempt to write
+// files fails. Symbolic links are only recognized when the
+// underlying fs.FS provides ReadLink and Lstat methods.
+type FileSystem struct {
+	fsys fs.FS
+}
+
+// NewFileSystem creates the api.FileSystem view of the fs.FS.
+func NewFileSystem(fsys fs.FS) *FileSystem {
+	return &FileSystem{fsys: fsys}
+}
+
+func (f *FileSystem) Open(path string) (api.File, error) {
+	file, err := f.fsys.Open(toName(path))
+	if err != nil {
+		return nil, err
+	}
+	return &fileWrapper{File: file, name: toName(path)}, nil
+}
+
+func (f *FileSystem) Stat(path string) (os.FileInfo, error) {
+	return fs.Stat(f.fsys, toName(path))
+}
+
+func (f *FileSystem) Lstat(path string) (os.FileInfo, error) {
+	if l, ok := f.fsys.(linkFS); ok {
+		return l.Lstat(toName(path))
+	}
+	return fs.Stat(f.fsys, toName(path))
+}
+
+func (f *FileSystem) Readlink(path string) (string, error) {
+	if l, ok := f.fsys.(linkFS); ok {
+		return l.ReadLink(toName(path))
+	}
+	return "", &fs.PathError{
+		Op: "readlink", Path: toName(path), Err: fs.ErrInvalid}
+}
+
+func (f *FileSystem) EvalSymlink(p string) (string, error) {
+	l, ok := f.fsys.(linkFS)
+	if !ok {
+		if _, err := fs.Stat(f.fsys, toName(p)); err != nil {
+			return "", err
+		}
+		return path.Clean("/" + p), nil
+	}
+	resolved := "/"
+	rest := toName(p)
+	if rest == "." {
+		rest = ""
+	}
+	links := 0
+	for rest != "" {
+		var item string
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			item, rest = rest[:i], rest[i+1:]
+		} else {
+			item, rest = rest, ""
+		}
+		switch item {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+		next := path.Join(resolved, item)
+		info, err := l.Lstat(toName(next))
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		links++
+		if links > maxSymlinks {
+			return "", &fs.PathError{
+				Op: "evalsymlink", Path: p, Err: syscall.ELOOP}
+		}
+		target, err := l.ReadLink(toName(next))
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			resolved = "/"
+		}
+		rest = path.Join(target, rest)
+	}
+	return resolved, nil
+}
+
+func (f *FileSystem) Readdir(path string) ([]os.FileInfo, error) {
+	entries, err := fs.ReadDir(f.fsys, toName(path))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// Walk visits the file system in the same way as the
+// filepath.Walk, with absolute paths passed to walkFn.
+func (f *FileSystem) Walk(root string, walkFn filepath.WalkFunc) error {
+	return fs.WalkDir(f.fsys, toName(root), func(
+		name string, d fs.DirEntry, err error,
+	) error {
+		var info os.FileInfo
+		if d != nil {
+			if i, infoErr := d.Info(); infoErr == nil {
+				info = i
+			} else if err == nil {
+				err = infoErr
+			}
+		}
+		return walkFn(toPath("/", name), info, err)
+	})
+}
+
+// fileWrapper converts fs.File into api.File, while the
+// operations unsupported by the fs.File fail.
+type fileWrapper struct {
+	fs.File
+	name string
+}
+
+func (f *fileWrapper) unsupported(op string) error {
+	return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrInvalid}
+}
+
+func (f *fileWrapper) ReadAt(b []byte, off int64) (int, error) {
+	if r, ok := f.File.(io.ReaderAt); ok {
+		return r.ReadAt(b, off)
+	}
+	return 0, f.unsupported("readat")
+}
+
+func (f *fileWrapper) Write(_ []byte) (int, error) {
+	return 0, &fs.PathError{
+		Op: "write", Path: f.name, Err: fs.ErrPermission}
+}
+
+func (f *fileWrapper) WriteAt(_ []byte, _ int64) (int, error) {
+	return 0, &fs.PathError{
+		Op: "writeat", Path: f.name, Err: fs.ErrPermission}
+}
+
+func (f *fileWrapper) Seek(off int64, whence int) (int64, error) {
+	if s, ok := f.File.(io.Seeker); ok {
+		return s.Seek(off, whence)
+	}
+	return 0, f.unsupported("seek")
+}
+
+func (f *fileWrapper) Stat() (os.FileInfo, error) {
+	return f.File.Stat()
+}
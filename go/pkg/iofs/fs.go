@@ -0,0 +1,155 @@
+package iofs
+
+import (
+	"io"
+	"io/fs"
+	"sort"
+	"syscall"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// FS is the fs.FS view of an api.FileSystem.
+//
+// Besides fs.FS, it also implements fs.StatFS, fs.ReadDirFS,
+// fs.ReadFileFS and fs.SubFS. Symbolic links are followed
+// when opening files, and are resolved inside the file system
+// instead of the host.
+type FS struct {
+	fsys api.FileSystem
+	root string
+}
+
+// New creates the fs.FS view of the api.FileSystem.
+func New(fsys api.FileSystem) *FS {
+	return &FS{fsys: fsys, root: "/"}
+}
+
+func (f *FS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{
+			Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return toPath(f.root, name), nil
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	p, err := f.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.fsys.Stat(p)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	if info.IsDir() {
+		return &dir{fsys: f, name: name, info: info}, nil
+	}
+	file, err := f.fsys.Open(p)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	return file, nil
+}
+
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	p, err := f.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.fsys.Stat(p)
+	if err != nil {
+		return nil, pathError("stat", name, err)
+	}
+	return info, nil
+}
+
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := f.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := f.fsys.Readdir(p)
+	if err != nil {
+		return nil, pathError("readdir", name, err)
+	}
+	result := make([]fs.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		result = append(result, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name() < result[j].Name()
+	})
+	return result, nil
+}
+
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	if _, ok := file.(*dir); ok {
+		return nil, &fs.PathError{
+			Op: "read", Path: name, Err: syscall.EISDIR}
+	}
+	return io.ReadAll(file)
+}
+
+func (f *FS) Sub(name string) (fs.FS, error) {
+	p, err := f.path("sub", name)
+	if err != nil {
+		return nil, err
+	}
+	if name == "." {
+		return f, nil
+	}
+	return &FS{fsys: f.fsys, root: p}, nil
+}
+
+// dir is the fs.ReadDirFile of a directory in FS, whose
+// entries are listed on the first call to ReadDir.
+type dir struct {
+	fsys    *FS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dir) Read(_ []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: syscall.EISDIR}
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.listed = true
+	}
+	if n <= 0 {
+		result := d.entries
+		d.entries = nil
+		return result, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	result := d.entries[:n]
+	d.entries = d.entries[n:]
+	return result, nil
+}
@@ -0,0 +1,155 @@
+package iofs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// testLinkFS is the fstest.MapFS with symbolic links, which
+// are recorded apart so that the test does not depend on the
+// symbolic links of fstest.MapFS in newer standard library.
+type testLinkFS struct {
+	fstest.MapFS
+	links map[string]string
+}
+
+// testLinkInfo is the file info of the symbolic link.
+type testLinkInfo struct {
+	name string
+}
+
+func (i testLinkInfo) Name() string       { return i.name }
+func (i testLinkInfo) Size() int64        { return 0 }
+func (i testLinkInfo) Mode() fs.FileMode  { return fs.ModeSymlink | 0777 }
+func (i testLinkInfo) ModTime() time.Time { return time.Time{} }
+func (i testLinkInfo) IsDir() bool        { return false }
+func (i testLinkInfo) Sys() interface{}   { return nil }
+
+func (f testLinkFS) ReadLink(name string) (string, error) {
+	if target, ok := f.links[name]; ok {
+		return target, nil
+	}
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+}
+
+func (f testLinkFS) Lstat(name string) (fs.FileInfo, error) {
+	if _, ok := f.links[name]; ok {
+		return testLinkInfo{name: path.Base(name)}, nil
+	}
+	return fs.Stat(f.MapFS, name)
+}
+
+func newTestMapFS() fstest.MapFS {
+	return fstest.MapFS{
+		"etc/passwd":          {Data: []byte("root:x:0:0::/root:/bin/sh\n")},
+		"etc/ssl/certs/a.pem": {Data: []byte("a")},
+		"usr/bin/sh":          {Data: []byte("#!"), Mode: 0755},
+		"var/empty":           {Mode: fs.ModeDir | 0755},
+	}
+}
+
+func TestFS(t *testing.T) {
+	fsys := New(NewFileSystem(newTestMapFS()))
+	if err := fstest.TestFS(fsys, "etc/passwd",
+		"etc/ssl/certs/a.pem", "usr/bin/sh", "var/empty"); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := fs.Sub(fsys, "etc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(sub, "passwd", "ssl/certs/a.pem"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFSInvalidPath(t *testing.T) {
+	fsys := New(NewFileSystem(newTestMapFS()))
+	for _, name := range []string{
+		"/etc/passwd", "etc/../etc/passwd", "etc/", "./etc", "",
+	} {
+		if _, err := fsys.Open(name); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("open %q: unexpected error %v", name, err)
+		}
+		if _, err := fsys.Stat(name); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("stat %q: unexpected error %v", name, err)
+		}
+	}
+	if _, err := fsys.ReadFile("etc"); err == nil {
+		t.Error("directory read as file")
+	}
+	if _, err := fsys.Open("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestFileSystemEvalSymlink(t *testing.T) {
+	fsys := NewFileSystem(testLinkFS{
+		MapFS: newTestMapFS(),
+		links: map[string]string{
+			"bin":            "usr/bin",
+			"etc/ssl/cert":   "certs/a.pem",
+			"etc/ssl/abs":    "/etc/passwd",
+			"etc/ssl/escape": "../../../../etc/passwd",
+			"loop":           "loop",
+			"dangling":       "missing",
+		},
+	})
+	for _, c := range []struct {
+		path string
+		want string
+		err  bool
+	}{
+		{path: "/etc/passwd", want: "/etc/passwd"},
+		{path: "etc/./ssl/../passwd", want: "/etc/passwd"},
+		{path: "/bin/sh", want: "/usr/bin/sh"},
+		{path: "/etc/ssl/cert", want: "/etc/ssl/certs/a.pem"},
+		{path: "/etc/ssl/abs", want: "/etc/passwd"},
+		{path: "/etc/ssl/escape", want: "/etc/passwd"},
+		{path: "/", want: "/"},
+		{path: "/loop", err: true},
+		{path: "/dangling", err: true},
+		{path: "/missing", err: true},
+	} {
+		resolved, err := fsys.EvalSymlink(c.path)
+		if c.err {
+			if err == nil {
+				t.Errorf("eval %q: unexpected result %q", c.path, resolved)
+			}
+			continue
+		}
+		if err != nil || resolved != c.want {
+			t.Errorf("eval %q: got %q, %v, want %q",
+				c.path, resolved, err, c.want)
+		}
+	}
+
+	target, err := fsys.Readlink("/bin")
+	if err != nil || target != "usr/bin" {
+		t.Fatalf("unexpected link %q: %v", target, err)
+	}
+	info, err := fsys.Lstat("/etc/ssl/cert")
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 || info.Name() != "cert" {
+		t.Fatalf("unexpected info %v: %v", info, err)
+	}
+}
+
+func TestFileSystemReadOnly(t *testing.T) {
+	fsys := NewFileSystem(newTestMapFS())
+	f, err := fsys.Open("/etc/passwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.Write([]byte("x")); err == nil {
+		t.Error("file written")
+	}
+	buf := make([]byte, 4)
+	if n, err := f.ReadAt(buf, 5); err != nil || string(buf[:n]) != "x:0:" {
+		t.Errorf("unexpected content %q: %v", buf[:n], err)
+	}
+}
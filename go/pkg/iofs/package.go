@@ -0,0 +1,43 @@
+// Package iofs adapts api.FileSystem to the io/fs interfaces
+// of the standard library, and vice versa.
+//
+// With FS, images, layers and containers can be visited by
+// anything accepting an fs.FS, like fs.WalkDir, fs.Glob,
+// template.ParseFS and http.FS. With FileSystem, any fs.FS
+// like fstest.MapFS or os.DirFS can stand in wherever an
+// api.FileSystem is expected, which is useful for testing.
+package iofs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// toName converts the absolute path in api.FileSystem into
+// the unrooted name accepted by fs.FS.
+func toName(p string) string {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+// toPath converts the name accepted by fs.FS into the
+// absolute path in api.FileSystem.
+func toPath(root, name string) string {
+	return path.Join(root, name)
+}
+
+// pathError reconstructs the error so that the path in it
+// is relative to the fs.FS instead of the api.FileSystem.
+func pathError(op, name string, err error) error {
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
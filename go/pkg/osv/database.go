@@ -0,0 +1,188 @@
+package osv
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Database is the collection of advisories indexed by the
+// ecosystem and the name of affected packages.
+type Database struct {
+	ecosystems map[string]struct{}
+	index      map[string]map[string][]*Advisory
+	count      int
+}
+
+type openOption struct {
+	ecosystems map[string]struct{}
+}
+
+// OpenOption specifies how to load the database.
+type OpenOption func(*openOption)
+
+// WithEcosystems specifies the ecosystems to load, which saves
+// memory when loading the full database. All ecosystems are
+// loaded if unspecified.
+func WithEcosystems(ecosystems ...string) OpenOption {
+	return func(o *openOption) {
+		if o.ecosystems == nil {
+			o.ecosystems = make(map[string]struct{})
+		}
+		for _, ecosystem := range ecosystems {
+			o.ecosystems[ecosystemBase(ecosystem)] = struct{}{}
+		}
+	}
+}
+
+// NewDatabase creates an empty database.
+func NewDatabase(opts ...OpenOption) *Database {
+	var option openOption
+	for _, opt := range opts {
+		opt(&option)
+	}
+	return &Database{
+		ecosystems: option.ecosystems,
+		index:      make(map[string]map[string][]*Advisory),
+	}
+}
+
+// Add the advisory to the database, withdrawn advisories and
+// those not affecting the loaded ecosystems are ignored.
+func (db *Database) Add(advisory *Advisory) {
+	if advisory.Withdrawn != "" {
+		return
+	}
+	added := false
+	seen := make(map[[2]string]struct{})
+	for _, affected := range advisory.Affected {
+		ecosystem := ecosystemBase(affected.Package.Ecosystem)
+		if db.ecosystems != nil {
+			if _, ok := db.ecosystems[ecosystem]; !ok {
+				continue
+			}
+		}
+		name := normalizeName(ecosystem, affected.Package.Name)
+		key := [2]string{ecosystem, name}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		names := db.index[ecosystem]
+		if names == nil {
+			names = make(map[string][]*Advisory)
+			db.index[ecosystem] = names
+		}
+		names[name] = append(names[name], advisory)
+		added = true
+	}
+	if added {
+		db.count++
+	}
+}
+
+// Len returns the number of advisories in the database.
+func (db *Database) Len() int {
+	return db.count
+}
+
+// Lookup the advisories affecting the package of ecosystem,
+// the release suffix of the ecosystem is ignored.
+func (db *Database) Lookup(ecosystem, name string) []*Advisory {
+	ecosystem = ecosystemBase(ecosystem)
+	return db.index[ecosystem][normalizeName(ecosystem, name)]
+}
+
+// load decodes the advisory and adds it to the database.
+func (db *Database) load(name string, r io.Reader) error {
+	var advisory Advisory
+	if err := json.NewDecoder(r).Decode(&advisory); err != nil {
+		return xerrors.Errorf("%s: %w", name, err)
+	}
+	db.Add(&advisory)
+	return nil
+}
+
+func isAdvisoryFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".json")
+}
+
+// OpenDir loads the advisories in the JSON files under the
+// directory recursively.
+func OpenDir(dir string, opts ...OpenOption) (*Database, error) {
+	db := NewDatabase(opts...)
+	var paths []string
+	if err := filepath.Walk(dir, func(
+		path string, info os.FileInfo, err error,
+	) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && isAdvisoryFile(path) {
+			paths = append(paths, path)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := func() error {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = f.Close() }()
+			return db.load(path, f)
+		}(); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// OpenZip loads the advisories in the JSON files inside the
+// zip archive, in the same layout as the "all.zip" of each
+// ecosystem distributed by OSV.
+func OpenZip(path string, opts ...OpenOption) (*Database, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+	db := NewDatabase(opts...)
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() || !isAdvisoryFile(f.Name) {
+			continue
+		}
+		if err := func() error {
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			defer func() { _ = rc.Close() }()
+			return db.load(f.Name, rc)
+		}(); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// Open loads the advisories from a directory or zip archive.
+func Open(path string, opts ...OpenOption) (*Database, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return OpenDir(path, opts...)
+	}
+	return OpenZip(path, opts...)
+}
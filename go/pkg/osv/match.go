@@ -0,0 +1,336 @@
+package osv
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chaitin/libveinmind/go/pkg/sbom"
+)
+
+// distroEcosystems maps the distribution ID in os-release to
+// the ecosystem of its packages.
+var distroEcosystems = map[string]string{
+	"debian":              Debian,
+	"ubuntu":              Ubuntu,
+	"alpine":              Alpine,
+	"wolfi":               Wolfi,
+	"chainguard":          Chainguard,
+	"rhel":                RedHat,
+	"rocky":               RockyLinux,
+	"almalinux":           AlmaLinux,
+	"sles":                SUSE,
+	"sled":                SUSE,
+	"opensuse":            OpenSUSE,
+	"opensuse-leap":       OpenSUSE,
+	"opensuse-tumbleweed": OpenSUSE,
+	"photon":              PhotonOS,
+	"mariner":             Mariner,
+}
+
+// packageEcosystems maps the package type to the ecosystem
+// of language packages.
+var packageEcosystems = map[sbom.PackageType]string{
+	sbom.Golang: Go,
+	sbom.PyPI:   PyPI,
+	sbom.NPM:    NPM,
+	sbom.Maven:  Maven,
+	sbom.Gem:    RubyGems,
+	sbom.Cargo:  CratesIO,
+}
+
+// Finding is a package affected by an advisory.
+type Finding struct {
+	Package  sbom.Package
+	Advisory *Advisory
+
+	// Ecosystem is the ecosystem of the affected entry that
+	// matches the package, including the release suffix.
+	Ecosystem string
+
+	// FixedVersion is the nearest version fixing the
+	// vulnerability, which is empty if no fix is available.
+	FixedVersion string
+}
+
+// distroRelease returns the release of the distribution in
+// the form of the release suffix of the ecosystem.
+func distroRelease(ecosystem string, distro *sbom.Distro) string {
+	version := distro.VersionID
+	major := version
+	if i := strings.IndexByte(version, '.'); i >= 0 {
+		major = version[:i]
+	}
+	switch ecosystem {
+	case Alpine:
+		// The alpine release is like "v3.16" while the
+		// version is like "3.16.2".
+		parts := strings.SplitN(version, ".", 3)
+		if len(parts) < 2 {
+			return ""
+		}
+		return "v" + parts[0] + "." + parts[1]
+	case Debian, RedHat, RockyLinux, AlmaLinux:
+		return major
+	case SUSE:
+		// The SUSE release is like "15 SP5" after the product
+		// name, while the version is like "15.5".
+		parts := strings.SplitN(version, ".", 2)
+		if len(parts) < 2 || parts[1] == "0" {
+			return major
+		}
+		return parts[0] + " SP" + parts[1]
+	case OpenSUSE:
+		// The openSUSE release is like "Leap 15.5", while the
+		// version of tumbleweed is the date of snapshot.
+		if distro.ID == "opensuse-tumbleweed" {
+			return "Tumbleweed"
+		}
+		if version == "" {
+			return ""
+		}
+		return "Leap " + version
+	default:
+		return version
+	}
+}
+
+// releaseMatches judges whether the release suffix of the
+// advisory ecosystem matches the release. Since the suffixes
+// are in different forms, like "22.04:LTS" and "enterprise_linux
+// :9::appstream", any component of the suffix equal to the
+// release is considered as a match. The SUSE suffixes are the
+// product names ending with the release, like "Linux Enterprise
+// Server 15 SP5", optionally with a variant like "-LTSS".
+//
+// An unknown release matches all suffixes, so that the
+// vulnerabilities will not be missed.
+func releaseMatches(ecosystem, suffix, release string) bool {
+	if suffix == "" || release == "" {
+		return true
+	}
+	if ecosystem == SUSE {
+		return suffix == release || strings.HasSuffix(suffix, " "+release) ||
+			strings.Contains(suffix, " "+release+"-")
+	}
+	for _, component := range strings.Split(suffix, ":") {
+		if component == release {
+			return true
+		}
+	}
+	return false
+}
+
+// candidate is a name and version to lookup for a package.
+type candidate struct {
+	name, version string
+}
+
+// packageCandidates returns the ecosystem, the release and the
+// names to lookup for the package. The OS packages are also
+// looked up by their source packages, which are used by the
+// advisories of debian and alpine.
+func packageCandidates(
+	pkg sbom.Package, distro *sbom.Distro,
+) (string, string, []candidate) {
+	if !pkg.Type.IsOS() {
+		ecosystem, ok := packageEcosystems[pkg.Type]
+		if !ok {
+			return "", "", nil
+		}
+		return ecosystem, "", []candidate{{pkg.Name, pkg.Version}}
+	}
+	if distro == nil {
+		return "", "", nil
+	}
+	ecosystem, ok := distroEcosystems[distro.ID]
+	if !ok {
+		return "", "", nil
+	}
+	release := distroRelease(ecosystem, distro)
+	version := pkg.Version
+	if pkg.Type == sbom.RPM && pkg.Epoch != 0 {
+		version = strconv.Itoa(pkg.Epoch) + ":" + version
+	}
+	result := []candidate{{pkg.Name, version}}
+	if pkg.SourceName != "" && pkg.SourceName != pkg.Name {
+		sourceVersion := version
+		if pkg.SourceVersion != "" && pkg.Type != sbom.RPM {
+			sourceVersion = pkg.SourceVersion
+		}
+		result = append(result, candidate{pkg.SourceName, sourceVersion})
+	}
+	return ecosystem, release, result
+}
+
+// compareEventVersion compares the versions in the range,
+// where the introduced "0" is less than any version.
+func compareEventVersion(
+	ecosystem, rangeType, a, b string,
+) (int, error) {
+	switch {
+	case a == "0" && b == "0":
+		return 0, nil
+	case a == "0":
+		return -1, nil
+	case b == "0":
+		return 1, nil
+	}
+	if rangeType == RangeSemver {
+		return compareSemver(a, b)
+	}
+	return CompareVersions(ecosystem, a, b)
+}
+
+func eventVersion(e Event) string {
+	switch {
+	case e.Introduced != "":
+		return e.Introduced
+	case e.Fixed != "":
+		return e.Fixed
+	case e.LastAffected != "":
+		return e.LastAffected
+	default:
+		return e.Limit
+	}
+}
+
+// evaluateRange judges whether the version is inside the range,
+// and returns the nearest fixed version if it is.
+func evaluateRange(
+	ecosystem string, r Range, version string,
+) (bool, string, error) {
+	events := append([]Event(nil), r.Events...)
+	var err error
+	sort.SliceStable(events, func(i, j int) bool {
+		result, cmpErr := compareEventVersion(ecosystem, r.Type,
+			eventVersion(events[i]), eventVersion(events[j]))
+		if cmpErr != nil {
+			err = cmpErr
+		}
+		return result < 0
+	})
+	if err != nil {
+		return false, "", err
+	}
+	affected := false
+	fixed := ""
+	for _, e := range events {
+		result, err := compareEventVersion(
+			ecosystem, r.Type, version, eventVersion(e))
+		if err != nil {
+			return false, "", err
+		}
+		switch {
+		case e.Introduced != "":
+			if result >= 0 {
+				affected = true
+				fixed = ""
+			}
+		case e.Fixed != "":
+			if result >= 0 {
+				affected = false
+			} else if affected && fixed == "" {
+				fixed = e.Fixed
+			}
+		case e.LastAffected != "":
+			if result > 0 {
+				affected = false
+			}
+		case e.Limit != "":
+			if result >= 0 {
+				affected = false
+			}
+		}
+	}
+	return affected, fixed, nil
+}
+
+// evaluate judges whether the version is affected by the
+// entry, and returns the nearest fixed version if it is.
+//
+// Ranges and versions that are malformed or of unsupported
+// ecosystems are skipped.
+func evaluate(affected Affected, version string) (bool, string) {
+	ecosystem := affected.Package.Ecosystem
+	result := false
+	for _, v := range affected.Versions {
+		if cmp, err := CompareVersions(ecosystem, version, v); err == nil && cmp == 0 {
+			result = true
+			break
+		}
+	}
+	fixed := ""
+	for _, r := range affected.Ranges {
+		if r.Type == RangeGit {
+			continue
+		}
+		ok, fixedVersion, err := evaluateRange(ecosystem, r, version)
+		if err != nil || !ok {
+			continue
+		}
+		result = true
+		if fixed == "" {
+			fixed = fixedVersion
+		}
+	}
+	return result, fixed
+}
+
+// MatchPackage matches a package against the database, the
+// distro is required to match the OS packages.
+func (db *Database) MatchPackage(
+	pkg sbom.Package, distro *sbom.Distro,
+) []Finding {
+	ecosystem, release, candidates := packageCandidates(pkg, distro)
+	if ecosystem == "" {
+		return nil
+	}
+	var result []Finding
+	seen := make(map[string]struct{})
+	for _, c := range candidates {
+		for _, advisory := range db.Lookup(ecosystem, c.name) {
+			if _, ok := seen[advisory.ID]; ok {
+				continue
+			}
+			for _, affected := range advisory.Affected {
+				if ecosystemBase(affected.Package.Ecosystem) != ecosystem ||
+					normalizeName(ecosystem, affected.Package.Name) !=
+						normalizeName(ecosystem, c.name) ||
+					!releaseMatches(ecosystem, ecosystemRelease(
+						affected.Package.Ecosystem), release) {
+					continue
+				}
+				ok, fixed := evaluate(affected, c.version)
+				if !ok {
+					continue
+				}
+				seen[advisory.ID] = struct{}{}
+				result = append(result, Finding{
+					Package:      pkg,
+					Advisory:     advisory,
+					Ecosystem:    affected.Package.Ecosystem,
+					FixedVersion: fixed,
+				})
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Match the packages in the inventory against the database.
+//
+// The findings are ordered by the packages in the inventory
+// and the ID of advisories.
+func (db *Database) Match(inventory *sbom.SBOM) []Finding {
+	var result []Finding
+	for _, pkg := range inventory.Packages {
+		findings := db.MatchPackage(pkg, inventory.Distro)
+		sort.SliceStable(findings, func(i, j int) bool {
+			return findings[i].Advisory.ID < findings[j].Advisory.ID
+		})
+		result = append(result, findings...)
+	}
+	return result
+}
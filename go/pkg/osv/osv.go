@@ -0,0 +1,146 @@
+// Package osv matches the package inventory against the
+// vulnerability advisories in the OSV format, which are loaded
+// from a local directory or zip archive so that it can be used
+// in air-gapped environments.
+//
+// The versions are compared with the rules of their ecosystem,
+// comparators of dpkg, rpm, apk, semantic versioning, PEP 440,
+// maven and rubygems are registered by default.
+//
+// See https://ossf.github.io/osv-schema/ for the format.
+package osv
+
+import (
+	"strings"
+)
+
+// Ecosystems in OSV, without the release suffix.
+const (
+	Debian     = "Debian"
+	Ubuntu     = "Ubuntu"
+	Alpine     = "Alpine"
+	Wolfi      = "Wolfi"
+	Chainguard = "Chainguard"
+	RedHat     = "Red Hat"
+	RockyLinux = "Rocky Linux"
+	AlmaLinux  = "AlmaLinux"
+	SUSE       = "SUSE"
+	OpenSUSE   = "openSUSE"
+	PhotonOS   = "Photon OS"
+	Mariner    = "Mariner"
+	Go         = "Go"
+	PyPI       = "PyPI"
+	NPM        = "npm"
+	Maven      = "Maven"
+	RubyGems   = "RubyGems"
+	CratesIO   = "crates.io"
+)
+
+// Types of affected range.
+const (
+	RangeSemver    = "SEMVER"
+	RangeEcosystem = "ECOSYSTEM"
+	RangeGit       = "GIT"
+)
+
+// Event is an event of the affected range. Exactly one of the
+// fields is specified in each event.
+type Event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+// Range is the range of versions affected.
+type Range struct {
+	Type   string  `json:"type"`
+	Repo   string  `json:"repo,omitempty"`
+	Events []Event `json:"events"`
+}
+
+// Package identifies the package affected.
+type Package struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	PURL      string `json:"purl,omitempty"`
+}
+
+// Severity is the severity score of the vulnerability.
+type Severity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+// Affected is a package affected by the vulnerability.
+type Affected struct {
+	Package  Package    `json:"package"`
+	Severity []Severity `json:"severity,omitempty"`
+	Ranges   []Range    `json:"ranges,omitempty"`
+	Versions []string   `json:"versions,omitempty"`
+}
+
+// Reference is a link to the further information.
+type Reference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// Advisory is a vulnerability advisory in the OSV format.
+//
+// Only the fields useful for matching and reporting are
+// decoded, the others are ignored.
+type Advisory struct {
+	ID         string      `json:"id"`
+	Modified   string      `json:"modified"`
+	Published  string      `json:"published,omitempty"`
+	Withdrawn  string      `json:"withdrawn,omitempty"`
+	Aliases    []string    `json:"aliases,omitempty"`
+	Related    []string    `json:"related,omitempty"`
+	Summary    string      `json:"summary,omitempty"`
+	Details    string      `json:"details,omitempty"`
+	Severity   []Severity  `json:"severity,omitempty"`
+	Affected   []Affected  `json:"affected"`
+	References []Reference `json:"references,omitempty"`
+}
+
+// ecosystemBase strips the release suffix of the ecosystem,
+// for example "Debian:11" is stripped to "Debian".
+func ecosystemBase(ecosystem string) string {
+	if i := strings.IndexByte(ecosystem, ':'); i >= 0 {
+		return ecosystem[:i]
+	}
+	return ecosystem
+}
+
+// ecosystemRelease returns the release suffix of the ecosystem,
+// for example "Ubuntu:22.04:LTS" returns "22.04:LTS".
+func ecosystemRelease(ecosystem string) string {
+	if i := strings.IndexByte(ecosystem, ':'); i >= 0 {
+		return ecosystem[i+1:]
+	}
+	return ""
+}
+
+// normalizeName normalizes the package name for lookup, the
+// python package names are case insensitive and the runs of
+// "-", "_" and "." are equivalent.
+func normalizeName(ecosystem, name string) string {
+	if ecosystemBase(ecosystem) != PyPI {
+		return name
+	}
+	var b strings.Builder
+	separator := false
+	for _, r := range strings.ToLower(name) {
+		if r == '-' || r == '_' || r == '.' {
+			separator = true
+			continue
+		}
+		if separator && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		separator = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
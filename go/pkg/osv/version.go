@@ -0,0 +1,110 @@
+package osv
+
+import (
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// Comparator compares two versions of an ecosystem, returning
+// -1, 0 or 1 when a is less than, equal to or greater than b.
+//
+// An error should be returned when either of the versions is
+// malformed, so that the range evaluation is not misguided.
+type Comparator func(a, b string) (int, error)
+
+var comparators = sync.Map{}
+
+// RegisterComparator to be used for comparing the versions of
+// the specified ecosystem, which is the ecosystem name in OSV
+// without the release suffix, like "Debian" and "PyPI".
+//
+// Registering a comparator for an ecosystem will replace the
+// previous one of the same ecosystem.
+func RegisterComparator(ecosystem string, comparator Comparator) {
+	comparators.Store(ecosystem, comparator)
+}
+
+// CompareVersions compares two versions of an ecosystem.
+func CompareVersions(ecosystem, a, b string) (int, error) {
+	value, ok := comparators.Load(ecosystemBase(ecosystem))
+	if !ok {
+		return 0, xerrors.Errorf("unsupported ecosystem %q", ecosystem)
+	}
+	return value.(Comparator)(a, b)
+}
+
+// compareInt compares the integers.
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareString compares the strings.
+func compareString(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareNumeric compares strings of digits by their numeric
+// value without the risk of overflowing.
+func compareNumeric(a, b string) int {
+	a, b = trimZeros(a), trimZeros(b)
+	if len(a) != len(b) {
+		return compareInt(int64(len(a)), int64(len(b)))
+	}
+	return compareString(a, b)
+}
+
+func trimZeros(s string) string {
+	for len(s) > 0 && s[0] == '0' {
+		s = s[1:]
+	}
+	return s
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func init() {
+	for _, ecosystem := range []string{
+		Debian, Ubuntu,
+	} {
+		RegisterComparator(ecosystem, compareDpkg)
+	}
+	for _, ecosystem := range []string{
+		RedHat, RockyLinux, AlmaLinux, SUSE, OpenSUSE, PhotonOS, Mariner,
+	} {
+		RegisterComparator(ecosystem, compareRPM)
+	}
+	for _, ecosystem := range []string{
+		Alpine, Wolfi, Chainguard,
+	} {
+		RegisterComparator(ecosystem, compareAPK)
+	}
+	for _, ecosystem := range []string{
+		Go, NPM, CratesIO,
+	} {
+		RegisterComparator(ecosystem, compareSemver)
+	}
+	RegisterComparator(PyPI, comparePEP440)
+	RegisterComparator(Maven, compareMaven)
+	RegisterComparator(RubyGems, compareGem)
+}
@@ -0,0 +1,130 @@
+package osv
+
+import (
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// apkSuffixes are the suffixes of apk version in ascending
+// order, those before the empty suffix are pre-releases.
+var apkSuffixes = map[string]int{
+	"alpha": 0,
+	"beta":  1,
+	"pre":   2,
+	"rc":    3,
+	"":      4,
+	"cvs":   5,
+	"svn":   6,
+	"git":   7,
+	"hg":    8,
+	"p":     9,
+}
+
+type apkSuffix struct {
+	rank   int
+	number string
+}
+
+// apkVersion is the version of alpine package, in the form of
+// "1.2.3[a][_suffix[N]]...[~hash][-rN]".
+type apkVersion struct {
+	numbers  []string
+	letter   byte
+	suffixes []apkSuffix
+	revision string
+}
+
+func parseAPKVersion(s string) (apkVersion, error) {
+	var result apkVersion
+	version := s
+	if i := strings.LastIndex(s, "-r"); i >= 0 {
+		result.revision = s[i+2:]
+		s = s[:i]
+		for j := 0; j < len(result.revision); j++ {
+			if !isDigit(result.revision[j]) {
+				return apkVersion{}, xerrors.Errorf(
+					"invalid revision of apk version %q", version)
+			}
+		}
+	}
+	// The commit hash is irrelevant to the order.
+	if i := strings.IndexByte(s, '~'); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, "_")
+	main := parts[0]
+	if n := len(main); n > 0 && isAlpha(main[n-1]) {
+		result.letter = main[n-1]
+		main = main[:n-1]
+	}
+	for _, number := range strings.Split(main, ".") {
+		if number == "" {
+			return apkVersion{}, xerrors.Errorf(
+				"invalid apk version %q", version)
+		}
+		for j := 0; j < len(number); j++ {
+			if !isDigit(number[j]) {
+				return apkVersion{}, xerrors.Errorf(
+					"invalid apk version %q", version)
+			}
+		}
+		result.numbers = append(result.numbers, number)
+	}
+	for _, part := range parts[1:] {
+		i := len(part)
+		for i > 0 && isDigit(part[i-1]) {
+			i--
+		}
+		rank, ok := apkSuffixes[part[:i]]
+		if !ok || part[:i] == "" {
+			return apkVersion{}, xerrors.Errorf(
+				"invalid suffix of apk version %q", version)
+		}
+		result.suffixes = append(result.suffixes, apkSuffix{
+			rank: rank, number: part[i:],
+		})
+	}
+	return result, nil
+}
+
+// compareAPK compares the alpine package versions.
+func compareAPK(a, b string) (int, error) {
+	va, err := parseAPKVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseAPKVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(va.numbers) && i < len(vb.numbers); i++ {
+		if result := compareNumeric(va.numbers[i], vb.numbers[i]); result != 0 {
+			return result, nil
+		}
+	}
+	if result := compareInt(int64(len(va.numbers)),
+		int64(len(vb.numbers))); result != 0 {
+		return result, nil
+	}
+	if result := compareInt(int64(va.letter), int64(vb.letter)); result != 0 {
+		return result, nil
+	}
+	none := apkSuffix{rank: apkSuffixes[""]}
+	for i := 0; i < len(va.suffixes) || i < len(vb.suffixes); i++ {
+		sa, sb := none, none
+		if i < len(va.suffixes) {
+			sa = va.suffixes[i]
+		}
+		if i < len(vb.suffixes) {
+			sb = vb.suffixes[i]
+		}
+		if result := compareInt(int64(sa.rank), int64(sb.rank)); result != 0 {
+			return result, nil
+		}
+		if result := compareNumeric(sa.number, sb.number); result != 0 {
+			return result, nil
+		}
+	}
+	return compareNumeric(va.revision, vb.revision), nil
+}
@@ -0,0 +1,107 @@
+package osv
+
+import (
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// dpkgVersion is the version of debian package, in the form
+// of "[epoch:]upstream_version[-debian_revision]".
+type dpkgVersion struct {
+	epoch    string
+	upstream string
+	revision string
+}
+
+func parseDpkgVersion(s string) (dpkgVersion, error) {
+	var result dpkgVersion
+	version := s
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		result.epoch = s[:i]
+		for j := 0; j < len(result.epoch); j++ {
+			if !isDigit(result.epoch[j]) {
+				return dpkgVersion{}, xerrors.Errorf(
+					"invalid epoch of dpkg version %q", version)
+			}
+		}
+		s = s[i+1:]
+	}
+	if i := strings.LastIndexByte(s, '-'); i >= 0 {
+		result.revision = s[i+1:]
+		s = s[:i]
+	}
+	if s == "" {
+		return dpkgVersion{}, xerrors.Errorf(
+			"invalid upstream of dpkg version %q", version)
+	}
+	result.upstream = s
+	return result, nil
+}
+
+// dpkgOrder is the weight of character in the non-digit part,
+// where the tilde sorts before anything, even the end of part.
+func dpkgOrder(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	c := s[i]
+	switch {
+	case isDigit(c):
+		return 0
+	case isAlpha(c):
+		return int(c)
+	case c == '~':
+		return -1
+	default:
+		return int(c) + 256
+	}
+}
+
+// dpkgVerRevCmp is a port of verrevcmp in dpkg, which compares
+// the non-digit and digit parts alternately.
+func dpkgVerRevCmp(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			ac, bc := dpkgOrder(a, i), dpkgOrder(b, j)
+			if ac != bc {
+				return compareInt(int64(ac), int64(bc))
+			}
+			i++
+			j++
+		}
+		si := i
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+		sj := j
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+		if result := compareNumeric(a[si:i], b[sj:j]); result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+// compareDpkg compares the debian package versions.
+func compareDpkg(a, b string) (int, error) {
+	va, err := parseDpkgVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseDpkgVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	if result := compareNumeric(va.epoch, vb.epoch); result != 0 {
+		return result, nil
+	}
+	if result := dpkgVerRevCmp(va.upstream, vb.upstream); result != 0 {
+		return result, nil
+	}
+	return dpkgVerRevCmp(va.revision, vb.revision), nil
+}
@@ -0,0 +1,80 @@
+package osv
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+var (
+	gemPattern  = regexp.MustCompile(`^[0-9]+(?:\.[0-9a-zA-Z]+)*(?:-[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?$`)
+	gemSegments = regexp.MustCompile(`[0-9]+|[a-zA-Z]+`)
+)
+
+// gemCanonical splits the version into segments in the same
+// way as Gem::Version, with the trailing zeros of the release
+// and pre-release parts removed.
+func gemCanonical(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if !gemPattern.MatchString(s) {
+		return nil, xerrors.Errorf("invalid gem version %q", s)
+	}
+	// The hyphen is the shorthand of ".pre.".
+	s = strings.ReplaceAll(s, "-", ".pre.")
+	segments := gemSegments.FindAllString(s, -1)
+	release := len(segments)
+	for i, segment := range segments {
+		if !isDigit(segment[0]) {
+			release = i
+			break
+		}
+	}
+	trim := func(segments []string) []string {
+		for len(segments) > 0 && isDigit(segments[len(segments)-1][0]) &&
+			trimZeros(segments[len(segments)-1]) == "" {
+			segments = segments[:len(segments)-1]
+		}
+		return segments
+	}
+	result := trim(segments[:release])
+	return append(result, trim(segments[release:])...), nil
+}
+
+// compareGem compares the ruby gem versions, where the string
+// segments are pre-releases sorting before the numeric ones.
+func compareGem(a, b string) (int, error) {
+	sa, err := gemCanonical(a)
+	if err != nil {
+		return 0, err
+	}
+	sb, err := gemCanonical(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(sa) || i < len(sb); i++ {
+		ia, ib := "0", "0"
+		if i < len(sa) {
+			ia = sa[i]
+		}
+		if i < len(sb) {
+			ib = sb[i]
+		}
+		na, nb := isDigit(ia[0]), isDigit(ib[0])
+		var result int
+		switch {
+		case na && nb:
+			result = compareNumeric(ia, ib)
+		case na:
+			result = 1
+		case nb:
+			result = -1
+		default:
+			result = compareString(ia, ib)
+		}
+		if result != 0 {
+			return result, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,232 @@
+package osv
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The maven version is compared in the same way as the
+// ComparableVersion of maven, which parses the version into
+// nested lists of integer and string items.
+
+// mavenItem is an item of maven version, the other item to
+// compare with might be nil when it is absent.
+type mavenItem interface {
+	compare(other mavenItem) int
+	isNull() bool
+}
+
+type mavenInt string
+
+func (i mavenInt) isNull() bool {
+	return trimZeros(string(i)) == ""
+}
+
+func (i mavenInt) compare(other mavenItem) int {
+	switch other := other.(type) {
+	case nil:
+		if i.isNull() {
+			return 0
+		}
+		return 1
+	case mavenInt:
+		return compareNumeric(string(i), string(other))
+	default:
+		return 1
+	}
+}
+
+// mavenQualifiers are the well-known qualifiers in ascending
+// order, where the empty qualifier is the release.
+var mavenQualifiers = []string{
+	"alpha", "beta", "milestone", "rc", "snapshot", "", "sp",
+}
+
+var mavenAliases = map[string]string{
+	"ga":      "",
+	"final":   "",
+	"release": "",
+	"cr":      "rc",
+}
+
+// mavenReleaseIndex is the comparable qualifier of release.
+var mavenReleaseIndex = comparableMavenQualifier("")
+
+// comparableMavenQualifier converts the qualifier into a
+// string that is ordered as the qualifiers are, unknown
+// qualifiers sort after the known ones lexically.
+func comparableMavenQualifier(q string) string {
+	for i, qualifier := range mavenQualifiers {
+		if q == qualifier {
+			return strconv.Itoa(i)
+		}
+	}
+	return strconv.Itoa(len(mavenQualifiers)) + "-" + q
+}
+
+type mavenString string
+
+func newMavenString(value string, followedByDigit bool) mavenString {
+	if followedByDigit && len(value) == 1 {
+		switch value {
+		case "a":
+			value = "alpha"
+		case "b":
+			value = "beta"
+		case "m":
+			value = "milestone"
+		}
+	}
+	if alias, ok := mavenAliases[value]; ok {
+		value = alias
+	}
+	return mavenString(value)
+}
+
+func (s mavenString) isNull() bool {
+	return comparableMavenQualifier(string(s)) == mavenReleaseIndex
+}
+
+func (s mavenString) compare(other mavenItem) int {
+	switch other := other.(type) {
+	case nil:
+		return compareString(
+			comparableMavenQualifier(string(s)), mavenReleaseIndex)
+	case mavenInt:
+		return -1
+	case mavenString:
+		return compareString(comparableMavenQualifier(string(s)),
+			comparableMavenQualifier(string(other)))
+	default:
+		return -1
+	}
+}
+
+type mavenList []mavenItem
+
+func (l *mavenList) isNull() bool {
+	return len(*l) == 0
+}
+
+// normalize removes the trailing null items.
+func (l *mavenList) normalize() {
+	for i := len(*l) - 1; i >= 0; i-- {
+		item := (*l)[i]
+		if item.isNull() {
+			*l = append((*l)[:i], (*l)[i+1:]...)
+		} else if _, ok := item.(*mavenList); !ok {
+			break
+		}
+	}
+}
+
+func (l *mavenList) compare(other mavenItem) int {
+	switch other := other.(type) {
+	case nil:
+		if len(*l) == 0 {
+			return 0
+		}
+		return (*l)[0].compare(nil)
+	case mavenInt:
+		return -1
+	case mavenString:
+		return 1
+	case *mavenList:
+		for i := 0; i < len(*l) || i < len(*other); i++ {
+			var left, right mavenItem
+			if i < len(*l) {
+				left = (*l)[i]
+			}
+			if i < len(*other) {
+				right = (*other)[i]
+			}
+			var result int
+			switch {
+			case left == nil && right == nil:
+				result = 0
+			case left == nil:
+				result = -right.compare(nil)
+			default:
+				result = left.compare(right)
+			}
+			if result != 0 {
+				return result
+			}
+		}
+		return 0
+	default:
+		return 0
+	}
+}
+
+func newMavenItem(isDigit bool, s string) mavenItem {
+	if isDigit {
+		return mavenInt(s)
+	}
+	return newMavenString(s, false)
+}
+
+// parseMaven parses the version into nested lists, where a
+// hyphen or a transition between digits and letters starts a
+// new sub list.
+func parseMaven(version string) *mavenList {
+	version = strings.ToLower(strings.TrimSpace(version))
+	root := &mavenList{}
+	list := root
+	stack := []*mavenList{root}
+	push := func() {
+		sub := &mavenList{}
+		*list = append(*list, sub)
+		list = sub
+		stack = append(stack, sub)
+	}
+	digit := false
+	start := 0
+	for i := 0; i < len(version); i++ {
+		c := version[i]
+		switch {
+		case c == '.':
+			if i == start {
+				*list = append(*list, mavenInt("0"))
+			} else {
+				*list = append(*list, newMavenItem(digit, version[start:i]))
+			}
+			start = i + 1
+		case c == '-':
+			if i == start {
+				*list = append(*list, mavenInt("0"))
+			} else {
+				*list = append(*list, newMavenItem(digit, version[start:i]))
+			}
+			start = i + 1
+			push()
+		case isDigit(c):
+			if !digit && i > start {
+				*list = append(*list, newMavenString(version[start:i], true))
+				start = i
+				push()
+			}
+			digit = true
+		default:
+			if digit && i > start {
+				*list = append(*list, mavenInt(version[start:i]))
+				start = i
+				push()
+			}
+			digit = false
+		}
+	}
+	if len(version) > start {
+		*list = append(*list, newMavenItem(digit, version[start:]))
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		stack[i].normalize()
+	}
+	return root
+}
+
+// compareMaven compares the maven artifact versions, every
+// string is a valid maven version so there's no error.
+func compareMaven(a, b string) (int, error) {
+	return parseMaven(a).compare(parseMaven(b)), nil
+}
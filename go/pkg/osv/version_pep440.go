@@ -0,0 +1,166 @@
+package osv
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// pep440Pattern is the pattern of python versions, which is
+// taken from the packaging library, see
+// https://peps.python.org/pep-0440/#appendix-b-parsing-version-strings-with-regular-expressions
+var pep440Pattern = regexp.MustCompile(`^v?` +
+	`(?:(?P<epoch>[0-9]+)!)?` +
+	`(?P<release>[0-9]+(?:\.[0-9]+)*)` +
+	`(?P<pre>[-_\.]?(?P<pre_l>alpha|beta|preview|pre|a|b|c|rc)[-_\.]?(?P<pre_n>[0-9]+)?)?` +
+	`(?P<post>(?:-(?P<post_n1>[0-9]+))|(?:[-_\.]?(?P<post_l>post|rev|r)[-_\.]?(?P<post_n2>[0-9]+)?))?` +
+	`(?P<dev>[-_\.]?(?P<dev_l>dev)[-_\.]?(?P<dev_n>[0-9]+)?)?` +
+	`(?:\+(?P<local>[a-z0-9]+(?:[-_\.][a-z0-9]+)*))?$`)
+
+// pep440Phases are the normalized pre-release phases.
+var pep440Phases = map[string]string{
+	"a": "a", "alpha": "a",
+	"b": "b", "beta": "b",
+	"c": "rc", "rc": "rc", "pre": "rc", "preview": "rc",
+}
+
+// pep440Key is a component of the sorting key, which might be
+// negative or positive infinity when the component is absent.
+type pep440Key struct {
+	infinity int
+	phase    string
+	number   string
+}
+
+func comparePEP440Key(a, b pep440Key) int {
+	if result := compareInt(int64(a.infinity),
+		int64(b.infinity)); result != 0 || a.infinity != 0 {
+		return result
+	}
+	if result := compareString(a.phase, b.phase); result != 0 {
+		return result
+	}
+	return compareNumeric(a.number, b.number)
+}
+
+// pep440Version is the parsed python version.
+type pep440Version struct {
+	epoch   string
+	release []string
+	pre     pep440Key
+	post    pep440Key
+	dev     pep440Key
+	local   []string
+}
+
+func parsePEP440(s string) (pep440Version, error) {
+	match := pep440Pattern.FindStringSubmatch(
+		strings.ToLower(strings.TrimSpace(s)))
+	if match == nil {
+		return pep440Version{}, xerrors.Errorf(
+			"invalid python version %q", s)
+	}
+	group := func(name string) string {
+		return match[pep440Pattern.SubexpIndex(name)]
+	}
+	result := pep440Version{epoch: group("epoch")}
+	result.release = strings.Split(group("release"), ".")
+	for len(result.release) > 1 &&
+		trimZeros(result.release[len(result.release)-1]) == "" {
+		result.release = result.release[:len(result.release)-1]
+	}
+
+	// The absent components are ordered as what the packaging
+	// library does, where the development release without
+	// pre-release and post-release sorts before pre-releases.
+	hasPre := group("pre") != ""
+	hasPost := group("post") != ""
+	hasDev := group("dev") != ""
+	switch {
+	case hasPre:
+		result.pre = pep440Key{
+			phase: pep440Phases[group("pre_l")], number: group("pre_n"),
+		}
+	case !hasPost && hasDev:
+		result.pre = pep440Key{infinity: -1}
+	default:
+		result.pre = pep440Key{infinity: 1}
+	}
+	if hasPost {
+		result.post = pep440Key{number: group("post_n1") + group("post_n2")}
+	} else {
+		result.post = pep440Key{infinity: -1}
+	}
+	if hasDev {
+		result.dev = pep440Key{number: group("dev_n")}
+	} else {
+		result.dev = pep440Key{infinity: 1}
+	}
+	if local := group("local"); local != "" {
+		result.local = strings.FieldsFunc(local, func(r rune) bool {
+			return r == '-' || r == '_' || r == '.'
+		})
+	}
+	return result, nil
+}
+
+// comparePEP440 compares the python versions.
+func comparePEP440(a, b string) (int, error) {
+	va, err := parsePEP440(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parsePEP440(b)
+	if err != nil {
+		return 0, err
+	}
+	if result := compareNumeric(va.epoch, vb.epoch); result != 0 {
+		return result, nil
+	}
+	for i := 0; i < len(va.release) || i < len(vb.release); i++ {
+		var ra, rb string
+		if i < len(va.release) {
+			ra = va.release[i]
+		}
+		if i < len(vb.release) {
+			rb = vb.release[i]
+		}
+		if result := compareNumeric(ra, rb); result != 0 {
+			return result, nil
+		}
+	}
+	for _, keys := range [][2]pep440Key{
+		{va.pre, vb.pre}, {va.post, vb.post}, {va.dev, vb.dev},
+	} {
+		if result := comparePEP440Key(keys[0], keys[1]); result != 0 {
+			return result, nil
+		}
+	}
+	for i := 0; i < len(va.local) && i < len(vb.local); i++ {
+		if result := comparePEP440Local(va.local[i], vb.local[i]); result != 0 {
+			return result, nil
+		}
+	}
+	return compareInt(int64(len(va.local)), int64(len(vb.local))), nil
+}
+
+// comparePEP440Local compares the local version segments, which
+// are compared numerically if both are numeric, and numeric
+// segments sort after the alphanumeric ones.
+func comparePEP440Local(a, b string) int {
+	numeric := func(s string) bool {
+		return strings.Trim(s, "0123456789") == ""
+	}
+	na, nb := numeric(a), numeric(b)
+	switch {
+	case na && nb:
+		return compareNumeric(a, b)
+	case na:
+		return 1
+	case nb:
+		return -1
+	default:
+		return compareString(a, b)
+	}
+}
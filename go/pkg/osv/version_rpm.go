@@ -0,0 +1,132 @@
+package osv
+
+import (
+	"strings"
+)
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || isAlpha(c)
+}
+
+// rpmVerCmp is a port of rpmvercmp in rpm, which compares the
+// alphabetic and numeric segments, where the tilde sorts before
+// everything and the caret sorts after the end of version.
+func rpmVerCmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	at := func(s string, i int) byte {
+		if i < len(s) {
+			return s[i]
+		}
+		return 0
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for i < len(a) && !isAlnum(a[i]) && a[i] != '~' && a[i] != '^' {
+			i++
+		}
+		for j < len(b) && !isAlnum(b[j]) && b[j] != '~' && b[j] != '^' {
+			j++
+		}
+		ca, cb := at(a, i), at(b, j)
+		if ca == '~' || cb == '~' {
+			if ca != '~' {
+				return 1
+			}
+			if cb != '~' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+		if ca == '^' || cb == '^' {
+			if ca == 0 {
+				return -1
+			}
+			if cb == 0 {
+				return 1
+			}
+			if ca != '^' {
+				return 1
+			}
+			if cb != '^' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+		if ca == 0 || cb == 0 {
+			break
+		}
+		si, sj := i, j
+		isNum := isDigit(ca)
+		segment := isAlpha
+		if isNum {
+			segment = isDigit
+		}
+		for i < len(a) && segment(a[i]) {
+			i++
+		}
+		for j < len(b) && segment(b[j]) {
+			j++
+		}
+		if sj == j {
+			// The segments are of different types, and the
+			// numeric segment is considered newer.
+			if isNum {
+				return 1
+			}
+			return -1
+		}
+		var result int
+		if isNum {
+			result = compareNumeric(a[si:i], b[sj:j])
+		} else {
+			result = compareString(a[si:i], b[sj:j])
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	switch {
+	case i >= len(a) && j >= len(b):
+		return 0
+	case i >= len(a):
+		return -1
+	default:
+		return 1
+	}
+}
+
+// splitRPMVersion splits the version in the form of
+// "[epoch:]version[-release]".
+func splitRPMVersion(s string) (epoch, version, release string) {
+	version = strings.TrimSpace(s)
+	if i := strings.IndexByte(version, ':'); i >= 0 {
+		epoch, version = version[:i], version[i+1:]
+	}
+	if i := strings.LastIndexByte(version, '-'); i >= 0 {
+		version, release = version[:i], version[i+1:]
+	}
+	return
+}
+
+// compareRPM compares the rpm package versions, the release is
+// only compared when both versions have specified it.
+func compareRPM(a, b string) (int, error) {
+	ea, va, ra := splitRPMVersion(a)
+	eb, vb, rb := splitRPMVersion(b)
+	if result := compareNumeric(ea, eb); result != 0 {
+		return result, nil
+	}
+	if result := rpmVerCmp(va, vb); result != 0 {
+		return result, nil
+	}
+	if ra == "" || rb == "" {
+		return 0, nil
+	}
+	return rpmVerCmp(ra, rb), nil
+}
@@ -0,0 +1,104 @@
+package osv
+
+import (
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// semver is the semantic version, see https://semver.org.
+type semver struct {
+	numbers    [3]string
+	prerelease []string
+}
+
+// parseSemver parses the semantic version leniently, which
+// accepts the "v" prefix of go modules, omitted minor and patch
+// numbers and pre-release without a hyphen like "1.21rc2".
+func parseSemver(s string) (semver, error) {
+	var result semver
+	version := s
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	i := 0
+	for n := 0; n < len(result.numbers); n++ {
+		start := i
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+		if start == i {
+			return semver{}, xerrors.Errorf(
+				"invalid semantic version %q", version)
+		}
+		result.numbers[n] = s[start:i]
+		if i >= len(s) || s[i] != '.' || n == len(result.numbers)-1 {
+			break
+		}
+		i++
+	}
+	rest := strings.TrimPrefix(s[i:], "-")
+	if rest != "" {
+		result.prerelease = strings.Split(rest, ".")
+	}
+	return result, nil
+}
+
+// compareIdentifier compares the pre-release identifiers,
+// where numeric identifiers sorts before the alphanumeric ones.
+func compareIdentifier(a, b string) int {
+	numeric := func(s string) bool {
+		for i := 0; i < len(s); i++ {
+			if !isDigit(s[i]) {
+				return false
+			}
+		}
+		return s != ""
+	}
+	na, nb := numeric(a), numeric(b)
+	switch {
+	case na && nb:
+		return compareNumeric(a, b)
+	case na:
+		return -1
+	case nb:
+		return 1
+	default:
+		return compareString(a, b)
+	}
+}
+
+// compareSemver compares the semantic versions.
+func compareSemver(a, b string) (int, error) {
+	va, err := parseSemver(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseSemver(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range va.numbers {
+		if result := compareNumeric(va.numbers[i], vb.numbers[i]); result != 0 {
+			return result, nil
+		}
+	}
+	// A version without pre-release has higher precedence.
+	switch {
+	case len(va.prerelease) == 0 && len(vb.prerelease) == 0:
+		return 0, nil
+	case len(va.prerelease) == 0:
+		return 1, nil
+	case len(vb.prerelease) == 0:
+		return -1, nil
+	}
+	for i := 0; i < len(va.prerelease) && i < len(vb.prerelease); i++ {
+		if result := compareIdentifier(
+			va.prerelease[i], vb.prerelease[i]); result != 0 {
+			return result, nil
+		}
+	}
+	return compareInt(int64(len(va.prerelease)),
+		int64(len(vb.prerelease))), nil
+}
@@ -0,0 +1,94 @@
+package osv
+
+import (
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, c := range []struct {
+		ecosystem string
+		a, b      string
+		want      int
+	}{
+		{Debian, "1.0", "1.0", 0},
+		{Debian, "1.0~rc1", "1.0", -1},
+		{Debian, "1:0.9", "2.0", 1},
+		{Debian, "2.31-13+deb11u5", "2.31-13+deb11u6", -1},
+		{Debian, "1.0a", "1.0", 1},
+		{Debian, "1.0-1", "1.0-1~bpo1", 1},
+		{"Debian:11", "1.0", "1.1", -1},
+		{Ubuntu, "3.0.2-0ubuntu1.10", "3.0.2-0ubuntu1.9", 1},
+		{RedHat, "1.2.3-4.el9", "1.2.3-10.el9", -1},
+		{RockyLinux, "1:3.0.7-16.el9", "3.0.7-17.el9", 1},
+		{AlmaLinux, "1.0~rc1", "1.0", -1},
+		{AlmaLinux, "1.0^git1", "1.0", 1},
+		{AlmaLinux, "1.0^git1", "1.0.1", -1},
+		{AlmaLinux, "1.0a", "1.0.1", -1},
+		{AlmaLinux, "2.0.1", "2.0", 1},
+		{Alpine, "1.2.3-r4", "1.2.3-r10", -1},
+		{Alpine, "1.2.3_rc1-r0", "1.2.3-r0", -1},
+		{Alpine, "1.2.3_p1-r0", "1.2.3-r0", 1},
+		{Alpine, "1.2.3a-r0", "1.2.3-r0", 1},
+		{Alpine, "1.2-r0", "1.2.1-r0", -1},
+		{Go, "v1.2.3", "1.2.10", -1},
+		{Go, "1.20.5", "1.21rc2", -1},
+		{NPM, "1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{NPM, "1.0.0-alpha.beta", "1.0.0-beta", -1},
+		{CratesIO, "1.0.0-rc.1", "1.0.0", -1},
+		{CratesIO, "1.0.0-2", "1.0.0-11", -1},
+		{CratesIO, "1.0.0+build.1", "1.0.0", 0},
+		{PyPI, "1.0", "1.0.0", 0},
+		{PyPI, "1.0.dev1", "1.0a1", -1},
+		{PyPI, "1.0a1", "1.0b1", -1},
+		{PyPI, "1.0rc1", "1.0", -1},
+		{PyPI, "1.0.post1", "1.0", 1},
+		{PyPI, "1.0+local.1", "1.0", 1},
+		{PyPI, "1!0.1", "2.0", 1},
+		{PyPI, "1.0.post1.dev1", "1.0.post1", -1},
+		{Maven, "1.0", "1.0.0", 0},
+		{Maven, "1.0-alpha-1", "1.0", -1},
+		{Maven, "1.0-SNAPSHOT", "1.0", -1},
+		{Maven, "1.0-rc1", "1.0-SNAPSHOT", -1},
+		{Maven, "1.0-sp1", "1.0", 1},
+		{Maven, "1.0.1", "1.0-sp1", 1},
+		{Maven, "2.17.1", "2.15.0", 1},
+		{Maven, "1.0.Final", "1.0", 0},
+		{Maven, "1-1", "1.1", -1},
+		{RubyGems, "1.0.0.pre", "1.0.0", -1},
+		{RubyGems, "1.0", "1.0.0", 0},
+		{RubyGems, "13.0.6", "13.0.10", -1},
+	} {
+		result, err := CompareVersions(c.ecosystem, c.a, c.b)
+		if err != nil || result != c.want {
+			t.Errorf("compare %s %q with %q: got %d, %v, want %d",
+				c.ecosystem, c.a, c.b, result, err, c.want)
+		}
+
+		// The comparison must be antisymmetric.
+		result, err = CompareVersions(c.ecosystem, c.b, c.a)
+		if err != nil || result != -c.want {
+			t.Errorf("compare %s %q with %q: got %d, %v, want %d",
+				c.ecosystem, c.b, c.a, result, err, -c.want)
+		}
+	}
+}
+
+func TestCompareVersionsMalformed(t *testing.T) {
+	for _, c := range []struct {
+		ecosystem string
+		version   string
+	}{
+		{Debian, "a:1.0"},
+		{Debian, ""},
+		{Alpine, "-r0"},
+		{Go, "latest"},
+		{NPM, ""},
+		{PyPI, "not a version"},
+		{RubyGems, "1.0$"},
+		{"Unknown", "1.0"},
+	} {
+		if _, err := CompareVersions(c.ecosystem, c.version, "1.0"); err == nil {
+			t.Errorf("malformed %s version %q compared", c.ecosystem, c.version)
+		}
+	}
+}
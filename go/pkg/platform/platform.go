@@ -0,0 +1,101 @@
+// Package platform parses and matches the platforms of
+// images, in the form of "os/arch[/variant]" like those
+// specified to "docker pull --platform".
+package platform
+
+import (
+	"strings"
+
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/xerrors"
+)
+
+// archAliases are the alternative names of architectures,
+// normalized in the same way as containerd.
+var archAliases = map[string]struct {
+	arch    string
+	variant string
+}{
+	"i386":    {"386", ""},
+	"x86_64":  {"amd64", ""},
+	"x86-64":  {"amd64", ""},
+	"aarch64": {"arm64", ""},
+	"armhf":   {"arm", "v7"},
+	"armel":   {"arm", "v6"},
+}
+
+// Normalize the platform, where the aliases of architectures
+// are replaced and the default variants are filled or removed.
+func Normalize(p imageV1.Platform) imageV1.Platform {
+	p.OS = strings.ToLower(p.OS)
+	p.Architecture = strings.ToLower(p.Architecture)
+	p.Variant = strings.ToLower(p.Variant)
+	if alias, ok := archAliases[p.Architecture]; ok {
+		p.Architecture = alias.arch
+		if p.Variant == "" {
+			p.Variant = alias.variant
+		}
+	}
+	switch p.Architecture {
+	case "arm64":
+		if p.Variant == "v8" || p.Variant == "8" {
+			p.Variant = ""
+		}
+	case "arm":
+		switch p.Variant {
+		case "", "7":
+			p.Variant = "v7"
+		case "5", "6", "8":
+			p.Variant = "v" + p.Variant
+		}
+	}
+	return p
+}
+
+// Parse the platform in the form of "os/arch[/variant]", the
+// result is normalized.
+func Parse(s string) (imageV1.Platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return imageV1.Platform{}, xerrors.Errorf("invalid platform %q", s)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return imageV1.Platform{}, xerrors.Errorf("invalid platform %q", s)
+		}
+	}
+	result := imageV1.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		result.Variant = parts[2]
+	}
+	return Normalize(result), nil
+}
+
+// Format the platform in the form of "os/arch[/variant]".
+func Format(p imageV1.Platform) string {
+	result := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		result += "/" + p.Variant
+	}
+	return result
+}
+
+// Match whether the platforms are the same after normalized,
+// the OS version and features are not compared.
+func Match(a, b imageV1.Platform) bool {
+	a, b = Normalize(a), Normalize(b)
+	return a.OS == b.OS && a.Architecture == b.Architecture &&
+		a.Variant == b.Variant
+}
+
+// FromConfig returns the platform recorded in the config of
+// image.
+func FromConfig(config *imageV1.Image) imageV1.Platform {
+	return imageV1.Platform{
+		OS:           config.OS,
+		Architecture: config.Architecture,
+		Variant:      config.Variant,
+		OSVersion:    config.OSVersion,
+		OSFeatures:   config.OSFeatures,
+	}
+}
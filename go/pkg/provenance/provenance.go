@@ -0,0 +1,261 @@
+// Package provenance traces the history of paths across the
+// layers of an image, answering questions like which layer
+// has introduced, overwritten or deleted a specific file.
+//
+// The trace is computed from the content of each layer and
+// its whiteouts and opaque directories, and each event is
+// aligned with the non-empty History entry in the image's
+// OCI specification, which is usually the Dockerfile step
+// that has created the layer.
+package provenance
+
+import (
+	"os"
+	"path/filepath"
+
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// Action is the kind of change a layer has made to a path.
+type Action int
+
+const (
+	// Added means the path is created by the layer, while it
+	// is absent in the merged view of the lower layers.
+	Added Action = iota
+
+	// Modified means the path is present in the lower layers
+	// and overwritten by the layer.
+	Modified
+
+	// Deleted means the path is present in the lower layers
+	// and removed by the layer, through whiteouts, opaque
+	// directories or replacing its parent with a non-directory.
+	Deleted
+)
+
+func (a Action) String() string {
+	switch a {
+	case Added:
+		return "added"
+	case Modified:
+		return "modified"
+	case Deleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change made to a path by a specific layer.
+type Event struct {
+	Path   string
+	Action Action
+
+	// Index is the index of the layer, counted from the
+	// bottom-most layer of the image.
+	Index   int
+	DiffID  string
+	LayerID string
+
+	// Digest is the digest of the layer blob, which is empty
+	// when the image fails to tell, like a tarball image whose
+	// digests have not been recorded while loading.
+	Digest string
+
+	// History is the non-empty history entry corresponding to
+	// the layer, which will be nil when the image specification
+	// does not record enough history for the layer.
+	History *imageV1.History
+
+	// Info is the file info of the path inside the layer,
+	// which is nil when the path is deleted.
+	Info os.FileInfo
+}
+
+// cleanPath normalizes the path into an absolute path.
+func cleanPath(path string) string {
+	return filepath.Clean(filepath.Join("/", path))
+}
+
+// isParentOf judges whether the dir is a strict ancestor of
+// the specified path, both of them must have been cleaned.
+func isParentOf(dir, path string) bool {
+	if dir == "/" {
+		return path != "/"
+	}
+	return len(path) > len(dir) && path[:len(dir)] == dir &&
+		path[len(dir)] == '/'
+}
+
+// layerHistories returns the history entries aligned with
+// each layer, by skipping those empty layer entries.
+func layerHistories(image api.Image) []*imageV1.History {
+	spec, err := image.OCISpecV1()
+	if err != nil || spec == nil {
+		return nil
+	}
+	var result []*imageV1.History
+	for i := range spec.History {
+		if spec.History[i].EmptyLayer {
+			continue
+		}
+		result = append(result, &spec.History[i])
+	}
+	return result
+}
+
+// layerState is the state of a layer while tracing.
+type layerState struct {
+	layer     api.Layer
+	whiteouts []string
+	opaques   []string
+}
+
+// deletes judges whether the layer has deleted the path
+// through whiteouts or opaque directories.
+func (s *layerState) deletes(path string) (whiteout, opaque bool) {
+	for _, w := range s.whiteouts {
+		w = cleanPath(w)
+		if w == path || isParentOf(w, path) {
+			return true, false
+		}
+	}
+	for _, o := range s.opaques {
+		if isParentOf(cleanPath(o), path) {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// shadows judges whether one of the path's ancestors has
+// been replaced by a non-directory in the layer.
+func (s *layerState) shadows(path string) bool {
+	for dir := filepath.Dir(path); dir != "/"; dir = filepath.Dir(dir) {
+		info, err := s.layer.Lstat(dir)
+		if err != nil {
+			continue
+		}
+		return !info.IsDir()
+	}
+	return false
+}
+
+// LookupPaths traces the history of each path across the
+// layers of the image, with each layer opened only once.
+//
+// Paths are looked up literally inside each layer, that is,
+// symbolic links in their ancestors are not resolved. The
+// result maps each cleaned path to its events, ordered from
+// the bottom-most layer to the top-most one.
+func LookupPaths(
+	image api.LayeredImage, paths []string,
+) (map[string][]Event, error) {
+	histories := layerHistories(image)
+	exists := make(map[string]bool)
+	result := make(map[string][]Event)
+	var cleaned []string
+	for _, path := range paths {
+		path = cleanPath(path)
+		if _, ok := result[path]; ok {
+			continue
+		}
+		result[path] = nil
+		cleaned = append(cleaned, path)
+	}
+	numLayers := image.NumLayers()
+	for i := 0; i < numLayers; i++ {
+		if err := func() error {
+			layer, err := image.OpenLayer(i)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = layer.Close() }()
+			state := &layerState{layer: layer}
+			if state.whiteouts, err = layer.Whiteouts(); err != nil {
+				return err
+			}
+			if state.opaques, err = layer.Opaques(); err != nil {
+				return err
+			}
+			diffID, err := image.LayerDiffID(i)
+			if err != nil {
+				return err
+			}
+			// The digest is informative only, so the events are
+			// still traced without it.
+			digest, err := image.LayerDigest(i)
+			if err != nil {
+				digest = ""
+			}
+			layerID := layer.ID()
+			var history *imageV1.History
+			if i < len(histories) {
+				history = histories[i]
+			}
+			for _, path := range cleaned {
+				event := Event{
+					Path:    path,
+					Index:   i,
+					DiffID:  diffID,
+					LayerID: layerID,
+					Digest:  digest,
+					History: history,
+				}
+				whiteout, opaque := state.deletes(path)
+				if !whiteout {
+					if info, err := layer.Lstat(path); err == nil {
+						event.Info = info
+						event.Action = Added
+						if exists[path] {
+							event.Action = Modified
+						}
+						exists[path] = true
+						result[path] = append(result[path], event)
+						continue
+					}
+				}
+				if !exists[path] {
+					continue
+				}
+				if whiteout || opaque || state.shadows(path) {
+					event.Action = Deleted
+					exists[path] = false
+					result[path] = append(result[path], event)
+				}
+			}
+			return nil
+		}(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Lookup traces the history of a single path across the
+// layers of the image.
+func Lookup(image api.LayeredImage, path string) ([]Event, error) {
+	result, err := LookupPaths(image, []string{path})
+	if err != nil {
+		return nil, err
+	}
+	return result[cleanPath(path)], nil
+}
+
+// Introduced returns the event of the layer that has created
+// the path currently present in the image, or nil if the path
+// is absent in the image.
+func Introduced(events []Event) *Event {
+	for i := len(events) - 1; i >= 0; i-- {
+		switch events[i].Action {
+		case Added:
+			return &events[i]
+		case Deleted:
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// Package reference parses the image references into the
+// normalized form of docker, and matches images against the
+// patterns in the rules of api.Runtime.FindImageIDs, for the
+// runtimes implemented in pure Go.
+package reference
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultDomain = "docker.io"
+	legacyDomain  = "index.docker.io"
+	officialRepo  = "library/"
+)
+
+var (
+	pathPattern   = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*)*$`)
+	tagPattern    = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestPattern = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+	hexPattern    = regexp.MustCompile(`^[a-f0-9]+$`)
+)
+
+// Reference is the normalized image reference.
+type Reference struct {
+	Domain string
+	Path   string
+	Tag    string
+	Digest string
+}
+
+// Parse the reference and normalize it, the domain of docker
+// hub and the "library/" prefix of its official images are
+// filled when they are omitted. The tag remains empty when
+// it is unspecified.
+func Parse(s string) (*Reference, error) {
+	var result Reference
+	name := s
+	if i := strings.IndexByte(name, '@'); i >= 0 {
+		name, result.Digest = name[:i], name[i+1:]
+		if !digestPattern.MatchString(result.Digest) {
+			return nil, xerrors.Errorf("invalid digest in %q", s)
+		}
+	}
+	if i := strings.LastIndexByte(name, ':'); i >= 0 &&
+		!strings.ContainsRune(name[i+1:], '/') {
+		name, result.Tag = name[:i], name[i+1:]
+		if !tagPattern.MatchString(result.Tag) {
+			return nil, xerrors.Errorf("invalid tag in %q", s)
+		}
+	}
+	result.Domain, result.Path = splitDomain(name)
+	if !pathPattern.MatchString(result.Path) {
+		return nil, xerrors.Errorf("invalid reference %q", s)
+	}
+	return &result, nil
+}
+
+// splitDomain splits the name into domain and path, in the
+// same way as docker.
+func splitDomain(name string) (string, string) {
+	domain, path := defaultDomain, name
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		prefix := name[:i]
+		if strings.ContainsAny(prefix, ".:") ||
+			prefix == "localhost" || prefix != strings.ToLower(prefix) {
+			domain, path = prefix, name[i+1:]
+		}
+	}
+	if domain == legacyDomain {
+		domain = defaultDomain
+	}
+	if domain == defaultDomain && !strings.ContainsRune(path, '/') {
+		path = officialRepo + path
+	}
+	return domain, path
+}
+
+// Name returns the repository of the reference.
+func (r *Reference) Name() string {
+	return r.Domain + "/" + r.Path
+}
+
+func (r *Reference) String() string {
+	result := r.Name()
+	if r.Tag != "" {
+		result += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		result += "@" + r.Digest
+	}
+	return result
+}
+
+// Match judges whether the image of the ID and references
+// matches the pattern, by the rules of FindImageIDs.
+func Match(pattern, id string, refs []string) bool {
+	if pattern == "" {
+		return false
+	}
+	if pattern == id {
+		return true
+	}
+	if hexPattern.MatchString(pattern) {
+		hex := id
+		if i := strings.IndexByte(hex, ':'); i >= 0 {
+			hex = hex[i+1:]
+		}
+		if strings.HasPrefix(hex, pattern) {
+			return true
+		}
+	}
+	if strings.ContainsAny(pattern, "/:@") {
+		expected, err := Parse(pattern)
+		if err != nil {
+			return false
+		}
+		for _, ref := range refs {
+			actual, err := Parse(ref)
+			if err != nil {
+				continue
+			}
+			if expected.Tag == "" && expected.Digest == "" {
+				if actual.Name() == expected.Name() {
+					return true
+				}
+			} else if actual.String() == expected.String() {
+				return true
+			}
+		}
+		return false
+	}
+	for _, ref := range refs {
+		actual, err := Parse(ref)
+		if err != nil {
+			continue
+		}
+		if actual.Path == pattern ||
+			strings.HasSuffix(actual.Path, "/"+pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package sbom
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// apkCataloger collects packages from the apk installed
+// database of alpine and its derivatives.
+type apkCataloger struct{}
+
+const apkInstalledPath = "/lib/apk/db/installed"
+
+func (apkCataloger) Name() string {
+	return "apk"
+}
+
+func (apkCataloger) Catalog(fsys api.FileSystem) ([]Package, error) {
+	f, err := fsys.Open(apkInstalledPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	var result []Package
+	current := Package{Type: APK, Path: apkInstalledPath}
+	flush := func() {
+		if current.Name != "" && current.Version != "" {
+			if current.SourceName == "" {
+				current.SourceName = current.Name
+			}
+			current.SourceVersion = current.Version
+			result = append(result, current)
+		}
+		current = Package{Type: APK, Path: apkInstalledPath}
+	}
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			flush()
+		} else if len(line) >= 2 && line[1] == ':' {
+			value := line[2:]
+			switch line[0] {
+			case 'P':
+				current.Name = value
+			case 'V':
+				current.Version = value
+			case 'A':
+				current.Arch = value
+			case 'o':
+				current.SourceName = value
+			case 'm':
+				current.Maintainer = value
+			case 'L':
+				if value != "" {
+					current.Licenses = []string{value}
+				}
+			}
+		}
+		if err == io.EOF {
+			flush()
+			break
+		}
+	}
+	return result, nil
+}
+
+func init() {
+	RegisterCataloger(apkCataloger{})
+}
@@ -0,0 +1,83 @@
+package sbom
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+const cargoLockfile = "Cargo.lock"
+
+// cargoDetector collects the rust crates from the lockfiles
+// of cargo projects.
+type cargoDetector struct{}
+
+func (cargoDetector) Name() string {
+	return "cargo"
+}
+
+func (cargoDetector) Match(path string, info os.FileInfo) bool {
+	return info.Name() == cargoLockfile
+}
+
+// Detect parses the "[[package]]" tables in the lockfile,
+// which only consists of plain key value pairs and arrays,
+// so that a full TOML parser is not required.
+func (cargoDetector) Detect(
+	fsys api.FileSystem, path string,
+) ([]Package, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	var result []Package
+	var current *Package
+	flush := func() {
+		if current != nil && current.Name != "" && current.Version != "" {
+			result = append(result, *current)
+		}
+		current = nil
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			flush()
+			if line == "[[package]]" {
+				current = &Package{Type: Cargo, Path: path}
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:i])
+		value, err := strconv.Unquote(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "name":
+			current.Name = value
+		case "version":
+			current.Version = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return result, nil
+}
+
+func init() {
+	RegisterDetector(cargoDetector{})
+}
@@ -0,0 +1,151 @@
+package sbom
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Values of the CycloneDX document, see
+// https://cyclonedx.org/docs/1.5/json/ for their definitions.
+const (
+	cyclonedxFormat  = "CycloneDX"
+	cyclonedxVersion = "1.5"
+)
+
+type cyclonedxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cyclonedxLicense struct {
+	License struct {
+		Name string `json:"name"`
+	} `json:"license"`
+}
+
+type cyclonedxComponent struct {
+	BOMRef     string              `json:"bom-ref,omitempty"`
+	Type       string              `json:"type"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	Publisher  string              `json:"publisher,omitempty"`
+	Licenses   []cyclonedxLicense  `json:"licenses,omitempty"`
+	PURL       string              `json:"purl,omitempty"`
+	Properties []cyclonedxProperty `json:"properties,omitempty"`
+}
+
+type cyclonedxMetadata struct {
+	Timestamp string `json:"timestamp"`
+	Tools     struct {
+		Components []cyclonedxComponent `json:"components"`
+	} `json:"tools"`
+	Component cyclonedxComponent `json:"component"`
+}
+
+type cyclonedxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+type cyclonedxDocument struct {
+	BOMFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	SerialNumber string                `json:"serialNumber"`
+	Version      int                   `json:"version"`
+	Metadata     cyclonedxMetadata     `json:"metadata"`
+	Components   []cyclonedxComponent  `json:"components"`
+	Dependencies []cyclonedxDependency `json:"dependencies,omitempty"`
+}
+
+// WriteCycloneDX encodes the SBOM as a CycloneDX 1.5 document
+// in JSON.
+//
+// The scanned object is described as a container component in
+// the metadata, and the operating system and packages found are
+// listed as its components. Fields that have no counterpart in
+// CycloneDX are placed as properties prefixed by "veinmind:".
+func WriteCycloneDX(w io.Writer, sbom *SBOM) error {
+	doc := cyclonedxDocument{
+		BOMFormat:    cyclonedxFormat,
+		SpecVersion:  cyclonedxVersion,
+		SerialNumber: uuid.New().URN(),
+		Version:      1,
+		Components:   []cyclonedxComponent{},
+	}
+	doc.Metadata.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	doc.Metadata.Tools.Components = []cyclonedxComponent{{
+		Type: "application",
+		Name: toolName,
+	}}
+	doc.Metadata.Component = cyclonedxComponent{
+		BOMRef: "root",
+		Type:   "container",
+		Name:   sbom.Name,
+	}
+	root := cyclonedxDependency{Ref: doc.Metadata.Component.BOMRef}
+	if sbom.Distro != nil {
+		distro := cyclonedxComponent{
+			BOMRef:  "os:" + sbom.Distro.ID,
+			Type:    "operating-system",
+			Name:    sbom.Distro.ID,
+			Version: sbom.Distro.VersionID,
+		}
+		if sbom.Distro.PrettyName != "" {
+			distro.Properties = append(distro.Properties, cyclonedxProperty{
+				Name: "veinmind:distro:prettyName", Value: sbom.Distro.PrettyName,
+			})
+		}
+		if sbom.Distro.VersionCodename != "" {
+			distro.Properties = append(distro.Properties, cyclonedxProperty{
+				Name:  "veinmind:distro:versionCodename",
+				Value: sbom.Distro.VersionCodename,
+			})
+		}
+		doc.Components = append(doc.Components, distro)
+		root.DependsOn = append(root.DependsOn, distro.BOMRef)
+	}
+	for i, p := range sbom.Packages {
+		purl := p.PackageURL(sbom.Distro)
+		component := cyclonedxComponent{
+			// The package URL might be duplicated when the same
+			// package is recorded in multiple databases.
+			BOMRef:    purl + "#" + strconv.Itoa(i),
+			Type:      "library",
+			Name:      p.Name,
+			Version:   p.Version,
+			Publisher: p.Maintainer,
+			PURL:      purl,
+		}
+		for _, license := range p.Licenses {
+			var l cyclonedxLicense
+			l.License.Name = license
+			component.Licenses = append(component.Licenses, l)
+		}
+		property := func(name, value string) {
+			if value != "" {
+				component.Properties = append(component.Properties,
+					cyclonedxProperty{Name: "veinmind:" + name, Value: value})
+			}
+		}
+		property("package:type", p.Type.String())
+		property("package:sourceName", p.SourceName)
+		property("package:sourceVersion", p.SourceVersion)
+		property("location:path", p.Path)
+		property("location:innerPath", p.InnerPath)
+		if p.Layer != nil {
+			property("location:layerIndex", strconv.Itoa(p.Layer.Index))
+			property("location:layerID", p.Layer.ID)
+			property("location:layerDiffID", p.Layer.DiffID)
+		}
+		doc.Components = append(doc.Components, component)
+		root.DependsOn = append(root.DependsOn, component.BOMRef)
+	}
+	doc.Dependencies = []cyclonedxDependency{root}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
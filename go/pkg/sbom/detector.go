@@ -0,0 +1,159 @@
+package sbom
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+
+	"golang.org/x/xerrors"
+
+	api "github.com/chaitin/libveinmind/go"
+	"github.com/chaitin/libveinmind/go/pkg/provenance"
+)
+
+// Detector recognizes packages of a language ecosystem from
+// the files scattered around the file system, like the go
+// binaries, python site-packages and java archives.
+//
+// Unlike a Cataloger reading the package database at a fixed
+// location, a detector is consulted for each regular file
+// while walking the file system, and Detect is called only
+// when Match accepts the file.
+type Detector interface {
+	Name() string
+	Match(path string, info os.FileInfo) bool
+
+	// Detect collects packages from the matched file. Files
+	// that turn out to be unrecognized or malformed should be
+	// reported with no package and no error. The errors of
+	// accessing the file, like a dangling symlink, are skipped
+	// with warnings, while other errors abort the whole scan.
+	Detect(fsys api.FileSystem, path string) ([]Package, error)
+}
+
+var detectors = sync.Map{}
+
+// RegisterDetector to be used when scanning file systems.
+//
+// Registering a detector with the name of another detector
+// will replace it.
+func RegisterDetector(detector Detector) {
+	detectors.Store(detector.Name(), detector)
+}
+
+// loadDetectors loads the registered detectors in the order
+// of their names, filtered by the names specified.
+func loadDetectors(names map[string]struct{}) []Detector {
+	var result []Detector
+	detectors.Range(func(key, value interface{}) bool {
+		if names != nil {
+			if _, ok := names[key.(string)]; !ok {
+				return true
+			}
+		}
+		result = append(result, value.(Detector))
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name() < result[j].Name()
+	})
+	return result
+}
+
+// fileError judges whether the error is caused by accessing
+// the file being detected, like a dangling symlink or a file
+// unreadable, which should not fail the whole scan.
+func fileError(err error) bool {
+	var pathErr *os.PathError
+	var errno syscall.Errno
+	return xerrors.As(err, &pathErr) || xerrors.As(err, &errno)
+}
+
+// detect walks the file system and collects packages with
+// the detectors specified, along with the warnings of files
+// skipped since they cannot be accessed.
+func detect(
+	fsys api.FileSystem, detectors []Detector,
+) ([]Package, []string, error) {
+	if len(detectors) == 0 {
+		return nil, nil, nil
+	}
+	var result []Package
+	var warnings []string
+	if err := fsys.Walk("/", func(
+		path string, info os.FileInfo, err error,
+	) error {
+		// The unreadable portion of the file system is
+		// skipped instead of failing the whole scan.
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		for _, detector := range detectors {
+			if !detector.Match(path, info) {
+				continue
+			}
+			pkgs, err := detector.Detect(fsys, path)
+			if err != nil && fileError(err) {
+				warnings = append(warnings, fmt.Sprintf(
+					"detector %q: %s: %v", detector.Name(), path, err))
+				continue
+			}
+			if err != nil {
+				return xerrors.Errorf("detector %q: %s: %w",
+					detector.Name(), path, err)
+			}
+			result = append(result, pkgs...)
+		}
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+	return result, warnings, nil
+}
+
+// cleanPath normalizes the path into an absolute path.
+func cleanPath(path string) string {
+	return filepath.Clean(filepath.Join("/", path))
+}
+
+// locateLayers fills the layer of packages found in the image,
+// by tracing the history of the files where they are found.
+func locateLayers(image api.LayeredImage, pkgs []Package) error {
+	var paths []string
+	for _, pkg := range pkgs {
+		paths = append(paths, pkg.Path)
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	events, err := provenance.LookupPaths(image, paths)
+	if err != nil {
+		return err
+	}
+	layers := make(map[string]*LayerRef)
+	for path, history := range events {
+		for i := len(history) - 1; i >= 0; i-- {
+			event := history[i]
+			if event.Action == provenance.Deleted {
+				break
+			}
+			layers[path] = &LayerRef{
+				Index:  event.Index,
+				ID:     event.LayerID,
+				DiffID: event.DiffID,
+				Digest: event.Digest,
+			}
+			break
+		}
+	}
+	for i := range pkgs {
+		if layer, ok := layers[cleanPath(pkgs[i].Path)]; ok {
+			layer := *layer
+			pkgs[i].Layer = &layer
+		}
+	}
+	return nil
+}
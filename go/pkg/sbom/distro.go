@@ -0,0 +1,108 @@
+package sbom
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// Distro is the distribution of operating system, which is
+// parsed from the os-release file.
+//
+// See https://www.freedesktop.org/software/systemd/man/os-release.html
+// for the meaning of each field.
+type Distro struct {
+	ID              string
+	IDLike          []string
+	Name            string
+	PrettyName      string
+	Version         string
+	VersionID       string
+	VersionCodename string
+}
+
+// osReleasePaths are the candidate of os-release files, in
+// the order of searching.
+var osReleasePaths = []string{
+	"/etc/os-release",
+	"/usr/lib/os-release",
+}
+
+// parseOSRelease parses the shell compatible assignments in
+// the os-release file.
+func parseOSRelease(f api.File) (*Distro, error) {
+	result := &Distro{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			continue
+		}
+		key, value := line[:i], line[i+1:]
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') &&
+			value[len(value)-1] == value[0] {
+			if unquoted, err := strconv.Unquote(
+				`"` + value[1:len(value)-1] + `"`); err == nil {
+				value = unquoted
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		}
+		switch key {
+		case "ID":
+			result.ID = value
+		case "ID_LIKE":
+			result.IDLike = strings.Fields(value)
+		case "NAME":
+			result.Name = value
+		case "PRETTY_NAME":
+			result.PrettyName = value
+		case "VERSION":
+			result.Version = value
+		case "VERSION_ID":
+			result.VersionID = value
+		case "VERSION_CODENAME":
+			result.VersionCodename = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DetectDistro detects the distribution of operating system
+// inside the file system.
+//
+// It returns nil without error when there's no os-release
+// file, which is common for distroless and scratch images.
+func DetectDistro(fsys api.FileSystem) (*Distro, error) {
+	for _, path := range osReleasePaths {
+		f, err := fsys.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		result, err := func() (*Distro, error) {
+			defer func() { _ = f.Close() }()
+			return parseOSRelease(f)
+		}()
+		if err != nil {
+			return nil, err
+		}
+		if result.ID == "" {
+			result.ID = "linux"
+		}
+		return result, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,193 @@
+package sbom
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+const (
+	dpkgStatusPath    = "/var/lib/dpkg/status"
+	dpkgStatusDirPath = "/var/lib/dpkg/status.d"
+	dpkgDocPath       = "/usr/share/doc"
+)
+
+// maxCopyrightSize is the maximum size of copyright file to
+// read while collecting licenses of dpkg packages.
+const maxCopyrightSize = 1 << 20
+
+// readControl reads the paragraphs in the deb822 control file
+// format, which is used by the dpkg status database.
+func readControl(r io.Reader, f func(map[string]string)) error {
+	reader := bufio.NewReader(r)
+	paragraph := make(map[string]string)
+	var lastKey string
+	flush := func() {
+		if len(paragraph) > 0 {
+			f(paragraph)
+			paragraph = make(map[string]string)
+		}
+		lastKey = ""
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		trimmed := strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.TrimSpace(trimmed) == "":
+			flush()
+		case trimmed[0] == ' ' || trimmed[0] == '\t':
+			if lastKey != "" {
+				paragraph[lastKey] += "\n" + strings.TrimSpace(trimmed)
+			}
+		default:
+			if i := strings.IndexByte(trimmed, ':'); i > 0 {
+				lastKey = trimmed[:i]
+				paragraph[lastKey] = strings.TrimSpace(trimmed[i+1:])
+			}
+		}
+		if err == io.EOF {
+			flush()
+			return nil
+		}
+	}
+}
+
+// dpkgCataloger collects packages from the dpkg status
+// database and the status.d directory of distroless images.
+type dpkgCataloger struct{}
+
+func (dpkgCataloger) Name() string {
+	return "dpkg"
+}
+
+// dpkgLicenses collects the licenses declared in the machine
+// readable copyright file of the package.
+func dpkgLicenses(fsys api.FileSystem, name string) []string {
+	f, err := fsys.Open(path.Join(dpkgDocPath, name, "copyright"))
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+	seen := make(map[string]struct{})
+	var result []string
+	scanner := bufio.NewScanner(io.LimitReader(f, maxCopyrightSize))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "License:") {
+			continue
+		}
+		license := strings.TrimSpace(strings.TrimPrefix(line, "License:"))
+		if license == "" {
+			continue
+		}
+		if _, ok := seen[license]; ok {
+			continue
+		}
+		seen[license] = struct{}{}
+		result = append(result, license)
+	}
+	return result
+}
+
+func newDpkgPackage(
+	fsys api.FileSystem, p string, fields map[string]string,
+) (Package, bool) {
+	name, version := fields["Package"], fields["Version"]
+	if name == "" || version == "" {
+		return Package{}, false
+	}
+	// The status.d of distroless images does not have the
+	// status field, and all packages there are installed.
+	if status, ok := fields["Status"]; ok {
+		items := strings.Fields(status)
+		if len(items) < 3 || items[2] != "installed" {
+			return Package{}, false
+		}
+	}
+	result := Package{
+		Type:          Deb,
+		Name:          name,
+		Version:       version,
+		Arch:          fields["Architecture"],
+		SourceName:    name,
+		SourceVersion: version,
+		Maintainer:    fields["Maintainer"],
+		Path:          p,
+	}
+	if source := fields["Source"]; source != "" {
+		// The source field is in the form of "name (version)"
+		// when the version differs from the binary package.
+		if i := strings.IndexByte(source, '('); i >= 0 {
+			result.SourceName = strings.TrimSpace(source[:i])
+			result.SourceVersion = strings.Trim(
+				strings.TrimSpace(source[i:]), "()")
+		} else {
+			result.SourceName = source
+		}
+	}
+	result.Licenses = dpkgLicenses(fsys, name)
+	return result, true
+}
+
+func (c dpkgCataloger) catalogFile(
+	fsys api.FileSystem, p string,
+) ([]Package, error) {
+	f, err := fsys.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	var result []Package
+	if err := readControl(f, func(fields map[string]string) {
+		if pkg, ok := newDpkgPackage(fsys, p, fields); ok {
+			result = append(result, pkg)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c dpkgCataloger) Catalog(fsys api.FileSystem) ([]Package, error) {
+	result, err := c.catalogFile(fsys, dpkgStatusPath)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := fsys.Readdir(dpkgStatusDirPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		pkgs, err := c.catalogFile(fsys,
+			path.Join(dpkgStatusDirPath, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, pkgs...)
+	}
+	return result, nil
+}
+
+func init() {
+	RegisterCataloger(dpkgCataloger{})
+}
@@ -0,0 +1,80 @@
+//go:build go1.18
+// +build go1.18
+
+// The build information is read by debug/buildinfo, which is
+// added in go 1.18, so the detector is left unregistered on
+// earlier toolchains.
+
+package sbom
+
+import (
+	"debug/buildinfo"
+	"os"
+	"strings"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// golangDetector collects the modules compiled into the go
+// binaries from their embedded build information.
+type golangDetector struct{}
+
+func (golangDetector) Name() string {
+	return "golang"
+}
+
+// Match accepts executable files only, since there's no way
+// to tell a go binary from its path.
+func (golangDetector) Match(path string, info os.FileInfo) bool {
+	return info.Mode()&0111 != 0 && info.Size() > 0
+}
+
+func (golangDetector) Detect(
+	fsys api.FileSystem, path string,
+) ([]Package, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := buildinfo.Read(f)
+	if err != nil {
+		// Not a go binary or built without module support.
+		return nil, nil
+	}
+	newPackage := func(name, version string) Package {
+		return Package{
+			Type:    Golang,
+			Name:    name,
+			Version: version,
+			Path:    path,
+		}
+	}
+	var result []Package
+	if version := strings.TrimPrefix(info.GoVersion, "go"); version != "" {
+		// The standard library is recorded as it might be
+		// vulnerable, just like the other modules.
+		result = append(result, newPackage("stdlib", version))
+	}
+	// The main module built from a working tree has version
+	// "(devel)", which is meaningless to be recorded.
+	if info.Main.Path != "" && info.Main.Version != "(devel)" {
+		result = append(result, newPackage(
+			info.Main.Path, info.Main.Version))
+	}
+	for _, dep := range info.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		// Modules replaced by local directories have no version.
+		if dep.Path == "" || dep.Version == "" {
+			continue
+		}
+		result = append(result, newPackage(dep.Path, dep.Version))
+	}
+	return result, nil
+}
+
+func init() {
+	RegisterDetector(golangDetector{})
+}
@@ -0,0 +1,87 @@
+//go:build go1.18
+// +build go1.18
+
+package sbom
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/chaitin/libveinmind/go/pkg/iofs"
+)
+
+func TestGolangDetector(t *testing.T) {
+	// The test binary itself is a go binary with the build
+	// information of its dependencies.
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := iofs.NewFileSystem(fstest.MapFS{
+		"usr/bin/app":    {Data: data, Mode: 0755},
+		"usr/bin/script": {Data: []byte("#!/bin/sh\n"), Mode: 0755},
+	})
+	var d golangDetector
+	for _, c := range []struct {
+		path string
+		want map[string]bool
+		err  bool
+	}{
+		{path: "/usr/bin/app", want: map[string]bool{
+			"stdlib": true, "golang.org/x/xerrors": true,
+		}},
+		{path: "/usr/bin/script"},
+		{path: "/usr/bin/missing", err: true},
+	} {
+		pkgs, err := d.Detect(fsys, c.path)
+		if (err != nil) != c.err {
+			t.Errorf("detect %q: unexpected error %v", c.path, err)
+			continue
+		}
+		if len(c.want) == 0 && len(pkgs) != 0 {
+			t.Errorf("detect %q: unexpected packages %v", c.path, pkgs)
+		}
+		found := make(map[string]bool)
+		for _, pkg := range pkgs {
+			if pkg.Type != Golang || pkg.Version == "" || pkg.Path != c.path {
+				t.Errorf("detect %q: unexpected package %+v", c.path, pkg)
+			}
+			found[pkg.Name] = true
+		}
+		for name := range c.want {
+			if !found[name] {
+				t.Errorf("detect %q: package %q not found", c.path, name)
+			}
+		}
+	}
+}
+
+func TestGolangDetectorMatch(t *testing.T) {
+	var d golangDetector
+	for _, c := range []struct {
+		mode os.FileMode
+		size int64
+		want bool
+	}{
+		{0755, 1024, true},
+		{0700, 1024, true},
+		{0644, 1024, false},
+		{0755, 0, false},
+	} {
+		info := fstest.MapFS{"app": {Data: make([]byte, c.size), Mode: c.mode}}
+		stat, err := info.Stat("app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := d.Match("/app", stat); result != c.want {
+			t.Errorf("match mode %v size %d: got %v, want %v",
+				c.mode, c.size, result, c.want)
+		}
+	}
+}
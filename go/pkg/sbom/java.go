@@ -0,0 +1,268 @@
+package sbom
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+const (
+	javaManifestPath  = "META-INF/MANIFEST.MF"
+	javaMavenDir      = "META-INF/maven/"
+	javaPomProperties = "pom.properties"
+
+	// javaMaxDepth is the maximum depth of nested archives,
+	// like the jars inside the war inside the ear.
+	javaMaxDepth = 4
+
+	// javaMaxNestedSize is the maximum size of nested archive
+	// to inspect, which must be loaded into memory.
+	javaMaxNestedSize = 64 << 20
+
+	// javaMaxMetadataSize is the maximum size of manifest or
+	// properties to read.
+	javaMaxMetadataSize = 1 << 20
+)
+
+// javaArchiveExts are the extensions of java archives.
+var javaArchiveExts = map[string]struct{}{
+	".jar": {}, ".war": {}, ".ear": {},
+	".par": {}, ".sar": {}, ".jpi": {}, ".hpi": {},
+}
+
+func isJavaArchive(name string) bool {
+	_, ok := javaArchiveExts[strings.ToLower(path.Ext(name))]
+	return ok
+}
+
+// javaDetector collects the maven artifacts packaged in the
+// java archives, including those nested inside, from their
+// pom.properties or manifest.
+type javaDetector struct{}
+
+func (javaDetector) Name() string {
+	return "java"
+}
+
+func (javaDetector) Match(path string, info os.FileInfo) bool {
+	return isJavaArchive(info.Name())
+}
+
+// readZipFile reads the content of the file inside archive.
+func readZipFile(f *zip.File, limit int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rc.Close() }()
+	return ioutil.ReadAll(io.LimitReader(rc, limit))
+}
+
+// readJavaManifest parses the main section of the manifest,
+// where long lines are continued by a leading space.
+func readJavaManifest(data []byte) map[string]string {
+	result := make(map[string]string)
+	var lastKey string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			// The end of main section.
+			break
+		}
+		if line[0] == ' ' {
+			if lastKey != "" {
+				result[lastKey] += line[1:]
+			}
+			continue
+		}
+		if i := strings.Index(line, ": "); i > 0 {
+			lastKey = line[:i]
+			result[lastKey] = line[i+2:]
+		}
+	}
+	return result
+}
+
+// readJavaProperties parses the key value pairs in the java
+// properties file, which is sufficient for pom.properties.
+func readJavaProperties(data []byte) map[string]string {
+	result := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		if i := strings.IndexAny(line, "=:"); i > 0 {
+			result[strings.TrimSpace(line[:i])] =
+				strings.TrimSpace(line[i+1:])
+		}
+	}
+	return result
+}
+
+// javaArchive is the archive being inspected.
+type javaArchive struct {
+	path      string
+	innerPath string
+	name      string
+	reader    *zip.Reader
+}
+
+func (a *javaArchive) newPackage(group, artifact, version string) Package {
+	name := artifact
+	if group != "" {
+		name = group + ":" + artifact
+	}
+	return Package{
+		Type:      Maven,
+		Name:      name,
+		Version:   version,
+		Path:      a.path,
+		InnerPath: a.innerPath,
+	}
+}
+
+// fromManifest guesses the artifact from the manifest, when
+// there's no pom.properties inside the archive.
+func (a *javaArchive) fromManifest(manifest map[string]string) *Package {
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if value := manifest[key]; value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+	artifact := first("Implementation-Title",
+		"Bundle-SymbolicName", "Specification-Title")
+	if i := strings.IndexByte(artifact, ';'); i >= 0 {
+		// Directives of the bundle symbolic name.
+		artifact = artifact[:i]
+	}
+	if artifact == "" {
+		artifact = strings.TrimSuffix(a.name, path.Ext(a.name))
+	}
+	version := first("Implementation-Version",
+		"Bundle-Version", "Specification-Version")
+	if version == "" {
+		return nil
+	}
+	result := a.newPackage(first("Implementation-Vendor-Id"),
+		strings.TrimSpace(artifact), version)
+	result.Maintainer = first("Implementation-Vendor", "Bundle-Vendor")
+	if license := first("Bundle-License"); license != "" {
+		result.Licenses = []string{license}
+	}
+	return &result
+}
+
+// inspect collects packages in the archive and the archives
+// nested inside it.
+func (a *javaArchive) inspect(depth int) ([]Package, error) {
+	var result []Package
+	var manifest map[string]string
+	var nested []*zip.File
+	for _, f := range a.reader.File {
+		switch {
+		case f.Name == javaManifestPath:
+			data, err := readZipFile(f, javaMaxMetadataSize)
+			if err != nil {
+				continue
+			}
+			manifest = readJavaManifest(data)
+		case strings.HasPrefix(f.Name, javaMavenDir) &&
+			path.Base(f.Name) == javaPomProperties:
+			data, err := readZipFile(f, javaMaxMetadataSize)
+			if err != nil {
+				continue
+			}
+			props := readJavaProperties(data)
+			if props["artifactId"] == "" || props["version"] == "" {
+				continue
+			}
+			result = append(result, a.newPackage(props["groupId"],
+				props["artifactId"], props["version"]))
+		case isJavaArchive(f.Name) && !f.FileInfo().IsDir():
+			nested = append(nested, f)
+		}
+	}
+	if len(result) == 0 && manifest != nil {
+		if pkg := a.fromManifest(manifest); pkg != nil {
+			result = append(result, *pkg)
+		}
+	}
+	if depth >= javaMaxDepth {
+		return result, nil
+	}
+	sort.Slice(nested, func(i, j int) bool {
+		return nested[i].Name < nested[j].Name
+	})
+	for _, f := range nested {
+		if f.UncompressedSize64 > javaMaxNestedSize {
+			continue
+		}
+		data, err := readZipFile(f, javaMaxNestedSize)
+		if err != nil {
+			continue
+		}
+		reader, err := zip.NewReader(
+			bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			continue
+		}
+		innerPath := f.Name
+		if a.innerPath != "" {
+			innerPath = a.innerPath + "!/" + f.Name
+		}
+		pkgs, err := (&javaArchive{
+			path:      a.path,
+			innerPath: innerPath,
+			name:      path.Base(f.Name),
+			reader:    reader,
+		}).inspect(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, pkgs...)
+	}
+	return result, nil
+}
+
+func (javaDetector) Detect(
+	fsys api.FileSystem, path string,
+) ([]Package, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	reader, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		// Not a valid archive.
+		return nil, nil
+	}
+	return (&javaArchive{
+		path:   path,
+		name:   filepath.Base(path),
+		reader: reader,
+	}).inspect(0)
+}
+
+func init() {
+	RegisterDetector(javaDetector{})
+}
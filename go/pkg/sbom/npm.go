@@ -0,0 +1,220 @@
+package sbom
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+const (
+	npmLockfile    = "package-lock.json"
+	npmManifest    = "package.json"
+	npmModulesDir  = "node_modules"
+	npmModulesPath = "/" + npmModulesDir + "/"
+)
+
+// npmPerson is the author field in package.json, which is
+// either a string or an object.
+type npmPerson string
+
+func (p *npmPerson) UnmarshalJSON(data []byte) error {
+	var object struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &object); err == nil {
+		*p = npmPerson(object.Name)
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+	*p = npmPerson(value)
+	return nil
+}
+
+// npmLicense is the license field in package.json, which is
+// either an SPDX expression or an object in legacy packages.
+type npmLicense string
+
+func (l *npmLicense) UnmarshalJSON(data []byte) error {
+	var object struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &object); err == nil {
+		*l = npmLicense(object.Type)
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+	*l = npmLicense(value)
+	return nil
+}
+
+type npmManifestFile struct {
+	Name    string     `json:"name"`
+	Version string     `json:"version"`
+	License npmLicense `json:"license"`
+	Author  npmPerson  `json:"author"`
+}
+
+type npmLockDependency struct {
+	Version      string                       `json:"version"`
+	Dependencies map[string]npmLockDependency `json:"dependencies"`
+}
+
+type npmLockPackage struct {
+	Name    string     `json:"name"`
+	Version string     `json:"version"`
+	License npmLicense `json:"license"`
+	Link    bool       `json:"link"`
+}
+
+type npmLockFile struct {
+	LockfileVersion int                          `json:"lockfileVersion"`
+	Packages        map[string]npmLockPackage    `json:"packages"`
+	Dependencies    map[string]npmLockDependency `json:"dependencies"`
+}
+
+// npmDetector collects the npm packages from the lockfiles
+// of node projects and the packages installed under the
+// node_modules directories.
+type npmDetector struct{}
+
+func (npmDetector) Name() string {
+	return "npm"
+}
+
+// isNpmModule judges whether the manifest is the one of the
+// package installed under node_modules, which is either
+// "node_modules/name" or "node_modules/@scope/name".
+func isNpmModule(path string) bool {
+	dir := filepath.Dir(path)
+	parent := filepath.Dir(dir)
+	if strings.HasPrefix(filepath.Base(parent), "@") {
+		parent = filepath.Dir(parent)
+	}
+	return filepath.Base(parent) == npmModulesDir
+}
+
+func (npmDetector) Match(path string, info os.FileInfo) bool {
+	switch info.Name() {
+	case npmLockfile:
+		return !strings.Contains(path, npmModulesPath)
+	case npmManifest:
+		return isNpmModule(path)
+	}
+	return false
+}
+
+func readJSON(fsys api.FileSystem, path string, v interface{}) (bool, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+func newNpmPackage(path, name, version, license, author string) Package {
+	result := Package{
+		Type:       NPM,
+		Name:       name,
+		Version:    version,
+		Maintainer: author,
+		Path:       path,
+	}
+	if license != "" {
+		result.Licenses = []string{license}
+	}
+	return result
+}
+
+func (npmDetector) detectManifest(
+	fsys api.FileSystem, path string,
+) ([]Package, error) {
+	var manifest npmManifestFile
+	ok, err := readJSON(fsys, path, &manifest)
+	if err != nil || !ok {
+		return nil, err
+	}
+	if manifest.Name == "" || manifest.Version == "" {
+		return nil, nil
+	}
+	return []Package{newNpmPackage(path, manifest.Name, manifest.Version,
+		string(manifest.License), string(manifest.Author))}, nil
+}
+
+func (npmDetector) detectLockfile(
+	fsys api.FileSystem, path string,
+) ([]Package, error) {
+	var lockfile npmLockFile
+	ok, err := readJSON(fsys, path, &lockfile)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var result []Package
+	if lockfile.Packages != nil {
+		// Since lockfile version 2, packages are keyed by
+		// their location, and the root project is keyed by "".
+		var keys []string
+		for key := range lockfile.Packages {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			pkg := lockfile.Packages[key]
+			i := strings.LastIndex(key, npmModulesDir+"/")
+			if i < 0 || pkg.Link || pkg.Version == "" {
+				continue
+			}
+			name := pkg.Name
+			if name == "" {
+				name = key[i+len(npmModulesDir)+1:]
+			}
+			result = append(result, newNpmPackage(
+				path, name, pkg.Version, string(pkg.License), ""))
+		}
+		return result, nil
+	}
+	var walk func(map[string]npmLockDependency)
+	walk = func(deps map[string]npmLockDependency) {
+		var names []string
+		for name := range deps {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			dep := deps[name]
+			if dep.Version != "" {
+				result = append(result, newNpmPackage(
+					path, name, dep.Version, "", ""))
+			}
+			walk(dep.Dependencies)
+		}
+	}
+	walk(lockfile.Dependencies)
+	return result, nil
+}
+
+func (d npmDetector) Detect(
+	fsys api.FileSystem, path string,
+) ([]Package, error) {
+	if filepath.Base(path) == npmLockfile {
+		return d.detectLockfile(fsys, path)
+	}
+	return d.detectManifest(fsys, path)
+}
+
+func init() {
+	RegisterDetector(npmDetector{})
+}
@@ -0,0 +1,102 @@
+package sbom
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// purlEscape percent-encodes all characters except the
+// unreserved ones, so that the component is safe to be placed
+// anywhere in the package URL.
+func purlEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z',
+			'0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// purlNamespaces are the namespaces of package types when the
+// distribution cannot be recognized.
+var purlNamespaces = map[PackageType]string{
+	Deb: "debian",
+	APK: "alpine",
+}
+
+// PackageURL returns the package URL of the package, which is
+// used to identify the package across tools.
+//
+// The distro is used as the namespace and the distro qualifier
+// of the OS packages, and it might be nil if unknown. The
+// namespace of the other packages is derived from their name.
+//
+// See https://github.com/package-url/purl-spec for details.
+func (p Package) PackageURL(distro *Distro) string {
+	var namespace []string
+	name := p.Name
+	qualifiers := make(map[string]string)
+	switch p.Type {
+	case Deb, RPM, APK:
+		if ns := purlNamespaces[p.Type]; ns != "" {
+			namespace = []string{ns}
+		}
+		if distro != nil {
+			namespace = []string{distro.ID}
+			qualifiers["distro"] = distro.ID
+			if distro.VersionID != "" {
+				qualifiers["distro"] += "-" + distro.VersionID
+			}
+		}
+		if p.Arch != "" {
+			qualifiers["arch"] = p.Arch
+		}
+		if p.Type == RPM && p.Epoch != 0 {
+			qualifiers["epoch"] = strconv.Itoa(p.Epoch)
+		}
+	case Golang, NPM:
+		if i := strings.LastIndexByte(name, '/'); i >= 0 {
+			namespace = strings.Split(name[:i], "/")
+			name = name[i+1:]
+		}
+	case Maven:
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			namespace = []string{name[:i]}
+			name = name[i+1:]
+		}
+	case PyPI:
+		name = strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+	}
+
+	var b strings.Builder
+	b.WriteString("pkg:" + p.Type.String() + "/")
+	for _, segment := range namespace {
+		b.WriteString(purlEscape(segment) + "/")
+	}
+	b.WriteString(purlEscape(name))
+	if p.Version != "" {
+		b.WriteString("@" + purlEscape(p.Version))
+	}
+	var keys []string
+	for key := range qualifiers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		if i == 0 {
+			b.WriteString("?")
+		} else {
+			b.WriteString("&")
+		}
+		b.WriteString(key + "=" + purlEscape(qualifiers[key]))
+	}
+	return b.String()
+}
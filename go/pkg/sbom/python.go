@@ -0,0 +1,81 @@
+package sbom
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// maxPythonMetadataSize is the maximum size of the metadata
+// file to read, the headers we are interested in are always
+// placed before the long description.
+const maxPythonMetadataSize = 1 << 20
+
+// pythonDetector collects the installed python distributions
+// from the metadata inside the site-packages.
+type pythonDetector struct{}
+
+func (pythonDetector) Name() string {
+	return "python"
+}
+
+func (pythonDetector) Match(path string, info os.FileInfo) bool {
+	dir := filepath.Base(filepath.Dir(path))
+	switch info.Name() {
+	case "METADATA":
+		return strings.HasSuffix(dir, ".dist-info")
+	case "PKG-INFO":
+		return strings.HasSuffix(dir, ".egg-info")
+	}
+	// The legacy distutils installs the metadata as a file.
+	return strings.HasSuffix(info.Name(), ".egg-info")
+}
+
+func (pythonDetector) Detect(
+	fsys api.FileSystem, path string,
+) ([]Package, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	var fields map[string]string
+	if err := readControl(io.LimitReader(f, maxPythonMetadataSize),
+		func(paragraph map[string]string) {
+			// Only the headers before the long description.
+			if fields == nil {
+				fields = paragraph
+			}
+		}); err != nil {
+		return nil, err
+	}
+	name, version := fields["Name"], fields["Version"]
+	if name == "" || version == "" {
+		return nil, nil
+	}
+	result := Package{
+		Type:       PyPI,
+		Name:       name,
+		Version:    version,
+		Maintainer: fields["Author"],
+		Path:       path,
+	}
+	license := fields["License-Expression"]
+	if license == "" {
+		license = fields["License"]
+	}
+	// Some distributions place the full license text in the
+	// license field, which is not useful as a license name.
+	if license != "" && license != "UNKNOWN" &&
+		!strings.Contains(license, "\n") {
+		result.Licenses = []string{license}
+	}
+	return []Package{result}, nil
+}
+
+func init() {
+	RegisterDetector(pythonDetector{})
+}
@@ -0,0 +1,247 @@
+package sbom
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// rpmdbFormat is the storage format of an rpm database.
+type rpmdbFormat int
+
+const (
+	rpmdbSQLite rpmdbFormat = iota
+	rpmdbNDB
+	rpmdbBDB
+)
+
+// rpmdbPaths are the candidate rpm databases, in the order of
+// preference. The first database found will be used, since
+// stale databases might be left behind when rpm migrates.
+var rpmdbPaths = []struct {
+	path   string
+	format rpmdbFormat
+}{
+	{"/usr/lib/sysimage/rpm/rpmdb.sqlite", rpmdbSQLite},
+	{"/var/lib/rpm/rpmdb.sqlite", rpmdbSQLite},
+	{"/usr/lib/sysimage/rpm/Packages.db", rpmdbNDB},
+	{"/var/lib/rpm/Packages.db", rpmdbNDB},
+	{"/usr/lib/sysimage/rpm/Packages", rpmdbBDB},
+	{"/var/lib/rpm/Packages", rpmdbBDB},
+}
+
+// Tags and types of the rpm header that we are interested
+// in, see rpmtag.h in rpm for their definitions.
+const (
+	rpmTagName      = 1000
+	rpmTagVersion   = 1001
+	rpmTagRelease   = 1002
+	rpmTagEpoch     = 1003
+	rpmTagVendor    = 1011
+	rpmTagLicense   = 1014
+	rpmTagPackager  = 1015
+	rpmTagArch      = 1022
+	rpmTagSourceRPM = 1044
+
+	rpmTypeInt32       = 4
+	rpmTypeString      = 6
+	rpmTypeStringArray = 8
+	rpmTypeI18NString  = 9
+
+	rpmIndexEntrySize = 16
+)
+
+// rpmHeader is the parsed tags of an rpm header blob.
+type rpmHeader struct {
+	strings map[int32]string
+	ints    map[int32]int32
+}
+
+// parseRPMHeader parses the header blob stored in the rpm
+// database, which consists of the index length, data length,
+// index entries and the data store, without the lead magic.
+func parseRPMHeader(blob []byte) (*rpmHeader, error) {
+	if len(blob) < 8 {
+		return nil, xerrors.New("rpm header too short")
+	}
+	il := binary.BigEndian.Uint32(blob[0:4])
+	dl := binary.BigEndian.Uint32(blob[4:8])
+	dataStart := 8 + uint64(il)*rpmIndexEntrySize
+	if dataStart+uint64(dl) > uint64(len(blob)) {
+		return nil, xerrors.New("rpm header out of range")
+	}
+	data := blob[dataStart : dataStart+uint64(dl)]
+	result := &rpmHeader{
+		strings: make(map[int32]string),
+		ints:    make(map[int32]int32),
+	}
+	for i := uint64(0); i < uint64(il); i++ {
+		entry := blob[8+i*rpmIndexEntrySize : 8+(i+1)*rpmIndexEntrySize]
+		tag := int32(binary.BigEndian.Uint32(entry[0:4]))
+		typ := binary.BigEndian.Uint32(entry[4:8])
+		offset := int32(binary.BigEndian.Uint32(entry[8:12]))
+		count := binary.BigEndian.Uint32(entry[12:16])
+		if offset < 0 || int(offset) >= len(data) {
+			continue
+		}
+		switch tag {
+		case rpmTagName, rpmTagVersion, rpmTagRelease,
+			rpmTagVendor, rpmTagLicense, rpmTagPackager,
+			rpmTagArch, rpmTagSourceRPM:
+			if typ != rpmTypeString && typ != rpmTypeI18NString &&
+				typ != rpmTypeStringArray {
+				continue
+			}
+			// Only the first string is taken for the i18n
+			// string and string array.
+			value := data[offset:]
+			if end := indexZero(value); end >= 0 {
+				value = value[:end]
+			}
+			result.strings[tag] = string(value)
+		case rpmTagEpoch:
+			if typ != rpmTypeInt32 || count < 1 ||
+				int(offset)+4 > len(data) {
+				continue
+			}
+			result.ints[tag] = int32(binary.BigEndian.Uint32(
+				data[offset : offset+4]))
+		}
+	}
+	return result, nil
+}
+
+func indexZero(b []byte) int {
+	for i, c := range b {
+		if c == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitSourceRPM splits the source rpm file name, which is in
+// the form of "name-version-release.src.rpm".
+func splitSourceRPM(sourceRPM string) (name, version string) {
+	s := strings.TrimSuffix(sourceRPM, ".rpm")
+	if i := strings.LastIndexByte(s, '.'); i >= 0 {
+		s = s[:i]
+	}
+	i := strings.LastIndexByte(s, '-')
+	if i < 0 {
+		return "", ""
+	}
+	j := strings.LastIndexByte(s[:i], '-')
+	if j < 0 {
+		return "", ""
+	}
+	return s[:j], s[j+1:]
+}
+
+func newRPMPackage(p string, blob []byte) (Package, bool, error) {
+	hdr, err := parseRPMHeader(blob)
+	if err != nil {
+		return Package{}, false, err
+	}
+	name := hdr.strings[rpmTagName]
+	version := hdr.strings[rpmTagVersion]
+	// The public keys imported are also stored in the rpm
+	// database as packages, which must be excluded.
+	if name == "" || version == "" || name == "gpg-pubkey" {
+		return Package{}, false, nil
+	}
+	if release := hdr.strings[rpmTagRelease]; release != "" {
+		version += "-" + release
+	}
+	result := Package{
+		Type:       RPM,
+		Name:       name,
+		Version:    version,
+		Epoch:      int(hdr.ints[rpmTagEpoch]),
+		Arch:       hdr.strings[rpmTagArch],
+		Maintainer: hdr.strings[rpmTagVendor],
+		Path:       p,
+	}
+	if result.Maintainer == "" {
+		result.Maintainer = hdr.strings[rpmTagPackager]
+	}
+	if license := hdr.strings[rpmTagLicense]; license != "" {
+		result.Licenses = []string{license}
+	}
+	result.SourceName, result.SourceVersion = splitSourceRPM(
+		hdr.strings[rpmTagSourceRPM])
+	return result, true, nil
+}
+
+// rpmCataloger collects packages from the rpm database, in
+// either sqlite, ndb or berkeley db format.
+type rpmCataloger struct{}
+
+func (rpmCataloger) Name() string {
+	return "rpm"
+}
+
+// rpmBlobFunc is the callback receiving each header blob.
+type rpmBlobFunc func([]byte) error
+
+func (rpmCataloger) Catalog(fsys api.FileSystem) ([]Package, error) {
+	for _, candidate := range rpmdbPaths {
+		info, err := fsys.Stat(candidate.path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		f, err := fsys.Open(candidate.path)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = f.Close() }()
+		var result []Package
+		collect := func(blob []byte) error {
+			pkg, ok, err := newRPMPackage(candidate.path, blob)
+			if err != nil {
+				return err
+			}
+			if ok {
+				result = append(result, pkg)
+			}
+			return nil
+		}
+		switch candidate.format {
+		case rpmdbSQLite:
+			err = readRPMSQLite(f, info.Size(), collect)
+		case rpmdbNDB:
+			err = readRPMNDB(f, info.Size(), collect)
+		case rpmdbBDB:
+			err = readRPMBDB(f, info.Size(), collect)
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("%s: %w", candidate.path, err)
+		}
+		return result, nil
+	}
+	return nil, nil
+}
+
+// readFull reads exactly len(b) bytes at specified offset.
+func readFull(r io.ReaderAt, b []byte, off int64) error {
+	n, err := r.ReadAt(b, off)
+	if n == len(b) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func init() {
+	RegisterCataloger(rpmCataloger{})
+}
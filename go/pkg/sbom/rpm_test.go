@@ -0,0 +1,172 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testRPMHeader encodes the header blob with the tags, whose
+// values are either strings or int32.
+func testRPMHeader(tags map[int32]interface{}) []byte {
+	var keys []int
+	for tag := range tags {
+		keys = append(keys, int(tag))
+	}
+	sort.Ints(keys)
+	var index, data []byte
+	for _, key := range keys {
+		entry := make([]byte, rpmIndexEntrySize)
+		binary.BigEndian.PutUint32(entry[0:4], uint32(key))
+		switch v := tags[int32(key)].(type) {
+		case string:
+			binary.BigEndian.PutUint32(entry[4:8], rpmTypeString)
+			binary.BigEndian.PutUint32(entry[8:12], uint32(len(data)))
+			data = append(append(data, v...), 0)
+		case int32:
+			for len(data)%4 != 0 {
+				data = append(data, 0)
+			}
+			binary.BigEndian.PutUint32(entry[4:8], rpmTypeInt32)
+			binary.BigEndian.PutUint32(entry[8:12], uint32(len(data)))
+			data = append(data, 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(data[len(data)-4:], uint32(v))
+		}
+		binary.BigEndian.PutUint32(entry[12:16], 1)
+		index = append(index, entry...)
+	}
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(keys)))
+	binary.BigEndian.PutUint32(header[4:8], uint32(len(data)))
+	return append(append(header, index...), data...)
+}
+
+// testRPMBlobs are the header blobs stored in the test rpm
+// databases, where the first one is large enough to overflow
+// the page and the public key is never collected.
+func testRPMBlobs() [][]byte {
+	return [][]byte{
+		testRPMHeader(map[int32]interface{}{
+			rpmTagName:    "bash",
+			rpmTagVersion: "5.1.8",
+			rpmTagLicense: strings.Repeat("GPLv3+", 200),
+		}),
+		testRPMHeader(map[int32]interface{}{
+			rpmTagName:    "openssl",
+			rpmTagVersion: "3.0.7",
+			rpmTagEpoch:   int32(1),
+		}),
+		testRPMHeader(map[int32]interface{}{
+			rpmTagName:    "gpg-pubkey",
+			rpmTagVersion: "fd431d51",
+		}),
+	}
+}
+
+// readTestRPMDB reads the names of packages in the database.
+func readTestRPMDB(
+	read func(io.ReaderAt, int64, rpmBlobFunc) error, db []byte,
+) (string, error) {
+	var names []string
+	err := read(bytes.NewReader(db), int64(len(db)),
+		func(blob []byte) error {
+			pkg, ok, err := newRPMPackage("/var/lib/rpm", blob)
+			if ok {
+				names = append(names, pkg.Name)
+			}
+			return err
+		})
+	return strings.Join(names, ","), err
+}
+
+func TestNewRPMPackage(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		blob []byte
+		want *Package
+		err  bool
+	}{{
+		name: "full",
+		blob: testRPMHeader(map[int32]interface{}{
+			rpmTagName:      "openssl-libs",
+			rpmTagVersion:   "3.0.7",
+			rpmTagRelease:   "16.el9",
+			rpmTagEpoch:     int32(1),
+			rpmTagArch:      "x86_64",
+			rpmTagLicense:   "ASL 2.0",
+			rpmTagVendor:    "Rocky",
+			rpmTagPackager:  "Infrastructure",
+			rpmTagSourceRPM: "openssl-3.0.7-16.el9.src.rpm",
+		}),
+		want: &Package{
+			Type: RPM, Name: "openssl-libs", Version: "3.0.7-16.el9",
+			Epoch: 1, Arch: "x86_64", Licenses: []string{"ASL 2.0"},
+			Maintainer: "Rocky", SourceName: "openssl",
+			SourceVersion: "3.0.7-16.el9", Path: "/var/lib/rpm/Packages",
+		},
+	}, {
+		name: "packager",
+		blob: testRPMHeader(map[int32]interface{}{
+			rpmTagName:     "bash",
+			rpmTagVersion:  "5.1.8",
+			rpmTagPackager: "Infrastructure",
+		}),
+		want: &Package{
+			Type: RPM, Name: "bash", Version: "5.1.8",
+			Maintainer: "Infrastructure", Path: "/var/lib/rpm/Packages",
+		},
+	}, {
+		name: "public key",
+		blob: testRPMHeader(map[int32]interface{}{
+			rpmTagName:    "gpg-pubkey",
+			rpmTagVersion: "fd431d51",
+		}),
+	}, {
+		name: "no version",
+		blob: testRPMHeader(map[int32]interface{}{rpmTagName: "bash"}),
+	}, {
+		name: "truncated",
+		blob: testRPMHeader(map[int32]interface{}{
+			rpmTagName: "bash",
+		})[:20],
+		err: true,
+	}, {
+		name: "short",
+		blob: []byte{0, 0},
+		err:  true,
+	}} {
+		t.Run(c.name, func(t *testing.T) {
+			pkg, ok, err := newRPMPackage("/var/lib/rpm/Packages", c.blob)
+			if (err != nil) != c.err {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if ok != (c.want != nil) || (ok && !reflect.DeepEqual(pkg, *c.want)) {
+				t.Fatalf("unexpected package %+v", pkg)
+			}
+		})
+	}
+}
+
+func TestSplitSourceRPM(t *testing.T) {
+	for _, c := range []struct {
+		sourceRPM string
+		name      string
+		version   string
+	}{
+		{"openssl-3.0.7-16.el9.src.rpm", "openssl", "3.0.7-16.el9"},
+		{"perl-Text-Tabs+Wrap-2013.0523-460.el9.src.rpm",
+			"perl-Text-Tabs+Wrap", "2013.0523-460.el9"},
+		{"malformed.src.rpm", "", ""},
+		{"", "", ""},
+	} {
+		name, version := splitSourceRPM(c.sourceRPM)
+		if name != c.name || version != c.version {
+			t.Errorf("split %q: got %q, %q, want %q, %q",
+				c.sourceRPM, name, version, c.name, c.version)
+		}
+	}
+}
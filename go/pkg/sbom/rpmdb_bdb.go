@@ -0,0 +1,168 @@
+package sbom
+
+import (
+	"encoding/binary"
+	"io"
+
+	"golang.org/x/xerrors"
+)
+
+// Constants of the berkeley db hash database, see dbinc/db_page.h
+// in berkeley db for their definitions.
+const (
+	bdbHashMagic = 0x061561
+
+	bdbMetaSize       = 72
+	bdbPageHeaderSize = 26
+
+	bdbPageHashUnsorted = 2
+	bdbPageOverflow     = 7
+	bdbPageHash         = 13
+
+	bdbKeyData = 1
+	bdbOffPage = 3
+)
+
+// bdbReader reads the rpm database in berkeley db hash format,
+// which is used by rpm prior to 4.16.
+type bdbReader struct {
+	r        io.ReaderAt
+	order    binary.ByteOrder
+	pageSize uint32
+	lastPage uint32
+}
+
+func (b *bdbReader) readPage(pgno uint32) ([]byte, error) {
+	if pgno > b.lastPage {
+		return nil, xerrors.Errorf("page %d out of range", pgno)
+	}
+	page := make([]byte, b.pageSize)
+	if err := readFull(b.r, page,
+		int64(pgno)*int64(b.pageSize)); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// readOverflow reads the value stored in the chain of overflow
+// pages, starting from the specified page.
+func (b *bdbReader) readOverflow(pgno, length uint32) ([]byte, error) {
+	result := make([]byte, 0, length)
+	visited := make(map[uint32]struct{})
+	for pgno != 0 {
+		if _, ok := visited[pgno]; ok {
+			return nil, xerrors.New("overflow page loop")
+		}
+		visited[pgno] = struct{}{}
+		page, err := b.readPage(pgno)
+		if err != nil {
+			return nil, err
+		}
+		if page[25] != bdbPageOverflow {
+			return nil, xerrors.Errorf(
+				"page %d is not an overflow page", pgno)
+		}
+		// The free area offset of overflow page records the
+		// length of data stored in the page.
+		n := uint32(b.order.Uint16(page[22:24]))
+		if bdbPageHeaderSize+n > b.pageSize {
+			return nil, xerrors.Errorf("page %d is corrupted", pgno)
+		}
+		result = append(result,
+			page[bdbPageHeaderSize:bdbPageHeaderSize+n]...)
+		pgno = b.order.Uint32(page[16:20])
+	}
+	if uint32(len(result)) < length {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return result[:length], nil
+}
+
+// readHashPage reads the values stored in a hash page.
+func (b *bdbReader) readHashPage(page []byte, f rpmBlobFunc) error {
+	entries := int(b.order.Uint16(page[20:22]))
+	if bdbPageHeaderSize+2*entries > len(page) {
+		return xerrors.New("hash page is corrupted")
+	}
+	offset := func(i int) int {
+		return int(b.order.Uint16(page[bdbPageHeaderSize+2*i:]))
+	}
+	// The items are stored as key and value pairs, growing
+	// from the end of the page towards the index array.
+	for i := 1; i < entries; i += 2 {
+		start, end := offset(i), offset(i-1)
+		if start >= end || end > len(page) {
+			continue
+		}
+		item := page[start:end]
+		switch item[0] {
+		case bdbKeyData:
+			// The key 0 stores the next instance number, which
+			// is not a header and must be skipped.
+			key := page[end:]
+			if i > 1 {
+				key = page[end:offset(i-2)]
+			}
+			if len(key) == 5 && b.order.Uint32(key[1:]) == 0 {
+				continue
+			}
+			if err := f(item[1:]); err != nil {
+				return err
+			}
+		case bdbOffPage:
+			if len(item) < 12 {
+				continue
+			}
+			blob, err := b.readOverflow(
+				b.order.Uint32(item[4:8]), b.order.Uint32(item[8:12]))
+			if err != nil {
+				return err
+			}
+			if err := f(blob); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readRPMBDB reads the header blobs from the berkeley db.
+func readRPMBDB(r io.ReaderAt, size int64, f rpmBlobFunc) error {
+	meta := make([]byte, bdbMetaSize)
+	if err := readFull(r, meta, 0); err != nil {
+		return err
+	}
+	b := &bdbReader{r: r}
+	// The database is stored in the byte order of the host
+	// creating it, which is recognized by the magic number.
+	switch {
+	case binary.LittleEndian.Uint32(meta[12:16]) == bdbHashMagic:
+		b.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(meta[12:16]) == bdbHashMagic:
+		b.order = binary.BigEndian
+	default:
+		return xerrors.New("not a berkeley db hash database")
+	}
+	b.pageSize = b.order.Uint32(meta[20:24])
+	if b.pageSize < 512 || b.pageSize > 65536 {
+		return xerrors.Errorf("invalid page size %d", b.pageSize)
+	}
+	b.lastPage = b.order.Uint32(meta[32:36])
+	if maxPage := uint32(size / int64(b.pageSize)); maxPage > 0 &&
+		b.lastPage >= maxPage {
+		b.lastPage = maxPage - 1
+	}
+	for pgno := uint32(1); pgno <= b.lastPage; pgno++ {
+		page, err := b.readPage(pgno)
+		if err != nil {
+			return err
+		}
+		if page[25] != bdbPageHash && page[25] != bdbPageHashUnsorted {
+			continue
+		}
+		if err := b.readHashPage(page, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+const testBDBPageSize = 512
+
+// newTestBDB builds the rpm database, where the metadata is on
+// page 0, the hash page is on page 1 and the blobs larger than
+// 200 bytes are stored in the overflow pages following it.
+func newTestBDB(order binary.ByteOrder, blobs ...[]byte) []byte {
+	meta := make([]byte, testBDBPageSize)
+	hash := make([]byte, testBDBPageSize)
+	pages := [][]byte{meta, hash}
+	hash[25] = bdbPageHash
+
+	// The key 0 storing the next instance number comes first.
+	items := [][]byte{{bdbKeyData, 0, 0, 0, 0}, {bdbKeyData, 4, 0, 0, 0}}
+	for i, blob := range blobs {
+		key := []byte{bdbKeyData, 0, 0, 0, 0}
+		order.PutUint32(key[1:], uint32(i+1))
+		item := append([]byte{bdbKeyData}, blob...)
+		if len(blob) > 200 {
+			item = make([]byte, 12)
+			item[0] = bdbOffPage
+			order.PutUint32(item[4:8], uint32(len(pages)))
+			order.PutUint32(item[8:12], uint32(len(blob)))
+			for rest := blob; len(rest) > 0; {
+				page := make([]byte, testBDBPageSize)
+				page[25] = bdbPageOverflow
+				n := copy(page[bdbPageHeaderSize:], rest)
+				order.PutUint16(page[22:24], uint16(n))
+				if rest = rest[n:]; len(rest) > 0 {
+					order.PutUint32(page[16:20], uint32(len(pages)+1))
+				}
+				pages = append(pages, page)
+			}
+		}
+		items = append(items, key, item)
+	}
+
+	// The items are stored from the end of the page.
+	end := testBDBPageSize
+	for i, item := range items {
+		end -= len(item)
+		copy(hash[end:], item)
+		order.PutUint16(hash[bdbPageHeaderSize+2*i:], uint16(end))
+	}
+	order.PutUint16(hash[20:22], uint16(len(items)))
+
+	order.PutUint32(meta[12:16], bdbHashMagic)
+	order.PutUint32(meta[20:24], testBDBPageSize)
+	order.PutUint32(meta[32:36], uint32(len(pages)-1))
+	return bytes.Join(pages, nil)
+}
+
+func TestReadRPMBDB(t *testing.T) {
+	page := func(db []byte, pgno int) []byte {
+		return db[pgno*testBDBPageSize : (pgno+1)*testBDBPageSize]
+	}
+	for _, c := range []struct {
+		name    string
+		order   binary.ByteOrder
+		corrupt func([]byte) []byte
+		want    string
+		err     string
+	}{{
+		name:  "little endian",
+		order: binary.LittleEndian,
+		want:  "bash,openssl",
+	}, {
+		name:  "big endian",
+		order: binary.BigEndian,
+		want:  "bash,openssl",
+	}, {
+		name: "last page beyond file",
+		corrupt: func(db []byte) []byte {
+			binary.LittleEndian.PutUint32(db[32:36], 100)
+			return db
+		},
+		want: "bash,openssl",
+	}, {
+		name: "truncated meta",
+		corrupt: func(db []byte) []byte {
+			return db[:40]
+		},
+		err: "unexpected EOF",
+	}, {
+		name: "magic",
+		corrupt: func(db []byte) []byte {
+			db[12] = 0
+			return db
+		},
+		err: "not a berkeley db hash database",
+	}, {
+		name: "page size",
+		corrupt: func(db []byte) []byte {
+			binary.LittleEndian.PutUint32(db[20:24], 100)
+			return db
+		},
+		err: "invalid page size 100",
+	}, {
+		name: "entries",
+		corrupt: func(db []byte) []byte {
+			binary.LittleEndian.PutUint16(page(db, 1)[20:22], 0xffff)
+			return db
+		},
+		err: "hash page is corrupted",
+	}, {
+		name: "overflow loop",
+		corrupt: func(db []byte) []byte {
+			binary.LittleEndian.PutUint32(page(db, 2)[16:20], 2)
+			return db
+		},
+		err: "overflow page loop",
+	}, {
+		name: "overflow kind",
+		corrupt: func(db []byte) []byte {
+			page(db, 2)[25] = bdbPageHash
+			return db
+		},
+		err: "page 2 is not an overflow page",
+	}, {
+		name: "overflow length",
+		corrupt: func(db []byte) []byte {
+			binary.LittleEndian.PutUint16(page(db, 2)[22:24], 600)
+			return db
+		},
+		err: "page 2 is corrupted",
+	}, {
+		name: "overflow short",
+		corrupt: func(db []byte) []byte {
+			binary.LittleEndian.PutUint16(page(db, 3)[22:24], 10)
+			return db
+		},
+		err: "unexpected EOF",
+	}, {
+		name: "truncated overflow",
+		corrupt: func(db []byte) []byte {
+			return db[:3*testBDBPageSize]
+		},
+		err: "page 3 out of range",
+	}} {
+		t.Run(c.name, func(t *testing.T) {
+			order := c.order
+			if order == nil {
+				order = binary.LittleEndian
+			}
+			db := newTestBDB(order, testRPMBlobs()...)
+			if c.corrupt != nil {
+				db = c.corrupt(db)
+			}
+			result, err := readTestRPMDB(readRPMBDB, db)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("unexpected error %v, want %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != c.want {
+				t.Fatalf("unexpected packages %q, want %q", result, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package sbom
+
+import (
+	"encoding/binary"
+	"io"
+
+	"golang.org/x/xerrors"
+)
+
+// Constants of the ndb package database, see lib/backend/ndb
+// /rpmpkg.c in rpm for their definitions.
+const (
+	ndbHeaderMagic = 'R' | 'p'<<8 | 'm'<<16 | 'P'<<24
+	ndbSlotMagic   = 'S' | 'l'<<8 | 'o'<<16 | 't'<<24
+	ndbBlobMagic   = 'B' | 'l'<<8 | 'b'<<16 | 'S'<<24
+
+	ndbHeaderSize   = 32
+	ndbSlotSize     = 16
+	ndbBlockSize    = 16
+	ndbPageSize     = 4096
+	ndbBlobHeadSize = 16
+
+	// ndbMaxSlotPages limits the slot pages to read, so that
+	// a corrupted database will not exhaust the memory.
+	ndbMaxSlotPages = 2048
+)
+
+// readRPMNDB reads the header blobs from the ndb database,
+// which is the native format of rpm on SUSE since 4.16.
+func readRPMNDB(r io.ReaderAt, size int64, f rpmBlobFunc) error {
+	header := make([]byte, ndbHeaderSize)
+	if err := readFull(r, header, 0); err != nil {
+		return err
+	}
+	order := binary.LittleEndian
+	if order.Uint32(header[0:4]) != ndbHeaderMagic {
+		return xerrors.New("not an ndb package database")
+	}
+	if version := order.Uint32(header[4:8]); version != 0 {
+		return xerrors.Errorf("unsupported ndb version %d", version)
+	}
+	npages := order.Uint32(header[12:16])
+	if npages == 0 || npages > ndbMaxSlotPages {
+		return xerrors.Errorf("invalid ndb slot pages %d", npages)
+	}
+	slots := make([]byte, npages*ndbPageSize)
+	if err := readFull(r, slots, 0); err != nil {
+		return err
+	}
+	// The leading slots are occupied by the database header.
+	for off := ndbHeaderSize; off < len(slots); off += ndbSlotSize {
+		slot := slots[off : off+ndbSlotSize]
+		if order.Uint32(slot[0:4]) != ndbSlotMagic {
+			return xerrors.Errorf("invalid ndb slot at %d", off)
+		}
+		pkgidx := order.Uint32(slot[4:8])
+		if pkgidx == 0 {
+			continue
+		}
+		blkoff := int64(order.Uint32(slot[8:12])) * ndbBlockSize
+		head := make([]byte, ndbBlobHeadSize)
+		if err := readFull(r, head, blkoff); err != nil {
+			return err
+		}
+		if order.Uint32(head[0:4]) != ndbBlobMagic ||
+			order.Uint32(head[4:8]) != pkgidx {
+			return xerrors.Errorf("invalid ndb blob of package %d", pkgidx)
+		}
+		length := int64(order.Uint32(head[12:16]))
+		if blkoff+ndbBlobHeadSize+length > size {
+			return xerrors.Errorf("ndb blob of package %d out of range",
+				pkgidx)
+		}
+		blob := make([]byte, length)
+		if err := readFull(r, blob, blkoff+ndbBlobHeadSize); err != nil {
+			return err
+		}
+		if err := f(blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package sbom
+
+import (
+	"encoding/binary"
+	"io"
+
+	"golang.org/x/xerrors"
+)
+
+// Constants of the sqlite database file format, see
+// https://www.sqlite.org/fileformat.html for their definitions.
+const (
+	sqliteMagic      = "SQLite format 3\x00"
+	sqliteHeaderSize = 100
+
+	sqlitePageInteriorTable = 5
+	sqlitePageLeafTable     = 13
+
+	// sqliteMaxDepth limits the depth of the b-tree to walk,
+	// so that a corrupted database will not loop forever.
+	sqliteMaxDepth = 64
+
+	// rpmSQLiteTable is the table storing the header blobs.
+	rpmSQLiteTable = "Packages"
+)
+
+// sqliteReader is a minimal sqlite reader that is only capable
+// of walking the table b-trees, which is sufficient for
+// reading the rpm database without a sqlite driver.
+type sqliteReader struct {
+	r        io.ReaderAt
+	pageSize uint32
+	usable   uint32
+	npages   uint32
+}
+
+func (s *sqliteReader) readPage(pgno uint32) ([]byte, error) {
+	if pgno == 0 || pgno > s.npages {
+		return nil, xerrors.Errorf("page %d out of range", pgno)
+	}
+	page := make([]byte, s.pageSize)
+	if err := readFull(s.r, page,
+		int64(pgno-1)*int64(s.pageSize)); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// sqliteVarint decodes the variable-length integer, returning
+// the value and the number of bytes consumed.
+func sqliteVarint(b []byte) (uint64, int) {
+	var result uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(b) {
+			return 0, 0
+		}
+		if i == 8 {
+			return result<<8 | uint64(b[i]), 9
+		}
+		result = result<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return result, i + 1
+		}
+	}
+	return result, 9
+}
+
+// payload reads the payload of the table leaf cell, following
+// the overflow pages when the payload does not fit in the page.
+func (s *sqliteReader) payload(page []byte, cell int) ([]byte, error) {
+	size, n := sqliteVarint(page[cell:])
+	if n == 0 {
+		return nil, xerrors.New("invalid cell payload size")
+	}
+	cell += n
+	if _, n = sqliteVarint(page[cell:]); n == 0 {
+		return nil, xerrors.New("invalid cell rowid")
+	}
+	cell += n
+	u := uint64(s.usable)
+	local := size
+	if x := u - 35; size > x {
+		m := ((u-12)*32/255 - 23)
+		local = m + (size-m)%(u-4)
+		if local > x {
+			local = m
+		}
+	}
+	if uint64(cell)+local > uint64(len(page)) {
+		return nil, xerrors.New("cell out of range")
+	}
+	// Each overflow page holds usable-4 bytes of the payload, so
+	// the size beyond what the overflow pages in the database
+	// could hold is corrupted, and must not be allocated.
+	if overflows := (size - local + u - 5) / (u - 4); overflows >=
+		uint64(s.npages) {
+		return nil, xerrors.Errorf(
+			"payload size %d exceeds the database", size)
+	}
+	result := make([]byte, 0, size)
+	result = append(result, page[cell:uint64(cell)+local]...)
+	if local == size {
+		return result, nil
+	}
+	off := uint64(cell) + local
+	if off+4 > uint64(len(page)) {
+		return nil, xerrors.New("cell out of range")
+	}
+	next := binary.BigEndian.Uint32(page[off : off+4])
+	visited := make(map[uint32]struct{})
+	for uint64(len(result)) < size {
+		if _, ok := visited[next]; ok {
+			return nil, xerrors.New("overflow page loop")
+		}
+		visited[next] = struct{}{}
+		overflow, err := s.readPage(next)
+		if err != nil {
+			return nil, err
+		}
+		chunk := overflow[4:s.usable]
+		if remain := size - uint64(len(result)); uint64(len(chunk)) > remain {
+			chunk = chunk[:remain]
+		}
+		result = append(result, chunk...)
+		next = binary.BigEndian.Uint32(overflow[0:4])
+	}
+	return result, nil
+}
+
+// sqliteRecord decodes the columns of the record, where the
+// integers are decoded as int64, and the texts and blobs are
+// decoded as byte slices.
+func sqliteRecord(record []byte) ([]interface{}, error) {
+	headerSize, n := sqliteVarint(record)
+	if n == 0 || headerSize > uint64(len(record)) {
+		return nil, xerrors.New("invalid record header")
+	}
+	var result []interface{}
+	header := record[n:headerSize]
+	body := record[headerSize:]
+	for len(header) > 0 {
+		serial, n := sqliteVarint(header)
+		if n == 0 {
+			return nil, xerrors.New("invalid record serial type")
+		}
+		header = header[n:]
+		var length uint64
+		switch {
+		case serial == 0:
+			result = append(result, nil)
+			continue
+		case serial == 8 || serial == 9:
+			result = append(result, int64(serial-8))
+			continue
+		case serial >= 1 && serial <= 4:
+			length = serial
+		case serial == 5:
+			length = 6
+		case serial == 6 || serial == 7:
+			length = 8
+		case serial >= 12:
+			length = (serial - 12) / 2
+		default:
+			return nil, xerrors.Errorf("invalid serial type %d", serial)
+		}
+		if length > uint64(len(body)) {
+			return nil, xerrors.New("record out of range")
+		}
+		value := body[:length]
+		body = body[length:]
+		switch {
+		case serial <= 6:
+			var v int64
+			for i, c := range value {
+				if i == 0 {
+					v = int64(int8(c))
+				} else {
+					v = v<<8 | int64(c)
+				}
+			}
+			result = append(result, v)
+		case serial == 7:
+			// Floating point numbers are not used by the
+			// tables we are interested in.
+			result = append(result, nil)
+		default:
+			result = append(result, value)
+		}
+	}
+	return result, nil
+}
+
+// walk visits the records in the table b-tree rooted at the
+// specified page in the order of rowid.
+func (s *sqliteReader) walk(
+	pgno uint32, depth int, f func([]interface{}) error,
+) error {
+	if depth > sqliteMaxDepth {
+		return xerrors.New("b-tree too deep")
+	}
+	page, err := s.readPage(pgno)
+	if err != nil {
+		return err
+	}
+	// The first page is prefixed by the database header.
+	hdr := 0
+	if pgno == 1 {
+		hdr = sqliteHeaderSize
+	}
+	kind := page[hdr]
+	ncells := int(binary.BigEndian.Uint16(page[hdr+3 : hdr+5]))
+	pointers := hdr + 8
+	if kind == sqlitePageInteriorTable {
+		pointers = hdr + 12
+	}
+	if pointers+2*ncells > len(page) {
+		return xerrors.Errorf("page %d is corrupted", pgno)
+	}
+	for i := 0; i < ncells; i++ {
+		cell := int(binary.BigEndian.Uint16(page[pointers+2*i:]))
+		if cell >= len(page) {
+			return xerrors.Errorf("page %d is corrupted", pgno)
+		}
+		switch kind {
+		case sqlitePageInteriorTable:
+			if cell+4 > len(page) {
+				return xerrors.Errorf("page %d is corrupted", pgno)
+			}
+			if err := s.walk(binary.BigEndian.Uint32(
+				page[cell:cell+4]), depth+1, f); err != nil {
+				return err
+			}
+		case sqlitePageLeafTable:
+			payload, err := s.payload(page, cell)
+			if err != nil {
+				return err
+			}
+			record, err := sqliteRecord(payload)
+			if err != nil {
+				return err
+			}
+			if err := f(record); err != nil {
+				return err
+			}
+		default:
+			return xerrors.Errorf("page %d is not a table page", pgno)
+		}
+	}
+	if kind == sqlitePageInteriorTable {
+		return s.walk(binary.BigEndian.Uint32(
+			page[hdr+8:hdr+12]), depth+1, f)
+	}
+	return nil
+}
+
+// readRPMSQLite reads the header blobs from the sqlite database,
+// which is the default format of rpm since 4.16.
+func readRPMSQLite(r io.ReaderAt, size int64, f rpmBlobFunc) error {
+	header := make([]byte, sqliteHeaderSize)
+	if err := readFull(r, header, 0); err != nil {
+		return err
+	}
+	if string(header[:len(sqliteMagic)]) != sqliteMagic {
+		return xerrors.New("not a sqlite database")
+	}
+	s := &sqliteReader{r: r}
+	s.pageSize = uint32(binary.BigEndian.Uint16(header[16:18]))
+	if s.pageSize == 1 {
+		s.pageSize = 65536
+	}
+	if s.pageSize < 512 || s.pageSize&(s.pageSize-1) != 0 {
+		return xerrors.Errorf("invalid page size %d", s.pageSize)
+	}
+	s.usable = s.pageSize - uint32(header[20])
+	if s.usable < 480 {
+		return xerrors.Errorf("invalid reserved size %d", header[20])
+	}
+	s.npages = uint32(size / int64(s.pageSize))
+
+	// Lookup the root page of the table in the schema table,
+	// whose columns are type, name, tbl_name, rootpage and sql.
+	var rootPage uint32
+	if err := s.walk(1, 0, func(record []interface{}) error {
+		if len(record) < 4 {
+			return nil
+		}
+		kind, _ := record[0].([]byte)
+		name, _ := record[1].([]byte)
+		root, _ := record[3].(int64)
+		if string(kind) == "table" && string(name) == rpmSQLiteTable {
+			rootPage = uint32(root)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if rootPage == 0 {
+		return xerrors.Errorf("table %q not found", rpmSQLiteTable)
+	}
+
+	// The table is created by "CREATE TABLE Packages (hnum
+	// INTEGER PRIMARY KEY AUTOINCREMENT, blob BLOB NOT NULL)".
+	return s.walk(rootPage, 0, func(record []interface{}) error {
+		if len(record) < 2 {
+			return nil
+		}
+		blob, ok := record[1].([]byte)
+		if !ok {
+			return nil
+		}
+		return f(blob)
+	})
+}
@@ -0,0 +1,288 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+const testSQLitePageSize = 512
+
+// testSQLiteVarint encodes the sqlite varint in at least the
+// specified number of bytes, padding with leading zero groups.
+func testSQLiteVarint(v uint64, min int) []byte {
+	var result []byte
+	for len(result) == 0 || v > 0 || len(result) < min {
+		c := byte(v & 0x7f)
+		if len(result) > 0 {
+			c |= 0x80
+		}
+		result = append([]byte{c}, result...)
+		v >>= 7
+	}
+	return result
+}
+
+// testSQLiteRecord encodes the record with the columns, which
+// are either nil, small int, string or blob.
+func testSQLiteRecord(values ...interface{}) []byte {
+	var header, body []byte
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			header = append(header, 0)
+		case int:
+			header = append(header, 1)
+			body = append(body, byte(v))
+		case string:
+			header = append(header,
+				testSQLiteVarint(uint64(13+2*len(v)), 1)...)
+			body = append(body, v...)
+		case []byte:
+			header = append(header,
+				testSQLiteVarint(uint64(12+2*len(v)), 1)...)
+			body = append(body, v...)
+		}
+	}
+	record := testSQLiteVarint(uint64(len(header)+1), 1)
+	return append(append(record, header...), body...)
+}
+
+// testSQLite builds the database page by page.
+type testSQLite struct {
+	pages [][]byte
+}
+
+func (s *testSQLite) page() (uint32, []byte) {
+	page := make([]byte, testSQLitePageSize)
+	s.pages = append(s.pages, page)
+	return uint32(len(s.pages)), page
+}
+
+// leafCell encodes the table leaf cell, spilling the payload to
+// the overflow pages appended. The payload size is always
+// encoded in two bytes so that it can be corrupted in place.
+func (s *testSQLite) leafCell(rowid uint64, payload []byte) []byte {
+	size, u := uint64(len(payload)), uint64(testSQLitePageSize)
+	local := size
+	if size > u-35 {
+		m := (u-12)*32/255 - 23
+		if local = m + (size-m)%(u-4); local > u-35 {
+			local = m
+		}
+	}
+	cell := append(testSQLiteVarint(size, 2), testSQLiteVarint(rowid, 1)...)
+	cell = append(cell, payload[:local]...)
+	if local == size {
+		return cell
+	}
+	next := make([]byte, 4)
+	binary.BigEndian.PutUint32(next, uint32(len(s.pages)+1))
+	cell = append(cell, next...)
+	for rest := payload[local:]; len(rest) > 0; {
+		pgno, page := s.page()
+		rest = rest[copy(page[4:], rest):]
+		if len(rest) > 0 {
+			binary.BigEndian.PutUint32(page[0:4], pgno+1)
+		}
+	}
+	return cell
+}
+
+// testSQLitePage writes the b-tree page with the cells, whose
+// page header is located at hdr.
+func testSQLitePage(page []byte, hdr int, kind byte, right uint32, cells ...[]byte) {
+	page[hdr] = kind
+	binary.BigEndian.PutUint16(page[hdr+3:], uint16(len(cells)))
+	pointers := hdr + 8
+	if kind == sqlitePageInteriorTable {
+		binary.BigEndian.PutUint32(page[hdr+8:], right)
+		pointers = hdr + 12
+	}
+	end := len(page)
+	for i, cell := range cells {
+		end -= len(cell)
+		copy(page[end:], cell)
+		binary.BigEndian.PutUint16(page[pointers+2*i:], uint16(end))
+	}
+	binary.BigEndian.PutUint16(page[hdr+5:], uint16(end))
+}
+
+// newTestSQLite builds the rpm database, where the schema is on
+// page 1, the interior root of the table is on page 2, the first
+// blob is on the leaf page 3 and the others are on the leaf page
+// 4, followed by the overflow pages.
+func newTestSQLite(blobs ...[]byte) []byte {
+	s := &testSQLite{}
+	_, schema := s.page()
+	_, root := s.page()
+	_, left := s.page()
+	_, right := s.page()
+	copy(schema, sqliteMagic)
+	binary.BigEndian.PutUint16(schema[16:18], testSQLitePageSize)
+	testSQLitePage(schema, sqliteHeaderSize, sqlitePageLeafTable, 0,
+		s.leafCell(1, testSQLiteRecord(
+			"index", "Packages_idx", rpmSQLiteTable, 9, "")),
+		s.leafCell(2, testSQLiteRecord(
+			"table", rpmSQLiteTable, rpmSQLiteTable, 2, "")))
+	child := []byte{0, 0, 0, 3, 1}
+	testSQLitePage(root, 0, sqlitePageInteriorTable, 4, child)
+	testSQLitePage(left, 0, sqlitePageLeafTable, 0,
+		s.leafCell(1, testSQLiteRecord(nil, blobs[0])))
+	var cells [][]byte
+	for i, blob := range blobs[1:] {
+		cells = append(cells, s.leafCell(
+			uint64(i+2), testSQLiteRecord(nil, blob)))
+	}
+	testSQLitePage(right, 0, sqlitePageLeafTable, 0, cells...)
+	return bytes.Join(s.pages, nil)
+}
+
+func TestReadRPMSQLite(t *testing.T) {
+	page := func(db []byte, pgno int) []byte {
+		return db[(pgno-1)*testSQLitePageSize : pgno*testSQLitePageSize]
+	}
+	for _, c := range []struct {
+		name    string
+		corrupt func([]byte) []byte
+		want    string
+		err     string
+	}{{
+		name: "valid",
+		want: "bash,openssl",
+	}, {
+		name: "truncated header",
+		corrupt: func(db []byte) []byte {
+			return db[:50]
+		},
+		err: "unexpected EOF",
+	}, {
+		name: "magic",
+		corrupt: func(db []byte) []byte {
+			db[0] = 'X'
+			return db
+		},
+		err: "not a sqlite database",
+	}, {
+		name: "page size",
+		corrupt: func(db []byte) []byte {
+			binary.BigEndian.PutUint16(db[16:18], 100)
+			return db
+		},
+		err: "invalid page size 100",
+	}, {
+		name: "reserved size",
+		corrupt: func(db []byte) []byte {
+			db[20] = 100
+			return db
+		},
+		err: "invalid reserved size 100",
+	}, {
+		name: "table missing",
+		corrupt: func(db []byte) []byte {
+			i := bytes.Index(db, []byte("table"+rpmSQLiteTable))
+			db[i] = 'T'
+			return db
+		},
+		err: `table "Packages" not found`,
+	}, {
+		name: "cell pointers",
+		corrupt: func(db []byte) []byte {
+			binary.BigEndian.PutUint16(page(db, 3)[3:5], 0xffff)
+			return db
+		},
+		err: "page 3 is corrupted",
+	}, {
+		name: "cell offset",
+		corrupt: func(db []byte) []byte {
+			binary.BigEndian.PutUint16(page(db, 3)[8:10], 0xffff)
+			return db
+		},
+		err: "page 3 is corrupted",
+	}, {
+		name: "page kind",
+		corrupt: func(db []byte) []byte {
+			page(db, 3)[0] = 2
+			return db
+		},
+		err: "page 3 is not a table page",
+	}, {
+		name: "page out of range",
+		corrupt: func(db []byte) []byte {
+			binary.BigEndian.PutUint32(page(db, 2)[8:12], 100)
+			return db
+		},
+		err: "page 100 out of range",
+	}, {
+		name: "b-tree loop",
+		corrupt: func(db []byte) []byte {
+			binary.BigEndian.PutUint32(page(db, 2)[8:12], 2)
+			return db
+		},
+		err: "b-tree too deep",
+	}, {
+		name: "payload size",
+		corrupt: func(db []byte) []byte {
+			leaf := page(db, 3)
+			cell := binary.BigEndian.Uint16(leaf[8:10])
+			leaf[cell], leaf[cell+1] = 0xff, 0x7f
+			return db
+		},
+		err: "payload size 16383 exceeds the database",
+	}, {
+		name: "overflow loop",
+		corrupt: func(db []byte) []byte {
+			binary.BigEndian.PutUint32(page(db, 5)[0:4], 5)
+			return db
+		},
+		err: "overflow page loop",
+	}, {
+		name: "truncated overflow",
+		corrupt: func(db []byte) []byte {
+			return db[:5*testSQLitePageSize]
+		},
+		err: "page 6 out of range",
+	}} {
+		t.Run(c.name, func(t *testing.T) {
+			db := newTestSQLite(testRPMBlobs()...)
+			if c.corrupt != nil {
+				db = c.corrupt(db)
+			}
+			result, err := readTestRPMDB(readRPMSQLite, db)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("unexpected error %v, want %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != c.want {
+				t.Fatalf("unexpected packages %q, want %q", result, c.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteVarint(t *testing.T) {
+	for _, c := range []struct {
+		data []byte
+		want uint64
+		n    int
+	}{
+		{[]byte{0x7f}, 0x7f, 1},
+		{[]byte{0x81, 0x00}, 0x80, 2},
+		{[]byte{0x80, 0x05}, 5, 2},
+		{bytes.Repeat([]byte{0xff}, 9), 1<<64 - 1, 9},
+		{[]byte{0x81}, 0, 0},
+		{nil, 0, 0},
+	} {
+		result, n := sqliteVarint(c.data)
+		if result != c.want || n != c.n {
+			t.Errorf("varint %x: got %d, %d, want %d, %d",
+				c.data, result, n, c.want, c.n)
+		}
+	}
+}
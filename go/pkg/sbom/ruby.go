@@ -0,0 +1,86 @@
+package sbom
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// gemspecDir is the directory where rubygems installs the
+// specifications of the installed gems.
+const gemspecDir = "specifications"
+
+var (
+	// gemspecAttr matches the attribute assignments in the
+	// gemspec generated by rubygems, for example:
+	//
+	//   s.name = "rake".freeze
+	//   s.licenses = ["MIT".freeze]
+	gemspecAttr = regexp.MustCompile(
+		`^\s*\w+\.(\w+)\s*=\s*(.+?)\s*$`)
+	gemspecString = regexp.MustCompile(`["']([^"']*)["']`)
+)
+
+// rubyDetector collects the installed gems from the gemspecs
+// generated by rubygems.
+type rubyDetector struct{}
+
+func (rubyDetector) Name() string {
+	return "ruby"
+}
+
+func (rubyDetector) Match(path string, info os.FileInfo) bool {
+	return strings.HasSuffix(info.Name(), ".gemspec") &&
+		filepath.Base(filepath.Dir(path)) == gemspecDir
+}
+
+func (rubyDetector) Detect(
+	fsys api.FileSystem, path string,
+) ([]Package, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	result := Package{Type: Gem, Path: path}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		match := gemspecAttr.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		var values []string
+		for _, value := range gemspecString.FindAllStringSubmatch(
+			match[2], -1) {
+			values = append(values, value[1])
+		}
+		if len(values) == 0 {
+			continue
+		}
+		switch match[1] {
+		case "name":
+			result.Name = values[0]
+		case "version":
+			result.Version = values[0]
+		case "license", "licenses":
+			result.Licenses = values
+		case "authors":
+			result.Maintainer = strings.Join(values, ", ")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if result.Name == "" || result.Version == "" {
+		return nil, nil
+	}
+	return []Package{result}, nil
+}
+
+func init() {
+	RegisterDetector(rubyDetector{})
+}
@@ -0,0 +1,289 @@
+// Package sbom inventories the software installed inside the
+// file system of an image or a container, and renders the
+// inventory as software bill of materials.
+//
+// The OS packages are collected by catalogers registered in
+// this package, each of them recognizes a kind of package
+// database, like the dpkg status database, the rpm database
+// and the apk installed database. And the distribution of the
+// operating system is detected from "/etc/os-release".
+//
+// The application dependencies are collected by detectors
+// registered in this package, each of them recognizes files
+// of a language ecosystem while walking the file system, like
+// go binaries, python metadata, npm lockfiles, java archives,
+// ruby gemspecs and cargo lockfiles.
+//
+// The inventory can then be encoded as SPDX 2.3 or CycloneDX
+// 1.5 documents in JSON format.
+package sbom
+
+import (
+	"sort"
+	"sync"
+
+	"golang.org/x/xerrors"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// PackageType is the type of package, which is also the
+// type component of its package URL.
+type PackageType string
+
+const (
+	Deb PackageType = "deb"
+	RPM PackageType = "rpm"
+	APK PackageType = "apk"
+
+	Golang PackageType = "golang"
+	PyPI   PackageType = "pypi"
+	NPM    PackageType = "npm"
+	Maven  PackageType = "maven"
+	Gem    PackageType = "gem"
+	Cargo  PackageType = "cargo"
+)
+
+func (t PackageType) String() string {
+	return string(t)
+}
+
+// IsOS judges whether the package is managed by the package
+// manager of the operating system.
+func (t PackageType) IsOS() bool {
+	switch t {
+	case Deb, RPM, APK:
+		return true
+	default:
+		return false
+	}
+}
+
+// LayerRef locates the layer of an image.
+type LayerRef struct {
+	// Index is the index of the layer, counted from the
+	// bottom-most layer of the image.
+	Index  int
+	ID     string
+	DiffID string
+
+	// Digest is the digest of the layer blob, which is empty
+	// when the image fails to tell.
+	Digest string
+}
+
+// Package is a piece of software found in the file system.
+type Package struct {
+	Type PackageType
+
+	// Name is the name of the package, in the same form as
+	// the OSV database names it. That is, the maven packages
+	// are named "groupId:artifactId", the npm packages are
+	// prefixed by their scope and the go packages are named
+	// by their module path.
+	Name    string
+	Version string
+	Arch    string
+
+	// Epoch is the epoch of the rpm package, which is not a
+	// part of the Version. Other package types carries their
+	// epoch in the Version if there's any.
+	Epoch int
+
+	// SourceName and SourceVersion are the name and version
+	// of the source package building this package, if known.
+	SourceName    string
+	SourceVersion string
+
+	Licenses   []string
+	Maintainer string
+
+	// Path is the file where the package is found, usually
+	// the package database.
+	Path string
+
+	// InnerPath is the path inside the archive at Path when
+	// the package is found inside an archive, like the jars
+	// nested in a war. Nested archives are separated by "!/".
+	InnerPath string
+
+	// Layer is the layer where the file at Path has been last
+	// written, which is only available when scanning an
+	// api.LayeredImage.
+	Layer *LayerRef
+}
+
+// Cataloger recognizes and collects packages in file system.
+//
+// A cataloger should return no package and no error when
+// the file system contains nothing it recognizes.
+type Cataloger interface {
+	Name() string
+	Catalog(fsys api.FileSystem) ([]Package, error)
+}
+
+var catalogers = sync.Map{}
+
+// RegisterCataloger to be used when scanning file systems.
+//
+// Registering a cataloger with the name of another cataloger
+// will replace it.
+func RegisterCataloger(cataloger Cataloger) {
+	catalogers.Store(cataloger.Name(), cataloger)
+}
+
+// SBOM is the inventory of software in a file system.
+type SBOM struct {
+	// Name is the name of the object being scanned, which is
+	// used as the name of the generated documents.
+	Name string
+
+	// Distro is the distribution of operating system, which
+	// is nil when it cannot be recognized.
+	Distro *Distro
+
+	Packages []Package
+
+	// Warnings are the files skipped by detectors since they
+	// cannot be accessed, like dangling symlinks.
+	Warnings []string
+}
+
+type scanOption struct {
+	name       string
+	catalogers map[string]struct{}
+	detectors  map[string]struct{}
+}
+
+// ScanOption specifies how to scan the file system.
+type ScanOption func(*scanOption)
+
+// WithName specifies the name of the object being scanned.
+//
+// When unspecified, the name will be the first repository
+// reference or ID of an api.Image, or the name of an
+// api.Container.
+func WithName(name string) ScanOption {
+	return func(o *scanOption) {
+		o.name = name
+	}
+}
+
+// WithCatalogers specifies the name of catalogers to use,
+// all catalogers registered will be used if unspecified.
+func WithCatalogers(names ...string) ScanOption {
+	return func(o *scanOption) {
+		if o.catalogers == nil {
+			o.catalogers = make(map[string]struct{})
+		}
+		for _, name := range names {
+			o.catalogers[name] = struct{}{}
+		}
+	}
+}
+
+// WithDetectors specifies the name of detectors to use, all
+// detectors registered will be used if unspecified. Specifying
+// no name disables the detectors, which avoids walking the
+// whole file system.
+func WithDetectors(names ...string) ScanOption {
+	return func(o *scanOption) {
+		if o.detectors == nil {
+			o.detectors = make(map[string]struct{})
+		}
+		for _, name := range names {
+			o.detectors[name] = struct{}{}
+		}
+	}
+}
+
+// defaultName attempts to name the file system by its type.
+func defaultName(fsys api.FileSystem) string {
+	switch obj := fsys.(type) {
+	case api.Image:
+		if refs, err := obj.RepoRefs(); err == nil && len(refs) > 0 {
+			return refs[0]
+		}
+		return obj.ID()
+	case api.Container:
+		if name := obj.Name(); name != "" {
+			return name
+		}
+		return obj.ID()
+	case api.Layer:
+		return obj.ID()
+	}
+	return "unknown"
+}
+
+// Scan the file system with registered catalogers and
+// detectors.
+//
+// When the file system is an api.LayeredImage, the layer of
+// each package is also located.
+func Scan(fsys api.FileSystem, opts ...ScanOption) (*SBOM, error) {
+	option := &scanOption{}
+	for _, opt := range opts {
+		opt(option)
+	}
+	result := &SBOM{Name: option.name}
+	if result.Name == "" {
+		result.Name = defaultName(fsys)
+	}
+	distro, err := DetectDistro(fsys)
+	if err != nil {
+		return nil, err
+	}
+	result.Distro = distro
+	var names []string
+	catalogers.Range(func(key, _ interface{}) bool {
+		name := key.(string)
+		if option.catalogers != nil {
+			if _, ok := option.catalogers[name]; !ok {
+				return true
+			}
+		}
+		names = append(names, name)
+		return true
+	})
+	sort.Strings(names)
+	for _, name := range names {
+		value, ok := catalogers.Load(name)
+		if !ok {
+			continue
+		}
+		pkgs, err := value.(Cataloger).Catalog(fsys)
+		if err != nil {
+			return nil, xerrors.Errorf("cataloger %q: %w", name, err)
+		}
+		result.Packages = append(result.Packages, pkgs...)
+	}
+	pkgs, warnings, err := detect(fsys, loadDetectors(option.detectors))
+	if err != nil {
+		return nil, err
+	}
+	result.Packages = append(result.Packages, pkgs...)
+	result.Warnings = warnings
+	if image, ok := fsys.(api.LayeredImage); ok {
+		if err := locateLayers(image, result.Packages); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(result.Packages, func(i, j int) bool {
+		a, b := result.Packages[i], result.Packages[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.InnerPath < b.InnerPath
+	})
+	return result, nil
+}
@@ -0,0 +1,164 @@
+package sbom
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Values of the SPDX document, see
+// https://spdx.github.io/spdx-spec/v2.3/ for their definitions.
+const (
+	spdxVersion       = "SPDX-2.3"
+	spdxDataLicense   = "CC0-1.0"
+	spdxDocumentID    = "SPDXRef-DOCUMENT"
+	spdxNoAssertion   = "NOASSERTION"
+	spdxNamespaceBase = "https://github.com/chaitin/libveinmind/spdxdocs/"
+
+	// toolName is the name of the tool generating documents.
+	toolName = "libveinmind"
+)
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxExternalRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	Supplier         string            `json:"supplier,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	SourceInfo       string            `json:"sourceInfo,omitempty"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	LicenseComments  string            `json:"licenseComments,omitempty"`
+	CopyrightText    string            `json:"copyrightText"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+	PrimaryPurpose   string            `json:"primaryPackagePurpose,omitempty"`
+}
+
+type spdxRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+// spdxID sanitizes the string into an SPDX identifier, which
+// only allows letters, numbers, "." and "-".
+func spdxID(s string) string {
+	return "SPDXRef-" + strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z',
+			'0' <= r && r <= '9', r == '.', r == '-':
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+// newSPDXPackage creates the package with all fields that are
+// unknown to us filled with NOASSERTION.
+func newSPDXPackage(id, name, version string) spdxPackage {
+	return spdxPackage{
+		SPDXID:           id,
+		Name:             name,
+		VersionInfo:      version,
+		DownloadLocation: spdxNoAssertion,
+		LicenseConcluded: spdxNoAssertion,
+		LicenseDeclared:  spdxNoAssertion,
+		CopyrightText:    spdxNoAssertion,
+	}
+}
+
+// WriteSPDX encodes the SBOM as an SPDX 2.3 document in JSON.
+//
+// The document describes a root package named after the SBOM,
+// which contains the operating system and packages found.
+// The licenses collected are placed as license comments, since
+// they are not guaranteed to be valid SPDX license expressions.
+func WriteSPDX(w io.Writer, sbom *SBOM) error {
+	root := newSPDXPackage(spdxID("Root"), sbom.Name, "")
+	root.PrimaryPurpose = "CONTAINER"
+	doc := spdxDocument{
+		SPDXVersion: spdxVersion,
+		DataLicense: spdxDataLicense,
+		SPDXID:      spdxDocumentID,
+		Name:        sbom.Name,
+		DocumentNamespace: spdxNamespaceBase +
+			purlEscape(sbom.Name) + "-" + uuid.New().String(),
+		CreationInfo: spdxCreationInfo{
+			Created:  time.Now().UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + toolName},
+		},
+		Packages: []spdxPackage{root},
+		Relationships: []spdxRelationship{{
+			Element: spdxDocumentID,
+			Type:    "DESCRIBES",
+			Related: root.SPDXID,
+		}},
+	}
+	contains := func(pkg spdxPackage) {
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			Element: root.SPDXID,
+			Type:    "CONTAINS",
+			Related: pkg.SPDXID,
+		})
+	}
+	if sbom.Distro != nil {
+		distro := newSPDXPackage(spdxID("OperatingSystem-"+sbom.Distro.ID),
+			sbom.Distro.ID, sbom.Distro.VersionID)
+		distro.PrimaryPurpose = "OPERATING-SYSTEM"
+		contains(distro)
+	}
+	for i, p := range sbom.Packages {
+		pkg := newSPDXPackage(spdxID("Package-"+p.Type.String()+
+			"-"+p.Name+"-"+strconv.Itoa(i)), p.Name, p.Version)
+		pkg.PrimaryPurpose = "LIBRARY"
+		if p.Maintainer != "" {
+			pkg.Supplier = "Organization: " + p.Maintainer
+		}
+		if p.SourceName != "" {
+			pkg.SourceInfo = "built package from: " + p.SourceName
+			if p.SourceVersion != "" {
+				pkg.SourceInfo += " " + p.SourceVersion
+			}
+		}
+		if len(p.Licenses) > 0 {
+			pkg.LicenseComments = strings.Join(p.Licenses, ", ")
+		}
+		pkg.ExternalRefs = []spdxExternalRef{{
+			Category: "PACKAGE-MANAGER",
+			Type:     "purl",
+			Locator:  p.PackageURL(sbom.Distro),
+		}}
+		contains(pkg)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
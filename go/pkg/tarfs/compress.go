@@ -0,0 +1,74 @@
+package tarfs
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	xzMagic   = []byte{0xfd, 0x37, 0x7a, 0x58, 0x5a, 0x00}
+)
+
+// maxMagic is the length of the longest magic number.
+const maxMagic = 6
+
+// Compressed sniffs whether the content is compressed in a
+// format recognized by Decompress.
+func Compressed(r io.ReaderAt) (bool, error) {
+	magic := make([]byte, maxMagic)
+	n, err := r.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	magic = magic[:n]
+	return bytes.HasPrefix(magic, gzipMagic) ||
+		bytes.HasPrefix(magic, zstdMagic) ||
+		bytes.HasPrefix(magic, xzMagic), nil
+}
+
+type zstdReader struct {
+	*zstd.Decoder
+}
+
+func (r zstdReader) Close() error {
+	r.Decoder.Close()
+	return nil
+}
+
+// Decompress sniffs the compression format of the stream, and
+// returns the decompressed stream. The gzip, zstd and xz
+// formats are recognized, and the stream is returned as is
+// otherwise.
+func Decompress(r io.Reader) (io.ReadCloser, error) {
+	buf := bufio.NewReader(r)
+	magic, err := buf.Peek(maxMagic)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(buf)
+	case bytes.HasPrefix(magic, zstdMagic):
+		decoder, err := zstd.NewReader(buf)
+		if err != nil {
+			return nil, err
+		}
+		return zstdReader{Decoder: decoder}, nil
+	case bytes.HasPrefix(magic, xzMagic):
+		decompressor, err := xz.NewReader(buf)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(decompressor), nil
+	default:
+		return ioutil.NopCloser(buf), nil
+	}
+}
@@ -0,0 +1,270 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// Prefixes of the whiteout files in the layer archive.
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutMeta   = ".wh..wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// Layer is the file system of a layer archive, where the
+// whiteout files are not presented but recorded aside.
+type Layer struct {
+	FS
+	whiteouts []string
+	opaques   []string
+
+	// links are the hard links whose target is not inside
+	// the layer, which will be resolved while merging.
+	links map[string]*node
+
+	closer func() error
+}
+
+// NewLayer indexes the uncompressed layer archive, the reader
+// must be kept available until the layer is no longer used.
+func NewLayer(r io.ReaderAt, size int64) (*Layer, error) {
+	l := &Layer{
+		FS:    FS{root: newDir("/")},
+		links: make(map[string]*node),
+	}
+	section := io.NewSectionReader(r, 0, size)
+	reader := tar.NewReader(section)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		offset, err := section.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		n := &node{header: header}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			n.children = make(map[string]*node)
+		case tar.TypeReg, tar.TypeGNUSparse:
+			n.data, n.offset, n.size = r, offset, header.Size
+			if isSparse(header) {
+				// The content of sparse files is not stored
+				// continuously, so they are expanded in memory.
+				content, err := ioutil.ReadAll(reader)
+				if err != nil {
+					return nil, err
+				}
+				n.data, n.offset = bytes.NewReader(content), 0
+				n.size = int64(len(content))
+			}
+		}
+		l.add(header.Name, n)
+	}
+	for p, n := range l.links {
+		target := l.find(n.header.Linkname)
+		if target != nil && target.data != nil {
+			n.data, n.offset, n.size = target.data, target.offset, target.size
+			delete(l.links, p)
+		}
+	}
+	return l, nil
+}
+
+func isSparse(header *tar.Header) bool {
+	if header.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for key := range header.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// add the node at the path, the whiteout files are recorded
+// instead of being added.
+func (l *Layer) add(name string, n *node) {
+	name = path.Clean("/" + name)
+	if name == "/" {
+		if n.isDir() {
+			l.root.header = n.header
+			l.root.implied = false
+		}
+		return
+	}
+	dir, base := path.Split(name)
+	parent := l.root
+	for _, item := range splitPath(dir) {
+		child := parent.children[item]
+		if child == nil || !child.isDir() {
+			child = newDir(item)
+			parent.children[item] = child
+		}
+		parent = child
+	}
+	switch {
+	case base == whiteoutOpaque:
+		l.opaques = append(l.opaques, path.Clean(dir))
+		return
+	case strings.HasPrefix(base, whiteoutMeta):
+		return
+	case strings.HasPrefix(base, whiteoutPrefix):
+		l.whiteouts = append(l.whiteouts,
+			path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+		return
+	}
+	n.name = base
+	if existing := parent.children[base]; existing != nil &&
+		existing.isDir() && n.isDir() {
+		n.children = existing.children
+	}
+	parent.children[base] = n
+	if n.header.Typeflag == tar.TypeLink {
+		l.links[name] = n
+	} else {
+		delete(l.links, name)
+	}
+}
+
+// openOptions is the internal state that a tarfs.OpenOption
+// can manipulate for opening a layer.
+type openOptions struct {
+	tempDir string
+}
+
+// OpenOption is the option that can be used for opening a
+// layer archive by tarfs.Open.
+type OpenOption func(*openOptions)
+
+// WithTempDir specifies the directory of the temporary file
+// the layer is decompressed into, which defaults to the
+// directory returned by os.TempDir.
+func WithTempDir(dir string) OpenOption {
+	return func(o *openOptions) {
+		o.tempDir = dir
+	}
+}
+
+// Open indexes the layer archive, which is decompressed into a
+// temporary file first when it is compressed by gzip, zstd or
+// xz. The temporary file is removed when the layer is closed.
+func Open(r io.ReaderAt, size int64, opts ...OpenOption) (*Layer, error) {
+	var options openOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	compressed, err := Compressed(r)
+	if err != nil {
+		return nil, err
+	}
+	if !compressed {
+		return NewLayer(r, size)
+	}
+	decompressor, err := Decompress(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = decompressor.Close() }()
+	f, err := ioutil.TempFile(options.tempDir, "veinmind-layer-")
+	if err != nil {
+		return nil, err
+	}
+	remove := func() error {
+		_ = f.Close()
+		return os.Remove(f.Name())
+	}
+	written, err := io.Copy(f, decompressor)
+	if err != nil {
+		_ = remove()
+		return nil, err
+	}
+	l, err := NewLayer(f, written)
+	if err != nil {
+		_ = remove()
+		return nil, err
+	}
+	l.closer = remove
+	return l, nil
+}
+
+// Whiteouts returns the paths removed by the layer.
+func (l *Layer) Whiteouts() []string {
+	return append([]string(nil), l.whiteouts...)
+}
+
+// Opaques returns the directories whose content in the lower
+// layers are hidden by the layer.
+func (l *Layer) Opaques() []string {
+	return append([]string(nil), l.opaques...)
+}
+
+// Close releases the temporary file of the layer if any.
+func (l *Layer) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	closer := l.closer
+	l.closer = nil
+	return closer()
+}
+
+// Merge the layers from the bottom-most one into the file
+// system of the image. The layers must be kept open until
+// the merged file system is no longer used.
+func Merge(layers ...*Layer) *FS {
+	f := &FS{root: newDir("/")}
+	for _, l := range layers {
+		for _, opaque := range l.opaques {
+			if n := f.find(opaque); n != nil && n.isDir() {
+				n.children = make(map[string]*node)
+			}
+		}
+		for _, whiteout := range l.whiteouts {
+			dir, base := path.Split(whiteout)
+			if n := f.find(dir); n != nil && n.isDir() {
+				delete(n.children, base)
+			}
+		}
+		overlay(f.root, l.root)
+		for p, link := range l.links {
+			n := f.find(p)
+			if n == nil || n.header != link.header {
+				continue
+			}
+			target := f.find(link.header.Linkname)
+			if target != nil && target.data != nil {
+				n.data, n.offset, n.size = target.data, target.offset, target.size
+			}
+		}
+	}
+	return f
+}
+
+// overlay the upper directory onto the lower one, where the
+// directories are merged and other files are replaced.
+func overlay(lower, upper *node) {
+	if !upper.implied {
+		lower.header = upper.header
+		lower.implied = false
+	}
+	for name, child := range upper.children {
+		existing := lower.children[name]
+		if existing != nil && existing.isDir() && child.isDir() {
+			overlay(existing, child)
+			continue
+		}
+		lower.children[name] = child.clone()
+	}
+}
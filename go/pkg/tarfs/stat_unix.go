@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package tarfs
+
+import (
+	"archive/tar"
+	"syscall"
+)
+
+// sysStat converts the header into the stat of the file, so
+// that its owner is recognized as if it is on the disk.
+func sysStat(header *tar.Header, size int64) interface{} {
+	return &syscall.Stat_t{
+		Uid:  uint32(header.Uid),
+		Gid:  uint32(header.Gid),
+		Size: size,
+	}
+}
@@ -0,0 +1,385 @@
+// Package tarfs serves the api.FileSystem over the tar archives
+// of image layers in pure Go, without extracting them.
+//
+// The archive is indexed once when it is opened, recording the
+// offset of each file's content, so that the files can be read
+// randomly from the archive later. Layers are either visited
+// individually, where the whiteout files are recorded aside
+// instead of being presented, or merged into the file system
+// of the image with the whiteouts and opaque directories
+// applied in the same way as overlayfs.
+package tarfs
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// maxSymlinks is the maximum number of symbolic links to
+// follow while resolving a path, in accordance with the
+// MAXSYMLINKS in linux.
+const maxSymlinks = 40
+
+// node is a file in the file system tree.
+type node struct {
+	name   string
+	header *tar.Header
+
+	// data, offset and size locates the content of the
+	// regular file inside the archive.
+	data   io.ReaderAt
+	offset int64
+	size   int64
+
+	// children is the entries of the directory, which is
+	// nil for the other file types.
+	children map[string]*node
+
+	// implied is set for the directories that are not
+	// recorded in the archive, like the omitted parents.
+	implied bool
+}
+
+// newDir creates a directory node that is not recorded in
+// the archive, like the root or the omitted parents.
+func newDir(name string) *node {
+	return &node{
+		name: name,
+		header: &tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     name,
+			Mode:     0755,
+			ModTime:  time.Unix(0, 0),
+		},
+		children: make(map[string]*node),
+		implied:  true,
+	}
+}
+
+func (n *node) isDir() bool {
+	return n.children != nil
+}
+
+func (n *node) isSymlink() bool {
+	return n.header.Typeflag == tar.TypeSymlink
+}
+
+// clone copies the node and its descendants, while the
+// headers and content are shared.
+func (n *node) clone() *node {
+	result := *n
+	if n.children != nil {
+		result.children = make(map[string]*node, len(n.children))
+		for name, child := range n.children {
+			result.children[name] = child.clone()
+		}
+	}
+	return &result
+}
+
+// names returns the sorted names of the children.
+func (n *node) names() []string {
+	result := make([]string, 0, len(n.children))
+	for name := range n.children {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (n *node) info() os.FileInfo {
+	return &fileInfo{name: n.name, header: n.header, size: n.size}
+}
+
+// fileInfo is the os.FileInfo of the file in the archive.
+type fileInfo struct {
+	name   string
+	header *tar.Header
+	size   int64
+}
+
+func (i *fileInfo) Name() string {
+	return i.name
+}
+
+func (i *fileInfo) Size() int64 {
+	return i.size
+}
+
+func (i *fileInfo) Mode() os.FileMode {
+	mode := i.header.FileInfo().Mode()
+	if i.header.Typeflag == tar.TypeLink {
+		mode &^= os.ModeType
+	}
+	return mode
+}
+
+func (i *fileInfo) ModTime() time.Time {
+	return i.header.ModTime
+}
+
+func (i *fileInfo) IsDir() bool {
+	return i.Mode().IsDir()
+}
+
+func (i *fileInfo) Sys() interface{} {
+	return sysStat(i.header, i.size)
+}
+
+// splitPath cleans the path and splits it into components.
+func splitPath(p string) []string {
+	p = path.Clean("/" + filepath.ToSlash(p))
+	if p == "/" {
+		return nil
+	}
+	return strings.Split(p[1:], "/")
+}
+
+// FS is the read-only file system over the file tree, where
+// every attempt to write files fails.
+type FS struct {
+	root *node
+}
+
+// find returns the node at the path literally, without
+// following any symbolic links.
+func (f *FS) find(p string) *node {
+	n := f.root
+	for _, item := range splitPath(p) {
+		if !n.isDir() {
+			return nil
+		}
+		if n = n.children[item]; n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// lookup resolves the path inside the file system, where the
+// symbolic links are confined to the root of the file system.
+// The last component is followed when it is a symbolic link
+// and follow is specified.
+func (f *FS) lookup(op, p string, follow bool) (*node, string, error) {
+	stack := []*node{f.root}
+	var names []string
+	rest := splitPath(p)
+	links := 0
+	for len(rest) > 0 {
+		item := rest[0]
+		rest = rest[1:]
+		switch item {
+		case "", ".":
+			continue
+		case "..":
+			if len(names) > 0 {
+				stack = stack[:len(stack)-1]
+				names = names[:len(names)-1]
+			}
+			continue
+		}
+		current := stack[len(stack)-1]
+		if !current.isDir() {
+			return nil, "", &os.PathError{
+				Op: op, Path: p, Err: syscall.ENOTDIR}
+		}
+		child := current.children[item]
+		if child == nil {
+			return nil, "", &os.PathError{
+				Op: op, Path: p, Err: syscall.ENOENT}
+		}
+		if child.isSymlink() && (len(rest) > 0 || follow) {
+			links++
+			if links > maxSymlinks {
+				return nil, "", &os.PathError{
+					Op: op, Path: p, Err: syscall.ELOOP}
+			}
+			target := filepath.ToSlash(child.header.Linkname)
+			if path.IsAbs(target) {
+				stack = stack[:1]
+				names = nil
+			}
+			rest = append(strings.Split(target, "/"), rest...)
+			continue
+		}
+		stack = append(stack, child)
+		names = append(names, item)
+	}
+	return stack[len(stack)-1], "/" + strings.Join(names, "/"), nil
+}
+
+func (f *FS) Open(p string) (api.File, error) {
+	n, _, err := f.lookup("open", p, true)
+	if err != nil {
+		return nil, err
+	}
+	var r io.ReaderAt = emptyReader{}
+	if n.data != nil {
+		r = n.data
+	}
+	return &file{
+		SectionReader: io.NewSectionReader(r, n.offset, n.size),
+		name:          p,
+		node:          n,
+	}, nil
+}
+
+func (f *FS) Stat(p string) (os.FileInfo, error) {
+	n, _, err := f.lookup("stat", p, true)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(), nil
+}
+
+func (f *FS) Lstat(p string) (os.FileInfo, error) {
+	n, _, err := f.lookup("lstat", p, false)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(), nil
+}
+
+func (f *FS) Readlink(p string) (string, error) {
+	n, _, err := f.lookup("readlink", p, false)
+	if err != nil {
+		return "", err
+	}
+	if !n.isSymlink() {
+		return "", &os.PathError{
+			Op: "readlink", Path: p, Err: syscall.EINVAL}
+	}
+	return n.header.Linkname, nil
+}
+
+func (f *FS) EvalSymlink(p string) (string, error) {
+	_, resolved, err := f.lookup("evalsymlink", p, true)
+	return resolved, err
+}
+
+func (f *FS) Readdir(p string) ([]os.FileInfo, error) {
+	n, _, err := f.lookup("readdir", p, true)
+	if err != nil {
+		return nil, err
+	}
+	if !n.isDir() {
+		return nil, &os.PathError{
+			Op: "readdir", Path: p, Err: syscall.ENOTDIR}
+	}
+	result := make([]os.FileInfo, 0, len(n.children))
+	for _, name := range n.names() {
+		result = append(result, n.children[name].info())
+	}
+	return result, nil
+}
+
+// xattrPrefix is the prefix of the PAX records holding the
+// extended attributes, which is the one used by GNU tar.
+const xattrPrefix = "SCHILY.xattr."
+
+// Xattrs returns the extended attributes of the file recorded
+// in the archive, without following symbolic links.
+func (f *FS) Xattrs(p string) (map[string]string, error) {
+	n, _, err := f.lookup("xattrs", p, false)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string)
+	for key, value := range n.header.PAXRecords {
+		if strings.HasPrefix(key, xattrPrefix) {
+			result[strings.TrimPrefix(key, xattrPrefix)] = value
+		}
+	}
+	return result, nil
+}
+
+// Walk visits the file system in the same way as the
+// filepath.Walk, in lexical order and without following
+// symbolic links.
+func (f *FS) Walk(root string, walkFn filepath.WalkFunc) error {
+	root = path.Clean("/" + filepath.ToSlash(root))
+	n, _, err := f.lookup("lstat", root, false)
+	if err != nil {
+		err = walkFn(root, nil, err)
+	} else {
+		err = walk(root, n, walkFn)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func walk(p string, n *node, walkFn filepath.WalkFunc) error {
+	if err := walkFn(p, n.info(), nil); err != nil {
+		return err
+	}
+	if !n.isDir() {
+		return nil
+	}
+	for _, name := range n.names() {
+		child := n.children[name]
+		if err := walk(path.Join(p, name), child, walkFn); err != nil {
+			if !child.isDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type emptyReader struct{}
+
+func (emptyReader) ReadAt(_ []byte, _ int64) (int, error) {
+	return 0, io.EOF
+}
+
+// file is the opened file in the archive.
+type file struct {
+	*io.SectionReader
+	name string
+	node *node
+}
+
+func (f *file) Read(b []byte) (int, error) {
+	if f.node.isDir() {
+		return 0, &os.PathError{
+			Op: "read", Path: f.name, Err: syscall.EISDIR}
+	}
+	return f.SectionReader.Read(b)
+}
+
+func (f *file) ReadAt(b []byte, off int64) (int, error) {
+	if f.node.isDir() {
+		return 0, &os.PathError{
+			Op: "read", Path: f.name, Err: syscall.EISDIR}
+	}
+	return f.SectionReader.ReadAt(b, off)
+}
+
+func (f *file) Write(_ []byte) (int, error) {
+	return 0, &os.PathError{
+		Op: "write", Path: f.name, Err: os.ErrPermission}
+}
+
+func (f *file) WriteAt(_ []byte, _ int64) (int, error) {
+	return 0, &os.PathError{
+		Op: "write", Path: f.name, Err: os.ErrPermission}
+}
+
+func (f *file) Close() error {
+	return nil
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	return f.node.info(), nil
+}
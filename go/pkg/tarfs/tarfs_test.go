@@ -0,0 +1,264 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/xerrors"
+
+	"github.com/chaitin/libveinmind/go/pkg/iofs"
+)
+
+// testEntry is an entry of the test archive, whose type is
+// inferred from its fields unless specified.
+type testEntry struct {
+	name     string
+	typeflag byte
+	content  string
+	linkname string
+	xattrs   map[string]string
+}
+
+// testArchive encodes the entries into an uncompressed tar.
+func testArchive(t *testing.T, entries ...testEntry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		header := &tar.Header{
+			Name: e.name, Typeflag: e.typeflag, Mode: 0644,
+			Linkname: e.linkname,
+		}
+		switch {
+		case header.Typeflag != 0:
+		case strings.HasSuffix(e.name, "/"):
+			header.Typeflag, header.Mode = tar.TypeDir, 0755
+		case e.linkname != "":
+			header.Typeflag = tar.TypeSymlink
+		default:
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(e.content))
+		}
+		for key, value := range e.xattrs {
+			if header.PAXRecords == nil {
+				header.PAXRecords = make(map[string]string)
+			}
+			header.PAXRecords[xattrPrefix+key] = value
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testLayer(t *testing.T, entries ...testEntry) *Layer {
+	data := testArchive(t, entries...)
+	l, err := NewLayer(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// readFile reads the content of the file in the file system,
+// or the error as the content when it fails.
+func readFile(f *FS, p string) string {
+	file, err := f.Open(p)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	defer func() { _ = file.Close() }()
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return string(data)
+}
+
+func TestLayer(t *testing.T) {
+	l := testLayer(t,
+		testEntry{name: "etc/"},
+		testEntry{name: "etc/passwd", content: "root"},
+		testEntry{name: "etc/.wh.shadow"},
+		testEntry{name: "var/lib/.wh..wh..opq"},
+		testEntry{name: "usr/bin/sh", content: "sh"},
+		testEntry{name: "usr/bin/bash", typeflag: tar.TypeLink,
+			linkname: "usr/bin/sh"},
+		testEntry{name: "bin", linkname: "usr/bin"},
+		testEntry{name: "etc/abs", linkname: "/etc/passwd"},
+		testEntry{name: "etc/escape", linkname: "../../../etc/passwd"},
+		testEntry{name: "etc/loop", linkname: "loop"},
+		testEntry{name: "etc/.wh..wh.plnk"},
+	)
+	defer func() { _ = l.Close() }()
+	if result := l.Whiteouts(); len(result) != 1 || result[0] != "/etc/shadow" {
+		t.Fatalf("unexpected whiteouts %v", result)
+	}
+	if result := l.Opaques(); len(result) != 1 || result[0] != "/var/lib" {
+		t.Fatalf("unexpected opaques %v", result)
+	}
+	for _, c := range []struct {
+		path string
+		want string
+	}{
+		{"/etc/passwd", "root"},
+		{"etc/../etc/passwd", "root"},
+		{"/usr/bin/bash", "sh"},
+		{"/bin/sh", "sh"},
+		{"/etc/abs", "root"},
+		{"/etc/escape", "root"},
+		{"/etc/shadow", "error: open /etc/shadow: no such file or directory"},
+		{"/etc/passwd/x", "error: open /etc/passwd/x: not a directory"},
+		{"/etc/loop", "error: open /etc/loop: too many levels of symbolic links"},
+	} {
+		if result := readFile(&l.FS, c.path); result != c.want {
+			t.Errorf("read %q: got %q, want %q", c.path, result, c.want)
+		}
+	}
+
+	for _, c := range []struct {
+		path string
+		want string
+	}{
+		{"/bin/sh", "/usr/bin/sh"},
+		{"/etc/escape", "/etc/passwd"},
+		{"/", "/"},
+	} {
+		if result, err := l.EvalSymlink(c.path); err != nil || result != c.want {
+			t.Errorf("eval %q: got %q, %v, want %q", c.path, result, err, c.want)
+		}
+	}
+	if target, err := l.Readlink("/bin"); err != nil || target != "usr/bin" {
+		t.Fatalf("unexpected link %q: %v", target, err)
+	}
+	if _, err := l.Readlink("/etc/passwd"); !xerrors.Is(err, syscall.EINVAL) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	info, err := l.Lstat("/bin")
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("unexpected info %v: %v", info, err)
+	}
+}
+
+func TestLayerXattrs(t *testing.T) {
+	l := testLayer(t, testEntry{name: "usr/bin/ping", content: "ping",
+		xattrs: map[string]string{"security.capability": "cap"}})
+	xattrs, err := l.Xattrs("/usr/bin/ping")
+	if err != nil || len(xattrs) != 1 || xattrs["security.capability"] != "cap" {
+		t.Fatalf("unexpected xattrs %v: %v", xattrs, err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	layers := []*Layer{
+		testLayer(t,
+			testEntry{name: "etc/passwd", content: "root"},
+			testEntry{name: "etc/shadow", content: "secret"},
+			testEntry{name: "var/lib/a", content: "a"},
+			testEntry{name: "usr/lib/x", content: "x"},
+			testEntry{name: "usr/bin/sh", content: "sh"}),
+		testLayer(t,
+			testEntry{name: "etc/.wh.shadow"},
+			testEntry{name: "var/lib/.wh..wh..opq"},
+			testEntry{name: "var/lib/b", content: "b"},
+			testEntry{name: "usr/lib", content: "file"},
+			testEntry{name: "usr/bin/bash", typeflag: tar.TypeLink,
+				linkname: "usr/bin/sh"}),
+		testLayer(t,
+			testEntry{name: "etc/passwd", content: "root:x"},
+			testEntry{name: "etc/shadow", content: "again"}),
+	}
+	merged := Merge(layers...)
+	for _, c := range []struct {
+		path string
+		want string
+	}{
+		{"/etc/passwd", "root:x"},
+		{"/etc/shadow", "again"},
+		{"/var/lib/a", "error: open /var/lib/a: no such file or directory"},
+		{"/var/lib/b", "b"},
+		{"/usr/lib", "file"},
+		{"/usr/lib/x", "error: open /usr/lib/x: not a directory"},
+		{"/usr/bin/bash", "sh"},
+	} {
+		if result := readFile(merged, c.path); result != c.want {
+			t.Errorf("read %q: got %q, want %q", c.path, result, c.want)
+		}
+	}
+
+	var walked []string
+	if err := merged.Walk("/", func(p string, _ os.FileInfo, err error) error {
+		walked = append(walked, p)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(walked, ",") != "/,/etc,/etc/passwd,/etc/shadow,"+
+		"/usr,/usr/bin,/usr/bin/bash,/usr/bin/sh,/usr/lib,/var,/var/lib,/var/lib/b" {
+		t.Fatalf("unexpected walk %v", walked)
+	}
+	if err := fstest.TestFS(iofs.New(merged),
+		"etc/passwd", "var/lib/b", "usr/lib", "usr/bin/bash"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	data := testArchive(t, testEntry{name: "etc/passwd", content: "root"})
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	_, _ = gw.Write(data)
+	_ = gw.Close()
+	dir, err := ioutil.TempDir("", "tarfs-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	for _, c := range []struct {
+		name  string
+		data  []byte
+		files int
+	}{
+		{"uncompressed", data, 0},
+		{"gzip", compressed.Bytes(), 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			l, err := Open(bytes.NewReader(c.data), int64(len(c.data)),
+				WithTempDir(dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result := readFile(&l.FS, "/etc/passwd"); result != "root" {
+				t.Fatalf("unexpected content %q", result)
+			}
+			files, _ := ioutil.ReadDir(dir)
+			if len(files) != c.files {
+				t.Fatalf("unexpected temporary files %v", files)
+			}
+			if err := l.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+				t.Fatalf("temporary files %v left", files)
+			}
+		})
+	}
+
+	if _, err := Open(bytes.NewReader([]byte("garbage")), 7); err == nil {
+		t.Fatal("garbage opened")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build linux && !go1.20
+// +build linux,!go1.20
+
+package plugin
+
+import (
+	"os"
+)
+
+// startInCgroup starts the plugin as usual, with the cgroup
+// left to the caller, since CLONE_INTO_CGROUP is unsupported
+// by the toolchain before go 1.20.
+func startInCgroup(
+	path string, argv []string, attr *os.ProcAttr, _ string,
+) (*os.Process, bool, error) {
+	proc, err := os.StartProcess(path, argv, attr)
+	return proc, false, err
+}
@@ -0,0 +1,48 @@
+//go:build linux && go1.20
+// +build linux,go1.20
+
+package plugin
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/xerrors"
+)
+
+// startInCgroup starts the plugin in the cgroup directly by
+// CLONE_INTO_CGROUP, so that nothing it does escapes the
+// cgroup. True is returned if it is placed in the cgroup, and
+// it is started as usual where it is unsupported before linux
+// 5.7, with the cgroup left to the caller.
+func startInCgroup(
+	path string, argv []string, attr *os.ProcAttr, dir string,
+) (*os.Process, bool, error) {
+	if dir == "" {
+		proc, err := os.StartProcess(path, argv, attr)
+		return proc, false, err
+	}
+	fd, err := syscall.Open(dir,
+		syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = syscall.Close(fd) }()
+	result := *attr
+	sys := &syscall.SysProcAttr{}
+	if attr.Sys != nil {
+		*sys = *attr.Sys
+	}
+	sys.UseCgroupFD = true
+	sys.CgroupFD = fd
+	result.Sys = sys
+	proc, err := os.StartProcess(path, argv, &result)
+	if err == nil {
+		return proc, true, nil
+	}
+	if !xerrors.Is(err, syscall.ENOSYS) && !xerrors.Is(err, syscall.E2BIG) {
+		return nil, false, err
+	}
+	proc, err = os.StartProcess(path, argv, attr)
+	return proc, false, err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"runtime"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 )
@@ -24,6 +25,10 @@ type execOption struct {
 	args         []string
 	generators   []ExecGenerator
 	interceptors []ExecInterceptor
+	timeout      time.Duration
+	limits       execLimits
+	report       *ExecReport
+	stderrTail   int
 }
 
 // clone creates a copy of current options so that we can reuse
@@ -32,6 +37,10 @@ func (e *execOption) clone() *execOption {
 	result := &execOption{
 		parallelism: e.parallelism,
 		errHandler:  e.errHandler,
+		timeout:     e.timeout,
+		limits:      e.limits.clone(),
+		report:      e.report,
+		stderrTail:  e.stderrTail,
 	}
 	result.args = append(result.args, e.args...)
 	result.generators = append(result.generators, e.generators...)
@@ -155,12 +164,61 @@ func (p *execOption) exec(
 				return p.exec(ctx, args, plug, cmd)
 			})
 	}
+	if p.report == nil {
+		return p.run(ctx, args, plug, cmd, nil)
+	}
+
+	// The record is allocated for each invocation after all
+	// options are resolved, since the report might be specified
+	// by the generators, and the interceptors might invoke the
+	// next function more than once.
+	record := &ExecRecord{
+		Plugin:  plug.Name,
+		Version: plug.Version,
+		Command: append([]string(nil), cmd.Path...),
+		Start:   time.Now(),
+	}
+	err := p.run(ctx, args, plug, cmd, record)
+	record.finish(err)
+	p.report.add(*record)
+	return err
+}
+
+// run executes the command with the options resolved, while
+// capturing the standard error into the record if specified.
+func (p *execOption) run(
+	ctx context.Context, args []string, plug *Plugin, cmd *Command,
+	record *ExecRecord,
+) error {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	if !p.limits.empty() {
+		limits := p.limits.clone()
+		ctx = context.WithValue(ctx, execLimitsKey{}, &limits)
+	}
 	var execArgs []string
 	execArgs = append(execArgs, cmd.Path...)
 	execArgs = append(execArgs, p.args...)
 	execArgs = append(execArgs, args...)
+	files := []*os.File{nil, nil, nil}
+	if record != nil {
+		record.Args = execArgs[len(cmd.Path):]
+		limit := p.stderrTail
+		if limit <= 0 {
+			limit = DefaultStderrTail
+		}
+		stderr, wait, err := captureStderr(record, limit)
+		if err != nil {
+			return err
+		}
+		defer wait()
+		files[2] = stderr
+	}
 	return plug.exec(ctx, execArgs, &os.ProcAttr{
-		Files: []*os.File{nil, nil, nil},
+		Files: files,
 	})
 }
 
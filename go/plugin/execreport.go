@@ -0,0 +1,156 @@
+package plugin
+
+import (
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// DefaultStderrTail is the number of bytes kept from the end of
+// the standard error of each command while reporting.
+const DefaultStderrTail = 4096
+
+// ExecRecord is the record of executing a command of plugin.
+type ExecRecord struct {
+	Plugin   string        `json:"plugin"`
+	Version  string        `json:"version,omitempty"`
+	Command  []string      `json:"command"`
+	Args     []string      `json:"args,omitempty"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+
+	// ExitCode is the exit code of the plugin, which is -1 if
+	// it is terminated by signal or not started at all.
+	ExitCode int    `json:"exitCode"`
+	Signal   string `json:"signal,omitempty"`
+
+	// Stderr is the tail of the standard error of the plugin.
+	Stderr string `json:"stderr,omitempty"`
+
+	// Err is the error reported to the ExecHandler, and Error
+	// is its message for serialization.
+	Err   error  `json:"-"`
+	Error string `json:"error,omitempty"`
+}
+
+// Failed returns whether the execution of command failed.
+func (r ExecRecord) Failed() bool {
+	return r.Err != nil
+}
+
+// ExecReport collects the records of commands executed, which
+// is safe to be written concurrently.
+type ExecReport struct {
+	mu      sync.Mutex
+	records []ExecRecord
+}
+
+func (r *ExecReport) add(record ExecRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, record)
+}
+
+// Records returns the records ordered by the start time.
+func (r *ExecReport) Records() []ExecRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := append([]ExecRecord(nil), r.records...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result
+}
+
+// Failed returns the records of commands failed.
+func (r *ExecReport) Failed() []ExecRecord {
+	var result []ExecRecord
+	for _, record := range r.Records() {
+		if record.Failed() {
+			result = append(result, record)
+		}
+	}
+	return result
+}
+
+// WithExecReport collects the record of each command executed
+// into the report, including the tail of standard error.
+func WithExecReport(report *ExecReport) ExecOption {
+	return func(p *execOption) {
+		p.report = report
+	}
+}
+
+// WithStderrTail specifies the number of bytes kept from the
+// end of the standard error of each command while reporting,
+// which defaults to DefaultStderrTail.
+func WithStderrTail(n int) ExecOption {
+	return func(p *execOption) {
+		p.stderrTail = n
+	}
+}
+
+// tailBuffer keeps the last bytes written to it.
+type tailBuffer struct {
+	limit int
+	data  []byte
+}
+
+func (t *tailBuffer) Write(b []byte) (int, error) {
+	t.data = append(t.data, b...)
+	if len(t.data) > t.limit {
+		t.data = append(t.data[:0], t.data[len(t.data)-t.limit:]...)
+	}
+	return len(b), nil
+}
+
+// stderrDrainTimeout is the duration to wait for the standard
+// error after the plugin exits, since the processes spawned by
+// the plugin might still be holding it.
+const stderrDrainTimeout = 100 * time.Millisecond
+
+// captureStderr creates the pipe capturing the standard error
+// into the record, and the returned function must be called
+// after the plugin exits.
+func captureStderr(
+	record *ExecRecord, limit int,
+) (*os.File, func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	tail := &tailBuffer{limit: limit}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = io.Copy(tail, r)
+	}()
+	return w, func() {
+		_ = w.Close()
+		_ = r.SetReadDeadline(time.Now().Add(stderrDrainTimeout))
+		<-done
+		_ = r.Close()
+		record.Stderr = string(tail.data)
+	}, nil
+}
+
+// finish fills the record with the error of execution.
+func (r *ExecRecord) finish(err error) {
+	r.Duration = time.Since(r.Start)
+	r.Err = err
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.ExitCode = 0
+	var exitErr *ExitError
+	if xerrors.As(err, &exitErr) {
+		r.ExitCode = exitErr.ExitCode()
+		r.Signal = exitSignal(exitErr.ProcessState)
+	} else if err != nil {
+		r.ExitCode = -1
+	}
+}
@@ -0,0 +1,212 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// DefaultGracePeriod is the duration to wait for the plugin to
+// exit after it is asked to terminate, before it is killed.
+const DefaultGracePeriod = 5 * time.Second
+
+var (
+	// ErrExecTimeout is reported when the plugin is terminated
+	// since the deadline of the context has been exceeded.
+	ErrExecTimeout = xerrors.New("plugin execution timeout")
+
+	// ErrExecCanceled is reported when the plugin is terminated
+	// since the context has been canceled.
+	ErrExecCanceled = xerrors.New("plugin execution canceled")
+)
+
+type executorOption struct {
+	gracePeriod    time.Duration
+	forwardSignals bool
+}
+
+// ExecutorOption specifies how the executor created by
+// NewExecutor manages the plugin process.
+type ExecutorOption func(*executorOption)
+
+// WithGracePeriod specifies the duration to wait for the plugin
+// to exit after it is asked to terminate, before it is killed.
+//
+// Setting it to 0 will cause the plugin to be killed at once.
+func WithGracePeriod(d time.Duration) ExecutorOption {
+	return func(o *executorOption) {
+		o.gracePeriod = d
+	}
+}
+
+// WithSignalForwarding terminates the plugin when the host
+// receives SIGINT or SIGTERM, in the same way as the context is
+// canceled, since the plugin is in a process group of its own
+// and receives no signal from the terminal, like the SIGINT of
+// Ctrl-C.
+//
+// The signals are handled by the executor while the plugin is
+// running, so the host is no longer terminated by them, but is
+// told by the error wrapping ErrExecCanceled instead.
+func WithSignalForwarding() ExecutorOption {
+	return func(o *executorOption) {
+		o.forwardSignals = true
+	}
+}
+
+// NewExecutor creates the executor starting the plugin as a
+// process directly, in a process group of its own.
+//
+// When the context is done, the process group is asked to
+// terminate and killed after the grace period, so that the
+// processes spawned by the plugin will not leak, and so are
+// the processes left after the plugin exits. The error
+// returned wraps ErrExecTimeout or ErrExecCanceled then, which
+// could be told by xerrors.Is in the ExecHandler.
+//
+// The plugin is killed when the host exits on linux, by the
+// parent death signal, which is actually sent when the thread
+// starting the plugin exits. So the thread is locked until the
+// plugin exits, which keeps it from exiting with a goroutine
+// locking it.
+//
+// The resource limits specified by exec options are applied
+// to the plugin by StartLimitedProcess on linux.
+func NewExecutor(opts ...ExecutorOption) Executor {
+	option := &executorOption{
+		gracePeriod: DefaultGracePeriod,
+	}
+	for _, opt := range opts {
+		opt(option)
+	}
+	return func(
+		ctx context.Context, _ *Plugin,
+		path string, argv []string, attr *os.ProcAttr,
+	) error {
+		return option.execute(ctx, path, argv, attr)
+	}
+}
+
+// ExitError is reported when the plugin exits unsuccessfully,
+// carrying the state of the process exited.
+type ExitError struct {
+	*os.ProcessState
+}
+
+func (e *ExitError) Error() string {
+	return e.ProcessState.String()
+}
+
+// waitProcess waits for the plugin to exit, and kills the
+// processes left in its process group, which are killed before
+// reaping the plugin where it is supported.
+func waitProcess(proc *os.Process) error {
+	exited := waitExited(proc)
+	if exited {
+		_ = killProcessGroup(proc)
+	}
+	state, err := proc.Wait()
+	if !exited {
+		_ = killProcessGroup(proc)
+	}
+	if err != nil {
+		return err
+	}
+	if !state.Success() {
+		return &ExitError{ProcessState: state}
+	}
+	return nil
+}
+
+func (o *executorOption) execute(
+	ctx context.Context, path string, argv []string, attr *os.ProcAttr,
+) error {
+	if err := ctx.Err(); err != nil {
+		return interruptError(err, nil)
+	}
+	if o.forwardSignals {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	proc, release, err := StartLimitedProcess(
+		ctx, path, argv, processGroupAttr(attr))
+	if err != nil {
+		return err
+	}
+	defer release()
+	waitCh := make(chan error, 1)
+	go func() {
+		waitCh <- waitProcess(proc)
+	}()
+	select {
+	case err := <-waitCh:
+		return err
+	case <-ctx.Done():
+	}
+	if o.gracePeriod > 0 {
+		_ = terminateProcessGroup(proc)
+		timer := time.NewTimer(o.gracePeriod)
+		defer timer.Stop()
+		select {
+		case err := <-waitCh:
+			return interruptError(ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+	_ = killProcessGroup(proc)
+	return interruptError(ctx.Err(), <-waitCh)
+}
+
+// interruptedError is the error reported when the plugin is
+// terminated since the context is done, which is ErrExecTimeout
+// or ErrExecCanceled, and is also the error of exiting.
+type interruptedError struct {
+	reason error
+	err    error
+}
+
+func (e *interruptedError) Error() string {
+	return e.err.Error() + ": " + e.reason.Error()
+}
+
+func (e *interruptedError) Unwrap() error {
+	return e.reason
+}
+
+func (e *interruptedError) As(target interface{}) bool {
+	return xerrors.As(e.err, target)
+}
+
+// interruptError converts the error of the context into the
+// error reported, along with the exit status of the plugin.
+func interruptError(ctxErr, err error) error {
+	reason := ErrExecCanceled
+	if xerrors.Is(ctxErr, context.DeadlineExceeded) {
+		reason = ErrExecTimeout
+	}
+	if err == nil {
+		return reason
+	}
+	return &interruptedError{reason: reason, err: err}
+}
+
+// ExecuteStartProcessWithContext executes the plugin and kills
+// its process group at once when the context is done.
+func ExecuteStartProcessWithContext(
+	ctx context.Context, plug *Plugin,
+	path string, argv []string, attr *os.ProcAttr,
+) error {
+	return killExecutor(ctx, plug, path, argv, attr)
+}
+
+var killExecutor = NewExecutor(WithGracePeriod(0))
+
+var DefaultExecutor = NewExecutor()
@@ -0,0 +1,27 @@
+//go:build windows
+// +build windows
+
+package plugin
+
+import (
+	"os"
+)
+
+// processGroupAttr leaves the attribute as is, since there's no
+// process group to signal on windows.
+func processGroupAttr(attr *os.ProcAttr) *os.ProcAttr {
+	return attr
+}
+
+func terminateProcessGroup(proc *os.Process) error {
+	return proc.Kill()
+}
+
+func killProcessGroup(proc *os.Process) error {
+	return proc.Kill()
+}
+
+// exitSignal returns empty since there's no signal on windows.
+func exitSignal(_ *os.ProcessState) string {
+	return ""
+}
@@ -0,0 +1,42 @@
+//go:build !windows
+// +build !windows
+
+package plugin
+
+import (
+	"os"
+	"syscall"
+)
+
+// processGroupAttr places the plugin in a new process group,
+// whose ID is the process ID of the plugin, and kills it when
+// the host exits where it is supported.
+func processGroupAttr(attr *os.ProcAttr) *os.ProcAttr {
+	result := *attr
+	sys := &syscall.SysProcAttr{}
+	if attr.Sys != nil {
+		*sys = *attr.Sys
+	}
+	sys.Setpgid = true
+	sys.Pgid = 0
+	setDeathSignal(sys)
+	result.Sys = sys
+	return &result
+}
+
+func terminateProcessGroup(proc *os.Process) error {
+	return syscall.Kill(-proc.Pid, syscall.SIGTERM)
+}
+
+func killProcessGroup(proc *os.Process) error {
+	return syscall.Kill(-proc.Pid, syscall.SIGKILL)
+}
+
+// exitSignal returns the signal terminating the process.
+func exitSignal(state *os.ProcessState) string {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return ""
+	}
+	return status.Signal().String()
+}
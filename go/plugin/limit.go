@@ -0,0 +1,162 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// rlimitResource is the platform independent identifier of the
+// resources to limit, mapped to RLIMIT_* while applying.
+type rlimitResource int
+
+const (
+	rlimitAddressSpace rlimitResource = iota
+	rlimitCPUTime
+	rlimitOpenFiles
+)
+
+type rlimit struct {
+	resource rlimitResource
+	cur, max uint64
+}
+
+type cgroupLimits struct {
+	parent    string
+	memoryMax int64
+	cpuQuota  time.Duration
+	cpuPeriod time.Duration
+}
+
+// execLimits are the resource limits applied to each plugin
+// process, which are passed to the executor in context.
+type execLimits struct {
+	rlimits []rlimit
+	cgroup  cgroupLimits
+}
+
+func (l *execLimits) clone() execLimits {
+	result := *l
+	result.rlimits = append([]rlimit(nil), l.rlimits...)
+	return result
+}
+
+func (l *execLimits) setRlimit(resource rlimitResource, cur, max uint64) {
+	for i := range l.rlimits {
+		if l.rlimits[i].resource == resource {
+			l.rlimits[i].cur, l.rlimits[i].max = cur, max
+			return
+		}
+	}
+	l.rlimits = append(l.rlimits, rlimit{
+		resource: resource, cur: cur, max: max,
+	})
+}
+
+func (l *execLimits) hasCgroup() bool {
+	return l.cgroup.memoryMax > 0 || l.cgroup.cpuQuota > 0
+}
+
+func (l *execLimits) empty() bool {
+	return len(l.rlimits) == 0 && !l.hasCgroup()
+}
+
+type execLimitsKey struct{}
+
+// StartLimitedProcess starts the plugin process with the
+// resource limits specified by the exec options, which are
+// applied by the host right after the plugin is started. The
+// plugin is placed in its cgroup on cloning where it is
+// supported, and its rlimits are set by prlimit, which are
+// inherited by the processes it spawns later. The returned
+// function releases the resources allocated for limiting after
+// the process exits.
+//
+// This is called by the executors created by NewExecutor, and
+// custom executors should also call it to respect the limits.
+func StartLimitedProcess(
+	ctx context.Context, path string, argv []string, attr *os.ProcAttr,
+) (*os.Process, func(), error) {
+	limits, ok := ctx.Value(execLimitsKey{}).(*execLimits)
+	if !ok || limits.empty() {
+		proc, err := os.StartProcess(path, argv, attr)
+		return proc, func() {}, err
+	}
+	return limits.start(path, argv, attr)
+}
+
+// WithExecTimeout specifies the wall-clock timeout of each
+// command, after which the plugin is terminated, and the
+// error wrapping ErrExecTimeout is reported.
+func WithExecTimeout(d time.Duration) ExecOption {
+	return func(p *execOption) {
+		p.timeout = d
+	}
+}
+
+// WithExecAddressSpaceLimit limits the virtual memory of each
+// plugin process in bytes, as RLIMIT_AS does.
+func WithExecAddressSpaceLimit(bytes uint64) ExecOption {
+	return func(p *execOption) {
+		p.limits.setRlimit(rlimitAddressSpace, bytes, bytes)
+	}
+}
+
+// WithExecCPUTimeLimit limits the CPU time of each plugin
+// process, as RLIMIT_CPU does. The plugin will be sent SIGXCPU
+// first and then killed a second later.
+func WithExecCPUTimeLimit(d time.Duration) ExecOption {
+	return func(p *execOption) {
+		seconds := uint64((d + time.Second - 1) / time.Second)
+		p.limits.setRlimit(rlimitCPUTime, seconds, seconds+1)
+	}
+}
+
+// WithExecOpenFilesLimit limits the number of files opened by
+// each plugin process, as RLIMIT_NOFILE does.
+func WithExecOpenFilesLimit(n uint64) ExecOption {
+	return func(p *execOption) {
+		p.limits.setRlimit(rlimitOpenFiles, n, n)
+	}
+}
+
+// WithExecCgroupParent specifies the cgroup v2 directory under
+// which the cgroup of each plugin is created, which is required
+// by WithExecMemoryMax and WithExecCPUMax.
+//
+// The directory must be delegated to the host and hold no
+// process of its own, since the controllers could only be
+// enabled in cgroups without processes in cgroup v2. So the
+// cgroup of the host itself could not be the parent.
+func WithExecCgroupParent(path string) ExecOption {
+	return func(p *execOption) {
+		p.limits.cgroup.parent = path
+	}
+}
+
+// WithExecMemoryMax places each plugin in a cgroup v2 of its
+// own under the parent specified by WithExecCgroupParent, with
+// memory.max set to the bytes specified.
+//
+// The plugin together with the processes it spawned will be
+// killed by the OOM killer on exceeding the limit, instead of
+// eating up the memory of host.
+func WithExecMemoryMax(bytes int64) ExecOption {
+	return func(p *execOption) {
+		p.limits.cgroup.memoryMax = bytes
+	}
+}
+
+// WithExecCPUMax places each plugin in a cgroup v2 of its own
+// under the parent specified by WithExecCgroupParent, with
+// cpu.max set to the quota in every period. The period
+// defaults to 100ms when it is 0.
+func WithExecCPUMax(quota, period time.Duration) ExecOption {
+	return func(p *execOption) {
+		if period <= 0 {
+			period = 100 * time.Millisecond
+		}
+		p.limits.cgroup.cpuQuota = quota
+		p.limits.cgroup.cpuPeriod = period
+	}
+}
@@ -0,0 +1,155 @@
+//go:build linux
+// +build linux
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/xerrors"
+)
+
+var rlimitResources = map[rlimitResource]int{
+	rlimitAddressSpace: syscall.RLIMIT_AS,
+	rlimitCPUTime:      syscall.RLIMIT_CPU,
+	rlimitOpenFiles:    syscall.RLIMIT_NOFILE,
+}
+
+func (l *execLimits) start(
+	path string, argv []string, attr *os.ProcAttr,
+) (*os.Process, func(), error) {
+	release := func() {}
+	var dir string
+	if l.hasCgroup() {
+		var err error
+		if dir, release, err = l.cgroup.create(); err != nil {
+			return nil, nil, err
+		}
+	}
+	proc, placed, err := startInCgroup(path, argv, attr, dir)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	if err := l.apply(proc, dir, placed); err != nil {
+		_ = killProcessGroup(proc)
+		_, _ = proc.Wait()
+		release()
+		return nil, nil, err
+	}
+	return proc, release, nil
+}
+
+// apply moves the plugin into the cgroup unless it is placed
+// there on cloning, and sets the rlimits of it by prlimit.
+//
+// The plugin is running while they are applied, so what it
+// does at the very beginning, like allocating memory or
+// spawning processes, could escape the limits unless it is
+// placed in the cgroup on cloning.
+func (l *execLimits) apply(proc *os.Process, dir string, placed bool) error {
+	if dir != "" && !placed {
+		if err := writeCgroupFile(dir, "cgroup.procs",
+			strconv.Itoa(proc.Pid)); err != nil {
+			return err
+		}
+	}
+	for _, r := range l.rlimits {
+		if err := prlimit(proc.Pid, rlimitResources[r.resource],
+			&syscall.Rlimit{Cur: r.cur, Max: r.max}); err != nil {
+			return xerrors.Errorf("set rlimit: %w", err)
+		}
+	}
+	return nil
+}
+
+func prlimit(pid, resource int, limit *syscall.Rlimit) error {
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64,
+		uintptr(pid), uintptr(resource),
+		uintptr(unsafe.Pointer(limit)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	if err := ioutil.WriteFile(filepath.Join(dir, name),
+		[]byte(value), 0644); err != nil {
+		return xerrors.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// create creates the cgroup of the plugin under the parent,
+// and the returned function removes it.
+func (c *cgroupLimits) create() (string, func(), error) {
+	if c.parent == "" {
+		return "", nil, xerrors.New(
+			"cgroup parent is required by cgroup limits")
+	}
+	if _, err := os.Stat(filepath.Join(
+		c.parent, "cgroup.controllers")); err != nil {
+		return "", nil, xerrors.Errorf("cgroup v2 is unavailable: %w", err)
+	}
+	var controllers []string
+	if c.memoryMax > 0 {
+		controllers = append(controllers, "+memory")
+	}
+	if c.cpuQuota > 0 {
+		controllers = append(controllers, "+cpu")
+	}
+	if err := writeCgroupFile(c.parent, "cgroup.subtree_control",
+		strings.Join(controllers, " ")); err != nil {
+		if xerrors.Is(err, syscall.EBUSY) {
+			return "", nil, xerrors.Errorf(
+				"cgroup parent %q has processes of its own: %w",
+				c.parent, err)
+		}
+		return "", nil, err
+	}
+	dir, err := ioutil.TempDir(c.parent, "veinmind-plugin-")
+	if err != nil {
+		return "", nil, err
+	}
+	release := func() {
+		// Kill the processes left so that it could be removed,
+		// which is unsupported before linux 5.14 and ignored.
+		_ = writeCgroupFile(dir, "cgroup.kill", "1")
+		for i := 0; i < 10; i++ {
+			if err := os.Remove(dir); err == nil || os.IsNotExist(err) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if err := c.write(dir); err != nil {
+		release()
+		return "", nil, err
+	}
+	return dir, release, nil
+}
+
+func (c *cgroupLimits) write(dir string) error {
+	if c.memoryMax > 0 {
+		if err := writeCgroupFile(dir, "memory.max",
+			strconv.FormatInt(c.memoryMax, 10)); err != nil {
+			return err
+		}
+	}
+	if c.cpuQuota > 0 {
+		if err := writeCgroupFile(dir, "cpu.max",
+			strconv.FormatInt(c.cpuQuota.Microseconds(), 10)+" "+
+				strconv.FormatInt(c.cpuPeriod.Microseconds(), 10)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
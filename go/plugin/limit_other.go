@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package plugin
+
+import (
+	"os"
+
+	"golang.org/x/xerrors"
+)
+
+func (l *execLimits) start(
+	path string, argv []string, attr *os.ProcAttr,
+) (*os.Process, func(), error) {
+	return nil, nil, xerrors.New("resource limits are unsupported")
+}
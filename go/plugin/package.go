@@ -11,9 +11,6 @@ import (
 	"context"
 	"encoding/json"
 	"os"
-
-	"golang.org/x/sync/errgroup"
-	"golang.org/x/xerrors"
 )
 
 // CurrentManifestVersion is the current version of manifest.
@@ -61,63 +58,6 @@ type Executor func(
 	path string, argv []string, attr *os.ProcAttr,
 ) error
 
-func ExecuteStartProcessWithContext(
-	ctx context.Context, _ *Plugin,
-	path string, argv []string, attr *os.ProcAttr) error {
-	proc, err := os.StartProcess(path, argv, attr)
-	if err != nil {
-		return err
-	}
-
-	wait := make(chan error, 1)
-	errG, _ := errgroup.WithContext(ctx)
-	errG.Go(func() error {
-		state, err := proc.Wait()
-		if err != nil {
-			return err
-		}
-		if !state.Success() {
-			return xerrors.New(state.String())
-		}
-
-		return nil
-	})
-	go func() {
-		wait <- errG.Wait()
-	}()
-
-	select {
-	case <-ctx.Done():
-		err = proc.Kill()
-		if err != nil {
-			return err
-		}
-	case err = <-wait:
-		return err
-	}
-	return nil
-}
-
-func executeStartProcess(
-	ctx context.Context, _ *Plugin,
-	path string, argv []string, attr *os.ProcAttr,
-) error {
-	proc, err := os.StartProcess(path, argv, attr)
-	if err != nil {
-		return err
-	}
-	state, err := proc.Wait()
-	if err != nil {
-		return err
-	}
-	if !state.Success() {
-		return xerrors.New(state.String())
-	}
-	return nil
-}
-
-var DefaultExecutor = Executor(executeStartProcess)
-
 // Plugin is the parsed and verified plugin, ready for
 // issuing commands to it.
 //
@@ -0,0 +1,36 @@
+//go:build linux
+// +build linux
+
+package plugin
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// pPID is the idtype of waitid waiting for a process ID.
+const pPID = 1
+
+// setDeathSignal kills the plugin when the host exits, so that
+// it will not be left running as an orphan. The signal is sent
+// when the thread forking the plugin exits rather than the
+// host, so the caller must lock the thread while it runs.
+func setDeathSignal(sys *syscall.SysProcAttr) {
+	sys.Pdeathsig = syscall.SIGKILL
+}
+
+// waitExited blocks until the process exits without reaping it,
+// so that its ID will not be reused while signaling its process
+// group. False is returned if it is unsupported.
+func waitExited(proc *os.Process) bool {
+	var info [16]uint64
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pPID,
+			uintptr(proc.Pid), uintptr(unsafe.Pointer(&info)),
+			syscall.WEXITED|syscall.WNOWAIT, 0, 0)
+		if errno != syscall.EINTR {
+			return errno == 0
+		}
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package plugin
+
+import (
+	"os"
+	"syscall"
+)
+
+// setDeathSignal does nothing since there's no parent death
+// signal on this platform.
+func setDeathSignal(_ *syscall.SysProcAttr) {}
+
+// waitExited returns false since waiting without reaping is
+// unsupported on this platform.
+func waitExited(_ *os.Process) bool {
+	return false
+}
@@ -0,0 +1,88 @@
+package report
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/chaitin/libveinmind/go/plugin/service"
+)
+
+// Variables related to the initialization of default sink.
+var (
+	defaultOnce       sync.Once
+	defaultClientCore *clientCore
+	defaultSink       Sink
+	defaultError      error
+)
+
+// SetDefaultSink updates the default sink if not hosted.
+//
+// The sink provided will be ignored if it is being hosted,
+// and it is recommended to invoke the program right at the
+// start since it is not synchronized.
+func SetDefaultSink(s Sink) {
+	_ = DefaultSink()
+	if defaultClientCore == nil {
+		defaultSink = s
+	}
+}
+
+// DefaultSink returns the default global sink for reporting.
+//
+// When the program is hosted, it returns the sink that can
+// communicate with the host. Otherwise it returns the sink
+// writing findings as JSON Lines to the standard output.
+func DefaultSink() Sink {
+	defaultOnce.Do(func() {
+		hasService := false
+		if service.Hosted() {
+			ok, err := service.HasNamespace(Namespace)
+			if err != nil {
+				defaultError = err
+			}
+			hasService = ok
+		}
+		if hasService {
+			core, err := newClientCore()
+			if err != nil {
+				defaultError = err
+				return
+			}
+			defaultClientCore = core
+			defaultSink = core
+		} else {
+			defaultSink = NewJSONLinesSink(os.Stdout)
+		}
+	})
+	if defaultError != nil {
+		panic(defaultError)
+	}
+	return defaultSink
+}
+
+// Destroy after flushing the findings of default sink.
+func Destroy() error {
+	defaultOnce.Do(func() {
+		// Disable further initialization of the client core.
+		defaultError = xerrors.New("race with destroy default sink")
+	})
+	if defaultClientCore != nil {
+		return defaultClientCore.Close()
+	}
+	return nil
+}
+
+// Report the findings to the default sink, and the time of
+// the findings is filled with current time if unspecified.
+func Report(findings ...Finding) error {
+	now := time.Now()
+	for i := range findings {
+		if findings[i].Time.IsZero() {
+			findings[i].Time = now
+		}
+	}
+	return DefaultSink().Write(findings)
+}
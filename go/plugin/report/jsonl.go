@@ -0,0 +1,47 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// jsonLinesSink writes each finding as a line of JSON.
+type jsonLinesSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// NewJSONLinesSink creates the sink writing each finding as a
+// line of JSON to the writer, which is closed with the sink
+// when it is an io.Closer.
+func NewJSONLinesSink(w io.Writer) Sink {
+	result := &jsonLinesSink{encoder: json.NewEncoder(w)}
+	if closer, ok := w.(io.Closer); ok {
+		result.closer = closer
+	}
+	return result
+}
+
+func (s *jsonLinesSink) Write(findings []Finding) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range findings {
+		if err := s.encoder.Encode(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *jsonLinesSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closer == nil {
+		return nil
+	}
+	closer := s.closer
+	s.closer = nil
+	return closer.Close()
+}
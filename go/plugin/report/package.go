@@ -0,0 +1,77 @@
+// Package plugin/report provides a structured channel for
+// plugins to report their findings to the host, which is
+// based on the plugin/service.
+//
+// The findings are typed, so that the host could collect the
+// findings of different plugins and render them in a unified
+// format, like JSON Lines or SARIF, instead of parsing the
+// output of each plugins.
+package report
+
+import (
+	"time"
+)
+
+// Severity is the severity of a finding.
+type Severity string
+
+const (
+	Critical Severity = "critical"
+	High     Severity = "high"
+	Medium   Severity = "medium"
+	Low      Severity = "low"
+	Info     Severity = "info"
+)
+
+// ObjectType is the type of object that a finding is about.
+type ObjectType string
+
+const (
+	Image     ObjectType = "image"
+	Container ObjectType = "container"
+	Runtime   ObjectType = "runtime"
+	Cluster   ObjectType = "cluster"
+	IaC       ObjectType = "iac"
+)
+
+// Object identifies the object that a finding is about.
+type Object struct {
+	Type ObjectType `json:"type"`
+	ID   string     `json:"id"`
+}
+
+// Evidence locates the content proving a finding.
+type Evidence struct {
+	// Path is the path of the file inside the object.
+	Path string `json:"path,omitempty"`
+
+	// Layer is the ID of the image layer containing the
+	// file, which is empty when it is not specified.
+	Layer string `json:"layer,omitempty"`
+
+	// Content is the snippet of the file, if any.
+	Content string `json:"content,omitempty"`
+}
+
+// Finding is a result reported by the plugin, which is
+// serializable between host and plugin.
+type Finding struct {
+	Time        time.Time              `json:"time"`
+	Object      Object                 `json:"object"`
+	Severity    Severity               `json:"severity"`
+	RuleID      string                 `json:"ruleId"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description,omitempty"`
+	Evidence    []Evidence             `json:"evidence,omitempty"`
+	Remediation string                 `json:"remediation,omitempty"`
+	Fields      map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Sink is the destination of the findings.
+//
+// The sink must be safe to be written concurrently, and the
+// findings written might be buffered until it is closed.
+type Sink interface {
+	Write([]Finding) error
+	Close() error
+}
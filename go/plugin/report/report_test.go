@@ -0,0 +1,285 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testWriter is the buffer recording the times it is closed.
+type testWriter struct {
+	bytes.Buffer
+	closed int
+}
+
+func (w *testWriter) Close() error {
+	w.closed++
+	return nil
+}
+
+// testSink records the findings written.
+type testSink struct {
+	findings []Finding
+}
+
+func (s *testSink) Write(findings []Finding) error {
+	s.findings = append(s.findings, findings...)
+	return nil
+}
+
+func (s *testSink) Close() error {
+	return nil
+}
+
+var testTime = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestJSONLinesSink(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		findings []Finding
+		want     []string
+	}{{
+		name: "empty",
+	}, {
+		name: "minimal",
+		findings: []Finding{{
+			Time:     testTime,
+			Object:   Object{Type: Image, ID: "sha256:aa"},
+			Severity: High,
+			RuleID:   "R1",
+			Title:    "weak password",
+		}},
+		want: []string{`{"time":"2022-01-01T00:00:00Z",` +
+			`"object":{"type":"image","id":"sha256:aa"},` +
+			`"severity":"high","ruleId":"R1","title":"weak password"}`},
+	}, {
+		name: "full",
+		findings: []Finding{{
+			Time:        testTime,
+			Object:      Object{Type: Container, ID: "c1"},
+			Severity:    Low,
+			RuleID:      "R2",
+			Title:       "backdoor",
+			Description: "crontab entry",
+			Evidence: []Evidence{{
+				Path: "/etc/crontab", Layer: "l1", Content: "* * * * *",
+			}},
+			Remediation: "remove it",
+			Fields:      map[string]interface{}{"plugin": "scan"},
+		}, {
+			Time:   testTime,
+			Object: Object{Type: IaC, ID: "deploy.yaml"},
+			RuleID: "R3",
+		}},
+		want: []string{
+			`{"time":"2022-01-01T00:00:00Z",` +
+				`"object":{"type":"container","id":"c1"},` +
+				`"severity":"low","ruleId":"R2","title":"backdoor",` +
+				`"description":"crontab entry","evidence":[{"path":` +
+				`"/etc/crontab","layer":"l1","content":"* * * * *"}],` +
+				`"remediation":"remove it","fields":{"plugin":"scan"}}`,
+			`{"time":"2022-01-01T00:00:00Z",` +
+				`"object":{"type":"iac","id":"deploy.yaml"},` +
+				`"severity":"","ruleId":"R3","title":""}`,
+		},
+	}} {
+		t.Run(c.name, func(t *testing.T) {
+			w := &testWriter{}
+			sink := NewJSONLinesSink(w)
+			if err := sink.Write(c.findings); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				if err := sink.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if w.closed != 1 {
+				t.Fatalf("writer closed %d times", w.closed)
+			}
+			var lines []string
+			if w.Len() > 0 {
+				lines = strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n")
+			}
+			if !reflect.DeepEqual(lines, c.want) {
+				t.Fatalf("unexpected lines %q, want %q", lines, c.want)
+			}
+		})
+	}
+}
+
+func TestSARIFSink(t *testing.T) {
+	w := &testWriter{}
+	sink := NewSARIFSink(w, "veinmind")
+	if err := sink.Write([]Finding{{
+		Object:      Object{Type: Image, ID: "sha256:aa"},
+		Severity:    Critical,
+		RuleID:      "R1",
+		Title:       "webshell",
+		Description: "php webshell",
+		Evidence: []Evidence{
+			{Path: "/var/www/shell.php", Layer: "l1"},
+			{Content: "eval"},
+		},
+		Remediation: "remove it",
+		Fields:      map[string]interface{}{"plugin": "webshell"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write([]Finding{{
+		Object:   Object{Type: Container, ID: "c1"},
+		Severity: Medium,
+		RuleID:   "R1",
+		Title:    "webshell again",
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if w.Len() != 0 {
+		t.Fatal("log written before closed")
+	}
+	for i := 0; i < 2; i++ {
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w.closed != 1 {
+		t.Fatalf("writer closed %d times", w.closed)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(w.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != sarifVersion || len(log.Runs) != 1 {
+		t.Fatalf("unexpected log %+v", log)
+	}
+	run := log.Runs[0]
+	rules := run.Tool.Driver.Rules
+	if run.Tool.Driver.Name != "veinmind" || len(rules) != 1 ||
+		rules[0].ShortDescription.Text != "webshell" ||
+		rules[0].FullDescription == nil || rules[0].Help == nil {
+		t.Fatalf("unexpected driver %+v", run.Tool.Driver)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("unexpected results %+v", run.Results)
+	}
+	for i, c := range []struct {
+		level     string
+		message   string
+		locations int
+		logical   []string
+		uri       string
+		props     map[string]interface{}
+	}{{
+		level:     "error",
+		message:   "webshell: php webshell",
+		locations: 2,
+		logical:   []string{"sha256:aa", "l1"},
+		uri:       "/var/www/shell.php",
+		props: map[string]interface{}{
+			"severity": "critical", "objectType": "image",
+			"objectId": "sha256:aa", "remediation": "remove it",
+			"plugin": "webshell",
+		},
+	}, {
+		level:     "warning",
+		message:   "webshell again",
+		locations: 1,
+		logical:   []string{"c1"},
+		props: map[string]interface{}{
+			"severity": "medium", "objectType": "container",
+			"objectId": "c1",
+		},
+	}} {
+		result := run.Results[i]
+		if result.Level != c.level || result.Message.Text != c.message ||
+			len(result.Locations) != c.locations {
+			t.Fatalf("unexpected result %+v", result)
+		}
+		var logical []string
+		for _, l := range result.Locations[0].LogicalLocations {
+			logical = append(logical, l.Name)
+		}
+		if !reflect.DeepEqual(logical, c.logical) {
+			t.Fatalf("unexpected logical locations %q", logical)
+		}
+		uri := ""
+		if physical := result.Locations[0].PhysicalLocation; physical != nil {
+			uri = physical.ArtifactLocation.URI
+		}
+		if uri != c.uri || !reflect.DeepEqual(result.Properties, c.props) {
+			t.Fatalf("unexpected result %+v", result)
+		}
+	}
+}
+
+func TestSARIFLevel(t *testing.T) {
+	for _, c := range []struct {
+		severity Severity
+		want     string
+	}{
+		{Critical, "error"},
+		{High, "error"},
+		{Medium, "warning"},
+		{Low, "note"},
+		{Info, "note"},
+		{"", "note"},
+	} {
+		if result := sarifLevel(c.severity); result != c.want {
+			t.Errorf("level %q: got %q, want %q", c.severity, result, c.want)
+		}
+	}
+}
+
+func TestReportService(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		opts   []ServiceOption
+		delay  time.Duration
+		fields map[string]interface{}
+	}{{
+		name:  "default",
+		delay: 20 * time.Millisecond,
+	}, {
+		name:  "buffer delay",
+		opts:  []ServiceOption{WithBufferDelay(time.Second)},
+		delay: time.Second,
+	}, {
+		name:  "no buffer",
+		opts:  []ServiceOption{WithBufferDelay(time.Second), WithNoBuffer()},
+		delay: 0,
+	}, {
+		name: "fields",
+		opts: []ServiceOption{WithFields(map[string]interface{}{
+			"plugin": "scan",
+		})},
+		delay:  20 * time.Millisecond,
+		fields: map[string]interface{}{"plugin": "scan"},
+	}} {
+		t.Run(c.name, func(t *testing.T) {
+			sink := &testSink{}
+			s := NewService(sink, c.opts...).(*reportService)
+			if config := s.getConfig(); config.Delay != c.delay {
+				t.Fatalf("unexpected delay %v", config.Delay)
+			}
+			if err := s.report([]Finding{{RuleID: "R1"}, {
+				RuleID: "R2", Fields: map[string]interface{}{"kept": true},
+			}}); err != nil {
+				t.Fatal(err)
+			}
+			if len(sink.findings) != 2 {
+				t.Fatalf("unexpected findings %v", sink.findings)
+			}
+			if result := sink.findings[0].Fields; !reflect.DeepEqual(result, c.fields) {
+				t.Fatalf("unexpected fields %v", result)
+			}
+			if result := sink.findings[1].Fields; result["kept"] != true ||
+				len(result) != len(c.fields)+1 {
+				t.Fatalf("unexpected fields %v", result)
+			}
+		})
+	}
+}
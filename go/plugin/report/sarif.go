@@ -0,0 +1,197 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	ID               string        `json:"id"`
+	ShortDescription sarifMessage  `json:"shortDescription"`
+	FullDescription  *sarifMessage `json:"fullDescription,omitempty"`
+	Help             *sarifMessage `json:"help,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifLogicalLocation struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver sarifDriver `json:"driver"`
+	} `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+// sarifLevel maps the severity to the level of SARIF.
+func sarifLevel(s Severity) string {
+	switch s {
+	case Critical, High:
+		return "error"
+	case Medium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// sarifSink collects the findings and writes them as a SARIF
+// log when it is closed, since SARIF is not a streaming format.
+type sarifSink struct {
+	mu    sync.Mutex
+	w     io.Writer
+	run   sarifRun
+	rules map[string]struct{}
+}
+
+// NewSARIFSink creates the sink writing the findings as a
+// SARIF log of the tool to the writer. The log is written when
+// the sink is closed, and the writer is also closed when it is
+// an io.Closer.
+func NewSARIFSink(w io.Writer, tool string) Sink {
+	result := &sarifSink{
+		w:     w,
+		rules: make(map[string]struct{}),
+	}
+	result.run.Tool.Driver.Name = tool
+	result.run.Tool.Driver.Rules = []sarifRule{}
+	result.run.Results = []sarifResult{}
+	return result
+}
+
+func (s *sarifSink) add(f Finding) {
+	if _, ok := s.rules[f.RuleID]; !ok {
+		s.rules[f.RuleID] = struct{}{}
+		rule := sarifRule{
+			ID:               f.RuleID,
+			ShortDescription: sarifMessage{Text: f.Title},
+		}
+		if f.Description != "" {
+			rule.FullDescription = &sarifMessage{Text: f.Description}
+		}
+		if f.Remediation != "" {
+			rule.Help = &sarifMessage{Text: f.Remediation}
+		}
+		s.run.Tool.Driver.Rules = append(s.run.Tool.Driver.Rules, rule)
+	}
+	message := f.Title
+	if f.Description != "" {
+		message += ": " + f.Description
+	}
+	result := sarifResult{
+		RuleID:  f.RuleID,
+		Level:   sarifLevel(f.Severity),
+		Message: sarifMessage{Text: message},
+		Properties: map[string]interface{}{
+			"severity":   f.Severity,
+			"objectType": f.Object.Type,
+			"objectId":   f.Object.ID,
+		},
+	}
+	object := sarifLogicalLocation{
+		Name: f.Object.ID,
+		Kind: string(f.Object.Type),
+	}
+	for _, e := range f.Evidence {
+		location := sarifLocation{
+			LogicalLocations: []sarifLogicalLocation{object},
+		}
+		if e.Path != "" {
+			location.PhysicalLocation = &sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: e.Path},
+			}
+		}
+		if e.Layer != "" {
+			location.LogicalLocations = append(location.LogicalLocations,
+				sarifLogicalLocation{Name: e.Layer, Kind: "layer"})
+		}
+		result.Locations = append(result.Locations, location)
+	}
+	if len(result.Locations) == 0 {
+		result.Locations = []sarifLocation{{
+			LogicalLocations: []sarifLogicalLocation{object},
+		}}
+	}
+	if f.Remediation != "" {
+		result.Properties["remediation"] = f.Remediation
+	}
+	for k, v := range f.Fields {
+		result.Properties[k] = v
+	}
+	s.run.Results = append(s.run.Results, result)
+}
+
+func (s *sarifSink) Write(findings []Finding) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range findings {
+		s.add(f)
+	}
+	return nil
+}
+
+func (s *sarifSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		return nil
+	}
+	w := s.w
+	s.w = nil
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs:    []sarifRun{s.run},
+	})
+	if closer, ok := w.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,237 @@
+package report
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/xerrors"
+
+	"github.com/chaitin/libveinmind/go/plugin/service"
+)
+
+const Namespace = "github.com/chaitin/libveinmind/report"
+
+type reportConfig struct {
+	Delay time.Duration `json:"delay"`
+}
+
+// clientCore is the sink used when a plugin is hosted, and in
+// which case the findings are written back to the host in
+// batches.
+type clientCore struct {
+	ctx       context.Context
+	group     *errgroup.Group
+	findingCh chan Finding
+	closeCh   chan struct{}
+	report    func([]Finding) error
+}
+
+func (c *clientCore) Write(findings []Finding) error {
+	for _, f := range findings {
+		select {
+		case <-c.ctx.Done():
+			return xerrors.New("reporter closed")
+		case c.findingCh <- f:
+		}
+	}
+	return nil
+}
+
+// Close flushes the findings buffered, and returns the error
+// while writing them back to the host.
+func (c *clientCore) Close() error {
+	select {
+	case <-c.ctx.Done():
+	case c.closeCh <- struct{}{}:
+	}
+	return c.group.Wait()
+}
+
+func (c *clientCore) runBufferThread(
+	d time.Duration, bufferCh chan<- []Finding,
+) error {
+	defer close(bufferCh)
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	var buffer []Finding
+	flushBuffer := func() {
+		b := buffer
+		buffer = nil
+		select {
+		case <-c.ctx.Done():
+		case bufferCh <- b:
+		}
+	}
+	for {
+		tickerCh := ticker.C
+		if buffer == nil {
+			tickerCh = nil
+		}
+		select {
+		case <-c.ctx.Done():
+			return nil
+		case <-tickerCh:
+			flushBuffer()
+		case <-c.closeCh:
+			if buffer != nil {
+				flushBuffer()
+			}
+			return nil
+		case item := <-c.findingCh:
+			buffer = append(buffer, item)
+		}
+	}
+}
+
+func (c *clientCore) runDirectThread(bufferCh chan<- []Finding) error {
+	defer close(bufferCh)
+	for {
+		var f Finding
+		select {
+		case <-c.ctx.Done():
+			return nil
+		case <-c.closeCh:
+			return nil
+		case f = <-c.findingCh:
+		}
+		select {
+		case <-c.ctx.Done():
+			return nil
+		case bufferCh <- []Finding{f}:
+		}
+	}
+}
+
+// runCallThread writes the buffers back to the host, until
+// the buffer channel is closed after the final flush.
+func (c *clientCore) runCallThread(bufferCh <-chan []Finding) error {
+	for buffer := range bufferCh {
+		if err := c.report(buffer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newClientCore() (*clientCore, error) {
+	if !service.Hosted() {
+		return nil, xerrors.New("client is not hosted")
+	}
+	var manifest struct{}
+	err := service.GetManifest(Namespace, &manifest)
+	if err != nil {
+		return nil, err
+	}
+	var getConfig func() (*reportConfig, error)
+	service.GetService(Namespace, "getConfig", &getConfig)
+	cfg, err := getConfig()
+	if err != nil {
+		return nil, err
+	}
+	var report func([]Finding) error
+	service.GetService(Namespace, "report", &report)
+	group, ctx := errgroup.WithContext(context.Background())
+	result := &clientCore{
+		ctx:       ctx,
+		group:     group,
+		findingCh: make(chan Finding),
+		closeCh:   make(chan struct{}),
+		report:    report,
+	}
+	bufferCh := make(chan []Finding)
+	group.Go(func() error {
+		return result.runCallThread(bufferCh)
+	})
+	if cfg.Delay <= 0 {
+		group.Go(func() error {
+			return result.runDirectThread(bufferCh)
+		})
+	} else {
+		group.Go(func() error {
+			return result.runBufferThread(cfg.Delay, bufferCh)
+		})
+	}
+	return result, nil
+}
+
+type reportService struct {
+	delay  time.Duration
+	fields map[string]interface{}
+	sink   Sink
+}
+
+func (s *reportService) getConfig() reportConfig {
+	return reportConfig{
+		Delay: s.delay,
+	}
+}
+
+func (s *reportService) report(buffer []Finding) error {
+	if s.fields != nil {
+		for i := range buffer {
+			if buffer[i].Fields == nil {
+				buffer[i].Fields = make(map[string]interface{})
+			}
+			for k, v := range s.fields {
+				buffer[i].Fields[k] = v
+			}
+		}
+	}
+	return s.sink.Write(buffer)
+}
+
+func (s *reportService) Add(registry *service.Registry) {
+	registry.Define(Namespace, struct{}{})
+	registry.AddService(Namespace, "getConfig", s.getConfig)
+	registry.AddService(Namespace, "report", s.report)
+}
+
+type serviceOption struct {
+	delay  time.Duration
+	fields map[string]interface{}
+}
+
+// ServiceOption are the options for creating report services
+// and provide it to plugins to execute.
+type ServiceOption func(*serviceOption)
+
+// WithBufferDelay sets timeout of findings buffering before
+// they could be sent back to the host.
+func WithBufferDelay(d time.Duration) ServiceOption {
+	return func(opt *serviceOption) {
+		opt.delay = d
+	}
+}
+
+// WithNoBuffer tells the plugin to send back findings to the
+// host without internal buffering.
+func WithNoBuffer() ServiceOption {
+	return func(opt *serviceOption) {
+		opt.delay = 0
+	}
+}
+
+// WithFields attaches the fields to the findings reported
+// through the service, like the name of plugin.
+func WithFields(fields map[string]interface{}) ServiceOption {
+	return func(opt *serviceOption) {
+		opt.fields = fields
+	}
+}
+
+// NewService creates the service collecting the findings of
+// plugins into the sink, which is not closed by the service.
+func NewService(sink Sink, opts ...ServiceOption) service.Services {
+	opt := &serviceOption{
+		delay: time.Millisecond * 20,
+	}
+	for _, f := range opts {
+		f(opt)
+	}
+	return &reportService{
+		delay:  opt.delay,
+		fields: opt.fields,
+		sink:   sink,
+	}
+}
@@ -0,0 +1,110 @@
+// Package plugin/sandbox provides the plugin executor running
+// plugins inside linux namespaces, so that the plugins found
+// could be executed without trusting them.
+//
+// Each plugin is started in new user, mount, PID, network and
+// IPC namespaces, with an empty root file system into which only
+// the paths specified are bound read-only, and with syscalls
+// restricted to an allowlist by seccomp. The plugin is executed
+// as an unprivileged user on host when the host runs as root,
+// or as the same user as the host otherwise.
+//
+// The sandbox is set up by executing the host program itself
+// again, which is recognized and handled while initializing
+// this package. So the host must import this package, and it
+// is recommended not to do heavy works while initializing.
+package sandbox
+
+import (
+	"github.com/chaitin/libveinmind/go/plugin"
+)
+
+// DefaultReadOnlyPaths are the paths bound into the sandbox by
+// default, which are required for executing most programs.
+// The paths absent on the host are ignored.
+var DefaultReadOnlyPaths = []string{
+	"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32", "/usr",
+	"/etc/ld.so.cache", "/etc/ld.so.conf", "/etc/ld.so.conf.d",
+	"/etc/passwd", "/etc/group", "/etc/nsswitch.conf",
+	"/etc/localtime", "/etc/hosts", "/etc/resolv.conf",
+	"/etc/ssl", "/etc/pki", "/etc/ca-certificates",
+}
+
+// DefaultUser is the user and group on host that the plugins
+// are executed as by default, which is "nobody".
+const DefaultUser = 65534
+
+type option struct {
+	uid, gid     int
+	paths        []string
+	network      bool
+	seccomp      bool
+	syscalls     []string
+	executorOpts []plugin.ExecutorOption
+}
+
+// Option specifies how the plugins are sandboxed.
+type Option func(*option)
+
+// WithReadOnlyPaths binds the paths on host into the sandbox
+// read-only, at the same location as on the host.
+//
+// Plugins accessing container runtimes should be provided with
+// their data directories, like "/var/lib/docker".
+func WithReadOnlyPaths(paths ...string) Option {
+	return func(o *option) {
+		o.paths = append(o.paths, paths...)
+	}
+}
+
+// WithUser specifies the user and group on host that the
+// plugins are executed as while the host runs as root, which
+// must not be root. The plugins and the paths bound must be
+// accessible to the user.
+func WithUser(uid, gid int) Option {
+	return func(o *option) {
+		o.uid, o.gid = uid, gid
+	}
+}
+
+// WithNetwork shares the network namespace of host with the
+// plugins, which have no network access by default.
+func WithNetwork() Option {
+	return func(o *option) {
+		o.network = true
+	}
+}
+
+// WithAllowedSyscalls appends the syscalls allowed by seccomp,
+// which are specified by names like "ptrace". The syscalls
+// absent on the architecture are ignored.
+func WithAllowedSyscalls(names ...string) Option {
+	return func(o *option) {
+		o.syscalls = append(o.syscalls, names...)
+	}
+}
+
+// WithoutSeccomp disables the syscall filtering by seccomp.
+func WithoutSeccomp() Option {
+	return func(o *option) {
+		o.seccomp = false
+	}
+}
+
+// WithExecutorOptions specifies the options for managing the
+// sandboxed process, like plugin.WithGracePeriod.
+func WithExecutorOptions(opts ...plugin.ExecutorOption) Option {
+	return func(o *option) {
+		o.executorOpts = append(o.executorOpts, opts...)
+	}
+}
+
+func newOption(opts ...Option) *option {
+	result := &option{
+		uid: DefaultUser, gid: DefaultUser, seccomp: true,
+	}
+	for _, opt := range opts {
+		opt(result)
+	}
+	return result
+}
@@ -0,0 +1,727 @@
+package sandbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/xerrors"
+
+	"github.com/chaitin/libveinmind/go/plugin"
+)
+
+// initEnv is the environment variable carrying the config of
+// sandbox to the host program executed again.
+const initEnv = "_VEINMIND_SANDBOX_INIT"
+
+// initExitCode is the exit code of the sandbox on failure of
+// setting up, whose cause is written to the error pipe.
+const initExitCode = 125
+
+type bindMount struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+type initConfig struct {
+	Root     string      `json:"root"`
+	Binds    []bindMount `json:"binds"`
+	Path     string      `json:"path"`
+	Argv     []string    `json:"argv"`
+	ErrorFD  int         `json:"errorFD"`
+	Seccomp  bool        `json:"seccomp"`
+	Syscalls []string    `json:"syscalls,omitempty"`
+
+	// UID and GID are the user switched to before executing
+	// the plugin, to which the inherited files are handed over.
+	UID       int   `json:"uid,omitempty"`
+	GID       int   `json:"gid,omitempty"`
+	Inherited []int `json:"inherited,omitempty"`
+}
+
+// NewExecutor creates the executor running plugins inside the
+// sandbox, which could be specified with plugin.WithExecutor
+// while discovering plugins.
+//
+// The process of sandbox is managed in the same way as the
+// plugin.NewExecutor does, including the resource limits.
+func NewExecutor(opts ...Option) plugin.Executor {
+	option := newOption(opts...)
+	base := plugin.NewExecutor(option.executorOpts...)
+	return func(
+		ctx context.Context, plug *plugin.Plugin,
+		path string, argv []string, attr *os.ProcAttr,
+	) error {
+		return option.execute(ctx, base, plug, path, argv, attr)
+	}
+}
+
+// hostFileFD returns the file descriptor of the file in path
+// like "/proc/<pid>/fd/<fd>", if it is opened by the host.
+func hostFileFD(p string) (int, bool) {
+	dir, name := path.Split(p)
+	fd, err := strconv.Atoi(name)
+	if err != nil {
+		return 0, false
+	}
+	dir = path.Clean(dir)
+	if path.Base(dir) != "fd" {
+		return 0, false
+	}
+	if path.Base(path.Dir(dir)) != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	return fd, true
+}
+
+// inheritHostFiles replaces the files of host in the "--host"
+// arguments, like the anonymous pipes of plugin/service, with
+// the files inherited by the plugin starting from next.
+//
+// The plugin in another user namespace is not allowed to open
+// the files of host through procfs, but it could still open
+// the files inherited through "/proc/self/fd".
+func inheritHostFiles(
+	argv []string, next int,
+) (result []string, files []*os.File, rerr error) {
+	defer func() {
+		if rerr != nil {
+			for _, f := range files {
+				_ = f.Close()
+			}
+		}
+	}()
+	result = append(result, argv...)
+	for i := 1; i+1 < len(result); i++ {
+		if result[i] != "--host" {
+			continue
+		}
+		u, err := url.Parse(result[i+1])
+		if err != nil || u.Scheme != "file" {
+			continue
+		}
+		fd, ok := hostFileFD(u.Path)
+		if !ok {
+			continue
+		}
+		dup, err := syscall.Dup(fd)
+		if err != nil {
+			return nil, nil, err
+		}
+		syscall.CloseOnExec(dup)
+		files = append(files, os.NewFile(uintptr(dup), u.Path))
+		u.Path = "/proc/self/fd/" + strconv.Itoa(next+len(files)-1)
+		result[i+1] = u.String()
+		i++
+	}
+	return result, files, nil
+}
+
+// binds returns the bind mounts in the order of mounting, so
+// that the paths under symlinks like "/bin" on merged /usr are
+// mounted after "/usr", and parents are mounted before children.
+func (o *option) binds(source, target string) []bindMount {
+	defaults := append([]string(nil), DefaultReadOnlyPaths...)
+	sort.Strings(defaults)
+	var paths []string
+	for _, p := range o.paths {
+		paths = append(paths, filepath.Clean(p))
+	}
+	sort.Strings(paths)
+	var result []bindMount
+	for _, p := range defaults {
+		result = append(result, bindMount{
+			Source: p, Target: p, Optional: true,
+		})
+	}
+	for _, p := range paths {
+		result = append(result, bindMount{Source: p, Target: p})
+	}
+	return append(result, bindMount{Source: source, Target: target})
+}
+
+func (o *option) execute(
+	ctx context.Context, base plugin.Executor, plug *plugin.Plugin,
+	path string, argv []string, attr *os.ProcAttr,
+) error {
+	privileged := os.Geteuid() == 0
+	if privileged && (o.uid == 0 || o.gid == 0) {
+		return xerrors.New("sandbox: plugins must not run as root")
+	}
+	target, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	source, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return err
+	}
+	root, err := ioutil.TempDir("", "veinmind-sandbox-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(root) }()
+	errReader, errWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = errReader.Close()
+		_ = errWriter.Close()
+	}()
+
+	files := append([]*os.File(nil), attr.Files...)
+	for len(files) < 3 {
+		files = append(files, nil)
+	}
+	argv, inherited, err := inheritHostFiles(argv, len(files))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, f := range inherited {
+			_ = f.Close()
+		}
+	}()
+	config := initConfig{
+		Root:    root,
+		Binds:   o.binds(source, target),
+		Path:    target,
+		Argv:    argv,
+		Seccomp: o.seccomp,
+	}
+	for _, f := range inherited {
+		config.Inherited = append(config.Inherited, len(files))
+		files = append(files, f)
+	}
+	config.ErrorFD = len(files)
+	if o.seccomp {
+		config.Syscalls = append(config.Syscalls, allowedSyscalls...)
+		config.Syscalls = append(config.Syscalls, o.syscalls...)
+	}
+	sys := &syscall.SysProcAttr{}
+	if attr.Sys != nil {
+		*sys = *attr.Sys
+	}
+	sys.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS |
+		syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC
+	if !o.network {
+		sys.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	if privileged {
+		// The root of namespace is mapped to root on host for
+		// setting up, and the plugin is executed as the user
+		// then, so that it has no privilege on host.
+		sys.UidMappings = []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: 0, Size: 1},
+			{ContainerID: o.uid, HostID: o.uid, Size: 1},
+		}
+		sys.GidMappings = []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: 0, Size: 1},
+			{ContainerID: o.gid, HostID: o.gid, Size: 1},
+		}
+		sys.GidMappingsEnableSetgroups = true
+		config.UID, config.GID = o.uid, o.gid
+	} else {
+		sys.UidMappings = []syscall.SysProcIDMap{{
+			ContainerID: 0, HostID: os.Getuid(), Size: 1,
+		}}
+		sys.GidMappings = []syscall.SysProcIDMap{{
+			ContainerID: 0, HostID: os.Getgid(), Size: 1,
+		}}
+		sys.GidMappingsEnableSetgroups = false
+	}
+	files = append(files, errWriter)
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	env := attr.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	env = append(env[:len(env):len(env)], initEnv+"="+string(data))
+
+	err = base(ctx, plug, "/proc/self/exe", []string{"veinmind-sandbox"},
+		&os.ProcAttr{
+			Dir:   attr.Dir,
+			Env:   env,
+			Files: files,
+			Sys:   sys,
+		})
+	_ = errWriter.Close()
+	if message, _ := ioutil.ReadAll(errReader); len(message) > 0 {
+		return xerrors.Errorf("sandbox: %s", message)
+	}
+	return err
+}
+
+// statfsMountFlags are the flags of statfs that must be kept
+// while remounting in a user namespace.
+var statfsMountFlags = map[int64]uintptr{
+	0x2:    syscall.MS_NOSUID,
+	0x4:    syscall.MS_NODEV,
+	0x8:    syscall.MS_NOEXEC,
+	0x400:  syscall.MS_NOATIME,
+	0x800:  syscall.MS_NODIRATIME,
+	0x1000: syscall.MS_RELATIME,
+}
+
+// unescapeMountInfo decodes the octal escapes like "\040".
+func unescapeMountInfo(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// remountReadOnly remounts the target and the mounts under it
+// read-only and nosuid, keeping the flags locked by the user
+// namespace.
+func remountReadOnly(target string) error {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	var mounts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mount := unescapeMountInfo(fields[4])
+		if mount == target || strings.HasPrefix(mount, target+"/") {
+			mounts = append(mounts, mount)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for _, mount := range mounts {
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(mount, &stat); err != nil {
+			return err
+		}
+		flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT |
+			syscall.MS_RDONLY | syscall.MS_NOSUID)
+		for k, v := range statfsMountFlags {
+			// The type of flags varies among architectures.
+			if int64(stat.Flags)&k != 0 {
+				flags |= v
+			}
+		}
+		if err := syscall.Mount("", mount, "", flags, ""); err != nil {
+			return xerrors.Errorf("remount %q: %w", mount, err)
+		}
+	}
+	return nil
+}
+
+// maxSymlinks is the maximum number of symlinks followed while
+// resolving a path, just like the ELOOP limit of linux.
+const maxSymlinks = 40
+
+// mkdirInRoot creates the directory under the root, and returns
+// its path. The symlinks are resolved as if the root were "/",
+// so that the path will never escape from the root.
+func mkdirInRoot(root, dir string) (string, error) {
+	components := strings.Split(dir, "/")
+	current, links := "/", 0
+	for i := 0; i < len(components); i++ {
+		if components[i] == "" {
+			continue
+		}
+		next := path.Join(current, components[i])
+		full := filepath.Join(root, next)
+		info, err := os.Lstat(full)
+		if os.IsNotExist(err) {
+			if err := os.Mkdir(full, 0755); err != nil {
+				return "", err
+			}
+			current = next
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if links++; links > maxSymlinks {
+				return "", xerrors.Errorf("%q: %w", dir, syscall.ELOOP)
+			}
+			link, err := os.Readlink(full)
+			if err != nil {
+				return "", err
+			}
+			if !path.IsAbs(link) {
+				link = path.Join(current, link)
+			}
+			components = append(strings.Split(link, "/"),
+				components[i+1:]...)
+			current, i = "/", -1
+			continue
+		}
+		if !info.IsDir() {
+			return "", xerrors.Errorf("%q is not a directory", full)
+		}
+		current = next
+	}
+	return filepath.Join(root, current), nil
+}
+
+// fileInRoot creates the file under the root if absent, and
+// returns its path, resolving symlinks like mkdirInRoot.
+func fileInRoot(root, file string) (string, error) {
+	for links := 0; links <= maxSymlinks; links++ {
+		dir, err := mkdirInRoot(root, path.Dir(file))
+		if err != nil {
+			return "", err
+		}
+		target := filepath.Join(dir, path.Base(file))
+		info, err := os.Lstat(target)
+		if os.IsNotExist(err) {
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL, 0644)
+			if err != nil {
+				return "", err
+			}
+			return target, f.Close()
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return target, nil
+		}
+		link, err := os.Readlink(target)
+		if err != nil {
+			return "", err
+		}
+		if !path.IsAbs(link) {
+			rel, err := filepath.Rel(root, dir)
+			if err != nil {
+				return "", err
+			}
+			link = path.Join("/", rel, link)
+		}
+		file = link
+	}
+	return "", xerrors.Errorf("%q: %w", file, syscall.ELOOP)
+}
+
+func (c *initConfig) bind(b bindMount) error {
+	info, err := os.Lstat(b.Source)
+	if err != nil {
+		if os.IsNotExist(err) && b.Optional {
+			return nil
+		}
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 && b.Optional {
+		// Keep the symlinks like "/lib" on merged /usr.
+		link, err := os.Readlink(b.Source)
+		if err != nil {
+			return err
+		}
+		dir, err := mkdirInRoot(c.Root, filepath.Dir(b.Target))
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, filepath.Join(dir, filepath.Base(b.Target)))
+	}
+	if info, err = os.Stat(b.Source); err != nil {
+		return err
+	}
+	var target string
+	if info.IsDir() {
+		if target, err = mkdirInRoot(c.Root, b.Target); err != nil {
+			return err
+		}
+	} else {
+		if target, err = fileInRoot(c.Root, b.Target); err != nil {
+			return err
+		}
+	}
+	if err := syscall.Mount(b.Source, target, "",
+		syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return xerrors.Errorf("bind %q: %w", b.Source, err)
+	}
+	return remountReadOnly(target)
+}
+
+// devices are the device files bound from the host.
+var devices = []string{"null", "zero", "full", "random", "urandom"}
+
+func (c *initConfig) mountDev() error {
+	dev := filepath.Join(c.Root, "dev")
+	if err := os.Mkdir(dev, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", dev, "tmpfs",
+		syscall.MS_NOSUID|syscall.MS_NOEXEC, "mode=0755"); err != nil {
+		return xerrors.Errorf("mount /dev: %w", err)
+	}
+	for _, name := range devices {
+		target := filepath.Join(dev, name)
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return err
+		}
+		_ = f.Close()
+		if err := syscall.Mount(filepath.Join("/dev", name), target, "",
+			syscall.MS_BIND, ""); err != nil {
+			return xerrors.Errorf("bind /dev/%s: %w", name, err)
+		}
+	}
+	links := map[string]string{
+		"fd":     "/proc/self/fd",
+		"stdin":  "/proc/self/fd/0",
+		"stdout": "/proc/self/fd/1",
+		"stderr": "/proc/self/fd/2",
+	}
+	for name, link := range links {
+		if err := os.Symlink(link, filepath.Join(dev, name)); err != nil {
+			return err
+		}
+	}
+	shm := filepath.Join(dev, "shm")
+	if err := os.Mkdir(shm, 01777); err != nil {
+		return err
+	}
+	return syscall.Mount("tmpfs", shm, "tmpfs",
+		syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777")
+}
+
+// procReadOnlyPaths are the paths under procfs remounted
+// read-only, and procMaskedPaths are the paths hidden, which
+// expose or configure the host despite the PID namespace.
+var (
+	procReadOnlyPaths = []string{
+		"bus", "fs", "irq", "sys", "sysrq-trigger",
+	}
+	procMaskedPaths = []string{
+		"acpi", "asound", "kcore", "keys", "latency_stats",
+		"sched_debug", "scsi", "timer_list", "timer_stats",
+	}
+)
+
+// mountProc mounts the procfs of the PID namespace, in which
+// the processes of host are invisible.
+func (c *initConfig) mountProc() error {
+	proc := filepath.Join(c.Root, "proc")
+	if err := os.Mkdir(proc, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("proc", proc, "proc", syscall.MS_NOSUID|
+		syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return xerrors.Errorf("mount /proc: %w", err)
+	}
+	for _, name := range procReadOnlyPaths {
+		target := filepath.Join(proc, name)
+		if _, err := os.Lstat(target); os.IsNotExist(err) {
+			continue
+		}
+		if err := syscall.Mount(target, target, "",
+			syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return xerrors.Errorf("bind /proc/%s: %w", name, err)
+		}
+		if err := remountReadOnly(target); err != nil {
+			return err
+		}
+	}
+	for _, name := range procMaskedPaths {
+		target := filepath.Join(proc, name)
+		info, err := os.Lstat(target)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			err = syscall.Mount("tmpfs", target, "tmpfs",
+				syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV|
+					syscall.MS_NOEXEC, "size=0")
+		} else {
+			err = syscall.Mount("/dev/null", target, "",
+				syscall.MS_BIND, "")
+		}
+		if err != nil {
+			return xerrors.Errorf("mask /proc/%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// mountRoot creates the root file system of the sandbox, and
+// switches into it.
+func (c *initConfig) mountRoot() error {
+	if err := syscall.Mount("", "/", "",
+		syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return xerrors.Errorf("make mounts private: %w", err)
+	}
+	if err := syscall.Mount("tmpfs", c.Root, "tmpfs",
+		syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755"); err != nil {
+		return xerrors.Errorf("mount root: %w", err)
+	}
+	if err := c.mountProc(); err != nil {
+		return err
+	}
+	if err := c.mountDev(); err != nil {
+		return err
+	}
+	tmp := filepath.Join(c.Root, "tmp")
+	if err := os.Mkdir(tmp, 01777); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", tmp, "tmpfs",
+		syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+		return xerrors.Errorf("mount /tmp: %w", err)
+	}
+	for _, b := range c.Binds {
+		if err := c.bind(b); err != nil {
+			return err
+		}
+	}
+	if err := os.Chdir(c.Root); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(".", "."); err != nil {
+		return xerrors.Errorf("pivot root: %w", err)
+	}
+	if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return xerrors.Errorf("detach old root: %w", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+	return syscall.Mount("", "/", "", syscall.MS_REMOUNT|
+		syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, "")
+}
+
+// dropCapabilities empties the bounding set, so that the plugin
+// executed has no capability even in the user namespace.
+func dropCapabilities() error {
+	for capability := uintptr(0); ; capability++ {
+		_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL,
+			syscall.PR_CAPBSET_DROP, capability, 0)
+		if errno == syscall.EINVAL {
+			return nil
+		}
+		if errno != 0 {
+			return xerrors.Errorf("drop capability: %w", errno)
+		}
+	}
+}
+
+// switchUser hands the inherited files over to the user, so
+// that they could still be opened through "/proc/self/fd", and
+// switches to the user, which also clears the capabilities.
+// The parent death signal cleared by switching is restored.
+func (c *initConfig) switchUser() error {
+	if c.UID == 0 {
+		return nil
+	}
+	var deathSignal int32
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL,
+		syscall.PR_GET_PDEATHSIG,
+		uintptr(unsafe.Pointer(&deathSignal)), 0); errno != 0 {
+		return xerrors.Errorf("get parent death signal: %w", errno)
+	}
+	for _, fd := range c.Inherited {
+		if err := syscall.Fchown(fd, c.UID, c.GID); err != nil {
+			return xerrors.Errorf("chown inherited file: %w", err)
+		}
+	}
+	if err := syscall.Setgroups(nil); err != nil {
+		return xerrors.Errorf("set groups: %w", err)
+	}
+	if err := syscall.Setresgid(c.GID, c.GID, c.GID); err != nil {
+		return xerrors.Errorf("set gid: %w", err)
+	}
+	if err := syscall.Setresuid(c.UID, c.UID, c.UID); err != nil {
+		return xerrors.Errorf("set uid: %w", err)
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL,
+		syscall.PR_SET_PDEATHSIG, uintptr(deathSignal), 0); errno != 0 {
+		return xerrors.Errorf("set parent death signal: %w", errno)
+	}
+	return nil
+}
+
+// run sets up the sandbox and executes the plugin, which only
+// returns on failure.
+func (c *initConfig) run() error {
+	syscall.CloseOnExec(c.ErrorFD)
+	if err := c.mountRoot(); err != nil {
+		return err
+	}
+	if err := dropCapabilities(); err != nil {
+		return err
+	}
+	if err := c.switchUser(); err != nil {
+		return err
+	}
+	if err := setNoNewPrivs(); err != nil {
+		return err
+	}
+	var env []string
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, initEnv+"=") {
+			env = append(env, e)
+		}
+	}
+	if c.Seccomp {
+		filter, err := seccompFilter(c.Syscalls)
+		if err != nil {
+			return err
+		}
+		if err := loadSeccomp(filter); err != nil {
+			return err
+		}
+	}
+	if err := syscall.Exec(c.Path, c.Argv, env); err != nil {
+		return xerrors.Errorf("exec %q: %w", c.Path, err)
+	}
+	return nil
+}
+
+func init() {
+	data, ok := os.LookupEnv(initEnv)
+	if !ok {
+		return
+	}
+	// The seccomp filter is loaded into the current thread,
+	// which must also be the one executing the plugin.
+	runtime.LockOSThread()
+	var config initConfig
+	err := json.Unmarshal([]byte(data), &config)
+	if err == nil {
+		err = config.run()
+	}
+	if config.ErrorFD > 2 {
+		f := os.NewFile(uintptr(config.ErrorFD), "error")
+		_, _ = f.WriteString(err.Error())
+	}
+	os.Exit(initExitCode)
+}
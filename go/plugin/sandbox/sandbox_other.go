@@ -0,0 +1,24 @@
+//go:build !linux
+// +build !linux
+
+package sandbox
+
+import (
+	"context"
+	"os"
+
+	"golang.org/x/xerrors"
+
+	"github.com/chaitin/libveinmind/go/plugin"
+)
+
+// NewExecutor creates the executor running plugins inside the
+// sandbox, which is unsupported on this platform.
+func NewExecutor(opts ...Option) plugin.Executor {
+	return func(
+		_ context.Context, _ *plugin.Plugin,
+		_ string, _ []string, _ *os.ProcAttr,
+	) error {
+		return xerrors.New("sandbox is unsupported on this platform")
+	}
+}
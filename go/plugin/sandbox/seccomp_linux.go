@@ -0,0 +1,165 @@
+package sandbox
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/xerrors"
+)
+
+// allowedSyscalls are the syscalls allowed by default, which
+// are sufficient for ordinary programs. The syscalls absent
+// on the architecture are ignored.
+//
+// Syscalls manipulating namespaces, mounts, kernel modules or
+// other processes are excluded, and "clone" is only allowed
+// without creating namespaces.
+var allowedSyscalls = []string{
+	"accept", "accept4", "access", "alarm", "arch_prctl", "bind",
+	"brk", "capget", "chdir", "chmod", "chown", "clock_getres",
+	"clock_gettime", "clock_nanosleep", "close", "close_range",
+	"connect", "copy_file_range", "creat", "dup", "dup2", "dup3",
+	"epoll_create", "epoll_create1", "epoll_ctl", "epoll_pwait",
+	"epoll_pwait2", "epoll_wait", "eventfd", "eventfd2", "execve",
+	"execveat", "exit", "exit_group", "faccessat", "faccessat2",
+	"fadvise64", "fallocate", "fchdir", "fchmod", "fchmodat",
+	"fchown", "fchownat", "fcntl", "fdatasync", "fgetxattr",
+	"flistxattr", "flock", "fork", "fstat", "fstatat", "fstatfs",
+	"fsync", "ftruncate", "futex", "futimesat", "get_robust_list",
+	"getcpu", "getcwd", "getdents", "getdents64", "getegid",
+	"geteuid", "getgid", "getgroups", "getitimer", "getpeername",
+	"getpgid", "getpgrp", "getpid", "getppid", "getpriority",
+	"getrandom", "getresgid", "getresuid", "getrlimit", "getrusage",
+	"getsid", "getsockname", "getsockopt", "gettid", "gettimeofday",
+	"getuid", "getxattr", "inotify_add_watch", "inotify_init",
+	"inotify_init1", "inotify_rm_watch", "ioctl", "kill",
+	"lgetxattr", "link", "linkat", "listen", "listxattr",
+	"llistxattr", "lseek", "lstat", "madvise", "membarrier",
+	"memfd_create", "mincore", "mkdir", "mkdirat", "mlock",
+	"mmap", "mprotect", "mremap", "msync", "munlock", "munmap",
+	"nanosleep", "newfstatat", "open", "openat", "openat2",
+	"pause", "pipe", "pipe2", "poll", "ppoll", "prctl", "pread64",
+	"preadv", "preadv2", "prlimit64", "pselect6", "pwrite64",
+	"pwritev", "pwritev2", "read", "readahead", "readlink",
+	"readlinkat", "readv", "recvfrom", "recvmmsg", "recvmsg",
+	"rename", "renameat", "renameat2", "restart_syscall", "rmdir",
+	"rseq", "rt_sigaction", "rt_sigpending", "rt_sigprocmask",
+	"rt_sigqueueinfo", "rt_sigreturn", "rt_sigsuspend",
+	"rt_sigtimedwait", "rt_tgsigqueueinfo", "sched_getaffinity",
+	"sched_getparam", "sched_get_priority_max",
+	"sched_get_priority_min", "sched_getscheduler",
+	"sched_setaffinity", "sched_yield", "select", "sendfile",
+	"sendmmsg", "sendmsg", "sendto", "set_robust_list",
+	"set_tid_address", "setitimer", "setpgid", "setsid",
+	"setsockopt", "shutdown", "sigaltstack", "socket", "socketpair",
+	"splice", "stat", "statfs", "statx", "symlink", "symlinkat",
+	"sync", "sync_file_range", "syncfs", "sysinfo", "tee", "tgkill",
+	"time", "timer_create", "timer_delete", "timer_getoverrun",
+	"timer_gettime", "timer_settime", "timerfd_create",
+	"timerfd_gettime", "timerfd_settime", "tkill", "truncate",
+	"umask", "uname", "unlink", "unlinkat", "utime", "utimensat",
+	"utimes", "vfork", "wait4", "waitid", "write", "writev",
+}
+
+// Constants of seccomp missing in package syscall.
+const (
+	prSetNoNewPrivs     = 38
+	seccompModeFilter   = 2
+	seccompRetAllow     = 0x7fff0000
+	seccompRetErrno     = 0x00050000
+	seccompDataNr       = 0
+	seccompDataArch     = 4
+	seccompDataArgs     = 16
+	cloneNamespaceFlags = syscall.CLONE_NEWNS | syscall.CLONE_NEWUTS |
+		syscall.CLONE_NEWIPC | syscall.CLONE_NEWUSER |
+		syscall.CLONE_NEWPID | syscall.CLONE_NEWNET | 0x02000000
+)
+
+func bpfStmt(code uint16, k uint32) syscall.SockFilter {
+	return syscall.SockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jt, jf uint8) syscall.SockFilter {
+	return syscall.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
+
+// seccompFilter creates the BPF program allowing the syscalls
+// specified, and failing the others with EPERM.
+func seccompFilter(names []string) ([]syscall.SockFilter, error) {
+	if auditArch == 0 {
+		return nil, xerrors.New("seccomp is unsupported on this architecture")
+	}
+	const (
+		load     = syscall.BPF_LD | syscall.BPF_W | syscall.BPF_ABS
+		jumpEq   = syscall.BPF_JMP | syscall.BPF_JEQ | syscall.BPF_K
+		and      = syscall.BPF_ALU | syscall.BPF_AND | syscall.BPF_K
+		ret      = syscall.BPF_RET | syscall.BPF_K
+		retAllow = seccompRetAllow
+		retEPERM = seccompRetErrno | uint32(syscall.EPERM)
+	)
+	result := []syscall.SockFilter{
+		bpfStmt(load, seccompDataArch),
+		bpfJump(jumpEq, auditArch, 1, 0),
+		bpfStmt(ret, retEPERM),
+		bpfStmt(load, seccompDataNr),
+	}
+	if nr, ok := syscallNumbers["clone"]; ok {
+		// Only the lower 32 bits of flags are inspected, which
+		// is where the flags of namespaces are.
+		result = append(result,
+			bpfJump(jumpEq, nr, 0, 5),
+			bpfStmt(load, seccompDataArgs),
+			bpfStmt(and, cloneNamespaceFlags),
+			bpfJump(jumpEq, 0, 0, 1),
+			bpfStmt(ret, retAllow),
+			bpfStmt(ret, retEPERM))
+	}
+	if nr, ok := syscallNumbers["clone3"]; ok {
+		// The flags of clone3 are not inspectable, so it fails
+		// with ENOSYS to make the libc fall back to clone.
+		result = append(result,
+			bpfJump(jumpEq, nr, 0, 1),
+			bpfStmt(ret, seccompRetErrno|uint32(syscall.ENOSYS)))
+	}
+	allowed := make(map[uint32]struct{})
+	for _, name := range names {
+		nr, ok := syscallNumbers[name]
+		if !ok {
+			continue
+		}
+		if _, ok := allowed[nr]; ok {
+			continue
+		}
+		allowed[nr] = struct{}{}
+		result = append(result,
+			bpfJump(jumpEq, nr, 0, 1),
+			bpfStmt(ret, retAllow))
+	}
+	return append(result, bpfStmt(ret, retEPERM)), nil
+}
+
+// setNoNewPrivs forbids the current thread and the programs it
+// executes from gaining privileges through setuid binaries.
+func setNoNewPrivs() error {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL,
+		prSetNoNewPrivs, 1, 0); errno != 0 {
+		return xerrors.Errorf("set no new privileges: %w", errno)
+	}
+	return nil
+}
+
+// loadSeccomp loads the program into the current thread, which
+// is inherited by the program executed then. No new privileges
+// must have been set by setNoNewPrivs.
+func loadSeccomp(filter []syscall.SockFilter) error {
+	prog := syscall.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL,
+		syscall.PR_SET_SECCOMP, seccompModeFilter,
+		uintptr(unsafe.Pointer(&prog))); errno != 0 {
+		return xerrors.Errorf("load seccomp: %w", errno)
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build linux && !amd64 && !arm64
+// +build linux,!amd64,!arm64
+
+package sandbox
+
+// auditArch is unknown for the architecture, on which seccomp
+// filtering is unsupported.
+const auditArch = 0
+
+var syscallNumbers = map[string]uint32{}
@@ -0,0 +1,329 @@
+package remote
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/xerrors"
+
+	"github.com/chaitin/libveinmind/go/pkg/platform"
+	"github.com/chaitin/libveinmind/go/pkg/reference"
+)
+
+const (
+	// catalogPageSize is the number of entries requested per
+	// page when enumerating the registry.
+	catalogPageSize = 100
+
+	// defaultParallelism is the number of tags inspected in
+	// parallel by default, which bounds the requests to the
+	// registry for each repository.
+	defaultParallelism = 4
+)
+
+// nextPage returns the path of the next page specified in the
+// Link header, or empty when it is the last page.
+func nextPage(link string) string {
+	for _, item := range strings.Split(link, ",") {
+		item = strings.TrimSpace(item)
+		if !strings.Contains(item, `rel="next"`) {
+			continue
+		}
+		start, end := strings.IndexByte(item, '<'), strings.IndexByte(item, '>')
+		if start < 0 || end < start {
+			return ""
+		}
+		u, err := url.Parse(item[start+1 : end])
+		if err != nil {
+			return ""
+		}
+		return u.RequestURI()
+	}
+	return ""
+}
+
+// paginate collects the entries of all pages starting from
+// the path, where the entries are decoded by the page function.
+func paginate(
+	path string, page func(string) ([]string, string, error),
+) ([]string, error) {
+	var result []string
+	for path != "" {
+		entries, next, err := page(path)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entries...)
+		path = next
+	}
+	return result, nil
+}
+
+// ListRepositories enumerates the repositories in the registry
+// through the catalog API, which are the names of repositories
+// without the registry domain.
+//
+// The credential and CA bundle are specified in the same way
+// as loading an image.
+func (t *Runtime) ListRepositories(
+	registry string, opts ...LoadOption,
+) ([]string, error) {
+	options := &loadOptions{}
+	for _, o := range opts {
+		o(options)
+	}
+	registry = registryHost(registry)
+	client, err := options.client(registry)
+	if err != nil {
+		return nil, err
+	}
+	const scope = "registry:catalog:*"
+	return paginate(
+		"/v2/_catalog?n="+strconv.Itoa(catalogPageSize),
+		func(path string) ([]string, string, error) {
+			var catalog struct {
+				Repositories []string `json:"repositories"`
+			}
+			header, err := client.getJSON(path, scope, &catalog)
+			if err != nil {
+				return nil, "", err
+			}
+			return catalog.Repositories, nextPage(header.Get("Link")), nil
+		})
+}
+
+// tags enumerates the tags of the repository in the registry.
+func (c *registryClient) tags(repo string) ([]string, error) {
+	scope := "repository:" + repo + ":pull"
+	return paginate(
+		"/v2/"+repo+"/tags/list?n="+strconv.Itoa(catalogPageSize),
+		func(path string) ([]string, string, error) {
+			var tags struct {
+				Tags []string `json:"tags"`
+			}
+			header, err := c.getJSON(path, scope, &tags)
+			if err != nil {
+				return nil, "", err
+			}
+			return tags.Tags, nextPage(header.Get("Link")), nil
+		})
+}
+
+// ListTags enumerates the tags of the repository, which is in
+// the form of the name in references like "docker.io/library/
+// alpine".
+func (t *Runtime) ListTags(repo string, opts ...LoadOption) ([]string, error) {
+	options := &loadOptions{}
+	for _, o := range opts {
+		o(options)
+	}
+	ref, err := reference.Parse(repo)
+	if err != nil {
+		return nil, err
+	}
+	client, err := options.client(ref.Domain)
+	if err != nil {
+		return nil, err
+	}
+	return client.tags(ref.Path)
+}
+
+// created retrieves the creation time of the image tagged in
+// the repository, from the config of the image. The manifest of
+// the specified platform, or the first one is inspected when
+// the tag refers to a multi-platform index.
+func (c *registryClient) created(
+	repo, tag string, selected *imageV1.Platform,
+) (time.Time, error) {
+	manifest, err := c.manifest(repo, tag)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(manifest.Manifests) > 0 {
+		chosen := manifest.Manifests[0]
+		if selected != nil {
+			var ok bool
+			if chosen, ok = selectPlatform(
+				manifest.Manifests, *selected); !ok {
+				return time.Time{}, xerrors.Errorf(
+					"remote: no %s image in %s:%s",
+					platform.Format(*selected), repo, tag)
+			}
+		}
+		return c.created(repo, string(chosen.Digest), nil)
+	}
+	config, err := c.config(repo, manifest)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if config.Created == nil {
+		return time.Time{}, nil
+	}
+	return *config.Created, nil
+}
+
+// TagErrors collects the failures of tags skipped while
+// ordering the tags of a repository.
+type TagErrors []error
+
+func (e TagErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// createdTimes inspects the creation time of the images
+// tagged, where the tags are inspected in parallel by the
+// workers. The errors are indexed in the same way as tags.
+func (c *registryClient) createdTimes(
+	repo string, tags []string, selected *imageV1.Platform, n int,
+) ([]time.Time, []error) {
+	if n <= 0 {
+		n = defaultParallelism
+	}
+	if n > len(tags) {
+		n = len(tags)
+	}
+	times := make([]time.Time, len(tags))
+	errs := make([]error, len(tags))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indices {
+				times[index], errs[index] = c.created(
+					repo, tags[index], selected)
+			}
+		}()
+	}
+	for index := range tags {
+		indices <- index
+	}
+	close(indices)
+	wg.Wait()
+	return times, errs
+}
+
+// RecentTags returns at most n tags of the repository, ordered
+// from the most recently created image. The images without
+// creation time are ordered last.
+//
+// The tags failed to inspect are skipped, with their failures
+// returned as TagErrors along with the other tags.
+func (t *Runtime) RecentTags(
+	repo string, n int, opts ...LoadOption,
+) ([]string, error) {
+	options := &loadOptions{}
+	for _, o := range opts {
+		o(options)
+	}
+	var selected *imageV1.Platform
+	if options.platform != "" {
+		p, err := platform.Parse(options.platform)
+		if err != nil {
+			return nil, err
+		}
+		selected = &p
+	}
+	ref, err := reference.Parse(repo)
+	if err != nil {
+		return nil, err
+	}
+	client, err := options.client(ref.Domain)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := client.tags(ref.Path)
+	if err != nil {
+		return nil, err
+	}
+	created, failures := client.createdTimes(
+		ref.Path, tags, selected, options.parallelism)
+	var result []string
+	var errs TagErrors
+	times := make(map[string]time.Time)
+	for i, tag := range tags {
+		if failures[i] != nil {
+			errs = append(errs, xerrors.Errorf("%s: %w", tag, failures[i]))
+			continue
+		}
+		times[tag] = created[i]
+		result = append(result, tag)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return times[result[i]].After(times[result[j]])
+	})
+	if n >= 0 && len(result) > n {
+		result = result[:n]
+	}
+	if len(errs) > 0 {
+		return result, errs
+	}
+	return result, nil
+}
+
+// RepositoryErrors collects the failures of repositories
+// skipped while loading the registry.
+type RepositoryErrors []error
+
+func (e RepositoryErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// LoadRecentTags loads the n most recent tags of each
+// repository in the registry, and returns the IDs of images
+// loaded. The options are used for both enumerating and
+// loading.
+//
+// The repositories and tags failed to enumerate, inspect or
+// load are skipped, with their failures returned as
+// RepositoryErrors along with the IDs of images loaded.
+func (t *Runtime) LoadRecentTags(
+	registry string, n int, opts ...LoadOption,
+) ([]string, error) {
+	registry = registryHost(registry)
+	repos, err := t.ListRepositories(registry, opts...)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	var errs RepositoryErrors
+	for _, repo := range repos {
+		name := registry + "/" + repo
+		tags, err := t.RecentTags(name, n, opts...)
+		var tagErrs TagErrors
+		if xerrors.As(err, &tagErrs) {
+			for _, err := range tagErrs {
+				errs = append(errs, xerrors.Errorf("%s:%w", name, err))
+			}
+		} else if err != nil {
+			errs = append(errs, xerrors.Errorf("%s: %w", name, err))
+			continue
+		}
+		for _, tag := range tags {
+			ids, err := t.Load(name+":"+tag, opts...)
+			if err != nil {
+				errs = append(errs, xerrors.Errorf("%s:%s: %w", name, tag, err))
+				continue
+			}
+			result = append(result, ids...)
+		}
+	}
+	if len(errs) > 0 {
+		return result, errs
+	}
+	return result, nil
+}
@@ -0,0 +1,156 @@
+package remote
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+// newTestCatalog starts the registry with the repositories "a"
+// and "b/c" listed in two pages, where only "b/c" has tags.
+func newTestCatalog(t *testing.T) string {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/_catalog", func(w http.ResponseWriter, r *http.Request) {
+		if username, password, _ := r.BasicAuth(); username != "user" ||
+			password != "pass" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</v2/_catalog?last=a&n=1>; rel="next"`)
+			_, _ = w.Write([]byte(`{"repositories":["a"]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"repositories":["b/c"]}`))
+	})
+	mux.HandleFunc("/v2/b/c/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</v2/b/c/tags/list?last=new&n=2>; rel="next"`)
+			_, _ = w.Write([]byte(`{"name":"b/c","tags":["old","new"]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"name":"b/c","tags":["multi"]}`))
+	})
+	mux.HandleFunc("/v2/b/c/manifests/", func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/v2/b/c/manifests/") {
+		case "multi":
+			_, _ = w.Write([]byte(`{"schemaVersion":2,"manifests":[` +
+				`{"digest":"sha256:aa","platform":{"os":"linux","architecture":"amd64"}},` +
+				`{"digest":"sha256:bb","platform":{"os":"linux","architecture":"arm64"}}]}`))
+		case "sha256:aa", "old":
+			_, _ = w.Write([]byte(`{"schemaVersion":2,"config":{"digest":"sha256:old"}}`))
+		default:
+			_, _ = w.Write([]byte(`{"schemaVersion":2,"config":{"digest":"sha256:new"}}`))
+		}
+	})
+	mux.HandleFunc("/v2/b/c/blobs/", func(w http.ResponseWriter, r *http.Request) {
+		created := map[string]string{
+			"sha256:old": "2020-01-01T00:00:00Z",
+			"sha256:new": "2022-01-01T00:00:00Z",
+		}[strings.TrimPrefix(r.URL.Path, "/v2/b/c/blobs/")]
+		_, _ = fmt.Fprintf(w, `{"created":%q}`, created)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestListRepositories(t *testing.T) {
+	host := newTestCatalog(t)
+	runtime := &Runtime{}
+	repos, err := runtime.ListRepositories(host,
+		WithInsecure(), WithAuth("user", "pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 || repos[0] != "a" || repos[1] != "b/c" {
+		t.Fatalf("unexpected repositories %v", repos)
+	}
+	if _, err := runtime.ListRepositories(host, WithInsecure()); err == nil {
+		t.Fatal("unauthorized catalog listed")
+	}
+}
+
+func TestListTags(t *testing.T) {
+	host := newTestCatalog(t)
+	runtime := &Runtime{}
+	tags, err := runtime.ListTags(host+"/b/c", WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(tags, ",") != "old,new,multi" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+}
+
+func TestRecentTags(t *testing.T) {
+	host := newTestCatalog(t)
+	runtime := &Runtime{}
+	tags, err := runtime.RecentTags(host+"/b/c", 1, WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0] != "new" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+
+	// The arm64 image of "multi" is created recently, while
+	// the first one is old.
+	tags, err = runtime.RecentTags(host+"/b/c", -1, WithInsecure(),
+		WithPlatform("linux/arm64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 3 || tags[2] != "old" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	tags, err = runtime.RecentTags(host+"/b/c", -1, WithInsecure(),
+		WithParallelism(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 3 || tags[0] != "new" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+
+	// The "multi" has no s390x image, which is skipped while
+	// the other tags are still ordered.
+	tags, err = runtime.RecentTags(host+"/b/c", -1, WithInsecure(),
+		WithPlatform("linux/s390x"))
+	var errs TagErrors
+	if !xerrors.As(err, &errs) || len(errs) != 1 ||
+		!strings.HasPrefix(errs[0].Error(), "multi: ") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if strings.Join(tags, ",") != "new,old" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+}
+
+func TestLoadRecentTagsSkipRepository(t *testing.T) {
+	host := newTestCatalog(t)
+	runtime := &Runtime{}
+
+	// The repository "a" has no tags, and the tag "multi" has
+	// no s390x image, which are skipped rather than aborting.
+	// The most recent tag left fails to load without a store.
+	ids, err := runtime.LoadRecentTags(host, 1, WithInsecure(),
+		WithAuth("user", "pass"), WithPlatform("linux/s390x"))
+	if len(ids) != 0 {
+		t.Fatalf("unexpected images %v", ids)
+	}
+	var errs RepositoryErrors
+	if !xerrors.As(err, &errs) || len(errs) != 3 {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !strings.HasPrefix(errs[0].Error(), host+"/a:") ||
+		!strings.HasPrefix(errs[1].Error(), host+"/b/c:multi: ") ||
+		!strings.HasPrefix(errs[2].Error(), host+"/b/c:new: ") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
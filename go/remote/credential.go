@@ -0,0 +1,210 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	// dockerHub is the domain of docker hub in references.
+	dockerHub = "docker.io"
+
+	// dockerHubServer is the server address of docker hub
+	// recorded in the config of docker client.
+	dockerHubServer = "https://index.docker.io/v1/"
+
+	// tokenUsername is the username returned by credential
+	// helpers when the secret is an identity token.
+	tokenUsername = "<token>"
+)
+
+// Credential is the credential authenticating with registry.
+type Credential struct {
+	Username string
+	Password string
+
+	// IdentityToken is the token exchanged for the registry
+	// tokens, in place of the username and password.
+	IdentityToken string
+
+	// RegistryToken is the bearer token presented to the
+	// registry directly.
+	RegistryToken string
+}
+
+// Empty returns whether there's nothing in the credential.
+func (c Credential) Empty() bool {
+	return c == Credential{}
+}
+
+// dockerAuth is an entry of "auths" in the docker config.
+type dockerAuth struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+	RegistryToken string `json:"registrytoken"`
+}
+
+// DockerConfig is the config file of docker client, where
+// only the fields related to credentials are recognized.
+type DockerConfig struct {
+	Auths       map[string]dockerAuth `json:"auths"`
+	CredHelpers map[string]string     `json:"credHelpers"`
+	CredsStore  string                `json:"credsStore"`
+}
+
+// DefaultDockerConfigPath returns the path of the config file
+// of docker client, specified by DOCKER_CONFIG or in the home
+// directory of current user.
+func DefaultDockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// LoadDockerConfig loads the config file of docker client, an
+// empty config is returned when the file does not exist.
+func LoadDockerConfig(path string) (*DockerConfig, error) {
+	var result DockerConfig
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, xerrors.Errorf("%s: %w", path, err)
+	}
+	return &result, nil
+}
+
+// registryHost normalizes the registry address or the server
+// address recorded in the docker config into the domain used
+// in references.
+func registryHost(server string) string {
+	host := server
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if i := strings.IndexByte(host, '/'); i >= 0 {
+		host = host[:i]
+	}
+	switch host {
+	case "index.docker.io", "registry-1.docker.io":
+		return dockerHub
+	}
+	return host
+}
+
+// serverAddress returns the server address of the registry
+// known by docker client and the credential helpers.
+func serverAddress(registry string) string {
+	if registryHost(registry) == dockerHub {
+		return dockerHubServer
+	}
+	return registry
+}
+
+// Resolve the credential of the registry, in the order of the
+// credential helper specified for the registry, the entry in
+// "auths" and the default credential store.
+//
+// An empty credential is returned when there's none.
+func (c *DockerConfig) Resolve(registry string) (Credential, error) {
+	host := registryHost(registry)
+	for server, helper := range c.CredHelpers {
+		if registryHost(server) == host {
+			return HelperCredential(helper, serverAddress(host))
+		}
+	}
+	for server, auth := range c.Auths {
+		if registryHost(server) != host {
+			continue
+		}
+		result := Credential{
+			Username:      auth.Username,
+			Password:      auth.Password,
+			IdentityToken: auth.IdentityToken,
+			RegistryToken: auth.RegistryToken,
+		}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return Credential{}, xerrors.Errorf(
+					"invalid auth of %q: %w", server, err)
+			}
+			i := strings.IndexByte(string(decoded), ':')
+			if i < 0 {
+				return Credential{}, xerrors.Errorf(
+					"invalid auth of %q", server)
+			}
+			result.Username = string(decoded[:i])
+			result.Password = string(decoded[i+1:])
+		}
+		if !result.Empty() {
+			return result, nil
+		}
+	}
+	if c.CredsStore != "" {
+		return HelperCredential(c.CredsStore, serverAddress(host))
+	}
+	return Credential{}, nil
+}
+
+// errCredentialsNotFound is the message of credential helpers
+// when there's no credential of the server.
+const errCredentialsNotFound = "credentials not found in native keychain"
+
+// HelperCredential retrieves the credential of the server from
+// the credential helper "docker-credential-<helper>".
+//
+// An empty credential is returned when the helper has no
+// credential of the server.
+func HelperCredential(helper, server string) (Credential, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stdout.String())
+		if message == errCredentialsNotFound {
+			return Credential{}, nil
+		}
+		if message == "" {
+			message = strings.TrimSpace(stderr.String())
+		}
+		return Credential{}, xerrors.Errorf(
+			"credential helper %q: %s: %w", helper, message, err)
+	}
+	var output struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return Credential{}, xerrors.Errorf(
+			"credential helper %q: %w", helper, err)
+	}
+	if output.Username == tokenUsername {
+		return Credential{IdentityToken: output.Secret}, nil
+	}
+	return Credential{
+		Username: output.Username,
+		Password: output.Secret,
+	}, nil
+}
@@ -0,0 +1,113 @@
+package remote
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeDockerConfig writes the docker config along with the
+// credential helper "docker-credential-test", which knows the
+// identity token of docker hub only.
+func writeDockerConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "remote-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	helper := "#!/bin/sh\nread server\n" +
+		"if [ \"$server\" = \"" + dockerHubServer + "\" ]; then\n" +
+		"\techo '{\"Username\":\"<token>\",\"Secret\":\"identity\"}'\n" +
+		"\texit 0\nfi\n" +
+		"echo '" + errCredentialsNotFound + "'\nexit 1\n"
+	if err := ioutil.WriteFile(filepath.Join(dir,
+		"docker-credential-test"), []byte(helper), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := os.Getenv("PATH")
+	t.Cleanup(func() { _ = os.Setenv("PATH", path) })
+	_ = os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	config := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(config, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestDockerConfigResolve(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("user:pa:ss"))
+	path := writeDockerConfig(t, `{
+		"auths": {
+			"https://ghcr.io/v1/": {"auth": "`+auth+`"},
+			"quay.io": {"username": "quay", "password": "secret"},
+			"mirror.local": {"registrytoken": "mirror-token"}
+		},
+		"credHelpers": {"index.docker.io": "test"}
+	}`)
+	config, err := LoadDockerConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		registry string
+		expected Credential
+	}{
+		{"ghcr.io", Credential{Username: "user", Password: "pa:ss"}},
+		{"quay.io", Credential{Username: "quay", Password: "secret"}},
+		{"docker.io", Credential{IdentityToken: "identity"}},
+		{"registry-1.docker.io", Credential{IdentityToken: "identity"}},
+		{"mirror.local", Credential{RegistryToken: "mirror-token"}},
+		{"example.com", Credential{}},
+	} {
+		cred, err := config.Resolve(test.registry)
+		if err != nil {
+			t.Fatalf("%s: %v", test.registry, err)
+		}
+		if cred != test.expected {
+			t.Errorf("%s: got %+v, want %+v",
+				test.registry, cred, test.expected)
+		}
+	}
+}
+
+func TestDockerConfigCredsStore(t *testing.T) {
+	path := writeDockerConfig(t, `{"credsStore": "test"}`)
+	config, err := LoadDockerConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred, err := config.Resolve("docker.io"); err != nil ||
+		cred.IdentityToken != "identity" {
+		t.Fatalf("docker.io: %+v %v", cred, err)
+	}
+	if cred, err := config.Resolve("ghcr.io"); err != nil || !cred.Empty() {
+		t.Fatalf("ghcr.io: %+v %v", cred, err)
+	}
+}
+
+func TestMirrorCredential(t *testing.T) {
+	path := writeDockerConfig(t, `{
+		"auths": {"mirror.local": {"registrytoken": "mirror-token"}}
+	}`)
+	options := &loadOptions{}
+	for _, opt := range []LoadOption{
+		WithRegistryToken("origin-token"),
+		WithDockerConfig(path),
+		WithMirror("docker.io", "mirror.local", "other.local"),
+	} {
+		opt(options)
+	}
+	if cred, err := options.credential("docker.io"); err != nil ||
+		cred.RegistryToken != "origin-token" {
+		t.Fatalf("registry: %+v %v", cred, err)
+	}
+	if cred, err := options.resolve("mirror.local"); err != nil ||
+		cred.RegistryToken != "mirror-token" {
+		t.Fatalf("mirror: %+v %v", cred, err)
+	}
+	if cred, err := options.resolve("other.local"); err != nil || !cred.Empty() {
+		t.Fatalf("mirror without credential: %+v %v", cred, err)
+	}
+}
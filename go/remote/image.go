@@ -1,18 +1,30 @@
 package remote
 
 import (
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/xerrors"
+
 	api "github.com/chaitin/libveinmind/go"
 	"github.com/chaitin/libveinmind/go/pkg/behaviour"
 	"github.com/chaitin/libveinmind/go/pkg/binding"
+	"github.com/chaitin/libveinmind/go/pkg/platform"
 )
 
-// Image represents a remote image.
+// Image represents a remote image, which is either loaded
+// into the native store, or pulled in Go into the OCI image
+// layout under the root.
 type Image struct {
+	api.LayeredImage
+	runtime *Runtime
+}
+
+// nativeImage is the image in the native store.
+type nativeImage struct {
 	behaviour.Closer
 	behaviour.Image
 	behaviour.FileSystem
-	image   binding.Handle
-	runtime *Runtime
+	image binding.Handle
+	owner *Image
 }
 
 type Layer struct {
@@ -22,19 +34,45 @@ type Layer struct {
 	image *Image
 }
 
+var _ api.LayeredImage = (*Image)(nil)
+
 func (i *Image) Runtime() *Runtime {
 	return i.runtime
 }
 
-func (i *Image) NumLayers() int {
-	return i.image.RemoteImageNumLayers()
+// Platform returns the platform of the image recorded in its
+// config.
+func (i *Image) Platform() (imageV1.Platform, error) {
+	config, err := i.OCISpecV1()
+	if err != nil {
+		return imageV1.Platform{}, err
+	}
+	return platform.FromConfig(config), nil
 }
 
 func (im *Image) GetLayerDiffID(i int) (string, error) {
+	return im.LayerDiffID(i)
+}
+
+func (i *nativeImage) NumLayers() int {
+	return i.image.RemoteImageNumLayers()
+}
+
+func (im *nativeImage) LayerDiffID(i int) (string, error) {
 	return im.image.RemoteImageGetLayerDiffID(i)
 }
 
-func (i *Image) OpenLayer(index int) (api.Layer, error) {
+// LayerDigest returns the digest of the layer blob, which is
+// not recorded by the native store of libveinmind.
+func (im *nativeImage) LayerDigest(i int) (string, error) {
+	if i < 0 || i >= im.NumLayers() {
+		return "", xerrors.Errorf("remote: layer %d out of range", i)
+	}
+	return "", xerrors.Errorf(
+		"remote: digest of layer %d unrecorded in image %q", i, im.ID())
+}
+
+func (i *nativeImage) OpenLayer(index int) (api.Layer, error) {
 	h, err := i.image.RemoteImageOpenLayer(index)
 	if err != nil {
 		return nil, err
@@ -44,7 +82,7 @@ func (i *Image) OpenLayer(index int) (api.Layer, error) {
 		Closer:     behaviour.NewCloser(&h),
 		FileSystem: behaviour.NewFileSystem(&h),
 		layer:      h,
-		image:      i,
+		image:      i.owner,
 	}, nil
 }
 
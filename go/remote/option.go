@@ -4,6 +4,16 @@ type loadOptions struct {
 	insecure bool
 	username string
 	password string
+
+	identityToken string
+	registryToken string
+	dockerConfig  *string
+	credHelper    string
+	mirrors       map[string][]string
+	caBundles     map[string]string
+	platform      string
+	allPlatforms  bool
+	parallelism   int
 }
 
 // LoadOption is the option that can be used for loading an
@@ -22,3 +32,101 @@ func WithInsecure() LoadOption {
 		o.insecure = true
 	}
 }
+
+// WithIdentityToken specifies the identity token, which is
+// exchanged for the registry token pulling the image.
+func WithIdentityToken(token string) LoadOption {
+	return func(o *loadOptions) {
+		o.identityToken = token
+	}
+}
+
+// WithRegistryToken specifies the bearer token presented to
+// the registry directly.
+func WithRegistryToken(token string) LoadOption {
+	return func(o *loadOptions) {
+		o.registryToken = token
+	}
+}
+
+// WithDockerConfig resolves the credential of the registry
+// from the config file of docker client, including the
+// credential helpers specified in it. The path defaults to
+// DefaultDockerConfigPath when it is empty.
+//
+// The credential specified explicitly takes precedence.
+func WithDockerConfig(path string) LoadOption {
+	return func(o *loadOptions) {
+		o.dockerConfig = &path
+	}
+}
+
+// WithCredentialHelper resolves the credential of registry
+// from the credential helper "docker-credential-<helper>".
+//
+// The credential specified explicitly takes precedence.
+func WithCredentialHelper(helper string) LoadOption {
+	return func(o *loadOptions) {
+		o.credHelper = helper
+	}
+}
+
+// WithMirror specifies the mirrors of the registry, which are
+// attempted in order before the registry itself. The registry
+// is in the form of the domain in references, for example
+// "docker.io" for docker hub.
+//
+// The credential of mirrors is resolved for their own hosts
+// by WithDockerConfig or WithCredentialHelper, the credential
+// of registry is never sent to mirrors. The images loaded from
+// a mirror are referenced by the mirror.
+func WithMirror(registry string, mirrors ...string) LoadOption {
+	return func(o *loadOptions) {
+		if o.mirrors == nil {
+			o.mirrors = make(map[string][]string)
+		}
+		registry = registryHost(registry)
+		o.mirrors[registry] = append(o.mirrors[registry], mirrors...)
+	}
+}
+
+// WithCABundle specifies the path of the PEM encoded CA bundle
+// trusted when connecting to the registry, which is also
+// trusted by its mirrors without a CA bundle of their own.
+func WithCABundle(registry, path string) LoadOption {
+	return func(o *loadOptions) {
+		if o.caBundles == nil {
+			o.caBundles = make(map[string]string)
+		}
+		o.caBundles[registryHost(registry)] = path
+	}
+}
+
+// WithPlatform specifies the platform to load when the image
+// is a multi-platform index, in the form of "os/arch[/variant]"
+// like "linux/arm64".
+func WithPlatform(platform string) LoadOption {
+	return func(o *loadOptions) {
+		o.platform = platform
+	}
+}
+
+// WithAllPlatforms loads the images of all platforms when the
+// image is a multi-platform index.
+func WithAllPlatforms() LoadOption {
+	return func(o *loadOptions) {
+		o.allPlatforms = true
+	}
+}
+
+// WithParallelism specifies how many tags are inspected in
+// parallel by RecentTags, for the creation time of images.
+//
+// Leaving parallelism unspecified or setting it to 0 will cause
+// up to defaultParallelism tags to be inspected in parallel.
+// Setting it to 1 will disable parallel inspection.
+func WithParallelism(n int) LoadOption {
+	return func(o *loadOptions) {
+		o.parallelism = n
+	}
+}
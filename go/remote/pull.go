@@ -0,0 +1,316 @@
+package remote
+
+import (
+	"bytes"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/xerrors"
+
+	"github.com/chaitin/libveinmind/go/ocilayout"
+	"github.com/chaitin/libveinmind/go/pkg/platform"
+	"github.com/chaitin/libveinmind/go/pkg/reference"
+)
+
+const (
+	// layoutDir is the OCI image layout under the root, which
+	// stores the images pulled in Go, since the native store
+	// of libveinmind is written by libveinmind only.
+	layoutDir = "layout"
+
+	// manifestLimit bounds the size of manifest read in memory.
+	manifestLimit = 4 << 20
+
+	// annotationImageName is the annotation of the reference
+	// pulled in the index, which is the one read by ocilayout.
+	annotationImageName = "io.containerd.image.name"
+)
+
+// layoutPath returns the path of the OCI image layout.
+func (t *Runtime) layoutPath() string {
+	return filepath.Join(t.root, layoutDir)
+}
+
+// openLayout opens the OCI image layout of the images pulled
+// in Go, nil is returned when nothing has been pulled.
+func (t *Runtime) openLayout() (*ocilayout.Layout, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.layout != nil {
+		return t.layout, nil
+	}
+	path := t.layoutPath()
+	if _, err := os.Stat(filepath.Join(path, "index.json")); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	layout, err := ocilayout.New(ocilayout.WithPath(path))
+	if err != nil {
+		return nil, err
+	}
+	t.layout = layout.(*ocilayout.Layout)
+	return t.layout, nil
+}
+
+// pulledManifest is the manifest pulled with its descriptor.
+type pulledManifest struct {
+	imageV1.Descriptor
+	data     []byte
+	manifest manifestOrIndex
+}
+
+// pullManifest retrieves the manifest or index referenced in
+// bytes, so that it is stored as is, and verifies it against
+// the digest if referenced by digest.
+func (c *registryClient) pullManifest(
+	repo, reference string,
+) (*pulledManifest, error) {
+	resp, err := c.get("/v2/"+repo+"/manifests/"+reference,
+		"repository:"+repo+":pull", manifestMediaTypes...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, manifestLimit+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > manifestLimit {
+		return nil, xerrors.Errorf(
+			"remote: manifest %s:%s too large", repo, reference)
+	}
+
+	result := &pulledManifest{data: data}
+	result.Digest = digest.FromBytes(data)
+	if strings.Contains(reference, ":") {
+		expected, err := digest.Parse(reference)
+		if err != nil {
+			return nil, err
+		}
+		verifier := expected.Verifier()
+		_, _ = verifier.Write(data)
+		if !verifier.Verified() {
+			return nil, xerrors.Errorf(
+				"remote: digest mismatch of manifest %q", reference)
+		}
+		result.Digest = expected
+	}
+	if err := json.Unmarshal(data, &result.manifest); err != nil {
+		return nil, xerrors.Errorf("remote: manifest %s:%s: %w",
+			repo, reference, err)
+	}
+	result.MediaType, _, _ = mime.ParseMediaType(
+		resp.Header.Get("Content-Type"))
+	if result.MediaType == "" {
+		result.MediaType = result.manifest.MediaType
+	}
+	if result.MediaType == "" {
+		result.MediaType = imageV1.MediaTypeImageManifest
+		if len(result.manifest.Manifests) > 0 {
+			result.MediaType = imageV1.MediaTypeImageIndex
+		}
+	}
+	result.Size = int64(len(data))
+	return result, nil
+}
+
+// blobPath returns the path of the blob in the layout.
+func (t *Runtime) blobPath(d digest.Digest) (string, error) {
+	if err := d.Validate(); err != nil {
+		return "", xerrors.Errorf("remote: digest %q: %w", d, err)
+	}
+	return filepath.Join(t.layoutPath(), "blobs",
+		d.Algorithm().String(), d.Encoded()), nil
+}
+
+// writeBlob writes the content into the blob of the digest,
+// which is renamed into place only if the content is verified.
+func (t *Runtime) writeBlob(d digest.Digest, r io.Reader) error {
+	path, err := t.blobPath(d)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".pull-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	verifier := d.Verifier()
+	if _, err := io.Copy(io.MultiWriter(f, verifier), r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if !verifier.Verified() {
+		return xerrors.Errorf("remote: digest mismatch of blob %q", d)
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// pullBlob pulls the blob into the layout unless it exists.
+func (t *Runtime) pullBlob(
+	client *registryClient, repo string, d imageV1.Descriptor,
+) error {
+	path, err := t.blobPath(d.Digest)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	resp, err := client.get("/v2/"+repo+"/blobs/"+string(d.Digest),
+		"repository:"+repo+":pull")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var body io.Reader = resp.Body
+	if d.Size > 0 {
+		body = io.LimitReader(body, d.Size+1)
+	}
+	return t.writeBlob(d.Digest, body)
+}
+
+// writeFile replaces the file with the data atomically.
+func writeFile(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// addManifest adds the manifest to the index of the layout,
+// replacing the one pulled by the same reference before.
+func (t *Runtime) addManifest(d imageV1.Descriptor) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	path := t.layoutPath()
+	layoutFile := filepath.Join(path, imageV1.ImageLayoutFile)
+	if _, err := os.Stat(layoutFile); os.IsNotExist(err) {
+		data, err := json.Marshal(imageV1.ImageLayout{
+			Version: imageV1.ImageLayoutVersion,
+		})
+		if err != nil {
+			return err
+		}
+		if err := writeFile(layoutFile, data); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	indexFile := filepath.Join(path, "index.json")
+	var index imageV1.Index
+	data, err := ioutil.ReadFile(indexFile)
+	switch {
+	case os.IsNotExist(err):
+		index.SchemaVersion = 2
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &index); err != nil {
+			return xerrors.Errorf("%s: %w", indexFile, err)
+		}
+	}
+	name := d.Annotations[annotationImageName]
+	var manifests []imageV1.Descriptor
+	for _, m := range index.Manifests {
+		if m.Annotations[annotationImageName] != name {
+			manifests = append(manifests, m)
+		}
+	}
+	index.Manifests = append(manifests, d)
+	if data, err = json.Marshal(index); err != nil {
+		return err
+	}
+	if err := writeFile(indexFile, data); err != nil {
+		return err
+	}
+	t.layout = nil
+	return nil
+}
+
+// pull pulls the image into the OCI image layout in Go, with
+// the registry token and CA bundle of the client, which cannot
+// be passed to the native loader. The manifest of the host
+// platform is pulled when the reference is an index.
+func (t *Runtime) pull(
+	client *registryClient, ref *reference.Reference,
+) ([]string, error) {
+	if t.root == "" {
+		return nil, xerrors.New("remote: root unspecified")
+	}
+	name := ref.Digest
+	if name == "" {
+		if name = ref.Tag; name == "" {
+			name = "latest"
+		}
+	}
+	pulled, err := client.pullManifest(ref.Path, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(pulled.manifest.Manifests) > 0 {
+		host := platform.Normalize(imageV1.Platform{
+			OS: goruntime.GOOS, Architecture: goruntime.GOARCH,
+		})
+		d, ok := selectPlatform(pulled.manifest.Manifests, host)
+		if !ok {
+			return nil, xerrors.Errorf("remote: no %s image in %s",
+				platform.Format(host), ref.String())
+		}
+		if pulled, err = client.pullManifest(
+			ref.Path, string(d.Digest)); err != nil {
+			return nil, err
+		}
+		if len(pulled.manifest.Manifests) > 0 {
+			return nil, xerrors.Errorf(
+				"remote: nested index in %s", ref.String())
+		}
+	}
+
+	manifest := pulled.manifest.Manifest
+	blobs := append([]imageV1.Descriptor{manifest.Config}, manifest.Layers...)
+	for _, d := range blobs {
+		if err := t.pullBlob(client, ref.Path, d); err != nil {
+			return nil, err
+		}
+	}
+	if err := t.writeBlob(
+		pulled.Digest, bytes.NewReader(pulled.data)); err != nil {
+		return nil, err
+	}
+	d := pulled.Descriptor
+	d.Annotations = map[string]string{annotationImageName: ref.String()}
+	if err := t.addManifest(d); err != nil {
+		return nil, err
+	}
+	return []string{string(manifest.Config.Digest)}, nil
+}
@@ -0,0 +1,215 @@
+package remote
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	goruntime "runtime"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	api "github.com/chaitin/libveinmind/go"
+	"github.com/chaitin/libveinmind/go/pkg/reference"
+)
+
+// testImage is the image served by the test registry, which
+// is an index of the image of the host platform.
+type testImage struct {
+	blobs    map[digest.Digest][]byte
+	index    digest.Digest
+	config   digest.Digest
+	layer    digest.Digest
+	tampered bool
+}
+
+func newTestImage(t *testing.T) *testImage {
+	var layer bytes.Buffer
+	tw := tar.NewWriter(&layer)
+	content := []byte("hello\n")
+	if err := tw.WriteHeader(&tar.Header{
+		Name: "etc/hello", Mode: 0644, Size: int64(len(content)),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = tw.Write(content)
+	_ = tw.Close()
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	_, _ = gw.Write(layer.Bytes())
+	_ = gw.Close()
+
+	result := &testImage{blobs: make(map[digest.Digest][]byte)}
+	add := func(v interface{}) (digest.Digest, int64) {
+		data, ok := v.([]byte)
+		if !ok {
+			var err error
+			if data, err = json.Marshal(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		d := digest.FromBytes(data)
+		result.blobs[d] = data
+		return d, int64(len(data))
+	}
+	var layerSize, configSize, manifestSize int64
+	result.layer, layerSize = add(compressed.Bytes())
+	config := imageV1.Image{OS: goruntime.GOOS, Architecture: goruntime.GOARCH}
+	config.RootFS.Type = "layers"
+	config.RootFS.DiffIDs = []digest.Digest{digest.FromBytes(layer.Bytes())}
+	result.config, configSize = add(config)
+	manifest := imageV1.Manifest{
+		MediaType: imageV1.MediaTypeImageManifest,
+		Config: imageV1.Descriptor{
+			MediaType: imageV1.MediaTypeImageConfig,
+			Digest:    result.config, Size: configSize,
+		},
+		Layers: []imageV1.Descriptor{{
+			MediaType: imageV1.MediaTypeImageLayerGzip,
+			Digest:    result.layer, Size: layerSize,
+		}},
+	}
+	manifest.SchemaVersion = 2
+	manifestDigest, manifestSize := add(manifest)
+	index := imageV1.Index{
+		MediaType: imageV1.MediaTypeImageIndex,
+		Manifests: []imageV1.Descriptor{{
+			MediaType: imageV1.MediaTypeImageManifest,
+			Digest:    digest.FromString("other"), Size: 1,
+			Platform: &imageV1.Platform{OS: "plan9", Architecture: "386"},
+		}, {
+			MediaType: imageV1.MediaTypeImageManifest,
+			Digest:    manifestDigest, Size: manifestSize,
+			Platform: &imageV1.Platform{
+				OS: goruntime.GOOS, Architecture: goruntime.GOARCH,
+			},
+		}},
+	}
+	index.SchemaVersion = 2
+	result.index, _ = add(index)
+	return result
+}
+
+func (i *testImage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var name string
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v2/foo/bar/manifests/"):
+		name = strings.TrimPrefix(r.URL.Path, "/v2/foo/bar/manifests/")
+		if name == "latest" {
+			name = string(i.index)
+		}
+		w.Header().Set("Content-Type", imageV1.MediaTypeImageManifest)
+		if digest.Digest(name) == i.index {
+			w.Header().Set("Content-Type", imageV1.MediaTypeImageIndex)
+		}
+	case strings.HasPrefix(r.URL.Path, "/v2/foo/bar/blobs/"):
+		name = strings.TrimPrefix(r.URL.Path, "/v2/foo/bar/blobs/")
+	}
+	data, ok := i.blobs[digest.Digest(name)]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if i.tampered && digest.Digest(name) == i.layer {
+		data = append([]byte(nil), data...)
+		data[len(data)-1] ^= 0xff
+	}
+	_, _ = w.Write(data)
+}
+
+// newTestRuntime creates the runtime pulling in Go only, which
+// has no native store opened.
+func newTestRuntime(t *testing.T) *Runtime {
+	dir, err := ioutil.TempDir("", "remote-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return &Runtime{root: dir}
+}
+
+func TestRuntimePull(t *testing.T) {
+	image := newTestImage(t)
+	server, client := newTestRegistry(t, image, Credential{RegistryToken: "token"})
+	ref, err := reference.Parse(
+		strings.TrimPrefix(server.URL, "https://") + "/foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime := newTestRuntime(t)
+	ids, err := runtime.pull(client, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != string(image.config) {
+		t.Fatalf("unexpected IDs %v", ids)
+	}
+	// Pulling again replaces the one in the index.
+	if _, err := runtime.pull(client, ref); err != nil {
+		t.Fatal(err)
+	}
+
+	layout, err := runtime.openLayout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed, err := layout.ListImageIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0] != string(image.config) {
+		t.Fatalf("unexpected images %v in layout", listed)
+	}
+	opened, err := runtime.OpenImageByID(string(image.config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = opened.Close() }()
+	layered := opened.(api.LayeredImage)
+	if digest, err := layered.LayerDigest(0); err != nil ||
+		digest != string(image.layer) {
+		t.Fatalf("unexpected layer digest %q: %v", digest, err)
+	}
+	refs, err := opened.RepoRefs()
+	if err != nil || len(refs) != 1 || refs[0] != ref.String() {
+		t.Fatalf("unexpected references %v: %v", refs, err)
+	}
+	f, err := opened.Open("/etc/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(f)
+	_ = f.Close()
+	if err != nil || string(data) != "hello\n" {
+		t.Fatalf("unexpected content %q: %v", data, err)
+	}
+}
+
+func TestRuntimePullTampered(t *testing.T) {
+	image := newTestImage(t)
+	image.tampered = true
+	server, client := newTestRegistry(t, image, Credential{RegistryToken: "token"})
+	ref, err := reference.Parse(
+		strings.TrimPrefix(server.URL, "https://") + "/foo/bar:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime := newTestRuntime(t)
+	if _, err := runtime.pull(client, ref); err == nil ||
+		!strings.Contains(err.Error(), "digest mismatch") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if layout, err := runtime.openLayout(); err != nil || layout != nil {
+		t.Fatalf("unexpected layout %v: %v", layout, err)
+	}
+}
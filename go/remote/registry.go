@@ -0,0 +1,452 @@
+package remote
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/xerrors"
+
+	"github.com/chaitin/libveinmind/go/pkg/platform"
+)
+
+// dockerHubEndpoint is the endpoint serving the registry API
+// of docker hub.
+const dockerHubEndpoint = "registry-1.docker.io"
+
+// endpoint returns the host serving the registry API.
+func endpoint(registry string) string {
+	if registryHost(registry) == dockerHub {
+		return dockerHubEndpoint
+	}
+	return registry
+}
+
+// newHTTPClient creates the HTTP client connecting to the
+// registry, trusting the CA bundle in PEM if specified.
+func newHTTPClient(caBundle []byte, insecure bool) (*http.Client, error) {
+	config := &tls.Config{InsecureSkipVerify: insecure}
+	if len(caBundle) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return nil, xerrors.New("remote: no certificate in CA bundle")
+		}
+		config.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	return &http.Client{Transport: transport}, nil
+}
+
+// challenge is the parsed WWW-Authenticate header.
+type challenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseChallenge parses the challenge in the form of
+// `Bearer realm="...",service="...",scope="..."`.
+func parseChallenge(header string) challenge {
+	result := challenge{params: make(map[string]string)}
+	header = strings.TrimSpace(header)
+	i := strings.IndexByte(header, ' ')
+	if i < 0 {
+		result.scheme = strings.ToLower(header)
+		return result
+	}
+	result.scheme = strings.ToLower(header[:i])
+	rest := header[i+1:]
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := 1
+			for end < len(rest) && rest[end] != '"' {
+				if rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(rest) {
+				end = len(rest) - 1
+			}
+			value = strings.Replace(rest[1:end], `\"`, `"`, -1)
+			rest = rest[end+1:]
+		} else if comma := strings.IndexByte(rest, ','); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		result.params[key] = value
+	}
+	return result
+}
+
+// registryClient issues requests to the registry API, which
+// answers the challenges of registry with the credential.
+type registryClient struct {
+	scheme   string
+	host     string
+	client   *http.Client
+	cred     Credential
+	insecure bool
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func newRegistryClient(
+	registry string, cred Credential, caBundle []byte, insecure bool,
+) (*registryClient, error) {
+	client, err := newHTTPClient(caBundle, insecure)
+	if err != nil {
+		return nil, err
+	}
+	return &registryClient{
+		scheme:   "https",
+		host:     endpoint(registry),
+		client:   client,
+		cred:     cred,
+		insecure: insecure,
+		tokens:   make(map[string]string),
+	}, nil
+}
+
+// token fetches the bearer token of the scope from the
+// authorization server specified in the challenge.
+func (c *registryClient) token(ch challenge, scope string) (string, error) {
+	realm := ch.params["realm"]
+	if realm == "" {
+		return "", xerrors.New("remote: bearer challenge without realm")
+	}
+	if scope == "" {
+		scope = ch.params["scope"]
+	}
+	key := realm + "\x00" + ch.params["service"] + "\x00" + scope
+	c.mu.Lock()
+	cached, ok := c.tokens[key]
+	c.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	var req *http.Request
+	var err error
+	if c.cred.IdentityToken != "" {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", c.cred.IdentityToken)
+		form.Set("service", ch.params["service"])
+		form.Set("client_id", "libveinmind")
+		if scope != "" {
+			form.Set("scope", scope)
+		}
+		req, err = http.NewRequest(http.MethodPost, realm,
+			strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		query := url.Values{}
+		if service := ch.params["service"]; service != "" {
+			query.Set("service", service)
+		}
+		if scope != "" {
+			query.Set("scope", scope)
+		}
+		u, err := url.Parse(realm)
+		if err != nil {
+			return "", err
+		}
+		u.RawQuery = query.Encode()
+		req, err = http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		if c.cred.Username != "" || c.cred.Password != "" {
+			req.SetBasicAuth(c.cred.Username, c.cred.Password)
+		}
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", xerrors.Errorf("remote: token request to %q: %s",
+			realm, resp.Status)
+	}
+	var result struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", xerrors.Errorf("remote: token response: %w", err)
+	}
+	if result.Token == "" {
+		result.Token = result.AccessToken
+	}
+	if result.Token == "" {
+		return "", xerrors.New("remote: empty token in response")
+	}
+	c.mu.Lock()
+	c.tokens[key] = result.Token
+	c.mu.Unlock()
+	return result.Token, nil
+}
+
+// authorize answers the challenge in the response by setting
+// the authorization of the request, false is returned if it
+// cannot be answered.
+func (c *registryClient) authorize(
+	req *http.Request, resp *http.Response, scope string,
+) (bool, error) {
+	ch := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	switch ch.scheme {
+	case "basic":
+		if c.cred.Username == "" && c.cred.Password == "" {
+			return false, nil
+		}
+		req.SetBasicAuth(c.cred.Username, c.cred.Password)
+		return true, nil
+	case "bearer":
+		token, err := c.token(ch, scope)
+		if err != nil {
+			return false, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return true, nil
+	}
+	return false, nil
+}
+
+// send the request to the registry, the insecure registry is
+// visited through plain HTTP when HTTPS fails.
+func (c *registryClient) send(
+	path string, accept []string, authorize func(*http.Request) error,
+) (*http.Response, error) {
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet,
+			c.scheme+"://"+c.host+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, mediaType := range accept {
+			req.Header.Add("Accept", mediaType)
+		}
+		if c.cred.RegistryToken != "" {
+			req.Header.Set("Authorization", "Bearer "+c.cred.RegistryToken)
+		}
+		if authorize != nil {
+			if err := authorize(req); err != nil {
+				return nil, err
+			}
+		}
+		return req, nil
+	}
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil && c.insecure && c.scheme == "https" {
+		c.scheme = "http"
+		if req, err = newRequest(); err != nil {
+			return nil, err
+		}
+		resp, err = c.client.Do(req)
+	}
+	return resp, err
+}
+
+// get issues the GET request to the path of registry API, and
+// authorizes it with the scope when challenged. The response
+// other than 200 OK is turned into an error.
+func (c *registryClient) get(
+	path, scope string, accept ...string,
+) (*http.Response, error) {
+	resp, err := c.send(path, accept, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized &&
+		c.cred.RegistryToken == "" {
+		_ = resp.Body.Close()
+		challenged, ok := resp, false
+		resp, err = c.send(path, accept, func(req *http.Request) error {
+			var err error
+			ok, err = c.authorize(req, challenged, scope)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			_ = resp.Body.Close()
+			return nil, xerrors.Errorf("remote: unauthorized to %q", c.host)
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, xerrors.Errorf("remote: GET %s%s: %s",
+			c.host, path, resp.Status)
+	}
+	return resp, nil
+}
+
+// getJSON issues the GET request and decodes the response.
+func (c *registryClient) getJSON(
+	path, scope string, v interface{}, accept ...string,
+) (http.Header, error) {
+	resp, err := c.get(path, scope, accept...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return nil, xerrors.Errorf("remote: GET %s%s: %w", c.host, path, err)
+	}
+	return resp.Header, nil
+}
+
+// pullToken retrieves the bearer token pulling the repository,
+// empty token is returned when the registry demands none.
+func (c *registryClient) pullToken(repository string) (string, error) {
+	if c.cred.RegistryToken != "" {
+		return c.cred.RegistryToken, nil
+	}
+	resp, err := c.send("/v2/", nil, nil)
+	if err != nil {
+		return "", err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		return "", nil
+	}
+	ch := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	if ch.scheme != "bearer" {
+		return "", nil
+	}
+	return c.token(ch, "repository:"+repository+":pull")
+}
+
+// manifestMediaTypes are the media types of manifest accepted.
+var manifestMediaTypes = []string{
+	imageV1.MediaTypeImageManifest,
+	imageV1.MediaTypeImageIndex,
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}
+
+// manifestOrIndex is either the manifest of an image, or the
+// index of the manifests of platforms.
+type manifestOrIndex struct {
+	imageV1.Manifest
+	Manifests []imageV1.Descriptor `json:"manifests"`
+}
+
+// manifest retrieves the manifest or index referenced by the
+// tag or digest in the repository.
+func (c *registryClient) manifest(
+	repo, reference string,
+) (*manifestOrIndex, error) {
+	var result manifestOrIndex
+	if _, err := c.getJSON("/v2/"+repo+"/manifests/"+reference,
+		"repository:"+repo+":pull", &result,
+		manifestMediaTypes...); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// config retrieves the config of the image in the repository.
+func (c *registryClient) config(
+	repo string, manifest *manifestOrIndex,
+) (*imageV1.Image, error) {
+	var result imageV1.Image
+	if _, err := c.getJSON("/v2/"+repo+"/blobs/"+
+		string(manifest.Config.Digest), "repository:"+repo+":pull",
+		&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// selectPlatform returns the manifest of the platform selected
+// in the index.
+func selectPlatform(
+	manifests []imageV1.Descriptor, selected imageV1.Platform,
+) (imageV1.Descriptor, bool) {
+	for _, d := range manifests {
+		if d.Platform != nil && platform.Match(*d.Platform, selected) {
+			return d, true
+		}
+	}
+	return imageV1.Descriptor{}, false
+}
+
+// platformManifests resolves the digests of the manifests in
+// the index referenced, which are the one of the platform
+// selected, or all of them when unselected. Nothing is returned
+// when the reference is the manifest of an image, which must be
+// of the platform selected.
+func (c *registryClient) platformManifests(
+	repo, reference string, selected *imageV1.Platform,
+) ([]string, error) {
+	manifest, err := c.manifest(repo, reference)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest.Manifests) == 0 {
+		if selected == nil {
+			return nil, nil
+		}
+		config, err := c.config(repo, manifest)
+		if err != nil {
+			return nil, err
+		}
+		if !platform.Match(platform.FromConfig(config), *selected) {
+			return nil, xerrors.Errorf("remote: no %s image in %s:%s",
+				platform.Format(*selected), repo, reference)
+		}
+		return nil, nil
+	}
+	if selected != nil {
+		d, ok := selectPlatform(manifest.Manifests, *selected)
+		if !ok {
+			return nil, xerrors.Errorf("remote: no %s image in %s:%s",
+				platform.Format(*selected), repo, reference)
+		}
+		return []string{string(d.Digest)}, nil
+	}
+	var result []string
+	for _, d := range manifest.Manifests {
+		// Skip the attestations attached by buildkit, which are
+		// not images of any platform.
+		if d.Platform != nil && d.Platform.OS == "unknown" {
+			continue
+		}
+		result = append(result, string(d.Digest))
+	}
+	return result, nil
+}
@@ -0,0 +1,186 @@
+package remote
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chaitin/libveinmind/go/pkg/platform"
+)
+
+// newTestRegistry starts the registry serving the handler over
+// TLS, and creates the client trusting its certificate.
+func newTestRegistry(
+	t *testing.T, handler http.Handler, cred Credential,
+) (*httptest.Server, *registryClient) {
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+	caBundle := pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: server.Certificate().Raw,
+	})
+	client, err := newRegistryClient(
+		strings.TrimPrefix(server.URL, "https://"), cred, caBundle, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, client
+}
+
+func TestRegistryClientIdentityToken(t *testing.T) {
+	var requests int32
+	tokenServer := httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			_ = r.ParseForm()
+			if r.Method != http.MethodPost ||
+				r.Form.Get("grant_type") != "refresh_token" ||
+				r.Form.Get("refresh_token") != "identity" ||
+				r.Form.Get("service") != "registry" ||
+				r.Form.Get("scope") != "repository:foo/bar:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`{"access_token":"token"}`))
+		}))
+	defer tokenServer.Close()
+	_, client := newTestRegistry(t, http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+
+				tokenServer.URL+`/token",service="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}), Credential{IdentityToken: "identity"})
+	client.client.Transport.(*http.Transport).TLSClientConfig.RootCAs.
+		AddCert(tokenServer.Certificate())
+
+	for i := 0; i < 2; i++ {
+		token, err := client.pullToken("foo/bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "token" {
+			t.Fatalf("unexpected token %q", token)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("token requested %d times, want cached", n)
+	}
+}
+
+func TestRegistryClientBasicToken(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			username, password, _ := r.BasicAuth()
+			if r.Method != http.MethodGet ||
+				username != "user" || password != "pass" ||
+				r.URL.Query().Get("scope") != "repository:foo/bar:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`{"token":"token"}`))
+		}))
+	defer tokenServer.Close()
+	_, client := newTestRegistry(t, http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.Header().Set("WWW-Authenticate",
+					`Bearer realm="`+tokenServer.URL+`"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"tags":["latest"]}`))
+		}), Credential{Username: "user", Password: "pass"})
+
+	var result struct {
+		Tags []string `json:"tags"`
+	}
+	if _, err := client.getJSON("/v2/foo/bar/tags/list",
+		"repository:foo/bar:pull", &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Tags) != 1 || result.Tags[0] != "latest" {
+		t.Fatalf("unexpected tags %v", result.Tags)
+	}
+}
+
+func TestRegistryClientBasicChallenge(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, _ := r.BasicAuth(); username != "user" ||
+			password != "pass" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	})
+	_, client := newTestRegistry(t, handler,
+		Credential{Username: "user", Password: "pass"})
+	var result struct{}
+	if _, err := client.getJSON("/v2/", "", &result); err != nil {
+		t.Fatal(err)
+	}
+
+	_, anonymous := newTestRegistry(t, handler, Credential{})
+	if _, err := anonymous.getJSON("/v2/", "", &result); err == nil {
+		t.Fatal("unauthorized request succeeded")
+	}
+}
+
+func TestRegistryClientInsecure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{}`))
+		}))
+	defer server.Close()
+	client, err := newRegistryClient(strings.TrimPrefix(
+		server.URL, "http://"), Credential{}, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct{}
+	if _, err := client.getJSON("/v2/", "", &result); err != nil {
+		t.Fatal(err)
+	}
+	if client.scheme != "http" {
+		t.Fatalf("unexpected scheme %q", client.scheme)
+	}
+}
+
+func TestRegistryClientPlatformManifests(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/foo/manifests/", func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/v2/foo/manifests/") {
+		case "index":
+			_, _ = w.Write([]byte(`{"schemaVersion":2,"manifests":[` +
+				`{"digest":"sha256:aa","platform":{"os":"linux","architecture":"amd64"}},` +
+				`{"digest":"sha256:bb","platform":{"os":"linux","architecture":"arm64","variant":"v8"}},` +
+				`{"digest":"sha256:cc","platform":{"os":"unknown","architecture":"unknown"}}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"schemaVersion":2,"config":{"digest":"sha256:config"}}`))
+		}
+	})
+	mux.HandleFunc("/v2/foo/blobs/sha256:config", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"os":"linux","architecture":"amd64"}`))
+	})
+	_, client := newTestRegistry(t, mux, Credential{})
+	arm64, _ := platform.Parse("linux/arm64")
+	amd64, _ := platform.Parse("linux/amd64")
+
+	digests, err := client.platformManifests("foo", "index", &arm64)
+	if err != nil || len(digests) != 1 || digests[0] != "sha256:bb" {
+		t.Fatalf("select arm64: %v %v", digests, err)
+	}
+	digests, err = client.platformManifests("foo", "index", nil)
+	if err != nil || len(digests) != 2 {
+		t.Fatalf("all platforms: %v %v", digests, err)
+	}
+	digests, err = client.platformManifests("foo", "image", &amd64)
+	if err != nil || digests != nil {
+		t.Fatalf("single image: %v %v", digests, err)
+	}
+	if _, err := client.platformManifests("foo", "image", &arm64); err == nil {
+		t.Fatal("image of another platform accepted")
+	}
+}
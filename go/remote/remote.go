@@ -2,11 +2,18 @@
 package remote
 
 import (
+	"io/ioutil"
+	"sync"
+
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 
 	api "github.com/chaitin/libveinmind/go"
+	"github.com/chaitin/libveinmind/go/ocilayout"
 	"github.com/chaitin/libveinmind/go/pkg/behaviour"
 	"github.com/chaitin/libveinmind/go/pkg/binding"
+	"github.com/chaitin/libveinmind/go/pkg/platform"
+	"github.com/chaitin/libveinmind/go/pkg/reference"
 )
 
 type Runtime struct {
@@ -16,6 +23,9 @@ type Runtime struct {
 	behaviour.Runtime
 	behaviour.FileSystem
 	runtime binding.Handle
+
+	mu     sync.Mutex
+	layout *ocilayout.Layout
 }
 
 func New(root string) (api.Runtime, error) {
@@ -33,15 +43,89 @@ func New(root string) (api.Runtime, error) {
 	return t, nil
 }
 
+// ListImageIDs returns the images in the native store along
+// with the ones pulled in Go.
+func (t *Runtime) ListImageIDs() ([]string, error) {
+	result, err := t.Runtime.ListImageIDs()
+	if err != nil {
+		return nil, err
+	}
+	layout, err := t.openLayout()
+	if err != nil || layout == nil {
+		return result, err
+	}
+	ids, err := layout.ListImageIDs()
+	if err != nil {
+		return nil, err
+	}
+	return appendIDs(result, ids...), nil
+}
+
+func (t *Runtime) FindImageIDs(pattern string) ([]string, error) {
+	result, err := t.Runtime.FindImageIDs(pattern)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := t.openLayout()
+	if err != nil || layout == nil {
+		return result, err
+	}
+	ids, err := layout.FindImageIDs(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return appendIDs(result, ids...), nil
+}
+
+func appendIDs(ids []string, added ...string) []string {
+	existing := make(map[string]struct{})
+	for _, id := range ids {
+		existing[id] = struct{}{}
+	}
+	for _, id := range added {
+		if _, ok := existing[id]; !ok {
+			existing[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// OpenImageByID opens the image pulled in Go from the layout
+// if found there, whose layer digests are known, otherwise
+// the image is opened from the native store.
 func (t *Runtime) OpenImageByID(id string) (api.Image, error) {
+	layout, err := t.openLayout()
+	if err != nil {
+		return nil, err
+	}
+	if layout != nil {
+		ids, err := layout.FindImageIDs(id)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 1 && ids[0] == id {
+			image, err := layout.OpenImageByID(id)
+			if err != nil {
+				return nil, err
+			}
+			return &Image{
+				LayeredImage: image.(api.LayeredImage),
+				runtime:      t,
+			}, nil
+		}
+	}
+
 	h, err := t.runtime.RuntimeOpenImageByID(id)
 	if err != nil {
 		return nil, err
 	}
-	result := &Image{runtime: t, image: h}
-	result.Closer = behaviour.NewCloser(&result.image)
-	result.Image = behaviour.NewImage(&result.image)
-	result.FileSystem = behaviour.NewFileSystem(&result.image)
+	result := &Image{runtime: t}
+	native := &nativeImage{image: h, owner: result}
+	native.Closer = behaviour.NewCloser(&native.image)
+	native.Image = behaviour.NewImage(&native.image)
+	native.FileSystem = behaviour.NewFileSystem(&native.image)
+	result.LayeredImage = native
 	return result, nil
 }
 
@@ -54,13 +138,226 @@ func (t *Runtime) Root() string {
 	return t.root
 }
 
-// Load image into remote manager system
+// credential returns the credential of the registry, where
+// the credential specified explicitly takes precedence.
+func (o *loadOptions) credential(registry string) (Credential, error) {
+	result := Credential{
+		Username:      o.username,
+		Password:      o.password,
+		IdentityToken: o.identityToken,
+		RegistryToken: o.registryToken,
+	}
+	if !result.Empty() {
+		return result, nil
+	}
+	return o.resolve(registry)
+}
+
+// resolve the credential of the host from the credential
+// helper and the docker config specified, which is also how
+// the credential of mirrors is resolved, since the credential
+// specified explicitly is of the registry.
+func (o *loadOptions) resolve(host string) (Credential, error) {
+	if o.credHelper != "" {
+		cred, err := HelperCredential(o.credHelper, serverAddress(host))
+		if err != nil || !cred.Empty() {
+			return cred, err
+		}
+	}
+	if o.dockerConfig != nil {
+		path := *o.dockerConfig
+		if path == "" {
+			path = DefaultDockerConfigPath()
+		}
+		config, err := LoadDockerConfig(path)
+		if err != nil {
+			return Credential{}, err
+		}
+		return config.Resolve(host)
+	}
+	return Credential{}, nil
+}
+
+// pullCredential exchanges the identity token in the credential
+// for the registry token pulling the repository from the host.
+func (o *loadOptions) pullCredential(
+	host, repository string, cred Credential, caBundle []byte,
+) (Credential, error) {
+	if cred.IdentityToken == "" || cred.RegistryToken != "" {
+		return cred, nil
+	}
+	client, err := newRegistryClient(host, cred, caBundle, o.insecure)
+	if err != nil {
+		return Credential{}, err
+	}
+	if cred.RegistryToken, err = client.pullToken(repository); err != nil {
+		return Credential{}, err
+	}
+	return cred, nil
+}
+
+// caBundle reads the CA bundle specified for the registry.
+func (o *loadOptions) caBundle(registry string) ([]byte, error) {
+	path := o.caBundles[registry]
+	if path == "" {
+		return nil, nil
+	}
+	return ioutil.ReadFile(path)
+}
+
+// client creates the client of the registry API, with the
+// credential and CA bundle of the registry.
+func (o *loadOptions) client(registry string) (*registryClient, error) {
+	cred, err := o.credential(registry)
+	if err != nil {
+		return nil, err
+	}
+	caBundle, err := o.caBundle(registry)
+	if err != nil {
+		return nil, err
+	}
+	return newRegistryClient(registry, cred, caBundle, o.insecure)
+}
+
+// Load image into remote manager system.
+//
+// The identity token is exchanged for the registry token
+// pulling the image before loading. The image is pulled in Go
+// into the OCI image layout under the root when there is a
+// registry token or CA bundle, which the native loader takes
+// no care of, otherwise it is loaded by the native loader.
+//
+// When WithPlatform or WithAllPlatforms is specified, the
+// manifests of platforms are resolved from the registry and
+// loaded by their digests, and the IDs of all images loaded
+// are returned.
 func (t *Runtime) Load(imageRef string, opts ...LoadOption) ([]string, error) {
 	options := &loadOptions{}
 	for _, o := range opts {
 		o(options)
 	}
-	return t.runtime.RemoteLoad(imageRef, options.username, options.password, options.insecure)
+	if options.platform != "" && options.allPlatforms {
+		return nil, errors.New(
+			"remote: WithPlatform conflicts with WithAllPlatforms")
+	}
+	var selected *imageV1.Platform
+	if options.platform != "" {
+		p, err := platform.Parse(options.platform)
+		if err != nil {
+			return nil, err
+		}
+		selected = &p
+	}
+	ref, err := reference.Parse(imageRef)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := options.credential(ref.Domain)
+	if err != nil {
+		return nil, err
+	}
+	caBundle, err := options.caBundle(ref.Domain)
+	if err != nil {
+		return nil, err
+	}
+	cred, err = options.pullCredential(ref.Domain, ref.Path, cred, caBundle)
+	if err != nil {
+		return nil, err
+	}
+	if selected == nil && !options.allPlatforms {
+		return t.load(imageRef, ref, cred, caBundle, options)
+	}
+
+	client, err := newRegistryClient(
+		ref.Domain, cred, caBundle, options.insecure)
+	if err != nil {
+		return nil, err
+	}
+	name := ref.Digest
+	if name == "" {
+		if name = ref.Tag; name == "" {
+			name = "latest"
+		}
+	}
+	digests, err := client.platformManifests(ref.Path, name, selected)
+	if err != nil {
+		return nil, err
+	}
+	if len(digests) == 0 {
+		return t.load(imageRef, ref, cred, caBundle, options)
+	}
+	var result []string
+	for _, digest := range digests {
+		platformRef := reference.Reference{
+			Domain: ref.Domain, Path: ref.Path, Digest: digest,
+		}
+		ids, err := t.load(platformRef.String(),
+			&platformRef, cred, caBundle, options)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ids...)
+	}
+	return result, nil
+}
+
+// load the image from the mirrors of the registry in order,
+// and from the registry itself when none of them succeeds. Each
+// mirror is pulled with the credential resolved for its own
+// host, so that the credential of registry is never sent to it.
+func (t *Runtime) load(
+	imageRef string, ref *reference.Reference,
+	cred Credential, caBundle []byte, options *loadOptions,
+) ([]string, error) {
+	for _, mirror := range options.mirrors[ref.Domain] {
+		mirrorRef := *ref
+		mirrorRef.Domain = registryHost(mirror)
+		if result, err := t.loadMirror(
+			&mirrorRef, caBundle, options); err == nil {
+			return result, nil
+		}
+	}
+	return t.loadWith(imageRef, ref, cred, caBundle, options.insecure)
+}
+
+// loadMirror loads the image from the mirror, trusting the CA
+// bundle of the mirror or the one of its registry.
+func (t *Runtime) loadMirror(
+	ref *reference.Reference, caBundle []byte, options *loadOptions,
+) ([]string, error) {
+	cred, err := options.resolve(ref.Domain)
+	if err != nil {
+		return nil, err
+	}
+	mirrorBundle, err := options.caBundle(ref.Domain)
+	if err != nil {
+		return nil, err
+	}
+	if len(mirrorBundle) > 0 {
+		caBundle = mirrorBundle
+	}
+	cred, err = options.pullCredential(ref.Domain, ref.Path, cred, caBundle)
+	if err != nil {
+		return nil, err
+	}
+	return t.loadWith(ref.String(), ref, cred, caBundle, options.insecure)
+}
+
+// loadWith loads the image with the credential and CA bundle,
+// which is pulled in Go if they cannot be passed natively.
+func (t *Runtime) loadWith(
+	imageRef string, ref *reference.Reference,
+	cred Credential, caBundle []byte, insecure bool,
+) ([]string, error) {
+	if cred.RegistryToken == "" && len(caBundle) == 0 {
+		return t.runtime.RemoteLoad(imageRef,
+			cred.Username, cred.Password, insecure)
+	}
+	client, err := newRegistryClient(ref.Domain, cred, caBundle, insecure)
+	if err != nil {
+		return nil, err
+	}
+	return t.pull(client, ref)
 }
 
 func (t *Runtime) Close() error {
@@ -0,0 +1,176 @@
+package rootfs
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/xerrors"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// maxSymlinks is the maximum number of symbolic links to
+// follow while resolving a path, in accordance with the
+// MAXSYMLINKS in linux.
+const maxSymlinks = 40
+
+// ErrSpecialFile is reported when opening a file which is
+// neither a regular file nor a directory, like a FIFO or a
+// device, which might block or access the host devices.
+var ErrSpecialFile = xerrors.New("rootfs: special file")
+
+// Image represents a root file system in a host directory.
+//
+// The image is read-only, and the files opened fail to be
+// written. The directory might be modified by others while
+// it is being visited, so the result is not guaranteed to be
+// consistent, but the files opened, stated, read as links or
+// directories are confined to the root directory on linux.
+type Image struct {
+	runtime *Rootfs
+	root    string
+}
+
+func (i *Image) Runtime() *Rootfs {
+	return i.runtime
+}
+
+func (i *Image) ID() string {
+	return i.root
+}
+
+// Root returns the host directory of the root file system.
+func (i *Image) Root() string {
+	return i.root
+}
+
+func (i *Image) Repos() ([]string, error) {
+	return nil, nil
+}
+
+func (i *Image) RepoRefs() ([]string, error) {
+	return nil, nil
+}
+
+// OCISpecV1 returns an empty image config, since there's no
+// config recorded with the root file system.
+func (i *Image) OCISpecV1() (*imageV1.Image, error) {
+	return &imageV1.Image{
+		RootFS: imageV1.RootFS{Type: "layers"},
+	}, nil
+}
+
+func (i *Image) Close() error {
+	return nil
+}
+
+// splitPath cleans the path and splits it into components.
+func splitPath(p string) []string {
+	p = path.Clean("/" + filepath.ToSlash(p))
+	if p == "/" {
+		return nil
+	}
+	return strings.Split(p[1:], "/")
+}
+
+// hostPath returns the host path of the resolved path.
+func (i *Image) hostPath(names []string) string {
+	return filepath.Join(i.root, filepath.FromSlash(path.Join(names...)))
+}
+
+// resolve the path inside the root file system, where the
+// symbolic links are confined to the root directory. The
+// last component is followed when it is a symbolic link and
+// follow is specified.
+//
+// Both the host path and the resolved path inside the root
+// file system are returned.
+func (i *Image) resolve(op, p string, follow bool) (string, string, error) {
+	var names []string
+	rest := splitPath(p)
+	links := 0
+	for len(rest) > 0 {
+		item := rest[0]
+		rest = rest[1:]
+		switch item {
+		case "", ".":
+			continue
+		case "..":
+			if len(names) > 0 {
+				names = names[:len(names)-1]
+			}
+			continue
+		}
+		host := filepath.Join(i.hostPath(names), item)
+		info, err := os.Lstat(host)
+		if err != nil {
+			if pathErr, ok := err.(*os.PathError); ok {
+				err = pathErr.Err
+			}
+			return "", "", &os.PathError{Op: op, Path: p, Err: err}
+		}
+		if info.Mode()&os.ModeSymlink != 0 && (len(rest) > 0 || follow) {
+			links++
+			if links > maxSymlinks {
+				return "", "", &os.PathError{
+					Op: op, Path: p, Err: syscall.ELOOP}
+			}
+			target, err := os.Readlink(host)
+			if err != nil {
+				return "", "", err
+			}
+			target = filepath.ToSlash(target)
+			if path.IsAbs(target) {
+				names = nil
+			}
+			rest = append(strings.Split(target, "/"), rest...)
+			continue
+		}
+		if len(rest) > 0 && !info.IsDir() {
+			return "", "", &os.PathError{
+				Op: op, Path: p, Err: syscall.ENOTDIR}
+		}
+		names = append(names, item)
+	}
+	return i.hostPath(names), "/" + strings.Join(names, "/"), nil
+}
+
+func (i *Image) Open(p string) (api.File, error) {
+	f, err := i.open(p)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (i *Image) EvalSymlink(p string) (string, error) {
+	_, resolved, err := i.resolve("evalsymlink", p, true)
+	return resolved, err
+}
+
+// Walk visits the root file system in the same way as the
+// filepath.Walk, with the paths inside the root file system
+// passed to walkFn.
+func (i *Image) Walk(root string, walkFn filepath.WalkFunc) error {
+	root = path.Clean("/" + filepath.ToSlash(root))
+	host, _, err := i.resolve("lstat", root, false)
+	if err != nil {
+		if err = walkFn(root, nil, err); err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	return filepath.Walk(host, func(
+		p string, info os.FileInfo, err error,
+	) error {
+		rel, relErr := filepath.Rel(host, p)
+		if relErr != nil {
+			return relErr
+		}
+		return walkFn(path.Join(root, filepath.ToSlash(rel)), info, err)
+	})
+}
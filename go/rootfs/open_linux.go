@@ -0,0 +1,261 @@
+//go:build linux
+// +build linux
+
+package rootfs
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// openFD opens the file inside the root directory with the
+// flags, where the path is resolved by the kernel through
+// openat2 so that the file opened never escapes the root, even
+// if the directory is modified concurrently. The file
+// descriptors are walked component by component on kernels
+// without openat2. The last component is followed when it is
+// a symbolic link and follow is specified.
+//
+// The host path of the file is returned for naming the file.
+func (i *Image) openFD(
+	op, p string, flags int, follow bool,
+) (int, string, error) {
+	clean := path.Clean("/" + filepath.ToSlash(p))
+	name := filepath.Join(i.root, filepath.FromSlash(clean))
+	root, err := unix.Open(i.root,
+		unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, "", &os.PathError{Op: op, Path: p, Err: err}
+	}
+	defer func() { _ = unix.Close(root) }()
+	how := &unix.OpenHow{
+		Flags:   uint64(flags | unix.O_CLOEXEC),
+		Resolve: unix.RESOLVE_IN_ROOT | unix.RESOLVE_NO_MAGICLINKS,
+	}
+	if !follow {
+		how.Flags |= unix.O_NOFOLLOW
+	}
+	fd, err := unix.Openat2(root, clean, how)
+	if err == unix.ENOSYS || err == unix.EAGAIN {
+		fd, err = openInRoot(root, clean, flags, follow)
+	}
+	if err != nil {
+		return -1, "", &os.PathError{Op: op, Path: p, Err: err}
+	}
+	return fd, name, nil
+}
+
+// open opens the file for reading, which is opened without
+// blocking first so that a FIFO never blocks, and only the
+// regular files and directories could be opened.
+func (i *Image) open(p string) (*os.File, error) {
+	fd, name, err := i.openFD("open", p,
+		unix.O_RDONLY|unix.O_NONBLOCK, true)
+	if err != nil {
+		return nil, err
+	}
+	var stat unix.Stat_t
+	if err = unix.Fstat(fd, &stat); err == nil {
+		switch stat.Mode & unix.S_IFMT {
+		case unix.S_IFREG, unix.S_IFDIR:
+			err = unix.SetNonblock(fd, false)
+		default:
+			err = ErrSpecialFile
+		}
+	}
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, &os.PathError{Op: "open", Path: p, Err: err}
+	}
+	return os.NewFile(uintptr(fd), name), nil
+}
+
+// stat the file resolved inside the root directory, which is
+// opened with O_PATH and stated through the descriptor.
+func (i *Image) stat(op, p string, follow bool) (os.FileInfo, error) {
+	fd, name, err := i.openFD(op, p, unix.O_PATH, follow)
+	if err != nil {
+		return nil, err
+	}
+	return statFD(fd, name)
+}
+
+// statFD stats the file descriptor and closes it, the name of
+// the file information is the base of the host path.
+func statFD(fd int, name string) (os.FileInfo, error) {
+	f := os.NewFile(uintptr(fd), name)
+	defer func() { _ = f.Close() }()
+	return f.Stat()
+}
+
+func (i *Image) Stat(p string) (os.FileInfo, error) {
+	return i.stat("stat", p, true)
+}
+
+func (i *Image) Lstat(p string) (os.FileInfo, error) {
+	return i.stat("lstat", p, false)
+}
+
+func (i *Image) Readlink(p string) (string, error) {
+	fd, _, err := i.openFD("readlink", p,
+		unix.O_PATH|unix.O_NOFOLLOW, false)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = unix.Close(fd) }()
+	var stat unix.Stat_t
+	if err := unix.Fstat(fd, &stat); err != nil {
+		return "", &os.PathError{Op: "readlink", Path: p, Err: err}
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFLNK {
+		return "", &os.PathError{
+			Op: "readlink", Path: p, Err: unix.EINVAL}
+	}
+	target, err := readlinkFD(fd)
+	if err != nil {
+		return "", &os.PathError{Op: "readlink", Path: p, Err: err}
+	}
+	return target, nil
+}
+
+// Readdir reads the entries of the directory through its
+// descriptor, and each entry is stated relative to it, so the
+// entries are never looked up outside the root directory. The
+// entries removed while reading are skipped.
+func (i *Image) Readdir(p string) ([]os.FileInfo, error) {
+	fd, name, err := i.openFD("readdir", p,
+		unix.O_RDONLY|unix.O_DIRECTORY, true)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = unix.Close(fd) }()
+	var names []string
+	buf := make([]byte, 8192)
+	for {
+		n, err := unix.ReadDirent(fd, buf)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "readdir", Path: p, Err: err}
+		}
+		if n <= 0 {
+			break
+		}
+		_, _, names = unix.ParseDirent(buf[:n], -1, names)
+	}
+	var result []os.FileInfo
+	for _, item := range names {
+		entry, err := unix.Openat(fd, item,
+			unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+		if err == unix.ENOENT {
+			continue
+		}
+		if err != nil {
+			return nil, &os.PathError{
+				Op: "readdir", Path: path.Join(p, item), Err: err}
+		}
+		info, err := statFD(entry, filepath.Join(name, item))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name() < result[j].Name()
+	})
+	return result, nil
+}
+
+// openInRoot resolves the path by opening each component
+// without following, and the symbolic links are interpreted
+// relative to the root in the same way as resolve. The last
+// component is opened with the flags, and it is followed only
+// if follow is specified.
+func openInRoot(root int, p string, flags int, follow bool) (int, error) {
+	fds := []int{root}
+	var names []string
+	defer func() {
+		for _, fd := range fds[1:] {
+			_ = unix.Close(fd)
+		}
+	}()
+	rest := splitPath(p)
+	links := 0
+	for len(rest) > 0 {
+		item := rest[0]
+		rest = rest[1:]
+		switch item {
+		case "", ".":
+			continue
+		case "..":
+			if len(names) > 0 {
+				_ = unix.Close(fds[len(fds)-1])
+				fds = fds[:len(fds)-1]
+				names = names[:len(names)-1]
+			}
+			continue
+		}
+		fd, err := unix.Openat(fds[len(fds)-1], item,
+			unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return -1, err
+		}
+		var stat unix.Stat_t
+		if err := unix.Fstat(fd, &stat); err != nil {
+			_ = unix.Close(fd)
+			return -1, err
+		}
+		if stat.Mode&unix.S_IFMT != unix.S_IFLNK ||
+			(len(rest) == 0 && !follow) {
+			fds = append(fds, fd)
+			names = append(names, item)
+			continue
+		}
+		links++
+		if links > maxSymlinks {
+			_ = unix.Close(fd)
+			return -1, unix.ELOOP
+		}
+		target, err := readlinkFD(fd)
+		_ = unix.Close(fd)
+		if err != nil {
+			return -1, err
+		}
+		if path.IsAbs(target) {
+			for _, fd := range fds[1:] {
+				_ = unix.Close(fd)
+			}
+			fds, names = fds[:1], nil
+		}
+		rest = append(strings.Split(target, "/"), rest...)
+	}
+	if len(names) == 0 {
+		return unix.Openat(root, ".", flags|unix.O_CLOEXEC, 0)
+	}
+
+	// The file might have been replaced after it is resolved,
+	// which is rejected if it becomes a symbolic link.
+	return unix.Openat(fds[len(fds)-2], names[len(names)-1],
+		flags|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+}
+
+// readlinkFD reads the target of the symbolic link opened
+// with O_PATH.
+func readlinkFD(fd int) (string, error) {
+	for size := 128; ; size *= 2 {
+		buf := make([]byte, size)
+		n, err := unix.Readlinkat(fd, "", buf)
+		if err != nil {
+			return "", err
+		}
+		if n < size {
+			return string(buf[:n]), nil
+		}
+	}
+}
@@ -0,0 +1,60 @@
+//go:build !linux
+// +build !linux
+
+package rootfs
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// open opens the file inside the root directory, which might
+// escape the root if the directory is modified concurrently
+// on the platforms other than linux. Only the regular files
+// and directories could be opened.
+func (i *Image) open(p string) (*os.File, error) {
+	host, _, err := i.resolve("open", p, true)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Lstat(host)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() && !info.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: p, Err: ErrSpecialFile}
+	}
+	return os.Open(host)
+}
+
+func (i *Image) Stat(p string) (os.FileInfo, error) {
+	host, _, err := i.resolve("stat", p, true)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(host)
+}
+
+func (i *Image) Lstat(p string) (os.FileInfo, error) {
+	host, _, err := i.resolve("lstat", p, false)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(host)
+}
+
+func (i *Image) Readlink(p string) (string, error) {
+	host, _, err := i.resolve("readlink", p, false)
+	if err != nil {
+		return "", err
+	}
+	return os.Readlink(host)
+}
+
+func (i *Image) Readdir(p string) ([]os.FileInfo, error) {
+	host, _, err := i.resolve("readdir", p, true)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadDir(host)
+}
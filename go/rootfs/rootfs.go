@@ -0,0 +1,111 @@
+// Package rootfs is the API implementation on the unpacked root
+// file systems in the host directories, like a chroot, the
+// mount point of a virtual machine disk, or the output of
+// "buildah mount".
+//
+// Each directory is presented as an image identified by its
+// absolute path, whose symbolic links are resolved inside the
+// directory as if it has been chroot-ed into.
+package rootfs
+
+import (
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"golang.org/x/xerrors"
+
+	api "github.com/chaitin/libveinmind/go"
+)
+
+// NewOption is the option that can be used for initializing an
+// rootfs.Rootfs object.
+type NewOption func(*Rootfs)
+
+// WithPath adds the directory of a root file system, it can
+// be specified multiple times.
+func WithPath(path string) NewOption {
+	return func(r *Rootfs) {
+		r.paths = append(r.paths, path)
+	}
+}
+
+// Rootfs is the runtime over the directories of root file
+// systems, which has no containers.
+type Rootfs struct {
+	paths []string
+}
+
+func New(options ...NewOption) (api.Runtime, error) {
+	r := &Rootfs{}
+	for _, opt := range options {
+		opt(r)
+	}
+	if len(r.paths) == 0 {
+		return nil, errors.New("rootfs: path unspecified")
+	}
+	var paths []string
+	seen := make(map[string]struct{})
+	for _, path := range r.paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[abs]; ok {
+			continue
+		}
+		seen[abs] = struct{}{}
+		paths = append(paths, abs)
+	}
+	r.paths = paths
+	return r, nil
+}
+
+// Paths returns the absolute paths of the root file systems.
+func (r *Rootfs) Paths() []string {
+	return append([]string(nil), r.paths...)
+}
+
+func (r *Rootfs) ListImageIDs() ([]string, error) {
+	return r.Paths(), nil
+}
+
+// FindImageIDs matches the root file systems by their paths,
+// the relative paths are resolved against the working
+// directory.
+func (r *Rootfs) FindImageIDs(pattern string) ([]string, error) {
+	abs, err := filepath.Abs(pattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range r.paths {
+		if path == abs {
+			return []string{path}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *Rootfs) OpenImageByID(id string) (api.Image, error) {
+	for _, path := range r.paths {
+		if path == id {
+			return &Image{runtime: r, root: path}, nil
+		}
+	}
+	return nil, xerrors.Errorf("rootfs: image %q not found", id)
+}
+
+func (r *Rootfs) ListContainerIDs() ([]string, error) {
+	return nil, nil
+}
+
+func (r *Rootfs) FindContainerIDs(pattern string) ([]string, error) {
+	return nil, nil
+}
+
+func (r *Rootfs) OpenContainerByID(id string) (api.Container, error) {
+	return nil, errors.New("rootfs: unsupported")
+}
+
+func (r *Rootfs) Close() error {
+	return nil
+}
@@ -0,0 +1,73 @@
+package rootfs
+
+import (
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Xattrs returns the extended attributes of the file, so
+// that they are preserved when the image is exported by the
+// archive package. Symbolic links have no attributes.
+func (i *Image) Xattrs(p string) (map[string]string, error) {
+	host, _, err := i.resolve("xattrs", p, false)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Lstat(host)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil, nil
+	}
+	names, err := readXattr(func(dest []byte) (int, error) {
+		return syscall.Listxattr(host, dest)
+	})
+	if err == syscall.ENOTSUP {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: "listxattr", Path: p, Err: err}
+	}
+	result := make(map[string]string)
+	for _, name := range strings.Split(string(names), "\x00") {
+		if name == "" {
+			continue
+		}
+		value, err := readXattr(func(dest []byte) (int, error) {
+			return syscall.Getxattr(host, name, dest)
+		})
+		if err == syscall.ENODATA {
+			continue
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "getxattr", Path: p, Err: err}
+		}
+		result[name] = string(value)
+	}
+	return result, nil
+}
+
+// readXattr calls the function with a buffer large enough to
+// hold the result, whose size is queried first.
+func readXattr(f func([]byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := f(nil)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size)
+		n, err := f(buf)
+		if err == syscall.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package rootfs
+
+// Xattrs returns no extended attributes on the platforms
+// other than linux.
+func (i *Image) Xattrs(p string) (map[string]string, error) {
+	if _, _, err := i.resolve("xattrs", p, false); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
@@ -0,0 +1,126 @@
+package tarball
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	api "github.com/chaitin/libveinmind/go"
+	"github.com/chaitin/libveinmind/go/imagearchive"
+)
+
+// layerDigestsFile is the file in the root recording the
+// digests of the layers of the images loaded, since they are
+// lost once the archive is loaded natively.
+const layerDigestsFile = "layerdigests.json"
+
+// recordLayerDigests reads the layer digests of the images in
+// the archive, and records them in the root. The archive has
+// been loaded successfully, so failing to read it only leaves
+// the digests unrecorded.
+func (t *Tarball) recordLayerDigests(path string) error {
+	runtime, err := imagearchive.New(imagearchive.WithPath(path))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = runtime.Close() }()
+	ids, err := runtime.ListImageIDs()
+	if err != nil {
+		return err
+	}
+	digests := make(map[string][]string)
+	for _, id := range ids {
+		image, err := runtime.OpenImageByID(id)
+		if err != nil {
+			return err
+		}
+		layered := image.(api.LayeredImage)
+		var layers []string
+		for i := 0; i < layered.NumLayers(); i++ {
+			digest, err := layered.LayerDigest(i)
+			if err != nil {
+				layers = nil
+				break
+			}
+			layers = append(layers, digest)
+		}
+		_ = image.Close()
+		if layers != nil {
+			digests[id] = layers
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.loadLayerDigests(); err != nil {
+		return err
+	}
+	for id, layers := range digests {
+		t.digests[id] = layers
+	}
+	if t.root == "" {
+		return nil
+	}
+	data, err := json.Marshal(t.digests)
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(t.root, layerDigestsFile)
+	f, err := ioutil.TempFile(t.root, layerDigestsFile+".")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), file)
+}
+
+// loadLayerDigests loads the digests recorded in the root
+// for the first time, the caller must hold the lock.
+func (t *Tarball) loadLayerDigests() error {
+	if t.digests != nil {
+		return nil
+	}
+	t.digests = make(map[string][]string)
+	if t.root == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(t.root, layerDigestsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &t.digests)
+}
+
+// layerDigests returns the layer digests recorded for the
+// image of the ID.
+func (t *Tarball) layerDigests(id string) ([]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.loadLayerDigests(); err != nil {
+		return nil, err
+	}
+	if layers, ok := t.digests[id]; ok {
+		return layers, nil
+	}
+	if !strings.Contains(id, ":") {
+		if layers, ok := t.digests["sha256:"+id]; ok {
+			return layers, nil
+		}
+	}
+	return nil, xerrors.Errorf(
+		"tarball: layer digests of image %q unrecorded", id)
+}
@@ -1,9 +1,13 @@
 package tarball
 
 import (
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/xerrors"
+
 	api "github.com/chaitin/libveinmind/go"
 	"github.com/chaitin/libveinmind/go/pkg/behaviour"
 	"github.com/chaitin/libveinmind/go/pkg/binding"
+	"github.com/chaitin/libveinmind/go/pkg/platform"
 )
 
 // Image represents a tarball image.
@@ -22,14 +26,58 @@ type Layer struct {
 	image *Image
 }
 
+var _ api.LayeredImage = (*Image)(nil)
+
 func (i *Image) Runtime() *Tarball {
 	return i.runtime
 }
 
+// Platform returns the platform of the image recorded in its
+// config.
+func (i *Image) Platform() (imageV1.Platform, error) {
+	config, err := i.OCISpecV1()
+	if err != nil {
+		return imageV1.Platform{}, err
+	}
+	return platform.FromConfig(config), nil
+}
+
 func (i *Image) NumLayers() int {
 	return i.image.TarballImageNumLayers()
 }
 
+func (im *Image) GetLayerDiffID(i int) (string, error) {
+	return im.LayerDiffID(i)
+}
+
+// LayerDiffID returns the diff ID of the layer in the config
+// of the image.
+func (im *Image) LayerDiffID(i int) (string, error) {
+	config, err := im.OCISpecV1()
+	if err != nil {
+		return "", err
+	}
+	diffIDs := config.RootFS.DiffIDs
+	if i < 0 || i >= len(diffIDs) {
+		return "", xerrors.Errorf("tarball: layer %d out of range", i)
+	}
+	return string(diffIDs[i]), nil
+}
+
+// LayerDigest returns the digest of the layer blob recorded
+// when the archive is loaded, where the uncompressed layers
+// saved by legacy docker have their diff IDs as digests.
+func (im *Image) LayerDigest(i int) (string, error) {
+	digests, err := im.runtime.layerDigests(im.ID())
+	if err != nil {
+		return "", err
+	}
+	if i < 0 || i >= len(digests) {
+		return "", xerrors.Errorf("tarball: layer %d out of range", i)
+	}
+	return digests[i], nil
+}
+
 func (i *Image) OpenLayer(index int) (api.Layer, error) {
 	h, err := i.image.TarballImageOpenLayer(index)
 	if err != nil {
@@ -2,11 +2,20 @@
 package tarball
 
 import (
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	imageV1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
+	"golang.org/x/xerrors"
 
 	api "github.com/chaitin/libveinmind/go"
 	"github.com/chaitin/libveinmind/go/pkg/behaviour"
 	"github.com/chaitin/libveinmind/go/pkg/binding"
+	"github.com/chaitin/libveinmind/go/pkg/platform"
+	"github.com/chaitin/libveinmind/go/pkg/tarfs"
 )
 
 // NewOption is the option that can be used for initializing an
@@ -19,8 +28,21 @@ func WithRoot(root string) NewOption {
 	}
 }
 
+// WithTempDir specifies the directory of the temporary files
+// the archives are decompressed or copied into before loading,
+// which defaults to the directory returned by os.TempDir.
+func WithTempDir(dir string) NewOption {
+	return func(tarball *Tarball) {
+		tarball.tempDir = dir
+	}
+}
+
 type Tarball struct {
-	root string
+	root    string
+	tempDir string
+
+	mu      sync.Mutex
+	digests map[string][]string
 
 	behaviour.Closer
 	behaviour.Runtime
@@ -67,9 +89,227 @@ func (t *Tarball) Root() string {
 	return t.root
 }
 
-// Load image into tarball manager system
-func (t *Tarball) Load(tarPath string) ([]string, error) {
-	return t.runtime.TarballLoad(tarPath)
+// ProgressFunc is the callback reporting the number of bytes
+// of the archive consumed while loading, the total is negative
+// when the size of the archive is unknown.
+type ProgressFunc func(current, total int64)
+
+// loadArgs is the internal state that a tarball.LoadOption can
+// manipulate for loading an archive.
+type loadArgs struct {
+	progress     ProgressFunc
+	platform     string
+	allPlatforms bool
+
+	selected *imageV1.Platform
+}
+
+// LoadOption is the option that can be used for loading an
+// archive into the tarball.Tarball object.
+type LoadOption func(*loadArgs)
+
+// WithProgress specifies the callback reporting the progress
+// of loading.
+//
+// The progress covers copying the archive into a temporary
+// file, decompressed if compressed, since loading it natively
+// reports nothing. So an uncompressed archive is also copied
+// when the progress is specified, and the native load happens
+// after the progress reaches total.
+func WithProgress(progress ProgressFunc) LoadOption {
+	return func(args *loadArgs) {
+		args.progress = progress
+	}
+}
+
+// WithPlatform specifies the platform to load when the archive
+// contains images of multiple platforms, in the form of
+// "os/arch[/variant]" like "linux/arm64".
+//
+// The archive is loaded natively as a whole, and the images
+// loaded of other platforms are removed afterwards, unless
+// they have been loaded before.
+func WithPlatform(platform string) LoadOption {
+	return func(args *loadArgs) {
+		args.platform = platform
+	}
+}
+
+// WithAllPlatforms keeps the images of all platforms in the
+// archive, which is what the native loader does by default,
+// and it conflicts with WithPlatform.
+func WithAllPlatforms() LoadOption {
+	return func(args *loadArgs) {
+		args.allPlatforms = true
+	}
+}
+
+// newLoadArgs applies the options and parses the platform.
+func newLoadArgs(opts []LoadOption) (loadArgs, error) {
+	var args loadArgs
+	for _, opt := range opts {
+		opt(&args)
+	}
+	if args.platform == "" {
+		return args, nil
+	}
+	if args.allPlatforms {
+		return args, errors.New(
+			"tarball: WithPlatform conflicts with WithAllPlatforms")
+	}
+	p, err := platform.Parse(args.platform)
+	if err != nil {
+		return args, err
+	}
+	args.selected = &p
+	return args, nil
+}
+
+// progressReader reports the bytes read through it.
+type progressReader struct {
+	r        io.Reader
+	current  int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if n > 0 {
+		r.current += int64(n)
+		r.progress(r.current, r.total)
+	}
+	return n, err
+}
+
+// Load image into tarball manager system, the archive is
+// decompressed first when it is compressed by gzip, zstd or xz.
+func (t *Tarball) Load(tarPath string, opts ...LoadOption) ([]string, error) {
+	args, err := newLoadArgs(opts)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(tarPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := tarfs.Compressed(f)
+	if err != nil {
+		return nil, err
+	}
+	if !compressed && args.progress == nil {
+		return t.load(tarPath, args)
+	}
+	return t.loadReader(f, info.Size(), args)
+}
+
+// LoadReader loads the image archive from the stream, like
+// the output of "docker save". The archive is decompressed
+// when it is compressed by gzip, zstd or xz.
+//
+// The stream is written into a temporary file under the temp
+// directory before loading, which is removed after the archive
+// is loaded.
+func (t *Tarball) LoadReader(r io.Reader, opts ...LoadOption) ([]string, error) {
+	args, err := newLoadArgs(opts)
+	if err != nil {
+		return nil, err
+	}
+	return t.loadReader(r, -1, args)
+}
+
+func (t *Tarball) loadReader(
+	r io.Reader, total int64, args loadArgs,
+) ([]string, error) {
+	if args.progress != nil {
+		args.progress(0, total)
+		r = &progressReader{r: r, total: total, progress: args.progress}
+	}
+	decompressor, err := tarfs.Decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = decompressor.Close() }()
+	f, err := ioutil.TempFile(t.tempDir, "veinmind-tarball-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+	if _, err := io.Copy(f, decompressor); err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return t.load(f.Name(), args)
+}
+
+// load the uncompressed archive natively, record the layer
+// digests of the images loaded, and select the platform.
+func (t *Tarball) load(path string, args loadArgs) ([]string, error) {
+	var existing []string
+	if args.selected != nil {
+		var err error
+		if existing, err = t.ListImageIDs(); err != nil {
+			return nil, err
+		}
+	}
+	result, err := t.runtime.TarballLoad(path)
+	if err != nil {
+		return nil, err
+	}
+	_ = t.recordLayerDigests(path)
+	if args.selected == nil {
+		return result, nil
+	}
+	return t.selectPlatform(result, existing, *args.selected)
+}
+
+// selectPlatform filters the images loaded by the platform in
+// their configs, and removes the images of other platforms
+// unless they are among the existing ones.
+func (t *Tarball) selectPlatform(
+	ids, existing []string, selected imageV1.Platform,
+) ([]string, error) {
+	kept := make(map[string]struct{})
+	for _, id := range existing {
+		kept[id] = struct{}{}
+	}
+	var result, removed []string
+	for _, id := range ids {
+		image, err := t.OpenImageByID(id)
+		if err != nil {
+			return nil, err
+		}
+		p, err := image.(*Image).Platform()
+		_ = image.Close()
+		if err != nil {
+			return nil, err
+		}
+		if platform.Match(p, selected) {
+			result = append(result, id)
+		} else if _, ok := kept[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	for _, id := range removed {
+		if err := t.RemoveImageByID(id); err != nil {
+			return nil, err
+		}
+	}
+	if len(result) == 0 {
+		return nil, xerrors.Errorf("tarball: no %s image in archive",
+			platform.Format(selected))
+	}
+	return result, nil
 }
 
 func (t *Tarball) RemoveImageByID(id string) error {